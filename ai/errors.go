@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Kinds of provider failures. Errors returned by providers wrap one of them.
+var (
+	ErrNotConfigured = errors.New("AI provider is not configured")
+	ErrTimeout       = errors.New("AI provider timed out")
+	ErrRateLimited   = errors.New("AI provider rate limit exceeded")
+	ErrUnavailable   = errors.New("AI provider is unavailable")
+	ErrUnauthorized  = errors.New("AI provider rejected the credentials")
+	ErrRejected      = errors.New("AI provider rejected the request")
+	ErrBadResponse   = errors.New("AI provider returned an invalid response")
+)
+
+// Error is a failed call to a provider
+type Error struct {
+	Provider string
+	// Kind is one of the Err values of the package
+	Kind error
+	// StatusCode is the HTTP status returned by the provider, if any
+	StatusCode int
+	// RetryAfter is how long the provider asked to wait before retrying
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s: %v", e.Provider, e.Kind)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// Temporary reports whether the call may succeed if retried
+func (e *Error) Temporary() bool {
+	return e.Kind == ErrTimeout || e.Kind == ErrRateLimited || e.Kind == ErrUnavailable
+}
+
+// transportError maps a failure to reach a provider. Cancellations by the
+// caller are returned as is.
+func transportError(provider string, err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &Error{Provider: provider, Kind: ErrTimeout, Message: err.Error()}
+	}
+	return &Error{Provider: provider, Kind: ErrUnavailable, Message: err.Error()}
+}
+
+// statusError maps an unsuccessful response from a provider
+func statusError(provider string, resp *http.Response) error {
+	e := &Error{Provider: provider, StatusCode: resp.StatusCode, Message: errorMessage(resp.Body)}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		e.Kind = ErrUnauthorized
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e.Kind = ErrRateLimited
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			e.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusGatewayTimeout:
+		e.Kind = ErrTimeout
+	case resp.StatusCode >= 500:
+		e.Kind = ErrUnavailable
+	default:
+		e.Kind = ErrRejected
+	}
+	return e
+}
+
+// errorMessage extracts the message of an error response, which providers
+// send either as {"error": "..."} or {"error": {"message": "..."}}
+func errorMessage(body io.Reader) string {
+	var apiError struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(body, 64<<10)).Decode(&apiError); err != nil {
+		return ""
+	}
+
+	var message string
+	if err := json.Unmarshal(apiError.Error, &message); err == nil {
+		return message
+	}
+	var detail struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(apiError.Error, &detail)
+	return detail.Message
+}
+
+// badResponse reports a response that couldn't be understood
+func badResponse(provider string, err error) error {
+	return &Error{Provider: provider, Kind: ErrBadResponse, Message: err.Error()}
+}
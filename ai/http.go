@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// newHTTPClient returns the client shared by the HTTP based providers
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout}
+}
+
+// HTTPProvider calls a generic AI service that takes the draft as JSON and
+// answers with the suggestions
+type HTTPProvider struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPProvider returns a provider for the AI service at url
+func NewHTTPProvider(url string, client *http.Client) *HTTPProvider {
+	return &HTTPProvider{url: url, client: client}
+}
+
+func (p *HTTPProvider) Name() string {
+	return models.AIProviderHTTP
+}
+
+func (p *HTTPProvider) Suggest(ctx context.Context, req *SuggestionRequest) (*models.AISuggestions, error) {
+	var suggestions models.AISuggestions
+	if err := postJSON(ctx, p.client, p.Name(), p.url, nil, req, &suggestions); err != nil {
+		return nil, err
+	}
+	return &suggestions, nil
+}
+
+// Localize sends the request with "task": "localize" so the service can
+// tell it apart from suggestion requests
+func (p *HTTPProvider) Localize(ctx context.Context, req *LocalizationRequest) ([]models.Localization, error) {
+	body := struct {
+		Task string `json:"task"`
+		*LocalizationRequest
+	}{Task: "localize", LocalizationRequest: req}
+
+	var response struct {
+		Localizations []models.Localization `json:"localizations"`
+	}
+	if err := postJSON(ctx, p.client, p.Name(), p.url, nil, body, &response); err != nil {
+		return nil, err
+	}
+	return response.Localizations, nil
+}
+
+// postJSON posts a JSON request to a provider and decodes its JSON response
+func postJSON(ctx context.Context, client *http.Client, provider, url string, header http.Header, in, out interface{}) error {
+	resp, err := post(ctx, client, provider, url, header, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return readError(provider, err)
+	}
+	return nil
+}
+
+// post posts a JSON request to a provider and returns its response if it
+// succeeded. The caller closes the body.
+func post(ctx context.Context, client *http.Client, provider, url string, header http.Header, in interface{}) (*http.Response, error) {
+	requestBody, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding AI request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("error creating AI request: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, transportError(provider, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(provider, resp)
+	}
+	return resp, nil
+}
+
+// readError maps a failure to read a response, which is either cut short by
+// the connection or malformed
+func readError(provider string, err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+		return transportError(provider, err)
+	}
+	return badResponse(provider, err)
+}
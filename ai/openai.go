@@ -0,0 +1,205 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+const suggestionInstructions = `You write YouTube metadata. Given a video draft, answer with a JSON object with the fields "title" (at most 100 characters), "description", "keywords" (an array of tags), "chapters" (an array of "MM:SS Title" lines) and "thumbnail" (a short idea for the thumbnail). When a transcript is given, ground the description, keywords and chapters in it and take the chapter times from its [HH:MM:SS] timecodes. Never use the characters < or >.`
+
+const localizationInstructions = `You translate YouTube metadata. Given a title, a description, their source language and target languages as JSON, answer with a JSON object {"localizations": [{"language": "<target language tag>", "title": "...", "description": "..."}]} with one entry per target language. Titles must stay under 100 characters. Never use the characters < or >.`
+
+// OpenAIProvider generates metadata through an OpenAI-compatible chat
+// completions API
+type OpenAIProvider struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+// NewOpenAIProvider returns a provider for the chat completions API at
+// baseURL, e.g. https://api.openai.com/v1
+func NewOpenAIProvider(baseURL, apiKey, model string, client *http.Client) *OpenAIProvider {
+	return &OpenAIProvider{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, model: model, client: client}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return models.AIProviderOpenAI
+}
+
+// Suggest sends the rendered prompt if there is one and the draft as JSON
+// otherwise. Tone, length and fields are added to the instructions.
+func (p *OpenAIProvider) Suggest(ctx context.Context, req *SuggestionRequest) (*models.AISuggestions, error) {
+	content, err := suggestionContent(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestions models.AISuggestions
+	if err := p.complete(ctx, suggestionPrompt(req), content, req.Seed, &suggestions); err != nil {
+		return nil, err
+	}
+	return &suggestions, nil
+}
+
+// SuggestStream asks for a streamed completion and relays its tokens
+func (p *OpenAIProvider) SuggestStream(ctx context.Context, req *SuggestionRequest, emit func(Event) error) (*models.AISuggestions, error) {
+	content, err := suggestionContent(req)
+	if err != nil {
+		return nil, err
+	}
+
+	request := p.chatRequest(suggestionPrompt(req), content, req.Seed)
+	request.Stream = true
+	resp, err := post(ctx, p.client, p.Name(), p.baseURL+"/chat/completions", p.header(), request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// The completion comes as server-sent events ending with "data: [DONE]"
+	var completion strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk chatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, badResponse(p.Name(), err)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		completion.WriteString(chunk.Choices[0].Delta.Content)
+		if err := emit(Event{Type: EventToken, Text: chunk.Choices[0].Delta.Content}); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, readError(p.Name(), err)
+	}
+
+	var suggestions models.AISuggestions
+	if err := json.Unmarshal([]byte(completion.String()), &suggestions); err != nil {
+		return nil, badResponse(p.Name(), err)
+	}
+	return &suggestions, nil
+}
+
+// suggestionContent returns the rendered prompt of a request, or its draft as
+// JSON if it has none
+func suggestionContent(req *SuggestionRequest) (string, error) {
+	if req.Prompt != "" {
+		return req.Prompt, nil
+	}
+	draft, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	return string(draft), nil
+}
+
+// suggestionPrompt returns the instructions for a suggestion request
+func suggestionPrompt(req *SuggestionRequest) string {
+	instructions := suggestionInstructions
+	if req.Tone != "" {
+		instructions += fmt.Sprintf(" Use a %s tone.", req.Tone)
+	}
+	if req.Length != "" {
+		instructions += fmt.Sprintf(" Keep the description %s.", req.Length)
+	}
+	if len(req.Fields) > 0 {
+		instructions += fmt.Sprintf(" Only the fields %s will be used, focus on them.", strings.Join(req.Fields, ", "))
+	}
+	return instructions
+}
+
+func (p *OpenAIProvider) Localize(ctx context.Context, req *LocalizationRequest) ([]models.Localization, error) {
+	var response struct {
+		Localizations []models.Localization `json:"localizations"`
+	}
+	content, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.complete(ctx, localizationInstructions, string(content), nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Localizations, nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model          string            `json:"model"`
+	Messages       []chatMessage     `json:"messages"`
+	ResponseFormat map[string]string `json:"response_format"`
+	Seed           *int64            `json:"seed,omitempty"`
+	Stream         bool              `json:"stream,omitempty"`
+}
+
+type chatChunk struct {
+	Choices []struct {
+		Delta chatMessage `json:"delta"`
+	} `json:"choices"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// chatRequest asks for a JSON object answering content under instructions
+func (p *OpenAIProvider) chatRequest(instructions, content string, seed *int64) *chatRequest {
+	return &chatRequest{
+		Model: p.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: instructions},
+			{Role: "user", Content: content},
+		},
+		ResponseFormat: map[string]string{"type": "json_object"},
+		Seed:           seed,
+	}
+}
+
+func (p *OpenAIProvider) header() http.Header {
+	return http.Header{"Authorization": {"Bearer " + p.apiKey}}
+}
+
+// complete sends the instructions and the content, asks for a JSON object in
+// return and decodes it into out
+func (p *OpenAIProvider) complete(ctx context.Context, instructions, content string, seed *int64, out interface{}) error {
+	var response chatResponse
+	if err := postJSON(ctx, p.client, p.Name(), p.baseURL+"/chat/completions", p.header(), p.chatRequest(instructions, content, seed), &response); err != nil {
+		return err
+	}
+
+	if len(response.Choices) == 0 {
+		return badResponse(p.Name(), errors.New("no choices in the completion"))
+	}
+	if err := json.Unmarshal([]byte(response.Choices[0].Message.Content), out); err != nil {
+		return badResponse(p.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Package ai generates video metadata through language model services. Each
+// backend implements Provider, and a Registry holds the providers configured
+// for the server and resolves the one a channel or user has chosen.
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// Provider generates metadata for videos
+type Provider interface {
+	// Name identifies the provider, e.g. "openai"
+	Name() string
+	// Suggest generates metadata suggestions for a video draft
+	Suggest(ctx context.Context, req *SuggestionRequest) (*models.AISuggestions, error)
+	// Localize translates the title and description of a video
+	Localize(ctx context.Context, req *LocalizationRequest) ([]models.Localization, error)
+}
+
+// SuggestionRequest is the draft metadata suggestions are based on
+type SuggestionRequest struct {
+	Title       string   `json:"videoTitle"`
+	Description string   `json:"videoDescription"`
+	Keywords    []string `json:"videoKeywords"`
+	Category    string   `json:"videoCategory"`
+	// Transcript is the timecoded transcript of the latest iteration
+	Transcript string `json:"transcript,omitempty"`
+	// Prompt is the rendered prompt template of the channel
+	Prompt string `json:"prompt,omitempty"`
+	// Seed asks providers that support it for reproducible output
+	Seed   *int64 `json:"seed,omitempty"`
+	Tone   string `json:"tone,omitempty"`
+	Length string `json:"length,omitempty"`
+	// Fields restricts the suggestions to some fields, see
+	// models.AISuggestionFields. All fields are suggested if it is empty.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// NewSuggestionRequest builds a suggestion request from a video draft
+func NewSuggestionRequest(video *models.Video) *SuggestionRequest {
+	return &SuggestionRequest{
+		Title:       video.Title,
+		Description: video.Description,
+		Keywords:    video.Keywords,
+		Category:    video.Category,
+	}
+}
+
+// LocalizationRequest asks for the title and description of a video in
+// other languages
+type LocalizationRequest struct {
+	Title           string   `json:"videoTitle"`
+	Description     string   `json:"videoDescription"`
+	SourceLanguage  string   `json:"sourceLanguage"`
+	TargetLanguages []string `json:"targetLanguages"`
+}
+
+// Registry holds the configured providers
+type Registry struct {
+	providers   map[string]Provider
+	defaultName string
+	transcriber Transcriber
+}
+
+// NewRegistry sets up the providers configured for the server. Every call
+// goes through the configured timeout and retries. The OpenAI provider is
+// only available when an API key is set.
+func NewRegistry(cfg *config.Config) *Registry {
+	registry := &Registry{providers: map[string]Provider{}, defaultName: cfg.AIProvider}
+	client := newHTTPClient(cfg.AITimeout)
+
+	if cfg.AIService != "" {
+		registry.Register(WithRetries(NewHTTPProvider(cfg.AIService, client), cfg.AIMaxRetries))
+	}
+	if cfg.OpenAIAPIKey != "" {
+		registry.Register(WithRetries(NewOpenAIProvider(cfg.OpenAIBaseURL, cfg.OpenAIAPIKey, cfg.OpenAIModel, client), cfg.AIMaxRetries))
+	}
+	registry.Register(NewStubProvider())
+
+	// Transcriptions take as long as the media so they get their own timeout
+	// and aren't retried
+	switch cfg.TranscriptionProvider {
+	case "http":
+		registry.transcriber = NewHTTPTranscriber(cfg.TranscriptionService, newHTTPClient(cfg.TranscriptionTimeout))
+	case "stub":
+		registry.transcriber = NewStubTranscriber()
+	}
+	return registry
+}
+
+// SetTranscriber replaces the transcriber
+func (r *Registry) SetTranscriber(transcriber Transcriber) {
+	r.transcriber = transcriber
+}
+
+// Transcriber returns the configured transcriber
+func (r *Registry) Transcriber() (Transcriber, error) {
+	if r.transcriber == nil {
+		return nil, fmt.Errorf("%w: no transcription provider", ErrNotConfigured)
+	}
+	return r.transcriber, nil
+}
+
+// Register adds a provider, replacing any provider with the same name
+func (r *Registry) Register(provider Provider) {
+	r.providers[provider.Name()] = provider
+}
+
+// Resolve returns the first provider chosen in names, typically the ones of
+// the channel and then the user, or the default provider if none is chosen
+func (r *Registry) Resolve(names ...string) (Provider, error) {
+	name := r.defaultName
+	for _, n := range names {
+		if n != "" {
+			name = n
+			break
+		}
+	}
+
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotConfigured, name)
+	}
+	return provider, nil
+}
@@ -0,0 +1,78 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+const (
+	retryBackoff    = 500 * time.Millisecond
+	maxRetryBackoff = 10 * time.Second
+)
+
+// retrying retries the calls of a provider that fail with a temporary error
+type retrying struct {
+	Provider
+	retries int
+}
+
+// WithRetries retries failed calls to provider up to retries times when the
+// failure is temporary, backing off exponentially or as long as the provider
+// asks to
+func WithRetries(provider Provider, retries int) Provider {
+	if retries <= 0 {
+		return provider
+	}
+	return &retrying{Provider: provider, retries: retries}
+}
+
+func (p *retrying) Suggest(ctx context.Context, req *SuggestionRequest) (*models.AISuggestions, error) {
+	var suggestions *models.AISuggestions
+	err := p.retry(ctx, func() error {
+		var err error
+		suggestions, err = p.Provider.Suggest(ctx, req)
+		return err
+	})
+	return suggestions, err
+}
+
+func (p *retrying) Localize(ctx context.Context, req *LocalizationRequest) ([]models.Localization, error) {
+	var localizations []models.Localization
+	err := p.retry(ctx, func() error {
+		var err error
+		localizations, err = p.Provider.Localize(ctx, req)
+		return err
+	})
+	return localizations, err
+}
+
+func (p *retrying) retry(ctx context.Context, call func() error) error {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := call()
+		var providerErr *Error
+		if err == nil || attempt == p.retries || !errors.As(err, &providerErr) || !providerErr.Temporary() {
+			return err
+		}
+
+		wait := backoff
+		if providerErr.RetryAfter > 0 {
+			wait = providerErr.RetryAfter
+		}
+		if wait > maxRetryBackoff {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"context"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// Event types sent while suggestions are streamed
+const (
+	// EventToken carries a piece of the raw output of the provider
+	EventToken = "token"
+	// EventField carries a complete field of the suggestions
+	EventField = "field"
+)
+
+// Event is a piece of streamed suggestions. Token events set Text, field
+// events set Field and Value.
+type Event struct {
+	Type  string      `json:"-"`
+	Text  string      `json:"text,omitempty"`
+	Field string      `json:"field,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// StreamingProvider is implemented by providers that can report suggestions
+// while they are generated. emit is called for every event and stops the
+// stream when it returns an error.
+type StreamingProvider interface {
+	Provider
+	SuggestStream(ctx context.Context, req *SuggestionRequest, emit func(Event) error) (*models.AISuggestions, error)
+}
+
+// SuggestStream streams suggestions from provider. Providers that can't
+// stream are called normally and their result is sent field by field.
+func SuggestStream(ctx context.Context, provider Provider, req *SuggestionRequest, emit func(Event) error) (*models.AISuggestions, error) {
+	if streaming, ok := provider.(StreamingProvider); ok {
+		return streaming.SuggestStream(ctx, req, emit)
+	}
+
+	suggestions, err := provider.Suggest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := emitFields(suggestions, emit); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// emitFields sends every field of suggestions as a field event
+func emitFields(suggestions *models.AISuggestions, emit func(Event) error) error {
+	fields := []struct {
+		name  string
+		value interface{}
+	}{
+		{models.AIFieldTitle, suggestions.Title},
+		{models.AIFieldDescription, suggestions.Description},
+		{models.AIFieldKeywords, suggestions.Keywords},
+		{models.AIFieldChapters, suggestions.Chapters},
+		{"thumbnail", suggestions.Thumbnail},
+	}
+	for _, field := range fields {
+		if err := emit(Event{Type: EventField, Field: field.name, Value: field.value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SuggestStream retries like Suggest as long as nothing was emitted, since
+// the client can't take back events it already received
+func (p *retrying) SuggestStream(ctx context.Context, req *SuggestionRequest, emit func(Event) error) (*models.AISuggestions, error) {
+	emitted := false
+	tracked := func(event Event) error {
+		emitted = true
+		return emit(event)
+	}
+
+	var suggestions *models.AISuggestions
+	err := p.retry(ctx, func() error {
+		var err error
+		suggestions, err = SuggestStream(ctx, p.Provider, req, tracked)
+		if err != nil && emitted {
+			return &streamError{err}
+		}
+		return err
+	})
+	if streamErr, ok := err.(*streamError); ok {
+		return nil, streamErr.err
+	}
+	return suggestions, err
+}
+
+// streamError stops retries once events have been emitted
+type streamError struct {
+	err error
+}
+
+func (e *streamError) Error() string {
+	return e.err.Error()
+}
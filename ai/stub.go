@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// StubProvider derives suggestions from the draft itself without calling any
+// service. The same request always gives the same result, which makes it
+// suitable for tests and local development.
+type StubProvider struct{}
+
+// NewStubProvider returns the stub provider
+func NewStubProvider() *StubProvider {
+	return &StubProvider{}
+}
+
+func (p *StubProvider) Name() string {
+	return models.AIProviderStub
+}
+
+func (p *StubProvider) Suggest(ctx context.Context, req *SuggestionRequest) (*models.AISuggestions, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = "Untitled video"
+	}
+
+	description := strings.TrimSpace(req.Description)
+	if description == "" {
+		description = "In this video: " + title + "."
+	}
+
+	// Keep the existing keywords and add the longer words of the title
+	keywords := []string{}
+	seen := map[string]bool{}
+	for _, keyword := range req.Keywords {
+		if !seen[strings.ToLower(keyword)] {
+			seen[strings.ToLower(keyword)] = true
+			keywords = append(keywords, keyword)
+		}
+	}
+	for _, word := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if utf8.RuneCountInString(word) > 3 && !seen[word] {
+			seen[word] = true
+			keywords = append(keywords, word)
+		}
+	}
+
+	return &models.AISuggestions{
+		Title:       title,
+		Description: description,
+		Keywords:    keywords,
+		Chapters:    []string{"00:00 Intro"},
+		Thumbnail:   title,
+	}, nil
+}
+
+// Localize prefixes the title and description with the target language
+func (p *StubProvider) Localize(ctx context.Context, req *LocalizationRequest) ([]models.Localization, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	localizations := make([]models.Localization, 0, len(req.TargetLanguages))
+	for _, lang := range req.TargetLanguages {
+		localization := models.Localization{Language: lang, Title: truncate("["+lang+"] "+req.Title, models.MaxTitleLength)}
+		if req.Description != "" {
+			localization.Description = "[" + lang + "] " + req.Description
+		}
+		localizations = append(localizations, localization)
+	}
+	return localizations, nil
+}
+
+// truncate cuts s to at most n runes
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
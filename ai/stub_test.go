@@ -0,0 +1,160 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// flakyProvider fails its first calls before handing them to the stub
+type flakyProvider struct {
+	*StubProvider
+	failures int
+	err      error
+	calls    int
+}
+
+func (p *flakyProvider) Suggest(ctx context.Context, req *SuggestionRequest) (*models.AISuggestions, error) {
+	p.calls++
+	if p.calls <= p.failures {
+		return nil, p.err
+	}
+	return p.StubProvider.Suggest(ctx, req)
+}
+
+// unavailable is a temporary failure that asks to be retried right away
+func unavailable() error {
+	return &Error{Provider: "flaky", Kind: ErrUnavailable, RetryAfter: time.Millisecond}
+}
+
+func TestStubSuggest(t *testing.T) {
+	req := &SuggestionRequest{Title: "Baking Sourdough Bread", Keywords: []string{"Bread", "bread", "recipe"}}
+	suggestions, err := NewStubProvider().Suggest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Suggest: %v", err)
+	}
+
+	want := &models.AISuggestions{
+		Title:       "Baking Sourdough Bread",
+		Description: "In this video: Baking Sourdough Bread.",
+		Keywords:    []string{"Bread", "recipe", "baking", "sourdough"},
+		Chapters:    []string{"00:00 Intro"},
+		Thumbnail:   "Baking Sourdough Bread",
+	}
+	if !reflect.DeepEqual(suggestions, want) {
+		t.Errorf("Suggest = %+v, want %+v", suggestions, want)
+	}
+}
+
+func TestWithRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   error
+	}{
+		{"succeeds", 0, nil, 1, nil},
+		{"retries temporary failures", 2, unavailable(), 3, nil},
+		{"gives up after the retries", 5, unavailable(), 4, ErrUnavailable},
+		{"doesn't retry rejections", 2, &Error{Provider: "flaky", Kind: ErrRejected}, 1, ErrRejected},
+		{"doesn't wait longer than the maximum backoff", 2, &Error{Provider: "flaky", Kind: ErrRateLimited, RetryAfter: time.Minute}, 1, ErrRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky := &flakyProvider{StubProvider: NewStubProvider(), failures: tt.failures, err: tt.err}
+			suggestions, err := WithRetries(flaky, 3).Suggest(context.Background(), &SuggestionRequest{Title: "Launch"})
+			if flaky.calls != tt.wantCalls {
+				t.Errorf("called the provider %d times, want %d", flaky.calls, tt.wantCalls)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Suggest error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Suggest: %v", err)
+			}
+			if suggestions.Title != "Launch" {
+				t.Errorf("Suggest title = %q", suggestions.Title)
+			}
+		})
+	}
+}
+
+func TestSuggestStreamStub(t *testing.T) {
+	var events []Event
+	suggestions, err := SuggestStream(context.Background(), NewStubProvider(), &SuggestionRequest{Title: "Launch"}, func(event Event) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SuggestStream: %v", err)
+	}
+
+	var fields []string
+	for _, event := range events {
+		if event.Type != EventField {
+			t.Errorf("got a %q event from a provider that doesn't stream", event.Type)
+		}
+		fields = append(fields, event.Field)
+	}
+	wantFields := []string{models.AIFieldTitle, models.AIFieldDescription, models.AIFieldKeywords, models.AIFieldChapters, "thumbnail"}
+	if !reflect.DeepEqual(fields, wantFields) {
+		t.Errorf("streamed fields %v, want %v", fields, wantFields)
+	}
+	if events[0].Value != suggestions.Title {
+		t.Errorf("streamed title %v, returned %q", events[0].Value, suggestions.Title)
+	}
+}
+
+func TestSuggestStreamStopsRetryingOnceEmitted(t *testing.T) {
+	flaky := &flakyProvider{StubProvider: NewStubProvider(), failures: 1, err: unavailable()}
+	provider := WithRetries(flaky, 3)
+
+	// Failures before anything is emitted are retried
+	var events int
+	_, err := SuggestStream(context.Background(), provider, &SuggestionRequest{Title: "Launch"}, func(Event) error {
+		events++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SuggestStream: %v", err)
+	}
+	if flaky.calls != 2 || events != 5 {
+		t.Errorf("called the provider %d times and emitted %d events, want 2 and 5", flaky.calls, events)
+	}
+
+	// Failures after an event was emitted are not
+	streaming := &interruptedProvider{StubProvider: NewStubProvider()}
+	events = 0
+	_, err = SuggestStream(context.Background(), WithRetries(streaming, 3), &SuggestionRequest{Title: "Launch"}, func(Event) error {
+		events++
+		return nil
+	})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("SuggestStream error = %v, want %v", err, ErrUnavailable)
+	}
+	if streaming.calls != 1 || events != 1 {
+		t.Errorf("called the provider %d times and emitted %d events, want 1 and 1", streaming.calls, events)
+	}
+}
+
+// interruptedProvider streams a token and then fails
+type interruptedProvider struct {
+	*StubProvider
+	calls int
+}
+
+func (p *interruptedProvider) SuggestStream(ctx context.Context, req *SuggestionRequest, emit func(Event) error) (*models.AISuggestions, error) {
+	p.calls++
+	if err := emit(Event{Type: EventToken, Text: "{"}); err != nil {
+		return nil, err
+	}
+	return nil, unavailable()
+}
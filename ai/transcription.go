@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/captions"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// Transcriber turns the media of an iteration into a transcript
+type Transcriber interface {
+	// Name is the name the transcriber is recorded as the source with
+	Name() string
+	// Transcribe returns the language and text of a transcript
+	Transcribe(ctx context.Context, req *TranscriptionRequest) (*models.Transcript, error)
+}
+
+// TranscriptionRequest points a transcriber at the media of an iteration
+type TranscriptionRequest struct {
+	MediaURL string `json:"mediaUrl"`
+	// Language is a hint, the transcriber detects it if it is empty
+	Language string `json:"language,omitempty"`
+}
+
+// Segment is a timed piece of a transcription, in seconds
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// HTTPTranscriber calls a transcription service that takes the media URL as
+// JSON and answers with {"language": "...", "text": "...", "segments": [...]}.
+// The segments are preferred to the text since they carry timecodes.
+type HTTPTranscriber struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPTranscriber returns a transcriber for the service at url
+func NewHTTPTranscriber(url string, client *http.Client) *HTTPTranscriber {
+	return &HTTPTranscriber{url: url, client: client}
+}
+
+func (t *HTTPTranscriber) Name() string {
+	return "http"
+}
+
+func (t *HTTPTranscriber) Transcribe(ctx context.Context, req *TranscriptionRequest) (*models.Transcript, error) {
+	var response struct {
+		Language string    `json:"language"`
+		Text     string    `json:"text"`
+		Segments []Segment `json:"segments"`
+	}
+	if err := postJSON(ctx, t.client, t.Name(), t.url, nil, req, &response); err != nil {
+		return nil, err
+	}
+
+	transcript := &models.Transcript{Language: response.Language, Text: response.Text}
+	if len(response.Segments) > 0 {
+		cues := make([]captions.Cue, 0, len(response.Segments))
+		for _, segment := range response.Segments {
+			cues = append(cues, captions.Cue{
+				Start: time.Duration(segment.Start * float64(time.Second)),
+				End:   time.Duration(segment.End * float64(time.Second)),
+				Text:  segment.Text,
+			})
+		}
+		transcript.Text = models.TranscriptText(cues)
+	}
+	if transcript.Language == "" {
+		transcript.Language = req.Language
+	}
+	return transcript, nil
+}
+
+// StubTranscriber returns a fixed transcript naming the media, for tests and
+// local development
+type StubTranscriber struct{}
+
+// NewStubTranscriber returns the stub transcriber
+func NewStubTranscriber() *StubTranscriber {
+	return &StubTranscriber{}
+}
+
+func (t *StubTranscriber) Name() string {
+	return "stub"
+}
+
+func (t *StubTranscriber) Transcribe(ctx context.Context, req *TranscriptionRequest) (*models.Transcript, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &models.Transcript{
+		Language: req.Language,
+		Text:     models.TranscriptText([]captions.Cue{{Text: fmt.Sprintf("Transcript of %s", req.MediaURL)}}),
+	}, nil
+}
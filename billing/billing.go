@@ -0,0 +1,163 @@
+// Package billing sells tiers through a Stripe-compatible API: it creates
+// checkout sessions for subscriptions and verifies the webhooks that report
+// what happens to them
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// ErrNotConfigured is returned when billing is used without an API key or
+// webhooks are received without a webhook secret
+var ErrNotConfigured = errors.New("billing is not configured")
+
+// Client calls the billing API
+type Client struct {
+	baseURL       string
+	secretKey     string
+	webhookSecret string
+	successURL    string
+	cancelURL     string
+	prices        map[models.Tier]string
+	client        *http.Client
+}
+
+// NewClient returns a client for the billing API of the configuration
+func NewClient(cfg *config.Config) *Client {
+	prices := map[models.Tier]string{}
+	if cfg.BillingPriceBasic != "" {
+		prices[models.Basic] = cfg.BillingPriceBasic
+	}
+	if cfg.BillingPricePremium != "" {
+		prices[models.Premium] = cfg.BillingPricePremium
+	}
+	return &Client{
+		baseURL:       strings.TrimSuffix(cfg.BillingAPIURL, "/"),
+		secretKey:     cfg.BillingSecretKey,
+		webhookSecret: cfg.BillingWebhookSecret,
+		successURL:    cfg.BillingSuccessURL,
+		cancelURL:     cfg.BillingCancelURL,
+		prices:        prices,
+		client:        &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Configured reports whether checkout sessions can be created
+func (c *Client) Configured() bool {
+	return c.secretKey != ""
+}
+
+// Price returns the ID of the price a tier is sold at
+func (c *Client) Price(tier models.Tier) (string, bool) {
+	price, ok := c.prices[tier]
+	return price, ok
+}
+
+// Tier returns the tier sold at a price
+func (c *Client) Tier(price string) (models.Tier, bool) {
+	for tier, id := range c.prices {
+		if id == price {
+			return tier, true
+		}
+	}
+	return "", false
+}
+
+// CheckoutRequest describes the subscription a user wants to buy
+type CheckoutRequest struct {
+	Tier   models.Tier
+	UserID string
+	Email  string
+	// CustomerID is the customer of the user's earlier subscriptions, if any
+	CustomerID string
+}
+
+// CheckoutSession is a hosted page where the user pays for a subscription
+type CheckoutSession struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// CreateCheckoutSession creates a subscription checkout session for a tier.
+// The ID of the user is stored in the metadata of the subscription, which
+// is how webhooks find the user back.
+func (c *Client) CreateCheckoutSession(ctx context.Context, req *CheckoutRequest) (*CheckoutSession, error) {
+	if !c.Configured() {
+		return nil, ErrNotConfigured
+	}
+	price, ok := c.Price(req.Tier)
+	if !ok {
+		return nil, fmt.Errorf("no price configured for the %s tier", req.Tier)
+	}
+
+	form := url.Values{
+		"mode":                                 {"subscription"},
+		"line_items[0][price]":                 {price},
+		"line_items[0][quantity]":              {"1"},
+		"success_url":                          {c.successURL},
+		"client_reference_id":                  {req.UserID},
+		"metadata[user_id]":                    {req.UserID},
+		"subscription_data[metadata][user_id]": {req.UserID},
+	}
+	if c.cancelURL != "" {
+		form.Set("cancel_url", c.cancelURL)
+	}
+	if req.CustomerID != "" {
+		form.Set("customer", req.CustomerID)
+	} else if req.Email != "" {
+		form.Set("customer_email", req.Email)
+	}
+
+	var session CheckoutSession
+	if err := c.post(ctx, "/v1/checkout/sessions", form, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// post sends a form to the API and decodes the JSON response
+func (c *Client) post(ctx context.Context, path string, form url.Values, out interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("error creating billing request: %w", err)
+	}
+	request.Header.Set("Authorization", "Bearer "+c.secretKey)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error calling billing API: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("error reading billing response: %w", err)
+	}
+	if response.StatusCode >= 300 {
+		var apiError struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &apiError) == nil && apiError.Error.Message != "" {
+			return fmt.Errorf("billing API returned %d: %s", response.StatusCode, apiError.Error.Message)
+		}
+		return fmt.Errorf("billing API returned %d", response.StatusCode)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("error decoding billing response: %w", err)
+	}
+	return nil
+}
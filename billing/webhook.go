@@ -0,0 +1,140 @@
+package billing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the header webhooks are signed in
+const SignatureHeader = "Stripe-Signature"
+
+// Tolerance is how old a signed webhook can be, to limit replays
+const Tolerance = 5 * time.Minute
+
+// ErrInvalidSignature is returned for webhooks that aren't signed with the
+// webhook secret or were signed too long ago
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Types of the webhook events that are handled
+const (
+	EventSubscriptionCreated = "customer.subscription.created"
+	EventSubscriptionUpdated = "customer.subscription.updated"
+	// EventSubscriptionDeleted is sent when a subscription is canceled
+	EventSubscriptionDeleted = "customer.subscription.deleted"
+	EventPaymentFailed       = "invoice.payment_failed"
+)
+
+// Event is a webhook event. Created is a Unix time.
+type Event struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// Subscription is the object of subscription events
+type Subscription struct {
+	ID               string            `json:"id"`
+	Customer         string            `json:"customer"`
+	Status           string            `json:"status"`
+	CurrentPeriodEnd int64             `json:"current_period_end"`
+	Metadata         map[string]string `json:"metadata"`
+	Items            struct {
+		Data []SubscriptionItem `json:"data"`
+	} `json:"items"`
+}
+
+// SubscriptionItem is a price a subscription pays for
+type SubscriptionItem struct {
+	Price struct {
+		ID string `json:"id"`
+	} `json:"price"`
+}
+
+// Price returns the ID of the price of the subscription
+func (s *Subscription) Price() string {
+	if len(s.Items.Data) == 0 {
+		return ""
+	}
+	return s.Items.Data[0].Price.ID
+}
+
+// Invoice is the object of invoice events
+type Invoice struct {
+	ID           string `json:"id"`
+	Customer     string `json:"customer"`
+	Subscription string `json:"subscription"`
+	AttemptCount int    `json:"attempt_count"`
+}
+
+// Sign returns the signature header of a payload signed with secret at t
+func Sign(payload []byte, secret string, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + signature(payload, secret, timestamp)
+}
+
+func signature(payload []byte, secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks the signature header of a webhook payload. Any of
+// the v1 signatures may match, so the secret can be rolled.
+func VerifySignature(payload []byte, header, secret string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > Tolerance || age < -Tolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := signature(payload, secret, timestamp)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// ParseWebhook verifies and decodes a webhook signed with the webhook secret
+func (c *Client) ParseWebhook(payload []byte, header string) (*Event, error) {
+	if c.webhookSecret == "" {
+		return nil, ErrNotConfigured
+	}
+	if err := VerifySignature(payload, header, c.webhookSecret, time.Now()); err != nil {
+		return nil, err
+	}
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("error decoding webhook event: %w", err)
+	}
+	if event.ID == "" || event.Type == "" {
+		return nil, fmt.Errorf("webhook event has no ID or type")
+	}
+	return &event, nil
+}
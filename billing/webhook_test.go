@@ -0,0 +1,157 @@
+package billing_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/billing"
+	"github.com/FuseWorkflows/fuse-go-server/billingtest"
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+const webhookSecret = "whsec_test"
+
+// newClient returns a client of the fake billing API that verifies webhooks
+// with secret
+func newClient(fake *billingtest.Server, secret string) *billing.Client {
+	return billing.NewClient(&config.Config{
+		BillingAPIURL:        fake.URL,
+		BillingSecretKey:     "sk_test",
+		BillingWebhookSecret: secret,
+		BillingPricePremium:  "price_premium",
+		BillingSuccessURL:    "https://app.example.com/billing",
+	})
+}
+
+// webhookReceiver parses the webhooks it receives with client and responds
+// with 400 to the ones it rejects
+func webhookReceiver(client *billing.Client, received *[]*billing.Event) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		event, err := client.ParseWebhook(payload, r.Header.Get(billing.SignatureHeader))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*received = append(*received, event)
+	}))
+}
+
+// subscribe completes a checkout on the fake and returns the event it sends
+func subscribe(t *testing.T, fake *billingtest.Server, client *billing.Client) *billing.Event {
+	t.Helper()
+	session, err := client.CreateCheckoutSession(context.Background(), &billing.CheckoutRequest{Tier: models.Premium, UserID: "user-1", Email: "user@example.com"})
+	if err != nil {
+		t.Fatalf("CreateCheckoutSession: %v", err)
+	}
+	event, err := fake.Complete(session.ID)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	return event
+}
+
+func TestWebhookSignature(t *testing.T) {
+	fake := billingtest.NewServer(webhookSecret)
+	defer fake.Close()
+	client := newClient(fake, webhookSecret)
+	var received []*billing.Event
+	receiver := webhookReceiver(client, &received)
+	defer receiver.Close()
+
+	event := subscribe(t, fake, client)
+
+	tests := []struct {
+		name     string
+		signedAt time.Time
+		wantCode int
+	}{
+		{"signed now", time.Now(), http.StatusOK},
+		{"signed within the tolerance", time.Now().Add(-billing.Tolerance + 30*time.Second), http.StatusOK},
+		{"replayed after the tolerance", time.Now().Add(-billing.Tolerance - 30*time.Second), http.StatusBadRequest},
+		{"signed in the future", time.Now().Add(billing.Tolerance + 30*time.Second), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := fake.SendAt(receiver.URL, event, tt.signedAt)
+			if err != nil {
+				t.Fatalf("SendAt: %v", err)
+			}
+			response.Body.Close()
+			if response.StatusCode != tt.wantCode {
+				t.Errorf("got status %d, want %d", response.StatusCode, tt.wantCode)
+			}
+		})
+	}
+
+	if len(received) != 2 || received[0].ID != event.ID || received[0].Type != billing.EventSubscriptionCreated {
+		t.Errorf("received %+v", received)
+	}
+}
+
+func TestWebhookWrongSecret(t *testing.T) {
+	fake := billingtest.NewServer("whsec_other")
+	defer fake.Close()
+	client := newClient(fake, webhookSecret)
+	var received []*billing.Event
+	receiver := webhookReceiver(client, &received)
+	defer receiver.Close()
+
+	response, err := fake.Send(receiver.URL, subscribe(t, fake, client))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest || len(received) != 0 {
+		t.Errorf("got status %d and %d events for a webhook signed with another secret", response.StatusCode, len(received))
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"customer.subscription.created"}`)
+	now := time.Now()
+	header := billing.Sign(payload, webhookSecret, now)
+
+	if err := billing.VerifySignature(payload, header, webhookSecret, now); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+
+	// A rolled secret signs with both the old and the new secret
+	rolled := header + ",v1=" + strings.TrimPrefix(strings.Split(billing.Sign(payload, "whsec_new", now), ",")[1], "v1=")
+	if err := billing.VerifySignature(payload, rolled, "whsec_new", now); err != nil {
+		t.Errorf("signature with a rolled secret: %v", err)
+	}
+
+	invalid := map[string]struct {
+		payload []byte
+		header  string
+	}{
+		"tampered payload":  {[]byte(`{"id":"evt_1","type":"customer.subscription.deleted"}`), header},
+		"no signature":      {payload, strings.Split(header, ",")[0]},
+		"no timestamp":      {payload, strings.Split(header, ",")[1]},
+		"empty header":      {payload, ""},
+		"changed timestamp": {payload, strings.Replace(header, "t=", "t=1", 1)},
+	}
+	for name, tt := range invalid {
+		if err := billing.VerifySignature(tt.payload, tt.header, webhookSecret, now); !errors.Is(err, billing.ErrInvalidSignature) {
+			t.Errorf("%s: got %v, want %v", name, err, billing.ErrInvalidSignature)
+		}
+	}
+}
+
+func TestParseWebhookNotConfigured(t *testing.T) {
+	fake := billingtest.NewServer(webhookSecret)
+	defer fake.Close()
+
+	client := newClient(fake, "")
+	if _, err := client.ParseWebhook([]byte(`{}`), "t=1,v1=00"); !errors.Is(err, billing.ErrNotConfigured) {
+		t.Errorf("got %v, want %v", err, billing.ErrNotConfigured)
+	}
+}
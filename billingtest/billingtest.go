@@ -0,0 +1,246 @@
+// Package billingtest provides a fake of the parts of the Stripe-compatible
+// billing API used by the server, for tests and local development. Point the
+// server at it with the BILLING_API_URL setting, complete checkout sessions
+// and deliver the resulting webhooks to /billing/webhook with Send.
+package billingtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/billing"
+)
+
+// Session is a checkout session created on the fake
+type Session struct {
+	ID                string
+	URL               string
+	SecretKey         string
+	Mode              string
+	Price             string
+	Customer          string
+	CustomerEmail     string
+	ClientReferenceID string
+	SuccessURL        string
+	CancelURL         string
+	// Metadata is the metadata given to the subscription
+	Metadata map[string]string
+}
+
+// Server is a fake billing API server that signs the webhooks it sends with
+// its webhook secret
+type Server struct {
+	*httptest.Server
+	WebhookSecret string
+
+	mu            sync.Mutex
+	sessions      []Session
+	subscriptions map[string]*billing.Subscription
+	nextID        int
+	lastCreated   int64
+}
+
+// NewServer starts a fake billing API server. Close it when done.
+func NewServer(webhookSecret string) *Server {
+	s := &Server{WebhookSecret: webhookSecret, subscriptions: map[string]*billing.Subscription{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/checkout/sessions", s.handleCreateSession)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Sessions returns the checkout sessions created so far
+func (s *Server) Sessions() []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Session(nil), s.sessions...)
+}
+
+// Complete pays for a checkout session and returns the event reporting the
+// new subscription
+func (s *Server) Complete(sessionID string) (*billing.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.ID != sessionID {
+			continue
+		}
+		customer := session.Customer
+		if customer == "" {
+			customer = s.newID("cus")
+		}
+		subscription := &billing.Subscription{
+			ID:               s.newID("sub"),
+			Customer:         customer,
+			Status:           "active",
+			CurrentPeriodEnd: time.Now().AddDate(0, 1, 0).Unix(),
+			Metadata:         session.Metadata,
+		}
+		var item billing.SubscriptionItem
+		item.Price.ID = session.Price
+		subscription.Items.Data = append(subscription.Items.Data, item)
+		s.subscriptions[subscription.ID] = subscription
+		return s.event(billing.EventSubscriptionCreated, subscription)
+	}
+	return nil, fmt.Errorf("no checkout session %s", sessionID)
+}
+
+// Update changes a subscription, such as its status or price, and returns
+// the event reporting it
+func (s *Server) Update(subscriptionID string, change func(*billing.Subscription)) (*billing.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.subscriptions[subscriptionID]
+	if !ok {
+		return nil, fmt.Errorf("no subscription %s", subscriptionID)
+	}
+	change(subscription)
+	return s.event(billing.EventSubscriptionUpdated, subscription)
+}
+
+// Cancel cancels a subscription and returns the event reporting it
+func (s *Server) Cancel(subscriptionID string) (*billing.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.subscriptions[subscriptionID]
+	if !ok {
+		return nil, fmt.Errorf("no subscription %s", subscriptionID)
+	}
+	subscription.Status = "canceled"
+	return s.event(billing.EventSubscriptionDeleted, subscription)
+}
+
+// FailPayment returns the event reporting a failed payment of a subscription
+func (s *Server) FailPayment(subscriptionID string) (*billing.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.subscriptions[subscriptionID]
+	if !ok {
+		return nil, fmt.Errorf("no subscription %s", subscriptionID)
+	}
+	return s.event(billing.EventPaymentFailed, &billing.Invoice{
+		ID:           s.newID("in"),
+		Customer:     subscription.Customer,
+		Subscription: subscription.ID,
+		AttemptCount: 1,
+	})
+}
+
+// Send delivers an event to the webhook at url, signed now
+func (s *Server) Send(url string, event *billing.Event) (*http.Response, error) {
+	return s.SendAt(url, event, time.Now())
+}
+
+// SendAt delivers an event to the webhook at url, signed at t
+func (s *Server) SendAt(url string, event *billing.Event, t time.Time) (*http.Response, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(billing.SignatureHeader, billing.Sign(payload, s.WebhookSecret, t))
+	return http.DefaultClient.Do(request)
+}
+
+// handleCreateSession implements checkout.sessions.create
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	secretKey := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if secretKey == "" {
+		writeError(w, http.StatusUnauthorized, "no API key provided")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.PostForm.Get("mode") != "subscription" || r.PostForm.Get("line_items[0][price]") == "" || r.PostForm.Get("success_url") == "" {
+		writeError(w, http.StatusBadRequest, "mode, line_items and success_url are required")
+		return
+	}
+
+	s.mu.Lock()
+	id := s.newID("cs_test")
+	session := Session{
+		ID:                id,
+		URL:               s.URL + "/pay/" + id,
+		SecretKey:         secretKey,
+		Mode:              r.PostForm.Get("mode"),
+		Price:             r.PostForm.Get("line_items[0][price]"),
+		Customer:          r.PostForm.Get("customer"),
+		CustomerEmail:     r.PostForm.Get("customer_email"),
+		ClientReferenceID: r.PostForm.Get("client_reference_id"),
+		SuccessURL:        r.PostForm.Get("success_url"),
+		CancelURL:         r.PostForm.Get("cancel_url"),
+		Metadata:          map[string]string{},
+	}
+	for key, values := range r.PostForm {
+		if name := strings.TrimPrefix(key, "subscription_data[metadata]["); name != key {
+			session.Metadata[strings.TrimSuffix(name, "]")] = values[0]
+		}
+	}
+	s.sessions = append(s.sessions, session)
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]interface{}{
+		"id":     session.ID,
+		"object": "checkout.session",
+		"mode":   session.Mode,
+		"url":    session.URL,
+	})
+}
+
+// event wraps an object in a new event. Events are created at distinct,
+// increasing times so their order is known. Callers hold the lock.
+func (s *Server) event(eventType string, object interface{}) (*billing.Event, error) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	created := time.Now().Unix()
+	if created <= s.lastCreated {
+		created = s.lastCreated + 1
+	}
+	s.lastCreated = created
+
+	event := &billing.Event{ID: s.newID("evt"), Type: eventType, Created: created}
+	event.Data.Object = data
+	return event, nil
+}
+
+// newID returns a new object ID with a prefix. Callers hold the lock.
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s_%d", prefix, s.nextID)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError responds with an error in the format of the Stripe API
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"type": "invalid_request_error", "message": message},
+	})
+}
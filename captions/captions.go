@@ -0,0 +1,303 @@
+// Package captions parses, validates and writes caption tracks in the SubRip
+// (SRT) and WebVTT formats.
+package captions
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is a caption file format
+type Format string
+
+const (
+	SRT Format = "srt"
+	VTT Format = "vtt"
+)
+
+// ErrUnknownFormat is returned for formats other than SRT and WebVTT
+var ErrUnknownFormat = errors.New("unknown caption format")
+
+// ContentType returns the media type of a format
+func (f Format) ContentType() string {
+	if f == VTT {
+		return "text/vtt"
+	}
+	return "application/x-subrip"
+}
+
+// ParseFormat parses a format name or file extension such as "srt" or ".vtt"
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "srt":
+		return SRT, nil
+	case "vtt", "webvtt":
+		return VTT, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// bom is the byte order mark some editors put at the start of caption files
+const bom = "\uFEFF"
+
+// DetectFormat guesses the format of a caption file from its content
+func DetectFormat(data []byte) Format {
+	if bytes.HasPrefix(bytes.TrimPrefix(data, []byte(bom)), []byte("WEBVTT")) {
+		return VTT
+	}
+	return SRT
+}
+
+// Cue is a piece of text shown between Start and End. Settings holds the
+// WebVTT cue settings, which SRT has no equivalent for.
+type Cue struct {
+	Start    time.Duration
+	End      time.Duration
+	Text     string
+	Settings string
+}
+
+// ParseError reports a problem with a caption file and where it is
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Parse parses a caption file in the given format and validates its cues
+func Parse(data []byte, format Format) ([]Cue, error) {
+	switch format {
+	case SRT:
+		return ParseSRT(data)
+	case VTT:
+		return ParseVTT(data)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// block is a group of consecutive non-empty lines
+type block struct {
+	line  int
+	lines []string
+}
+
+// splitBlocks splits a caption file into blocks separated by blank lines
+func splitBlocks(data []byte) []block {
+	text := strings.TrimPrefix(string(data), bom)
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var blocks []block
+	var current *block
+	for i, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			current = nil
+			continue
+		}
+		if current == nil {
+			blocks = append(blocks, block{line: i + 1})
+			current = &blocks[len(blocks)-1]
+		}
+		current.lines = append(current.lines, line)
+	}
+	return blocks
+}
+
+// ParseSRT parses a SubRip file
+func ParseSRT(data []byte) ([]Cue, error) {
+	cues := []Cue{}
+	for _, b := range splitBlocks(data) {
+		lines, line := b.lines, b.line
+		// The cue number is optional in practice
+		if !strings.Contains(lines[0], "-->") {
+			if _, err := strconv.Atoi(strings.TrimSpace(lines[0])); err != nil || len(lines) < 2 {
+				return nil, &ParseError{Line: line, Message: "expected a cue number followed by a timing line"}
+			}
+			lines, line = lines[1:], line+1
+		}
+
+		cue, err := parseTiming(lines[0], ',', line)
+		if err != nil {
+			return nil, err
+		}
+		if cue.Settings != "" {
+			return nil, &ParseError{Line: line, Message: "unexpected text after the end time"}
+		}
+		cue.Text = strings.Join(lines[1:], "\n")
+		if err := addCue(&cues, cue, line); err != nil {
+			return nil, err
+		}
+	}
+	return cues, nil
+}
+
+// ParseVTT parses a WebVTT file. Comments, styles and regions are skipped.
+func ParseVTT(data []byte) ([]Cue, error) {
+	blocks := splitBlocks(data)
+	if len(blocks) == 0 || blocks[0].line != 1 || !isVTTHeader(blocks[0].lines[0]) {
+		return nil, &ParseError{Line: 1, Message: "a WebVTT file must start with WEBVTT"}
+	}
+
+	cues := []Cue{}
+	for _, b := range blocks[1:] {
+		lines, line := b.lines, b.line
+		if keyword := strings.Fields(lines[0])[0]; keyword == "NOTE" || keyword == "STYLE" || keyword == "REGION" {
+			continue
+		}
+		// Cues may be preceded by an identifier
+		if !strings.Contains(lines[0], "-->") {
+			if len(lines) < 2 || !strings.Contains(lines[1], "-->") {
+				return nil, &ParseError{Line: line, Message: "expected a timing line"}
+			}
+			lines, line = lines[1:], line+1
+		}
+
+		cue, err := parseTiming(lines[0], '.', line)
+		if err != nil {
+			return nil, err
+		}
+		cue.Text = strings.Join(lines[1:], "\n")
+		if err := addCue(&cues, cue, line); err != nil {
+			return nil, err
+		}
+	}
+	return cues, nil
+}
+
+func isVTTHeader(line string) bool {
+	return line == "WEBVTT" || strings.HasPrefix(line, "WEBVTT ") || strings.HasPrefix(line, "WEBVTT\t")
+}
+
+// addCue validates a cue against the previous ones and appends it
+func addCue(cues *[]Cue, cue Cue, line int) error {
+	if strings.TrimSpace(cue.Text) == "" {
+		return &ParseError{Line: line, Message: "cue has no text"}
+	}
+	if cue.End <= cue.Start {
+		return &ParseError{Line: line, Message: "cue must end after it starts"}
+	}
+	if n := len(*cues); n > 0 && cue.Start < (*cues)[n-1].Start {
+		return &ParseError{Line: line, Message: "cues must be in order of their start time"}
+	}
+	*cues = append(*cues, cue)
+	return nil
+}
+
+// parseTiming parses a "start --> end [settings]" line
+func parseTiming(s string, fractionSep byte, line int) (Cue, error) {
+	startText, rest, ok := strings.Cut(s, "-->")
+	if !ok {
+		return Cue{}, &ParseError{Line: line, Message: "expected a timing line"}
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return Cue{}, &ParseError{Line: line, Message: "missing end time"}
+	}
+
+	start, ok := parseTimestamp(strings.TrimSpace(startText), fractionSep)
+	if !ok {
+		return Cue{}, &ParseError{Line: line, Message: fmt.Sprintf("invalid start time %q", strings.TrimSpace(startText))}
+	}
+	end, ok := parseTimestamp(fields[0], fractionSep)
+	if !ok {
+		return Cue{}, &ParseError{Line: line, Message: fmt.Sprintf("invalid end time %q", fields[0])}
+	}
+	return Cue{Start: start, End: end, Settings: strings.Join(fields[1:], " ")}, nil
+}
+
+// parseTimestamp parses [HH:]MM:SS<sep>mmm. Hours are required in SRT.
+func parseTimestamp(s string, fractionSep byte) (time.Duration, bool) {
+	clock, millis, ok := strings.Cut(s, string(fractionSep))
+	if !ok || len(millis) != 3 {
+		return 0, false
+	}
+	parts := strings.Split(clock, ":")
+	if len(parts) != 3 && (fractionSep != '.' || len(parts) != 2) {
+		return 0, false
+	}
+
+	ms, err := strconv.Atoi(millis)
+	if err != nil || ms < 0 {
+		return 0, false
+	}
+	total := time.Duration(ms) * time.Millisecond
+	units := []time.Duration{time.Second, time.Minute, time.Hour}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		unit := len(parts) - 1 - i
+		if err != nil || n < 0 || len(part) < 2 || (unit < 2 && (len(part) != 2 || n > 59)) {
+			return 0, false
+		}
+		total += time.Duration(n) * units[unit]
+	}
+	return total, true
+}
+
+// Write writes cues in the given format
+func Write(cues []Cue, format Format) ([]byte, error) {
+	switch format {
+	case SRT:
+		return WriteSRT(cues), nil
+	case VTT:
+		return WriteVTT(cues), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// WriteSRT writes cues as a SubRip file. WebVTT cue settings are dropped.
+func WriteSRT(cues []Cue) []byte {
+	var buf bytes.Buffer
+	for i, cue := range cues {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "%d\n%s --> %s\n%s\n", i+1, formatTimestamp(cue.Start, ','), formatTimestamp(cue.End, ','), cue.Text)
+	}
+	return buf.Bytes()
+}
+
+// WriteVTT writes cues as a WebVTT file
+func WriteVTT(cues []Cue) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&buf, "\n%s --> %s", formatTimestamp(cue.Start, '.'), formatTimestamp(cue.End, '.'))
+		if cue.Settings != "" {
+			buf.WriteString(" " + cue.Settings)
+		}
+		// WebVTT doesn't allow "-->" inside cue text
+		text := strings.ReplaceAll(cue.Text, "-->", "--&gt;")
+		fmt.Fprintf(&buf, "\n%s\n", text)
+	}
+	return buf.Bytes()
+}
+
+func formatTimestamp(d time.Duration, fractionSep byte) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, fractionSep, ms%1000)
+}
+
+// Offset shifts all cues by d. Cues that would end before zero are dropped and
+// cues that would start before zero are cut to start at zero.
+func Offset(cues []Cue, d time.Duration) []Cue {
+	shifted := make([]Cue, 0, len(cues))
+	for _, cue := range cues {
+		cue.Start += d
+		cue.End += d
+		if cue.End <= 0 {
+			continue
+		}
+		if cue.Start < 0 {
+			cue.Start = 0
+		}
+		shifted = append(shifted, cue)
+	}
+	return shifted
+}
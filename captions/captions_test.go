@@ -0,0 +1,189 @@
+package captions
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// at returns a duration of minutes, seconds and milliseconds
+func at(minutes, seconds, millis int) time.Duration {
+	return time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second + time.Duration(millis)*time.Millisecond
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		data   string
+		want   []Cue
+	}{
+		{
+			name:   "srt",
+			format: SRT,
+			data:   "1\n00:00:01,000 --> 00:00:02,500\nHello\nworld\n\n2\n00:01:00,000 --> 01:00:00,001\nBye\n",
+			want: []Cue{
+				{Start: at(0, 1, 0), End: at(0, 2, 500), Text: "Hello\nworld"},
+				{Start: at(1, 0, 0), End: time.Hour + time.Millisecond, Text: "Bye"},
+			},
+		},
+		{
+			name:   "srt with a byte order mark, CRLF and no cue numbers",
+			format: SRT,
+			data:   "\uFEFF00:00:01,000 --> 00:00:02,000\r\nHello\r\n\r\n\r\n00:00:03,000 --> 00:00:04,000\r\nAgain\r\n",
+			want: []Cue{
+				{Start: at(0, 1, 0), End: at(0, 2, 0), Text: "Hello"},
+				{Start: at(0, 3, 0), End: at(0, 4, 0), Text: "Again"},
+			},
+		},
+		{
+			name:   "srt hours past 99",
+			format: SRT,
+			data:   "1\n100:00:00,000 --> 100:00:01,000\nLate\n",
+			want:   []Cue{{Start: 100 * time.Hour, End: 100*time.Hour + time.Second, Text: "Late"}},
+		},
+		{
+			name:   "vtt",
+			format: VTT,
+			data:   "WEBVTT - Launch\n\nNOTE written by hand\n\nSTYLE\n::cue { color: red }\n\nintro\n00:01.000 --> 00:02.000 align:start line:0\nHello\n\n00:00:03.000 --> 00:00:04.000\nBye\n",
+			want: []Cue{
+				{Start: at(0, 1, 0), End: at(0, 2, 0), Text: "Hello", Settings: "align:start line:0"},
+				{Start: at(0, 3, 0), End: at(0, 4, 0), Text: "Bye"},
+			},
+		},
+		{
+			name:   "empty vtt",
+			format: VTT,
+			data:   "WEBVTT\n",
+			want:   []Cue{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cues, err := Parse([]byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(cues, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", cues, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		data   string
+		line   int
+	}{
+		{"srt without timing", SRT, "1\nHello\n", 2},
+		{"srt without cue number or timing", SRT, "Hello\n", 1},
+		{"srt with a period", SRT, "1\n00:00:01.000 --> 00:00:02.000\nHello\n", 2},
+		{"srt without hours", SRT, "1\n00:01,000 --> 00:02,000\nHello\n", 2},
+		{"srt with settings", SRT, "1\n00:00:01,000 --> 00:00:02,000 align:start\nHello\n", 2},
+		{"srt minutes past 59", SRT, "1\n00:60:00,000 --> 00:61:00,000\nHello\n", 2},
+		{"srt short milliseconds", SRT, "1\n00:00:01,00 --> 00:00:02,000\nHello\n", 2},
+		{"srt missing end", SRT, "1\n00:00:01,000 -->\nHello\n", 2},
+		{"srt without text", SRT, "1\n00:00:01,000 --> 00:00:02,000\n\n", 2},
+		{"srt ending before it starts", SRT, "1\n00:00:02,000 --> 00:00:01,000\nHello\n", 2},
+		{"srt out of order", SRT, "1\n00:00:05,000 --> 00:00:06,000\nB\n\n2\n00:00:01,000 --> 00:00:02,000\nA\n", 6},
+		{"vtt without header", VTT, "00:01.000 --> 00:02.000\nHello\n", 1},
+		{"vtt with another header", VTT, "WEBVTTX\n\n00:01.000 --> 00:02.000\nHello\n", 1},
+		{"vtt with a comma", VTT, "WEBVTT\n\n00:01,000 --> 00:02,000\nHello\n", 3},
+		{"vtt identifier without timing", VTT, "WEBVTT\n\nintro\nHello\n", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cues, err := Parse([]byte(tt.data), tt.format)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Parse = %+v, %v, want a parse error", cues, err)
+			}
+			if parseErr.Line != tt.line {
+				t.Errorf("error %q is on line %d, want %d", parseErr, parseErr.Line, tt.line)
+			}
+		})
+	}
+
+	if _, err := Parse(nil, "ass"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("unknown format got %v", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cues := []Cue{
+		{Start: at(0, 1, 5), End: at(1, 2, 30), Text: "Hello\nworld", Settings: "align:start"},
+		{Start: time.Hour, End: time.Hour + time.Second, Text: "a --> b"},
+	}
+
+	srt, err := Write(cues, SRT)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	wantSRT := "1\n00:00:01,005 --> 00:01:02,030\nHello\nworld\n\n2\n01:00:00,000 --> 01:00:01,000\na --> b\n"
+	if string(srt) != wantSRT {
+		t.Errorf("WriteSRT = %q, want %q", srt, wantSRT)
+	}
+
+	vtt, err := Write(cues, VTT)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	wantVTT := "WEBVTT\n\n00:00:01.005 --> 00:01:02.030 align:start\nHello\nworld\n\n01:00:00.000 --> 01:00:01.000\na --&gt; b\n"
+	if string(vtt) != wantVTT {
+		t.Errorf("WriteVTT = %q, want %q", vtt, wantVTT)
+	}
+
+	// Written files parse back to the same cues, less what the format drops
+	for format, want := range map[Format][]Cue{
+		SRT: {{Start: cues[0].Start, End: cues[0].End, Text: cues[0].Text}, cues[1]},
+		VTT: {cues[0], {Start: cues[1].Start, End: cues[1].End, Text: "a --&gt; b"}},
+	} {
+		data, _ := Write(cues, format)
+		parsed, err := Parse(data, format)
+		if err != nil {
+			t.Fatalf("Parse(Write(%s)): %v", format, err)
+		}
+		if !reflect.DeepEqual(parsed, want) {
+			t.Errorf("Parse(Write(%s)) = %+v, want %+v", format, parsed, want)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	for s, want := range map[string]Format{"srt": SRT, ".SRT": SRT, "vtt": VTT, ".vtt": VTT, "webvtt": VTT} {
+		if got, err := ParseFormat(s); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("ass"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ParseFormat(ass) got %v", err)
+	}
+
+	for data, want := range map[string]Format{"WEBVTT\n": VTT, "\uFEFFWEBVTT\n": VTT, "1\n00:00:01,000 --> 00:00:02,000\n": SRT, "": SRT} {
+		if got := DetectFormat([]byte(data)); got != want {
+			t.Errorf("DetectFormat(%q) = %q, want %q", data, got, want)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	cues := []Cue{
+		{Start: at(0, 1, 0), End: at(0, 2, 0), Text: "dropped"},
+		{Start: at(0, 2, 0), End: at(0, 4, 0), Text: "cut"},
+		{Start: at(0, 5, 0), End: at(0, 6, 0), Text: "shifted"},
+	}
+	want := []Cue{
+		{Start: 0, End: at(0, 1, 0), Text: "cut"},
+		{Start: at(0, 2, 0), End: at(0, 3, 0), Text: "shifted"},
+	}
+	if got := Offset(cues, -3*time.Second); !reflect.DeepEqual(got, want) {
+		t.Errorf("Offset = %+v, want %+v", got, want)
+	}
+	if cues[0].Start != at(0, 1, 0) {
+		t.Error("Offset changed the original cues")
+	}
+}
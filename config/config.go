@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,8 +18,55 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	JWTKey     string
-	Port       string
-	AIService  string
+	// ApprovalKey signs the links of sponsor approvers. It is derived from
+	// JWTKey when empty.
+	ApprovalKey string
+	Port        string
+	AIService   string
+	// AIProvider is the AI provider used when neither the channel nor the user chooses one
+	AIProvider string
+	// AITimeout bounds each call to an AI provider
+	AITimeout time.Duration
+	// AIMaxRetries is how many times a failed AI call is retried when the failure is transient
+	AIMaxRetries int
+	// OpenAI-compatible chat completions API
+	OpenAIBaseURL string
+	OpenAIAPIKey  string
+	OpenAIModel   string
+	// TranscriptionProvider transcribes iterations: http, stub or empty for none
+	TranscriptionProvider string
+	TranscriptionService  string
+	// TranscriptionTimeout bounds each transcription
+	TranscriptionTimeout time.Duration
+	// YouTubeEndpoint overrides the YouTube Data API base URL, e.g. to use a fake
+	YouTubeEndpoint string
+	// AutoMigrate applies pending migrations when the server starts
+	AutoMigrate bool
+	// TrashRetention is how long deleted items can be restored before being purged
+	TrashRetention time.Duration
+	// TrialLength is how long the trial new users get lasts, zero for no trial
+	TrialLength time.Duration
+	// TrialTier is the tier of trials
+	TrialTier string
+	// TrialWarning is how long before the end of a trial users are warned
+	TrialWarning time.Duration
+	// Stripe-compatible billing API, enabled by setting a secret key
+	BillingAPIURL        string
+	BillingSecretKey     string
+	BillingWebhookSecret string
+	// BillingPriceBasic and BillingPricePremium are the IDs of the prices the tiers are sold at
+	BillingPriceBasic   string
+	BillingPricePremium string
+	// BillingSuccessURL and BillingCancelURL are where checkout sends users back to
+	BillingSuccessURL string
+	BillingCancelURL  string
+	// AppURL is the URL of the web app, which the links of emails open
+	AppURL string
+	// SMTP server emails are sent through. Without one they are only logged.
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
 }
 
 // NewConfig loads configuration settings from environment variables
@@ -28,18 +77,71 @@ func NewConfig() (*Config, error) {
 	}
 
 	cfg := &Config{
-		DBHost:     os.Getenv("DB_HOST"),
-		DBPort:     os.Getenv("DB_PORT"),
-		DBUser:     os.Getenv("DB_USER"),
-		DBPassword: os.Getenv("DB_PASSWORD"),
-		DBName:     os.Getenv("DB_NAME"),
-		JWTKey:     os.Getenv("JWT_KEY"),
-		Port:       os.Getenv("PORT"),
-		AIService:  os.Getenv("AI_SERVICE"),
-	}
-
-	// Validate required environment variables
-	if cfg.DBHost == "" || cfg.DBPort == "" || cfg.DBUser == "" || cfg.DBPassword == "" || cfg.DBName == "" || cfg.JWTKey == "" || cfg.Port == "" || cfg.AIService == "" {
+		DBHost:               os.Getenv("DB_HOST"),
+		DBPort:               os.Getenv("DB_PORT"),
+		DBUser:               os.Getenv("DB_USER"),
+		DBPassword:           os.Getenv("DB_PASSWORD"),
+		DBName:               os.Getenv("DB_NAME"),
+		JWTKey:               os.Getenv("JWT_KEY"),
+		ApprovalKey:          os.Getenv("APPROVAL_SIGNING_KEY"),
+		Port:                 os.Getenv("PORT"),
+		AIService:            os.Getenv("AI_SERVICE"),
+		AIProvider:           "http",
+		AITimeout:            60 * time.Second,
+		AIMaxRetries:         2,
+		OpenAIBaseURL:        "https://api.openai.com/v1",
+		OpenAIAPIKey:         os.Getenv("OPENAI_API_KEY"),
+		OpenAIModel:          "gpt-4o-mini",
+		TranscriptionService: os.Getenv("TRANSCRIPTION_SERVICE"),
+		TranscriptionTimeout: 10 * time.Minute,
+		YouTubeEndpoint:      os.Getenv("YOUTUBE_ENDPOINT"),
+		AutoMigrate:          true,
+		TrashRetention:       30 * 24 * time.Hour,
+		TrialLength:          14 * 24 * time.Hour,
+		TrialTier:            "premium",
+		TrialWarning:         3 * 24 * time.Hour,
+		BillingAPIURL:        "https://api.stripe.com",
+		BillingSecretKey:     os.Getenv("BILLING_SECRET_KEY"),
+		BillingWebhookSecret: os.Getenv("BILLING_WEBHOOK_SECRET"),
+		BillingPriceBasic:    os.Getenv("BILLING_PRICE_BASIC"),
+		BillingPricePremium:  os.Getenv("BILLING_PRICE_PREMIUM"),
+		BillingSuccessURL:    os.Getenv("BILLING_SUCCESS_URL"),
+		BillingCancelURL:     os.Getenv("BILLING_CANCEL_URL"),
+		AppURL:               "http://localhost:3000",
+		SMTPAddr:             os.Getenv("SMTP_ADDR"),
+		SMTPUsername:         os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:         os.Getenv("SMTP_PASSWORD"),
+		MailFrom:             os.Getenv("MAIL_FROM"),
+	}
+
+	if provider := os.Getenv("AI_PROVIDER"); provider != "" {
+		switch provider {
+		case "http", "openai", "stub":
+			cfg.AIProvider = provider
+		default:
+			return nil, fmt.Errorf("invalid AI_PROVIDER value: %s", provider)
+		}
+	}
+
+	// Iterations are transcribed by the transcription service when there is one
+	if cfg.TranscriptionService != "" {
+		cfg.TranscriptionProvider = "http"
+	}
+	if provider := os.Getenv("TRANSCRIPTION_PROVIDER"); provider != "" {
+		switch provider {
+		case "http", "stub":
+			cfg.TranscriptionProvider = provider
+		default:
+			return nil, fmt.Errorf("invalid TRANSCRIPTION_PROVIDER value: %s", provider)
+		}
+	}
+	if cfg.TranscriptionProvider == "http" && cfg.TranscriptionService == "" {
+		return nil, fmt.Errorf("TRANSCRIPTION_SERVICE is required by the http transcription provider")
+	}
+
+	// Validate required environment variables. The AI service URL is only
+	// needed when it is the default provider.
+	if cfg.DBHost == "" || cfg.DBPort == "" || cfg.DBUser == "" || cfg.DBPassword == "" || cfg.DBName == "" || cfg.JWTKey == "" || cfg.Port == "" || (cfg.AIProvider == "http" && cfg.AIService == "") {
 		return nil, fmt.Errorf("missing required environment variables")
 	}
 
@@ -50,5 +152,87 @@ func NewConfig() (*Config, error) {
 	}
 	cfg.Port = strconv.Itoa(portInt)
 
+	// Migrations run at startup unless disabled
+	if autoMigrate := os.Getenv("AUTO_MIGRATE"); autoMigrate != "" {
+		cfg.AutoMigrate, err = strconv.ParseBool(autoMigrate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTO_MIGRATE value: %w", err)
+		}
+	}
+
+	// Deleted items are kept for 30 days unless configured otherwise
+	if retentionDays := os.Getenv("TRASH_RETENTION_DAYS"); retentionDays != "" {
+		days, err := strconv.Atoi(retentionDays)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("invalid TRASH_RETENTION_DAYS value: %s", retentionDays)
+		}
+		cfg.TrashRetention = time.Duration(days) * 24 * time.Hour
+	}
+
+	// New users get a 14 day premium trial and are warned 3 days before it
+	// ends unless configured otherwise
+	if trialDays := os.Getenv("TRIAL_DAYS"); trialDays != "" {
+		days, err := strconv.Atoi(trialDays)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("invalid TRIAL_DAYS value: %s", trialDays)
+		}
+		cfg.TrialLength = time.Duration(days) * 24 * time.Hour
+	}
+	if trialTier := os.Getenv("TRIAL_TIER"); trialTier != "" {
+		switch trialTier {
+		case "basic", "premium":
+			cfg.TrialTier = trialTier
+		default:
+			return nil, fmt.Errorf("invalid TRIAL_TIER value: %s", trialTier)
+		}
+	}
+	if warningDays := os.Getenv("TRIAL_WARNING_DAYS"); warningDays != "" {
+		days, err := strconv.Atoi(warningDays)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("invalid TRIAL_WARNING_DAYS value: %s", warningDays)
+		}
+		cfg.TrialWarning = time.Duration(days) * 24 * time.Hour
+	}
+
+	// AI calls time out after a minute and are retried twice unless configured otherwise
+	if timeoutSeconds := os.Getenv("AI_TIMEOUT_SECONDS"); timeoutSeconds != "" {
+		seconds, err := strconv.Atoi(timeoutSeconds)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid AI_TIMEOUT_SECONDS value: %s", timeoutSeconds)
+		}
+		cfg.AITimeout = time.Duration(seconds) * time.Second
+	}
+	if maxRetries := os.Getenv("AI_MAX_RETRIES"); maxRetries != "" {
+		cfg.AIMaxRetries, err = strconv.Atoi(maxRetries)
+		if err != nil || cfg.AIMaxRetries < 0 {
+			return nil, fmt.Errorf("invalid AI_MAX_RETRIES value: %s", maxRetries)
+		}
+	}
+	if timeoutSeconds := os.Getenv("TRANSCRIPTION_TIMEOUT_SECONDS"); timeoutSeconds != "" {
+		seconds, err := strconv.Atoi(timeoutSeconds)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid TRANSCRIPTION_TIMEOUT_SECONDS value: %s", timeoutSeconds)
+		}
+		cfg.TranscriptionTimeout = time.Duration(seconds) * time.Second
+	}
+	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+		cfg.OpenAIBaseURL = baseURL
+	}
+	if model := os.Getenv("OPENAI_MODEL"); model != "" {
+		cfg.OpenAIModel = model
+	}
+	if apiURL := os.Getenv("BILLING_API_URL"); apiURL != "" {
+		cfg.BillingAPIURL = apiURL
+	}
+	if cfg.BillingSecretKey != "" && cfg.BillingSuccessURL == "" {
+		return nil, fmt.Errorf("BILLING_SUCCESS_URL is required when BILLING_SECRET_KEY is set")
+	}
+	if appURL := os.Getenv("APP_URL"); appURL != "" {
+		cfg.AppURL = strings.TrimSuffix(appURL, "/")
+	}
+	if cfg.SMTPAddr != "" && cfg.MailFrom == "" {
+		return nil, fmt.Errorf("MAIL_FROM is required when SMTP_ADDR is set")
+	}
+
 	return cfg, nil
 }
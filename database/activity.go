@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// activityIteration is the ID of the iteration an entry of the audit log is
+// about: its resource for iterations and their notes and transcripts, or the
+// iterationId it recorded, like comments, share links and uploads do
+const activityIteration = `COALESCE(
+	CASE WHEN al.resource_type IN ('iteration', 'note', 'transcript') THEN al.resource_id END,
+	al.changes->'iterationId'->>'after',
+	al.changes->'iterationId'->>'before')`
+
+// activityFrom joins the entries of the audit log with the title of their
+// video and their iteration. Iterations are numbered in the order they were
+// created, counting the ones in the trash so numbers don't shift.
+const activityFrom = ` FROM audit_log al
+	LEFT JOIN videos v ON v.id = al.video_id
+	LEFT JOIN iterations it ON it.id::text = ` + activityIteration
+
+const activityColumns = auditColumns + `, COALESCE(v.title, ''),
+	(SELECT COUNT(*) FROM iterations i2 WHERE i2.video_id = it.video_id AND (i2.created_at, i2.id) <= (it.created_at, it.id))`
+
+// activityQuery returns the conditions of an activity feed. Only entries a
+// handler recorded on a resource are events, not the ones logged by route, and
+// an event recorded more than once by a request, like a transfer that both
+// organizations keep, shows up once.
+func activityQuery(filter AuditFilter) *listQuery {
+	q := filter.query()
+	q.where("al.resource_type <> ''")
+	q.where(`NOT EXISTS (
+		SELECT 1 FROM audit_log d
+		WHERE d.request_id <> '' AND d.request_id = al.request_id AND d.action = al.action
+			AND d.resource_type = al.resource_type AND d.resource_id = al.resource_id AND d.id < al.id)`)
+	return q
+}
+
+// ListActivity retrieves a page of the events of the audit log matching a
+// filter, newest first, described for the user with viewerID
+func (db *DB) ListActivity(filter AuditFilter, viewerID string, limit int, cursorValue string) (*models.List, error) {
+	q := activityQuery(filter)
+
+	var total int
+	if err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM audit_log al"+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("error counting activity: %w", err)
+	}
+
+	if cursorValue != "" {
+		c, err := decodeCursor(cursorValue)
+		if err != nil {
+			return nil, err
+		}
+		id, err := strconv.ParseInt(c.ID, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		q.where("al.id < ?", id)
+	}
+
+	args := append(q.args, limit+1)
+	rows, err := db.QueryContext(context.Background(),
+		"SELECT "+activityColumns+activityFrom+q.whereClause()+fmt.Sprintf(" ORDER BY al.id DESC LIMIT $%d", len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching activity: %w", err)
+	}
+	defer rows.Close()
+
+	activities := []models.Activity{}
+	for rows.Next() {
+		var event models.ActivityEvent
+		if err := scanAuditEntry(&activityScanner{rows, &event}, &event.AuditEntry); err != nil {
+			return nil, fmt.Errorf("error scanning activity: %w", err)
+		}
+		activities = append(activities, models.NewActivity(&event, viewerID))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	list := &models.List{Total: total}
+	if len(activities) > limit {
+		activities = activities[:limit]
+		list.NextCursor = encodeCursor(cursor{ID: strconv.FormatInt(activities[limit-1].ID, 10)})
+	}
+	list.Data = activities
+	return list, nil
+}
+
+// activityScanner scans the columns an activity feed adds to the ones of the
+// audit log into its event
+type activityScanner struct {
+	row   rowScanner
+	event *models.ActivityEvent
+}
+
+func (s *activityScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, &s.event.VideoTitle, &s.event.IterationNumber)...)
+}
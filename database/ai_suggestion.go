@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+const aiSuggestionRunColumns = "s.id, s.video_id, COALESCE(s.user_id::text, ''), s.provider, s.prompt, s.seed, COALESCE(s.tone, ''), COALESCE(s.length, ''), s.fields, COALESCE(s.parent_id::text, ''), s.result, s.accepted_fields, s.rejected_fields, s.created_at"
+
+func scanAISuggestionRun(row rowScanner, run *models.AISuggestionRun) error {
+	var seed sql.NullInt64
+	var result []byte
+	err := row.Scan(
+		&run.ID,
+		&run.VideoID,
+		&run.UserID,
+		&run.Provider,
+		&run.Prompt,
+		&seed,
+		&run.Tone,
+		&run.Length,
+		pq.Array(&run.Fields),
+		&run.ParentID,
+		&result,
+		pq.Array(&run.AcceptedFields),
+		pq.Array(&run.RejectedFields),
+		&run.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if seed.Valid {
+		run.Seed = &seed.Int64
+	}
+	if err := json.Unmarshal(result, &run.Result); err != nil {
+		return fmt.Errorf("error decoding suggestion result: %w", err)
+	}
+	return nil
+}
+
+// CreateAISuggestionRun stores the result of an AI suggestion run
+func (db *DB) CreateAISuggestionRun(run *models.AISuggestionRun) (*models.AISuggestionRun, error) {
+	result, err := json.Marshal(&run.Result)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding suggestion result: %w", err)
+	}
+
+	var created models.AISuggestionRun
+	err = scanAISuggestionRun(db.QueryRowContext(context.Background(), `
+		INSERT INTO ai_suggestion_runs AS s (video_id, user_id, provider, prompt, seed, tone, length, fields, parent_id, result)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), COALESCE($8::text[], '{}'), NULLIF($9, '')::uuid, $10)
+		RETURNING `+aiSuggestionRunColumns,
+		run.VideoID, run.UserID, run.Provider, run.Prompt, run.Seed, run.Tone, run.Length, pq.Array(run.Fields), run.ParentID, result), &created)
+	if err != nil {
+		return nil, fmt.Errorf("error creating suggestion run: %w", err)
+	}
+	return &created, nil
+}
+
+// GetAISuggestionRunsByVideo retrieves the suggestion runs of a video, newest first
+func (db *DB) GetAISuggestionRunsByVideo(videoID string) ([]models.AISuggestionRun, error) {
+	runs := []models.AISuggestionRun{}
+	rows, err := db.QueryContext(context.Background(), "SELECT "+aiSuggestionRunColumns+" FROM ai_suggestion_runs s WHERE s.video_id = $1 ORDER BY s.created_at DESC", videoID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching suggestion runs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run models.AISuggestionRun
+		if err := scanAISuggestionRun(rows, &run); err != nil {
+			return nil, fmt.Errorf("error scanning suggestion run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return runs, nil
+}
+
+// GetAISuggestionRun retrieves a suggestion run of a video
+func (db *DB) GetAISuggestionRun(videoID, runID string) (*models.AISuggestionRun, error) {
+	var run models.AISuggestionRun
+	err := scanAISuggestionRun(db.QueryRowContext(context.Background(), "SELECT "+aiSuggestionRunColumns+" FROM ai_suggestion_runs s WHERE s.video_id = $1 AND s.id = $2", videoID, runID), &run)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error fetching suggestion run: %w", err)
+	}
+	return &run, nil
+}
+
+// AcceptAISuggestion writes the fields of a video that were accepted from a
+// suggestion run and marks them as AI-sourced. chapters replaces the chapters
+// of the video unless it is nil. If expectedVersion is not zero the video
+// must still be on that version.
+func (db *DB) AcceptAISuggestion(videoID, runID string, fields *models.VideoFields, chapters []models.Chapter, accepted []string, expectedVersion int) (*models.Video, error) {
+	err := db.inTx(func(tx *sql.Tx) error {
+		if err := updateVideoFields(tx, videoID, fields, expectedVersion); err != nil {
+			return err
+		}
+		if chapters != nil {
+			if err := setChapters(tx, videoID, chapters); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec("UPDATE videos SET ai_fields = ARRAY(SELECT DISTINCT unnest(ai_fields || $1::text[])) WHERE id = $2", pq.Array(accepted), videoID); err != nil {
+			return fmt.Errorf("error updating video: %w", err)
+		}
+		return setSuggestionFields(tx, runID, accepted, "accepted_fields", "rejected_fields")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return db.GetVideoByID(videoID)
+}
+
+// RejectAISuggestion records that fields of a suggestion run were rejected
+func (db *DB) RejectAISuggestion(videoID, runID string, rejected []string) (*models.AISuggestionRun, error) {
+	err := db.inTx(func(tx *sql.Tx) error {
+		return setSuggestionFields(tx, runID, rejected, "rejected_fields", "accepted_fields")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.GetAISuggestionRun(videoID, runID)
+}
+
+// setSuggestionFields adds fields to one list of a suggestion run and removes
+// them from the other, since a field can be accepted after being rejected and
+// the other way around
+func setSuggestionFields(tx *sql.Tx, runID string, fields []string, addTo, removeFrom string) error {
+	result, err := tx.Exec(`
+		UPDATE ai_suggestion_runs
+		SET `+addTo+` = ARRAY(SELECT DISTINCT unnest(`+addTo+` || $1::text[])),
+			`+removeFrom+` = ARRAY(SELECT f FROM unnest(`+removeFrom+`) AS f WHERE f <> ALL($1::text[]))
+		WHERE id = $2`, pq.Array(fields), runID)
+	if err != nil {
+		return fmt.Errorf("error updating suggestion run: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+const auditColumns = "al.id, al.actor_id, al.actor_name, al.principal_type, al.action, COALESCE(al.organization_id::text, ''), COALESCE(al.channel_id::text, ''), COALESCE(al.video_id::text, ''), al.resource_type, al.resource_id, al.changes, al.request_id, al.ip, al.created_at"
+
+func scanAuditEntry(row rowScanner, entry *models.AuditEntry) error {
+	var changes []byte
+	err := row.Scan(
+		&entry.ID,
+		&entry.AuditActor.ID,
+		&entry.Name,
+		&entry.PrincipalType,
+		&entry.Action,
+		&entry.OrganizationID,
+		&entry.ChannelID,
+		&entry.VideoID,
+		&entry.ResourceType,
+		&entry.ResourceID,
+		&changes,
+		&entry.RequestID,
+		&entry.IP,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+		return fmt.Errorf("error decoding audit changes: %w", err)
+	}
+	return nil
+}
+
+// AuditFilter selects entries of the audit log. Empty fields match every
+// entry.
+type AuditFilter struct {
+	OrganizationID string
+	ChannelID      string
+	VideoID        string
+	ActorID        string
+	PrincipalType  string
+	Action         string
+	ResourceType   string
+	ResourceID     string
+	Since          *time.Time
+	Until          *time.Time
+}
+
+// query returns the conditions of the filter
+func (f *AuditFilter) query() *listQuery {
+	q := &listQuery{}
+	for _, filter := range []struct{ column, value string }{
+		{"al.organization_id::text", f.OrganizationID},
+		{"al.channel_id::text", f.ChannelID},
+		{"al.video_id::text", f.VideoID},
+		{"al.actor_id", f.ActorID},
+		{"al.principal_type", f.PrincipalType},
+		{"al.action", f.Action},
+		{"al.resource_type", f.ResourceType},
+		{"al.resource_id", f.ResourceID},
+	} {
+		if filter.value != "" {
+			q.where(filter.column+" = ?", filter.value)
+		}
+	}
+	if f.Since != nil {
+		q.where("al.created_at >= ?", *f.Since)
+	}
+	if f.Until != nil {
+		q.where("al.created_at < ?", *f.Until)
+	}
+	return q
+}
+
+// RecordAuditEntries appends entries to the audit log
+func (db *DB) RecordAuditEntries(ctx context.Context, entries []models.AuditEntry) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		for _, entry := range entries {
+			changes, err := json.Marshal(entry.Changes)
+			if err != nil {
+				return fmt.Errorf("error encoding audit changes: %w", err)
+			}
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO audit_log (actor_id, actor_name, principal_type, action, organization_id, channel_id, video_id, resource_type, resource_id, changes, request_id, ip)
+				VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, NULLIF($6, '')::uuid, NULLIF($7, '')::uuid, $8, $9, $10, $11, $12)`,
+				entry.AuditActor.ID, entry.Name, entry.PrincipalType, entry.Action, entry.OrganizationID, entry.ChannelID, entry.VideoID,
+				entry.ResourceType, entry.ResourceID, changes, entry.RequestID, entry.IP)
+			if err != nil {
+				return fmt.Errorf("error recording audit entry: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ListAuditEntries retrieves a page of the entries of the audit log that
+// match a filter, newest first
+func (db *DB) ListAuditEntries(filter AuditFilter, limit int, cursorValue string) (*models.List, error) {
+	q := filter.query()
+
+	var total int
+	if err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM audit_log al"+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("error counting audit entries: %w", err)
+	}
+
+	if cursorValue != "" {
+		c, err := decodeCursor(cursorValue)
+		if err != nil {
+			return nil, err
+		}
+		id, err := strconv.ParseInt(c.ID, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		q.where("al.id < ?", id)
+	}
+
+	entries := []models.AuditEntry{}
+	err := db.queryAuditEntries(q, limit+1, func(entry *models.AuditEntry) error {
+		entries = append(entries, *entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := &models.List{Total: total}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		list.NextCursor = encodeCursor(cursor{ID: strconv.FormatInt(entries[limit-1].ID, 10)})
+	}
+	list.Data = entries
+	return list, nil
+}
+
+// ExportAuditEntries calls fn with every entry of the audit log that matches
+// a filter, newest first, without loading them all at once
+func (db *DB) ExportAuditEntries(filter AuditFilter, fn func(entry *models.AuditEntry) error) error {
+	return db.queryAuditEntries(filter.query(), 0, fn)
+}
+
+// queryAuditEntries calls fn with the entries matching q, newest first and at
+// most limit of them unless limit is zero
+func (db *DB) queryAuditEntries(q *listQuery, limit int, fn func(entry *models.AuditEntry) error) error {
+	query := "SELECT " + auditColumns + " FROM audit_log al" + q.whereClause() + " ORDER BY al.id DESC"
+	args := q.args
+	if limit > 0 {
+		args = append(args, limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return fmt.Errorf("error fetching audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.AuditEntry
+		if err := scanAuditEntry(rows, &entry); err != nil {
+			return fmt.Errorf("error scanning audit entry: %w", err)
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return nil
+}
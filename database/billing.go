@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// ErrDuplicateEvent is returned for billing events that were already processed
+var ErrDuplicateEvent = errors.New("billing event already processed")
+
+// SubscriptionChange is the state of a subscription reported by a billing
+// event. UserID may be empty for subscriptions created outside of checkout,
+// which are matched to users by customer instead.
+type SubscriptionChange struct {
+	ID         string
+	UserID     string
+	CustomerID string
+	Status     string
+	PriceID    string
+	Tier       models.Tier
+	// CurrentPeriodEnd is a Unix time, zero if unknown
+	CurrentPeriodEnd int64
+}
+
+const subscriptionColumns = "s.id, s.customer_id, s.status, s.tier, s.current_period_end, s.created_at, s.updated_at"
+
+func scanSubscription(row rowScanner, subscription *models.Subscription) error {
+	return row.Scan(
+		&subscription.ID,
+		&subscription.CustomerID,
+		&subscription.Status,
+		&subscription.Tier,
+		&subscription.CurrentPeriodEnd,
+		&subscription.CreatedAt,
+		&subscription.UpdatedAt,
+	)
+}
+
+// GetSubscriptionsByUser retrieves the subscriptions of a user, newest first
+func (db *DB) GetSubscriptionsByUser(userID string) ([]models.Subscription, error) {
+	subscriptions := []models.Subscription{}
+	rows, err := db.QueryContext(context.Background(), "SELECT "+subscriptionColumns+" FROM subscriptions s WHERE s.user_id = $1 ORDER BY s.created_at DESC", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var subscription models.Subscription
+		if err := scanSubscription(rows, &subscription); err != nil {
+			return nil, fmt.Errorf("error scanning subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// GetBillingCustomerID returns the billing customer of a user, empty if the
+// user never subscribed
+func (db *DB) GetBillingCustomerID(userID string) (string, error) {
+	var customerID string
+	err := db.QueryRowContext(context.Background(), "SELECT COALESCE(billing_customer_id, '') FROM users WHERE id = $1", userID).Scan(&customerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("error fetching billing customer: %w", err)
+	}
+	return customerID, nil
+}
+
+// ApplySubscriptionEvent records the state of a subscription from a billing
+// event and updates the tier of its user to the best tier among the user's
+// paying subscriptions. Subscribing ends a running trial, while a trial is
+// left alone as long as the user has no paying subscription. Events older
+// than the last one applied to the subscription don't change it, and each
+// event is only processed once.
+func (db *DB) ApplySubscriptionEvent(eventID, eventType string, created int64, change *SubscriptionChange) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		if err := recordBillingEvent(tx, eventID, eventType); err != nil {
+			return err
+		}
+		userID, err := billingUser(tx, change.UserID, change.CustomerID, change.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(context.Background(),
+			"UPDATE users SET billing_customer_id = COALESCE(billing_customer_id, NULLIF($2, '')) WHERE id = $1", userID, change.CustomerID)
+		if err != nil {
+			return fmt.Errorf("error setting billing customer: %w", err)
+		}
+
+		_, err = tx.ExecContext(context.Background(), `
+			INSERT INTO subscriptions AS s (id, user_id, customer_id, status, price_id, tier, current_period_end, event_created)
+			VALUES ($1, $2, $3, $4, $5, $6, CASE WHEN $7::bigint > 0 THEN TO_TIMESTAMP($7::bigint) AT TIME ZONE 'UTC' END, $8)
+			ON CONFLICT (id) DO UPDATE SET
+				status = EXCLUDED.status,
+				price_id = EXCLUDED.price_id,
+				tier = EXCLUDED.tier,
+				current_period_end = EXCLUDED.current_period_end,
+				event_created = EXCLUDED.event_created,
+				updated_at = NOW()
+			WHERE s.event_created <= EXCLUDED.event_created`,
+			change.ID, userID, change.CustomerID, change.Status, change.PriceID, change.Tier, change.CurrentPeriodEnd, created)
+		if err != nil {
+			return fmt.Errorf("error saving subscription: %w", err)
+		}
+
+		return updateBilledTier(tx, userID)
+	})
+}
+
+// RecordPaymentFailure notifies the user of a failed subscription payment
+// and marks the subscription as past due until its next event says
+// otherwise. Each event is only processed once.
+func (db *DB) RecordPaymentFailure(eventID, eventType string, created int64, customerID, subscriptionID string) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		if err := recordBillingEvent(tx, eventID, eventType); err != nil {
+			return err
+		}
+		userID, err := billingUser(tx, "", customerID, subscriptionID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(context.Background(), `
+			UPDATE subscriptions SET status = 'past_due', event_created = $2, updated_at = NOW()
+			WHERE id = $1 AND status = 'active' AND event_created <= $2`, subscriptionID, created)
+		if err != nil {
+			return fmt.Errorf("error updating subscription: %w", err)
+		}
+
+		_, err = tx.ExecContext(context.Background(), "INSERT INTO notifications (user_id, kind, message) VALUES ($1, $2, $3)",
+			userID, models.NotificationPaymentFailed, "A payment for your subscription failed. Update your payment method to keep your tier.")
+		if err != nil {
+			return fmt.Errorf("error creating notification: %w", err)
+		}
+		return nil
+	})
+}
+
+// recordBillingEvent marks an event as processed, failing with
+// ErrDuplicateEvent if it already was
+func recordBillingEvent(tx *sql.Tx, eventID, eventType string) error {
+	result, err := tx.ExecContext(context.Background(),
+		"INSERT INTO billing_events (id, type) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING", eventID, eventType)
+	if err != nil {
+		return fmt.Errorf("error recording billing event: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if count == 0 {
+		return ErrDuplicateEvent
+	}
+	return nil
+}
+
+// billingUser finds the user a billing event is about. The owner of a known
+// subscription comes first, then the user of the customer, and the user ID
+// in the subscription's metadata is only used for new customers, so that
+// metadata that disagrees with what is stored can't move a subscription to
+// another user.
+func billingUser(tx *sql.Tx, userID, customerID, subscriptionID string) (string, error) {
+	var id string
+	err := tx.QueryRowContext(context.Background(), `
+		SELECT id FROM (
+			SELECT user_id::text AS id, 1 AS precedence FROM subscriptions WHERE id = $3
+			UNION ALL
+			SELECT id::text, 2 FROM users WHERE billing_customer_id = NULLIF($2, '')
+			UNION ALL
+			SELECT id::text, 3 FROM users WHERE id::text = $1
+		) candidates
+		ORDER BY precedence LIMIT 1`, userID, customerID, subscriptionID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("error finding billing user: %w", err)
+	}
+	return id, nil
+}
+
+// updateBilledTier sets the tier of a user from their paying subscriptions
+// and notifies them when it changes
+func updateBilledTier(tx *sql.Tx, userID string) error {
+	var previous, tier models.Tier
+	err := tx.QueryRowContext(context.Background(), `
+		WITH paid AS (
+			SELECT tier FROM subscriptions
+			WHERE user_id = $1 AND status = ANY($2)
+			ORDER BY CASE tier WHEN $3 THEN 2 WHEN $4 THEN 1 ELSE 0 END DESC
+			LIMIT 1
+		), previous AS (
+			SELECT tier FROM users WHERE id = $1
+		)
+		UPDATE users SET
+			tier = COALESCE((SELECT tier FROM paid), $5),
+			trial = trial AND NOT EXISTS (SELECT 1 FROM paid),
+			updated_at = NOW()
+		WHERE id = $1 AND (NOT trial OR EXISTS (SELECT 1 FROM paid))
+		RETURNING (SELECT tier FROM previous), tier`,
+		userID, pq.Array(models.PayingSubscriptionStatuses), models.Premium, models.Basic, models.Free).Scan(&previous, &tier)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// On a trial without a paying subscription
+			return nil
+		}
+		return fmt.Errorf("error updating tier: %w", err)
+	}
+	if previous == tier {
+		return nil
+	}
+
+	_, err = tx.ExecContext(context.Background(), "INSERT INTO notifications (user_id, kind, message) VALUES ($1, $2, $3)",
+		userID, models.NotificationSubscription, fmt.Sprintf("Your account is now on the %s tier.", tier))
+	if err != nil {
+		return fmt.Errorf("error creating notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+const captionColumns = "c.id, c.video_id, COALESCE(c.iteration_id::text, ''), c.language, c.name, c.format, c.cue_count, COALESCE(c.youtube_id, ''), c.created_at, c.updated_at"
+
+func scanCaption(row rowScanner, caption *models.Caption) error {
+	return row.Scan(
+		&caption.ID,
+		&caption.VideoID,
+		&caption.IterationID,
+		&caption.Language,
+		&caption.Name,
+		&caption.Format,
+		&caption.CueCount,
+		&caption.YouTubeID,
+		&caption.CreatedAt,
+		&caption.UpdatedAt,
+	)
+}
+
+// CreateCaption stores a caption track for a video. A track pinned to an
+// iteration must be pinned to an iteration of the same video.
+func (db *DB) CreateCaption(caption *models.Caption, content []byte) (*models.Caption, error) {
+	if caption.IterationID != "" {
+		var exists bool
+		err := db.QueryRowContext(context.Background(), "SELECT EXISTS (SELECT 1 FROM iterations WHERE id = $1 AND video_id = $2 AND deleted_at IS NULL)",
+			caption.IterationID, caption.VideoID).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching iteration: %w", err)
+		}
+		if !exists {
+			return nil, &models.ValidationError{Field: "iterationId", Message: "must be an iteration of the video"}
+		}
+	}
+
+	var created models.Caption
+	err := scanCaption(db.QueryRowContext(context.Background(), `
+		INSERT INTO captions AS c (video_id, iteration_id, language, name, format, content, cue_count)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, $7)
+		RETURNING `+captionColumns,
+		caption.VideoID, caption.IterationID, caption.Language, caption.Name, caption.Format, string(content), caption.CueCount), &created)
+	if err != nil {
+		return nil, fmt.Errorf("error creating caption: %w", err)
+	}
+	return &created, nil
+}
+
+// GetCaptionsByVideo retrieves the caption tracks of a video
+func (db *DB) GetCaptionsByVideo(videoID string) ([]models.Caption, error) {
+	captions := []models.Caption{}
+	rows, err := db.QueryContext(context.Background(), "SELECT "+captionColumns+" FROM captions c WHERE c.video_id = $1 ORDER BY c.language, c.created_at", videoID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching captions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var caption models.Caption
+		if err := scanCaption(rows, &caption); err != nil {
+			return nil, fmt.Errorf("error scanning caption: %w", err)
+		}
+		captions = append(captions, caption)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return captions, nil
+}
+
+// GetCaptionContent retrieves the metadata and content of a caption track
+func (db *DB) GetCaptionContent(videoID, captionID string) (*models.Caption, []byte, error) {
+	var caption models.Caption
+	var content string
+	err := db.QueryRowContext(context.Background(), "SELECT "+captionColumns+", c.content FROM captions c WHERE c.video_id = $1 AND c.id = $2", videoID, captionID).Scan(
+		&caption.ID,
+		&caption.VideoID,
+		&caption.IterationID,
+		&caption.Language,
+		&caption.Name,
+		&caption.Format,
+		&caption.CueCount,
+		&caption.YouTubeID,
+		&caption.CreatedAt,
+		&caption.UpdatedAt,
+		&content,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, fmt.Errorf("error fetching caption: %w", err)
+	}
+	return &caption, []byte(content), nil
+}
+
+// UpdateCaptionContent replaces the content of a caption track
+func (db *DB) UpdateCaptionContent(videoID, captionID string, content []byte, cueCount int) (*models.Caption, error) {
+	var caption models.Caption
+	err := scanCaption(db.QueryRowContext(context.Background(),
+		"UPDATE captions c SET content = $1, cue_count = $2, updated_at = NOW() WHERE c.video_id = $3 AND c.id = $4 RETURNING "+captionColumns,
+		string(content), cueCount, videoID, captionID), &caption)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error updating caption: %w", err)
+	}
+	return &caption, nil
+}
+
+// SetCaptionYouTubeID records the ID YouTube assigned to an uploaded track
+func (db *DB) SetCaptionYouTubeID(captionID, youtubeID string) error {
+	_, err := db.ExecContext(context.Background(), "UPDATE captions SET youtube_id = $1 WHERE id = $2", youtubeID, captionID)
+	if err != nil {
+		return fmt.Errorf("error updating caption: %w", err)
+	}
+	return nil
+}
+
+// DeleteCaption deletes a caption track
+func (db *DB) DeleteCaption(videoID, captionID string) error {
+	result, err := db.ExecContext(context.Background(), "DELETE FROM captions WHERE video_id = $1 AND id = $2", videoID, captionID)
+	if err != nil {
+		return fmt.Errorf("error deleting caption: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// GetChaptersByVideo retrieves the chapters of a video in order
+func (db *DB) GetChaptersByVideo(videoID string) ([]models.Chapter, error) {
+	chapters := []models.Chapter{}
+	rows, err := db.QueryContext(context.Background(), "SELECT start_seconds, title FROM chapters WHERE video_id = $1 ORDER BY start_seconds", videoID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching chapters: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chapter models.Chapter
+		if err := rows.Scan(&chapter.Start, &chapter.Title); err != nil {
+			return nil, fmt.Errorf("error scanning chapter: %w", err)
+		}
+		chapters = append(chapters, chapter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return chapters, nil
+}
+
+// ReplaceChapters replaces the chapters of a video and returns its new
+// version. Chapters are part of the video, so its version is bumped. If
+// expectedVersion is not zero the chapters are only replaced on that version.
+func (db *DB) ReplaceChapters(videoID string, chapters []models.Chapter, expectedVersion int) (int, error) {
+	var version int
+	err := db.inTx(func(tx *sql.Tx) error {
+		var err error
+		if version, err = bumpVideoVersion(tx, videoID, expectedVersion); err != nil {
+			return err
+		}
+
+		// Chapters written by hand are no longer AI-sourced
+		if _, err := tx.Exec("UPDATE videos SET ai_fields = array_remove(ai_fields, 'chapters') WHERE id = $1", videoID); err != nil {
+			return fmt.Errorf("error updating video: %w", err)
+		}
+		return setChapters(tx, videoID, chapters)
+	})
+	return version, err
+}
+
+// setChapters replaces the chapter rows of a video
+func setChapters(tx *sql.Tx, videoID string, chapters []models.Chapter) error {
+	if _, err := tx.Exec("DELETE FROM chapters WHERE video_id = $1", videoID); err != nil {
+		return fmt.Errorf("error deleting chapters: %w", err)
+	}
+	for _, chapter := range chapters {
+		if _, err := tx.Exec("INSERT INTO chapters (video_id, start_seconds, title) VALUES ($1, $2, $3)", videoID, chapter.Start, chapter.Title); err != nil {
+			return fmt.Errorf("error creating chapter: %w", err)
+		}
+	}
+	return nil
+}
+
+// bumpVideoVersion marks a video as modified when something it owns changes
+// and returns its new version. If expectedVersion is not zero the video must
+// still be on that version.
+func bumpVideoVersion(tx *sql.Tx, videoID string, expectedVersion int) (int, error) {
+	var version int
+	err := tx.QueryRow("UPDATE videos SET updated_at = NOW(), version = version + 1 WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2) RETURNING version", videoID, expectedVersion).Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, versionConflict(tx, "videos", videoID)
+		}
+		return 0, fmt.Errorf("error updating video: %w", err)
+	}
+	return version, nil
+}
+
+// bumpIterationVideoVersion marks the video of an iteration as modified since
+// videos embed their iterations
+func bumpIterationVideoVersion(tx *sql.Tx, iterationID string) error {
+	if _, err := tx.Exec("UPDATE videos SET updated_at = NOW(), version = version + 1 WHERE id = (SELECT video_id FROM iterations WHERE id = $1)", iterationID); err != nil {
+		return fmt.Errorf("error updating video: %w", err)
+	}
+	return nil
+}
+
+// bumpEditorVideoVersions marks the videos an editor is assigned to as
+// modified since videos embed their editors
+func bumpEditorVideoVersions(tx *sql.Tx, editorID string) error {
+	if _, err := tx.Exec("UPDATE videos SET updated_at = NOW(), version = version + 1 WHERE id IN (SELECT video_id FROM video_editor WHERE editor_id = $1)", editorID); err != nil {
+		return fmt.Errorf("error updating videos: %w", err)
+	}
+	return nil
+}
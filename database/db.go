@@ -6,16 +6,19 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/FuseWorkflows/fuse-go-server/models"
 	"github.com/google/uuid"
-	"github.com/lib/pq"
-	_ "github.com/lib/pq" // postgres driver
+	"github.com/lib/pq" // postgres driver
 )
 
-var ErrNotFound = errors.New("resource not found")
+var (
+	ErrNotFound = errors.New("resource not found")
+	// ErrVersionMismatch is returned when a conditional update or delete
+	// expected a different version of the row
+	ErrVersionMismatch = errors.New("resource version mismatch")
+)
 
 type DB struct {
 	*sql.DB
@@ -46,19 +49,44 @@ func InitDB() (*DB, error) {
 	return &DB{db}, nil
 }
 
+// inTx runs fn in a transaction, committing it if fn returns nil
+func (db *DB) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// queryRower is implemented by *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// versionConflict is called when a conditional write matched no row. It
+// tells a missing row apart from one whose version has changed.
+func versionConflict(q queryRower, table, id string) error {
+	var exists bool
+	err := q.QueryRowContext(context.Background(), "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error checking %s version: %w", table, err)
+	}
+	if exists {
+		return ErrVersionMismatch
+	}
+	return ErrNotFound
+}
+
 // GetUserByID retrieves a user by ID
 func (db *DB) GetUserByID(userID string) (*models.User, error) {
 	var user models.User
-	err := db.QueryRowContext(context.Background(), "SELECT * FROM users WHERE id = $1", userID).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.Tier,
-		&user.Trial,
-	)
+	err := scanUser(db.QueryRowContext(context.Background(), "SELECT "+userColumns+" FROM users u WHERE u.id = $1", userID), &user)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -72,16 +100,7 @@ func (db *DB) GetUserByID(userID string) (*models.User, error) {
 // GetUserByEmail retrieves a user by email
 func (db *DB) GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
-	err := db.QueryRowContext(context.Background(), "SELECT * FROM users WHERE email = $1", email).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.Tier,
-		&user.Trial,
-	)
+	err := scanUser(db.QueryRowContext(context.Background(), "SELECT "+userColumns+" FROM users u WHERE u.email = $1", email), &user)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -92,10 +111,28 @@ func (db *DB) GetUserByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-// GetUsers retrieves all users
-func (db *DB) GetUsers() ([]models.User, error) {
-	var users []models.User
-	rows, err := db.QueryContext(context.Background(), "SELECT * FROM users")
+// ListUsers retrieves a page of users
+func (db *DB) ListUsers(params ListParams) (*models.List, error) {
+	q := &listQuery{}
+	if params.Tier != "" {
+		q.where("u.tier = ?", params.Tier)
+	}
+	if params.CreatedAfter != nil {
+		q.where("u.created_at > ?", *params.CreatedAfter)
+	}
+
+	var total int
+	if err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM users u"+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("error counting users: %w", err)
+	}
+
+	page, err := q.page(params, userSortFields, "u.id")
+	if err != nil {
+		return nil, err
+	}
+
+	users := []models.User{}
+	rows, err := db.QueryContext(context.Background(), "SELECT "+userColumns+" FROM users u"+q.whereClause()+page, q.args...)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching users: %w", err)
 	}
@@ -103,16 +140,7 @@ func (db *DB) GetUsers() ([]models.User, error) {
 
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(
-			&user.ID,
-			&user.Username,
-			&user.Email,
-			&user.Password,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-			&user.Tier,
-			&user.Trial,
-		); err != nil {
+		if err := scanUser(rows, &user); err != nil {
 			return nil, fmt.Errorf("error scanning user: %w", err)
 		}
 
@@ -123,7 +151,14 @@ func (db *DB) GetUsers() ([]models.User, error) {
 		return nil, fmt.Errorf("error iterating through rows: %w", err)
 	}
 
-	return users, nil
+	list := &models.List{Total: total}
+	if len(users) > params.Limit {
+		list.NextCursor = nextCursor(params, userSortFields, len(users), &users[params.Limit-1], users[params.Limit-1].ID)
+		users = users[:params.Limit]
+	}
+	list.Data = users
+
+	return list, nil
 }
 
 // CreateUser creates a new user
@@ -149,12 +184,36 @@ func (db *DB) CreateUser(user *models.User) (*models.User, error) {
 	return createdUser, nil
 }
 
+// CreateUserWithTrial creates a new user on a trial of tier that lasts for
+// length
+func (db *DB) CreateUserWithTrial(user *models.User, tier models.Tier, length time.Duration) (*models.User, error) {
+	ctx := context.Background()
+
+	// Generate a new UUID
+	user.ID = uuid.New().String()
+
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO users (id, username, email, password, tier, trial, trial_started_at, trial_ends_at)
+		VALUES ($1, $2, $3, $4, $5, TRUE, NOW(), NOW() + $6::bigint * INTERVAL '1 second') RETURNING id`,
+		user.ID, user.Username, user.Email, user.Password, tier, int64(length.Seconds())).Scan(&user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error creating user: %w", err)
+	}
+
+	// Fetch the user before returning
+	createdUser, err := db.GetUserByID(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching user: %w", err)
+	}
+	return createdUser, nil
+}
+
 // UpdateUser updates an existing user
 func (db *DB) UpdateUser(userID string, user *models.User) (*models.User, error) {
 	ctx := context.Background()
 
-	result, err := db.ExecContext(ctx, "UPDATE users SET username = $1, email = $2, password = $3, tier = $4, trial = $5, updated_at = NOW() WHERE id = $6",
-		user.Username, user.Email, user.Password, user.Tier, user.Trial, userID)
+	result, err := db.ExecContext(ctx, "UPDATE users SET username = $1, email = $2, password = $3, ai_provider = NULLIF($4, ''), updated_at = NOW() WHERE id = $5",
+		user.Username, user.Email, user.Password, user.AIProvider, userID)
 	if err != nil {
 		return nil, fmt.Errorf("error updating user: %w", err)
 	}
@@ -175,36 +234,57 @@ func (db *DB) UpdateUser(userID string, user *models.User) (*models.User, error)
 	return updatedUser, nil
 }
 
-// DeleteUser deletes an existing user
+// DeleteUser deletes an existing user along with the organizations they are
+// the only member of. Users who are the last owner of an organization with
+// other members can't be deleted.
 func (db *DB) DeleteUser(userID string) error {
-	ctx := context.Background()
-	result, err := db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", userID)
-	if err != nil {
-		return fmt.Errorf("error deleting user: %w", err)
-	}
+	return db.inTx(func(tx *sql.Tx) error {
+		var ownsShared bool
+		err := tx.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM organization_members m
+				WHERE m.user_id = $1 AND m.role = $2
+				AND NOT EXISTS (SELECT 1 FROM organization_members o WHERE o.organization_id = m.organization_id AND o.user_id <> $1 AND o.role = $2)
+				AND EXISTS (SELECT 1 FROM organization_members o WHERE o.organization_id = m.organization_id AND o.user_id <> $1)
+			)`, userID, models.RoleOwner).Scan(&ownsShared)
+		if err != nil {
+			return fmt.Errorf("error checking organizations: %w", err)
+		}
+		if ownsShared {
+			return ErrLastOwner
+		}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("error getting rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return ErrNotFound
-	}
+		// Their channels, including the ones in the trash, are removed with
+		// their videos by the cascading foreign keys
+		_, err = tx.Exec(`
+			DELETE FROM organizations o
+			WHERE EXISTS (SELECT 1 FROM organization_members m WHERE m.organization_id = o.id AND m.user_id = $1)
+			AND NOT EXISTS (SELECT 1 FROM organization_members m WHERE m.organization_id = o.id AND m.user_id <> $1)`, userID)
+		if err != nil {
+			return fmt.Errorf("error deleting organizations: %w", err)
+		}
 
-	return nil
+		result, err := tx.Exec("DELETE FROM users WHERE id = $1", userID)
+		if err != nil {
+			return fmt.Errorf("error deleting user: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
 }
 
 // GetChannelByID retrieves a channel by ID
 func (db *DB) GetChannelByID(channelID string) (*models.Channel, error) {
 	var channel models.Channel
-	err := db.QueryRowContext(context.Background(), "SELECT * FROM channels WHERE id = $1", channelID).Scan(
-		&channel.ID,
-		&channel.Name,
-		&channel.API_KEY,
-		&channel.Owner.ID,
-		&channel.CreatedAt,
-		&channel.UpdatedAt,
-	)
+	err := scanChannel(db.QueryRowContext(context.Background(), "SELECT "+channelColumns+" FROM channels c WHERE c.id = $1 AND c.deleted_at IS NULL", channelID), &channel)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -212,29 +292,40 @@ func (db *DB) GetChannelByID(channelID string) (*models.Channel, error) {
 		return nil, fmt.Errorf("error fetching channel: %w", err)
 	}
 
-	// Fetch the owner data using the owner ID
-	owner, err := db.GetUserByID(channel.Owner.ID)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching owner: %w", err)
+	if err := db.loadChannelOwner(&channel, nil); err != nil {
+		return nil, err
 	}
 
-	// Assign the fetched owner to the channel
-	channel.Owner = *owner // Dereference the owner pointer
-
 	return &channel, nil
 }
 
-// GetChannelsByUser retrieves channels by user ID
+// loadChannelOwner fetches the owner of the organization of a channel. Owners
+// already fetched can be passed in to share them between channels.
+func (db *DB) loadChannelOwner(channel *models.Channel, owners map[string]*models.User) error {
+	if channel.Owner.ID == "" {
+		return nil
+	}
+	owner, ok := owners[channel.Owner.ID]
+	if !ok {
+		var err error
+		owner, err = db.GetOwner(channel.Owner.ID)
+		if err != nil {
+			return fmt.Errorf("error fetching owner: %w", err)
+		}
+		if owners != nil {
+			owners[channel.Owner.ID] = owner
+		}
+	}
+	channel.Owner = *owner
+	return nil
+}
+
+// GetChannelsByUser retrieves the channels of the organizations a user is
+// a member of
 func (db *DB) GetChannelsByUser(userID string) ([]models.Channel, error) {
 	var channels []models.Channel
 
-	// Fetch the owner data using the owner ID
-	owner, err := db.GetOwner(userID)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching owner: %w", err)
-	}
-
-	rows, err := db.QueryContext(context.Background(), "SELECT * FROM channels WHERE owner_id = $1", userID)
+	rows, err := db.QueryContext(context.Background(), "SELECT "+channelColumns+" FROM channels c WHERE "+memberCondition("$1", models.PermissionChannelRead)+" AND c.deleted_at IS NULL", userID)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching channels: %w", err)
 	}
@@ -242,24 +333,9 @@ func (db *DB) GetChannelsByUser(userID string) ([]models.Channel, error) {
 
 	for rows.Next() {
 		var channel models.Channel
-		if err := rows.Scan(
-			&channel.ID,
-			&channel.Name,
-			&channel.API_KEY,
-			&channel.Owner.ID,
-			&channel.CreatedAt,
-			&channel.UpdatedAt,
-		); err != nil {
+		if err := scanChannel(rows, &channel); err != nil {
 			return nil, fmt.Errorf("error scanning channel: %w", err)
 		}
-
-		// Assign the fetched owner to the channel
-		channel.Owner = *owner // Dereference the owner pointer
-
-		if err != nil {
-			return nil, fmt.Errorf("error fetching owner: %w", err)
-		}
-
 		channels = append(channels, channel)
 	}
 
@@ -267,6 +343,14 @@ func (db *DB) GetChannelsByUser(userID string) ([]models.Channel, error) {
 		return nil, fmt.Errorf("error iterating through rows: %w", err)
 	}
 
+	// Organizations usually share their owner between many channels
+	owners := map[string]*models.User{}
+	for i := range channels {
+		if err := db.loadChannelOwner(&channels[i], owners); err != nil {
+			return nil, err
+		}
+	}
+
 	return channels, nil
 }
 
@@ -278,8 +362,8 @@ func (db *DB) CreateChannel(channel *models.Channel) (*models.Channel, error) {
 	channel.ID = uuid.New().String()
 
 	// Insert the channel with the generated UUID
-	err := db.QueryRowContext(ctx, "INSERT INTO channels (id, name, api_key, owner_id) VALUES ($1, $2, $3, $4) RETURNING id",
-		channel.ID, channel.Name, channel.API_KEY, channel.Owner.ID).Scan(&channel.ID)
+	err := db.QueryRowContext(ctx, "INSERT INTO channels (id, name, api_key, organization_id, ai_provider, prompt_template) VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, '')) RETURNING id",
+		channel.ID, channel.Name, channel.API_KEY, channel.OrganizationID, channel.AIProvider, channel.PromptTemplate).Scan(&channel.ID)
 	if err != nil {
 		return nil, fmt.Errorf("error creating channel: %w", err)
 	}
@@ -292,35 +376,13 @@ func (db *DB) CreateChannel(channel *models.Channel) (*models.Channel, error) {
 	return createdChannel, nil
 }
 
-// UpdateChannel updates an existing channel
-func (db *DB) UpdateChannel(channelID string, channel *models.Channel) (*models.Channel, error) {
-	ctx := context.Background()
-	query := "UPDATE channels SET"
-	params := []interface{}{}
-	paramCounter := 1
-
-	// Dynamically add fields to the query if they are not empty
-	if channel.Name != "" {
-		query += fmt.Sprintf(" name = $%d,", paramCounter)
-		params = append(params, channel.Name)
-		paramCounter++
-	}
-	if channel.API_KEY != "" {
-		query += fmt.Sprintf(" api_key = $%d,", paramCounter)
-		params = append(params, channel.API_KEY)
-		paramCounter++
-	}
-
-	query += fmt.Sprintf(" updated_at = $%d", paramCounter)
-	params = append(params, time.Now()) // Bind the current time
-	paramCounter++
-
-	// Remove trailing comma and add WHERE clause
-	query = strings.TrimSuffix(query, ",") + fmt.Sprintf(" WHERE id = $%d", paramCounter)
-	params = append(params, channelID)
-
-	// Execute the query
-	result, err := db.ExecContext(ctx, query, params...)
+// UpdateChannel writes the patchable fields of a channel. If expectedVersion
+// is not zero the update only applies to that version of the channel.
+func (db *DB) UpdateChannel(channelID string, fields *models.ChannelFields, expectedVersion int) (*models.Channel, error) {
+	result, err := db.ExecContext(context.Background(), `
+		UPDATE channels SET name = $1, api_key = $2, ai_provider = NULLIF($3, ''), prompt_template = NULLIF($4, ''), updated_at = NOW(), version = version + 1
+		WHERE id = $5 AND deleted_at IS NULL AND ($6 = 0 OR version = $6)`,
+		fields.Name, fields.API_KEY, fields.AIProvider, fields.PromptTemplate, channelID, expectedVersion)
 	if err != nil {
 		return nil, fmt.Errorf("error updating channel: %w", err)
 	}
@@ -330,7 +392,7 @@ func (db *DB) UpdateChannel(channelID string, channel *models.Channel) (*models.
 		return nil, fmt.Errorf("error getting rows affected: %w", err)
 	}
 	if rowsAffected == 0 {
-		return nil, ErrNotFound
+		return nil, versionConflict(db, "channels", channelID)
 	}
 
 	// Fetch the updated channel before returning
@@ -341,42 +403,10 @@ func (db *DB) UpdateChannel(channelID string, channel *models.Channel) (*models.
 	return updatedChannel, nil
 }
 
-// DeleteChannel deletes an existing channel
-func (db *DB) DeleteChannel(channelID string) error {
-	ctx := context.Background()
-	result, err := db.ExecContext(ctx, "DELETE FROM channels WHERE id = $1", channelID)
-	if err != nil {
-		return fmt.Errorf("error deleting channel: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("error getting rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return ErrNotFound
-	}
-
-	return nil
-}
-
 // GetVideoByID retrieves a video by ID
 func (db *DB) GetVideoByID(videoID string) (*models.Video, error) {
 	var video models.Video
-	var keywords []byte
-	err := db.QueryRowContext(context.Background(), "SELECT * FROM videos WHERE id = $1", videoID).Scan(
-		&video.ID,
-		&video.Status,
-		&video.Resources,
-		&video.Title,
-		&video.Description,
-		&keywords,
-		&video.Category,
-		&video.PrivacyStatus,
-		&video.Channel.ID,
-		&video.CreatedAt,
-		&video.UpdatedAt,
-	)
+	err := scanVideo(db.QueryRowContext(context.Background(), "SELECT "+videoColumns+" FROM videos v WHERE v.id = $1 AND v.deleted_at IS NULL", videoID), &video)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -384,99 +414,100 @@ func (db *DB) GetVideoByID(videoID string) (*models.Video, error) {
 		return nil, fmt.Errorf("error fetching video: %w", err)
 	}
 
-	// Convert byte array to string array using pq.Array
-	if err := pq.Array(&video.Keywords).Scan(keywords); err != nil {
-		return nil, fmt.Errorf("error converting keywords array: %w", err)
+	if err := db.loadVideoRelations(&video); err != nil {
+		return nil, err
 	}
 
-	// Fetch the channel data using the channel ID
-	channel, err := db.GetChannelByID(video.Channel.ID)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching channel: %w", err)
+	return &video, nil
+}
+
+// ListVideos retrieves a page of the videos in the channels of the
+// organizations a user is a member of
+func (db *DB) ListVideos(userID string, params ListParams) (*models.List, error) {
+	q := &listQuery{}
+	q.where(memberCondition("?", models.PermissionVideoRead), userID)
+	q.where("v.deleted_at IS NULL")
+	if params.Status != "" {
+		q.where("v.status = ?", params.Status)
+	}
+	if params.ChannelID != "" {
+		q.where("v.channel_id = ?", params.ChannelID)
+	}
+	if params.Category != "" {
+		q.where("v.category = ?", params.Category)
+	}
+	if params.EditorID != "" {
+		q.where("EXISTS (SELECT 1 FROM video_editor ve WHERE ve.video_id = v.id AND ve.editor_id = ?)", params.EditorID)
+	}
+	if params.CreatedAfter != nil {
+		q.where("v.created_at > ?", *params.CreatedAfter)
 	}
 
-	// Assign the fetched channel to the video
-	video.Channel = *channel // Dereference the channel pointer
+	from := " FROM videos v JOIN channels c ON v.channel_id = c.id"
 
-	video.Iterations, err = db.GetIterationsByVideo(video.ID)
+	var total int
+	if err := db.QueryRowContext(context.Background(), "SELECT COUNT(*)"+from+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("error counting videos: %w", err)
+	}
+
+	page, err := q.page(params, videoSortFields, "v.id")
 	if err != nil {
-		return nil, fmt.Errorf("error fetching iterations: %w", err)
+		return nil, err
 	}
 
-	video.Editors, err = db.GetEditorsByVideo(video.ID)
+	videos, err := db.queryVideos("SELECT "+videoColumns+from+q.whereClause()+page, q.args...)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching editors: %w", err)
+		return nil, err
 	}
 
-	return &video, nil
+	list := &models.List{Total: total}
+	if len(videos) > params.Limit {
+		list.NextCursor = nextCursor(params, videoSortFields, len(videos), &videos[params.Limit-1], videos[params.Limit-1].ID)
+		videos = videos[:params.Limit]
+	}
+	list.Data = videos
+
+	return list, nil
+}
+
+// GetVideosByChannel retrieves videos by channel ID
+func (db *DB) GetVideosByChannel(channelID string) ([]models.Video, error) {
+	return db.queryVideos("SELECT "+videoColumns+" FROM videos v WHERE v.channel_id = $1 AND v.deleted_at IS NULL", channelID)
 }
 
-// GetVideosByUser retrieves videos by user ID
-func (db *DB) GetVideosByUser(userID string) ([]models.Video, error) {
-	var videos []models.Video
-	rows, err := db.QueryContext(context.Background(), "SELECT v.* FROM videos v JOIN channels c ON v.channel_id = c.id WHERE c.owner_id = $1", userID)
+// GetPublishedTitlesByChannel retrieves the titles of the latest videos of a
+// channel that were published, newest first. There are no view counts yet so
+// these stand in for the channel's top titles.
+func (db *DB) GetPublishedTitlesByChannel(channelID string, limit int) ([]string, error) {
+	titles := []string{}
+	rows, err := db.QueryContext(context.Background(), `
+		SELECT v.title FROM videos v
+		WHERE v.channel_id = $1 AND v.status = $2 AND v.deleted_at IS NULL AND COALESCE(v.title, '') <> ''
+		ORDER BY v.updated_at DESC LIMIT $3`, channelID, models.Published, limit)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching videos: %w", err)
+		return nil, fmt.Errorf("error fetching titles: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var video models.Video
-		var keywords []byte
-		if err := rows.Scan(
-			&video.ID,
-			&video.Status,
-			&video.Resources,
-			&video.Title,
-			&video.Description,
-			&keywords,
-			&video.Category,
-			&video.PrivacyStatus,
-			&video.Channel.ID,
-			&video.CreatedAt,
-			&video.UpdatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("error scanning video: %w", err)
-		}
-
-		// Convert byte array to string array using pq.Array
-		if err := pq.Array(&video.Keywords).Scan(keywords); err != nil {
-			return nil, fmt.Errorf("error converting keywords array: %w", err)
-		}
-
-		// Fetch the channel data using the channel ID
-		channel, err := db.GetChannelByID(video.Channel.ID)
-		if err != nil {
-			return nil, fmt.Errorf("error fetching channel: %w", err)
-		}
-
-		// Assign the fetched channel to the video
-		video.Channel = *channel // Dereference the channel pointer
-
-		video.Iterations, err = db.GetIterationsByVideo(video.ID)
-		if err != nil {
-			return nil, fmt.Errorf("error fetching iterations: %w", err)
-		}
-
-		video.Editors, err = db.GetEditorsByVideo(video.ID)
-		if err != nil {
-			return nil, fmt.Errorf("error fetching editors: %w", err)
+		var title string
+		if err := rows.Scan(&title); err != nil {
+			return nil, fmt.Errorf("error scanning title: %w", err)
 		}
-
-		videos = append(videos, video)
+		titles = append(titles, title)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating through rows: %w", err)
 	}
 
-	return videos, nil
+	return titles, nil
 }
 
-// GetVideosByChannel retrieves videos by channel ID
-func (db *DB) GetVideosByChannel(channelID string) ([]models.Video, error) {
-	var videos []models.Video
-	rows, err := db.QueryContext(context.Background(), "SELECT * FROM videos WHERE channel_id = $1", channelID)
+// queryVideos runs a query selecting videoColumns and loads each video's relations
+func (db *DB) queryVideos(query string, args ...interface{}) ([]models.Video, error) {
+	videos := []models.Video{}
+	rows, err := db.QueryContext(context.Background(), query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching videos: %w", err)
 	}
@@ -484,75 +515,85 @@ func (db *DB) GetVideosByChannel(channelID string) ([]models.Video, error) {
 
 	for rows.Next() {
 		var video models.Video
-		var keywords []byte
-		if err := rows.Scan(
-			&video.ID,
-			&video.Status,
-			&video.Resources,
-			&video.Title,
-			&video.Description,
-			&keywords,
-			&video.Category,
-			&video.PrivacyStatus,
-			&video.Channel.ID,
-			&video.CreatedAt,
-			&video.UpdatedAt,
-		); err != nil {
+		if err := scanVideo(rows, &video); err != nil {
 			return nil, fmt.Errorf("error scanning video: %w", err)
 		}
 
-		// Convert byte array to string array using pq.Array
-		if err := pq.Array(&video.Keywords).Scan(keywords); err != nil {
-			return nil, fmt.Errorf("error converting keywords array: %w", err)
-		}
+		videos = append(videos, video)
+	}
 
-		// Fetch the channel data using the channel ID
-		channel, err := db.GetChannelByID(video.Channel.ID)
-		if err != nil {
-			return nil, fmt.Errorf("error fetching channel: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	// Relations are loaded once the rows are closed so that the connection is released
+	for i := range videos {
+		if err := db.loadVideoRelations(&videos[i]); err != nil {
+			return nil, err
 		}
+	}
 
-		// Assign the fetched channel to the video
-		video.Channel = *channel // Dereference the channel pointer
+	return videos, nil
+}
 
-		video.Iterations, err = db.GetIterationsByVideo(video.ID)
-		if err != nil {
-			return nil, fmt.Errorf("error fetching iterations: %w", err)
-		}
+// loadVideoRelations fetches the channel, iterations, editors, chapters,
+// localizations and sponsorship of a video
+func (db *DB) loadVideoRelations(video *models.Video) error {
+	// Fetch the channel data using the channel ID
+	channel, err := db.GetChannelByID(video.Channel.ID)
+	if err != nil {
+		return fmt.Errorf("error fetching channel: %w", err)
+	}
 
-		video.Editors, err = db.GetEditorsByVideo(video.ID)
-		if err != nil {
-			return nil, fmt.Errorf("error fetching editors: %w", err)
-		}
+	// Assign the fetched channel to the video
+	video.Channel = *channel // Dereference the channel pointer
 
-		videos = append(videos, video)
+	video.Iterations, err = db.GetIterationsByVideo(video.ID)
+	if err != nil {
+		return fmt.Errorf("error fetching iterations: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	video.Editors, err = db.GetEditorsByVideo(video.ID)
+	if err != nil {
+		return fmt.Errorf("error fetching editors: %w", err)
 	}
 
-	return videos, nil
+	video.Chapters, err = db.GetChaptersByVideo(video.ID)
+	if err != nil {
+		return err
+	}
+
+	video.Localizations, err = db.GetLocalizationsByVideo(video.ID)
+	if err != nil {
+		return err
+	}
+
+	video.Sponsorship, err = db.GetSponsorship(video.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	return nil
 }
 
 // CreateVideo creates a new video
 func (db *DB) CreateVideo(video *models.Video) (*models.Video, error) {
 	ctx := context.Background()
 
-	// Format keywords into a PostgreSQL array literal
-	keywords := "{}" // Default to an empty array literal
-	if len(video.Keywords) > 0 {
-		keywords = fmt.Sprintf("{%s}", strings.Join(video.Keywords, ", "))
+	keywords := video.Keywords
+	if keywords == nil {
+		keywords = []string{}
 	}
 
 	video.ID = uuid.New().String()
 
 	// Use QueryRowContext and RETURNING to get the video ID
 	err := db.QueryRowContext(ctx, `
-		INSERT INTO videos (status, resources, title, description, keywords, category, privacy_status, channel_id) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO videos (status, resources, title, description, keywords, category, privacy_status, made_for_kids, default_language, license, embeddable, channel_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, NULLIF($9, ''), $10, $11, $12)
 		RETURNING id`,
-		video.Status, video.Resources, video.Title, video.Description, keywords, video.Category, video.PrivacyStatus, video.Channel.ID,
+		video.Status, video.Resources, video.Title, video.Description, pq.Array(keywords), video.Category,
+		video.PrivacyStatus, video.MadeForKids, video.DefaultLanguage, video.License, video.Embeddable, video.Channel.ID,
 	).Scan(&video.ID)
 
 	if err != nil {
@@ -575,153 +616,112 @@ func (db *DB) CreateVideo(video *models.Video) (*models.Video, error) {
 	return createdVideo, nil
 }
 
-// UpdateVideo updates an existing video
-func (db *DB) UpdateVideo(videoID string, video *models.Video) (*models.Video, error) {
-	ctx := context.Background()
-	query := "UPDATE videos SET"
-	params := []interface{}{}
-	paramCounter := 1
-
-	// Dynamically add fields to the query if they are not empty
-	if video.Status != "" {
-		// Check if the status is valid
-		validStatus := false
-		for _, s := range []models.Status{models.Pending, models.Published, models.Draft} {
-			if video.Status == s {
-				validStatus = true
-				break
-			}
-		}
-
-		if validStatus {
-			query += fmt.Sprintf(" status = $%d,", paramCounter)
-			params = append(params, video.Status)
-			paramCounter++
-		} else {
-			return nil, fmt.Errorf("invalid video status: %s", video.Status)
-		}
-	}
-	if video.Resources != "" {
-		query += fmt.Sprintf(" resources = $%d,", paramCounter)
-		params = append(params, video.Resources)
-		paramCounter++
-	}
-	if video.Title != "" {
-		query += fmt.Sprintf(" title = $%d,", paramCounter)
-		params = append(params, video.Title)
-		paramCounter++
-	}
-	if video.Description != "" {
-		query += fmt.Sprintf(" description = $%d,", paramCounter)
-		params = append(params, video.Description)
-		paramCounter++
-	}
-	if len(video.Keywords) > 0 {
-		keywords := fmt.Sprintf("{%s}", strings.Join(video.Keywords, ", ")) // Remove single quotes
-		query += fmt.Sprintf(" keywords = $%d,", paramCounter)
-		params = append(params, keywords)
-		paramCounter++
-	}
-	if video.Category != "" {
-		query += fmt.Sprintf(" category = $%d,", paramCounter)
-		params = append(params, video.Category)
-		paramCounter++
-	}
-	if video.PrivacyStatus {
-		query += fmt.Sprintf(" privacy_status = $%d,", paramCounter)
-		params = append(params, video.PrivacyStatus)
-		paramCounter++
+// UpdateVideo writes the patchable fields of a video, including the set of
+// editors assigned to it. If expectedVersion is not zero the update only
+// applies to that version of the video. Empty text fields are stored as NULL.
+func (db *DB) UpdateVideo(videoID string, fields *models.VideoFields, expectedVersion int) (*models.Video, error) {
+	err := db.inTx(func(tx *sql.Tx) error {
+		return updateVideoFields(tx, videoID, fields, expectedVersion)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	query += fmt.Sprintf(" updated_at = $%d", paramCounter)
-	params = append(params, time.Now()) // Bind the current time
-	paramCounter++
-
-	// Remove trailing comma and add WHERE clause
-	query = strings.TrimSuffix(query, ",") + fmt.Sprintf(" WHERE id = $%d", paramCounter)
-	params = append(params, videoID)
+	// Fetch the updated video before returning
+	updatedVideo, err := db.GetVideoByID(videoID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching video: %w", err)
+	}
+	return updatedVideo, nil
+}
 
-	// Execute the query
-	result, err := db.ExecContext(ctx, query, params...)
+// updateVideoFields writes the patchable fields of a video. Fields that were
+// accepted from an AI suggestion stop counting as AI-sourced once their value
+// changes.
+func updateVideoFields(tx *sql.Tx, videoID string, fields *models.VideoFields, expectedVersion int) error {
+	keywords := fields.Keywords
+	if keywords == nil {
+		keywords = []string{}
+	}
+	editorIDs := fields.EditorIDs
+	if editorIDs == nil {
+		editorIDs = []string{}
+	}
+
+	result, err := tx.Exec(`
+		UPDATE videos SET status = $1, resources = NULLIF($2, ''), title = NULLIF($3, ''), description = NULLIF($4, ''),
+			keywords = $5, category = NULLIF($6, ''), privacy_status = $7, made_for_kids = $8, default_language = NULLIF($9, ''),
+			license = $10, embeddable = $11, updated_at = NOW(), version = version + 1,
+			ai_fields = ARRAY(SELECT f FROM unnest(ai_fields) AS f WHERE
+				(f <> 'title' OR title IS NOT DISTINCT FROM NULLIF($3, '')) AND
+				(f <> 'description' OR description IS NOT DISTINCT FROM NULLIF($4, '')) AND
+				(f <> 'keywords' OR keywords IS NOT DISTINCT FROM $5))
+		WHERE id = $12 AND deleted_at IS NULL AND ($13 = 0 OR version = $13)`,
+		fields.Status, fields.Resources, fields.Title, fields.Description, pq.Array(keywords), fields.Category, fields.PrivacyStatus,
+		fields.MadeForKids, fields.DefaultLanguage, fields.License, fields.Embeddable, videoID, expectedVersion)
 	if err != nil {
-		return nil, fmt.Errorf("error updating video: %w", err)
+		return fmt.Errorf("error updating video: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("error getting rows affected: %w", err)
+		return fmt.Errorf("error getting rows affected: %w", err)
 	}
 	if rowsAffected == 0 {
-		return nil, ErrNotFound
+		return versionConflict(tx, "videos", videoID)
 	}
 
-	// Update editors assigned to the video
-	// Get the current editors assigned to the video
-	currentEditors, err := db.GetEditorsByVideo(videoID)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching current editors: %w", err)
-	}
-
-	// Compare new editors with current editors
-	for _, newEditor := range video.Editors {
-		// Check if the new editor is already in the current editors list
-		found := false
-		for _, currentEditor := range currentEditors {
-			if newEditor.ID == currentEditor.ID {
-				found = true
-				break
-			}
-		}
+	return setVideoEditors(tx, videoID, editorIDs)
+}
 
-		// If the new editor is not found in the current list, add it
-		if !found {
-			_, err := db.AddEditorToVideo(videoID, newEditor.ID)
-			if err != nil {
-				return nil, fmt.Errorf("error assigning editor to video: %w", err)
-			}
-		}
+// setVideoEditors replaces the editors assigned to a video
+func setVideoEditors(tx *sql.Tx, videoID string, editorIDs []string) error {
+	unique := map[string]bool{}
+	for _, id := range editorIDs {
+		unique[id] = true
 	}
 
-	// Fetch the updated video before returning
-	updatedVideo, err := db.GetVideoByID(videoID)
+	var found int
+	err := tx.QueryRow("SELECT COUNT(*) FROM editors WHERE id = ANY($1::uuid[])", pq.Array(editorIDs)).Scan(&found)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching video: %w", err)
+		return fmt.Errorf("error fetching editors: %w", err)
 	}
-	return updatedVideo, nil
+	if found != len(unique) {
+		return &models.ValidationError{Field: "editorIds", Message: "contains an unknown editor"}
+	}
+
+	if _, err := tx.Exec("DELETE FROM video_editor WHERE video_id = $1 AND editor_id <> ALL($2::uuid[])", videoID, pq.Array(editorIDs)); err != nil {
+		return fmt.Errorf("error removing editors from video: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO video_editor (video_id, editor_id) SELECT $1, id FROM editors WHERE id = ANY($2::uuid[]) ON CONFLICT DO NOTHING", videoID, pq.Array(editorIDs)); err != nil {
+		return fmt.Errorf("error assigning editors to video: %w", err)
+	}
+	return nil
 }
 
-// DeleteVideo deletes an existing video
-func (db *DB) DeleteVideo(videoID string) error {
-	ctx := context.Background()
-	result, err := db.ExecContext(ctx, "DELETE FROM videos WHERE id = $1", videoID)
+// MarkVideoPublished records that a video has been uploaded to YouTube
+func (db *DB) MarkVideoPublished(videoID, youtubeID string) (*models.Video, error) {
+	result, err := db.ExecContext(context.Background(), "UPDATE videos SET status = $1, youtube_id = $2, updated_at = NOW(), version = version + 1 WHERE id = $3 AND deleted_at IS NULL",
+		models.Published, youtubeID, videoID)
 	if err != nil {
-		return fmt.Errorf("error deleting video: %w", err)
+		return nil, fmt.Errorf("error updating video: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("error getting rows affected: %w", err)
+		return nil, fmt.Errorf("error getting rows affected: %w", err)
 	}
 	if rowsAffected == 0 {
-		return ErrNotFound
+		return nil, ErrNotFound
 	}
 
-	return nil
+	return db.GetVideoByID(videoID)
 }
 
 // GetIterationByID retrieves an iteration by ID
 func (db *DB) GetIterationByID(iterationID string) (*models.Iteration, error) {
 	var iteration models.Iteration
-	err := db.QueryRowContext(context.Background(), "SELECT * FROM iterations WHERE id = $1", iterationID).Scan(
-		&iteration.ID,
-		&iteration.Video.ID,
-		&iteration.URL,
-		&iteration.Length,
-		&iteration.Status,
-		&iteration.Notes,
-		&iteration.CreatedAt,
-		&iteration.UpdatedAt,
-	)
+	err := scanIteration(db.QueryRowContext(context.Background(), "SELECT "+iterationColumns+" FROM iterations i WHERE i.id = $1 AND i.deleted_at IS NULL", iterationID), &iteration)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -729,65 +729,81 @@ func (db *DB) GetIterationByID(iterationID string) (*models.Iteration, error) {
 		return nil, fmt.Errorf("error fetching iteration: %w", err)
 	}
 
-	// Fetch the channel data using the channel ID
+	// Fetch the video data using the video ID
 	video, err := db.GetVideoByID(iteration.Video.ID)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching video: %w", err)
 	}
 
-	// Assign the fetched channel to the video
-	iteration.Video = *video // Dereference the channel pointer
+	// Assign the fetched video to the iteration
+	iteration.Video = *video // Dereference the video pointer
 
 	return &iteration, nil
 }
 
-// GetIterations retrieves all iterations
-func (db *DB) GetIterations() ([]models.Iteration, error) {
-	var iterations []models.Iteration
-	rows, err := db.QueryContext(context.Background(), "SELECT * FROM iterations")
+// ListIterations retrieves a page of the iterations of the videos a user can
+// see
+func (db *DB) ListIterations(userID string, params ListParams) (*models.List, error) {
+	q := &listQuery{}
+	q.where("EXISTS (SELECT 1 FROM videos v JOIN channels c ON c.id = v.channel_id WHERE v.id = i.video_id AND "+
+		memberCondition("?", models.PermissionVideoRead)+")", userID)
+	q.where("i.deleted_at IS NULL")
+	if params.Status != "" {
+		q.where("i.status = ?", params.Status)
+	}
+	if params.VideoID != "" {
+		q.where("i.video_id = ?", params.VideoID)
+	}
+	if params.CreatedAfter != nil {
+		q.where("i.created_at > ?", *params.CreatedAfter)
+	}
+
+	var total int
+	if err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM iterations i"+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("error counting iterations: %w", err)
+	}
+
+	page, err := q.page(params, iterationSortFields, "i.id")
 	if err != nil {
-		return nil, fmt.Errorf("error fetching iterations: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var iteration models.Iteration
-		if err := rows.Scan(
-			&iteration.ID,
-			&iteration.Video.ID,
-			&iteration.URL,
-			&iteration.Length,
-			&iteration.Status,
-			&iteration.Notes,
-			&iteration.CreatedAt,
-			&iteration.UpdatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("error scanning iteration: %w", err)
-		}
+	iterations, err := db.queryIterations("SELECT "+iterationColumns+" FROM iterations i"+q.whereClause()+page, q.args...)
+	if err != nil {
+		return nil, err
+	}
 
-		// Fetch the channel data using the channel ID
-		video, err := db.GetVideoByID(iteration.Video.ID)
+	for i := range iterations {
+		// Fetch the video data using the video ID
+		video, err := db.GetVideoByID(iterations[i].Video.ID)
 		if err != nil {
 			return nil, fmt.Errorf("error fetching video: %w", err)
 		}
 
-		// Assign the fetched channel to the video
-		iteration.Video = *video // Dereference the channel pointer
-
-		iterations = append(iterations, iteration)
+		// Assign the fetched video to the iteration
+		iterations[i].Video = *video // Dereference the video pointer
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	list := &models.List{Total: total}
+	if len(iterations) > params.Limit {
+		list.NextCursor = nextCursor(params, iterationSortFields, len(iterations), &iterations[params.Limit-1], iterations[params.Limit-1].ID)
+		iterations = iterations[:params.Limit]
 	}
+	list.Data = iterations
 
-	return iterations, nil
+	return list, nil
 }
 
-// GetIterationsByVideo retrieves iterations by video ID
+// GetIterationsByVideo retrieves iterations by video ID. Only the ID of the
+// video is set on each iteration, as the caller already holds the video.
 func (db *DB) GetIterationsByVideo(videoID string) ([]models.Iteration, error) {
-	var iterations []models.Iteration
-	rows, err := db.QueryContext(context.Background(), "SELECT * FROM iterations WHERE video_id = $1", videoID)
+	return db.queryIterations("SELECT "+iterationColumns+" FROM iterations i WHERE i.video_id = $1 AND i.deleted_at IS NULL ORDER BY i.created_at", videoID)
+}
+
+// queryIterations runs a query selecting iterationColumns
+func (db *DB) queryIterations(query string, args ...interface{}) ([]models.Iteration, error) {
+	iterations := []models.Iteration{}
+	rows, err := db.QueryContext(context.Background(), query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching iterations: %w", err)
 	}
@@ -795,28 +811,10 @@ func (db *DB) GetIterationsByVideo(videoID string) ([]models.Iteration, error) {
 
 	for rows.Next() {
 		var iteration models.Iteration
-		if err := rows.Scan(
-			&iteration.ID,
-			&iteration.Video.ID,
-			&iteration.URL,
-			&iteration.Length,
-			&iteration.Status,
-			&iteration.Notes,
-			&iteration.CreatedAt,
-			&iteration.UpdatedAt,
-		); err != nil {
+		if err := scanIteration(rows, &iteration); err != nil {
 			return nil, fmt.Errorf("error scanning iteration: %w", err)
 		}
 
-		// Fetch the channel data using the channel ID
-		video, err := db.GetVideoByID(iteration.Video.ID)
-		if err != nil {
-			return nil, fmt.Errorf("error fetching video: %w", err)
-		}
-
-		// Assign the fetched channel to the video
-		iteration.Video = *video // Dereference the channel pointer
-
 		iterations = append(iterations, iteration)
 	}
 
@@ -829,84 +827,75 @@ func (db *DB) GetIterationsByVideo(videoID string) ([]models.Iteration, error) {
 
 // CreateIteration creates a new iteration
 func (db *DB) CreateIteration(iteration *models.Iteration) (*models.Iteration, error) {
-	ctx := context.Background()
-	result, err := db.ExecContext(ctx, "INSERT INTO iterations (video_id, url, length, status, notes) VALUES ($1, $2, $3, $4, $5)",
-		iteration.Video.ID, iteration.URL, iteration.Length, iteration.Status, iteration.Notes)
+	err := db.inTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow("INSERT INTO iterations (video_id, url, length, status, notes) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+			iteration.Video.ID, iteration.URL, iteration.Length, iteration.Status, iteration.Notes).Scan(&iteration.ID)
+		if err != nil {
+			return fmt.Errorf("error creating iteration: %w", err)
+		}
+		return bumpIterationVideoVersion(tx, iteration.ID)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error creating iteration: %w", err)
+		return nil, err
 	}
 
-	iterationID, err := result.LastInsertId()
+	// Fetch the iteration before returning
+	createdIteration, err := db.GetIterationByID(iteration.ID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting last insert ID: %w", err)
+		return nil, fmt.Errorf("error fetching iteration: %w", err)
 	}
-	iteration.ID = fmt.Sprintf("%d", iterationID)
-
-	return iteration, nil
+	return createdIteration, nil
 }
 
-// UpdateIteration updates an existing iteration
-func (db *DB) UpdateIteration(iterationID string, iteration *models.Iteration) (*models.Iteration, error) {
-	ctx := context.Background()
-	result, err := db.ExecContext(ctx, "UPDATE iterations SET video_id = $1, url = $2, length = $3, status = $4, notes = $5, updated_at = NOW() WHERE id = $6",
-		iteration.Video.ID, iteration.URL, iteration.Length, iteration.Status, iteration.Notes, iterationID)
-	if err != nil {
-		return nil, fmt.Errorf("error updating iteration: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return nil, fmt.Errorf("error getting rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return nil, ErrNotFound
-	}
-
-	return iteration, nil
-}
+// UpdateIteration writes the patchable fields of an iteration. If
+// expectedVersion is not zero the update only applies to that version of the
+// iteration. Empty text fields are stored as NULL.
+func (db *DB) UpdateIteration(iterationID string, fields *models.IterationFields, expectedVersion int) (*models.Iteration, error) {
+	err := db.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			UPDATE iterations SET url = $1, length = NULLIF($2, ''), status = $3, notes = NULLIF($4, ''), updated_at = NOW(), version = version + 1
+			WHERE id = $5 AND deleted_at IS NULL AND ($6 = 0 OR version = $6)`,
+			fields.URL, fields.Length, fields.Status, fields.Notes, iterationID, expectedVersion)
+		if err != nil {
+			return fmt.Errorf("error updating iteration: %w", err)
+		}
 
-// DeleteIteration deletes an existing iteration
-func (db *DB) DeleteIteration(iterationID string) error {
-	ctx := context.Background()
-	result, err := db.ExecContext(ctx, "DELETE FROM iterations WHERE id = $1", iterationID)
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return versionConflict(tx, "iterations", iterationID)
+		}
+		return bumpIterationVideoVersion(tx, iterationID)
+	})
 	if err != nil {
-		return fmt.Errorf("error deleting iteration: %w", err)
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	// Fetch the updated iteration before returning
+	updatedIteration, err := db.GetIterationByID(iterationID)
 	if err != nil {
-		return fmt.Errorf("error getting rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return ErrNotFound
+		return nil, fmt.Errorf("error fetching iteration: %w", err)
 	}
-
-	return nil
+	return updatedIteration, nil
 }
 
 // AddNoteToIteration adds a note to an iteration
 func (db *DB) AddNoteToIteration(iterationID string, note *models.Note) error {
-	ctx := context.Background()
-	_, err := db.ExecContext(ctx, "UPDATE iterations SET notes = $1 WHERE id = $2", note.Content, iterationID)
-	if err != nil {
-		return fmt.Errorf("error adding note to iteration: %w", err)
-	}
-	return nil
+	return db.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE iterations SET notes = $1, updated_at = NOW(), version = version + 1 WHERE id = $2 AND deleted_at IS NULL", note.Content, iterationID)
+		if err != nil {
+			return fmt.Errorf("error adding note to iteration: %w", err)
+		}
+		return bumpIterationVideoVersion(tx, iterationID)
+	})
 }
 
 // GetEditorByID retrieves an editor by ID
 func (db *DB) GetEditorByID(editorID string) (*models.Editor, error) {
 	var editor models.Editor
-	err := db.QueryRowContext(context.Background(), "SELECT * FROM editors WHERE id = $1", editorID).Scan(
-		&editor.ID,
-		&editor.Username,
-		&editor.Email,
-		&editor.Password,
-		&editor.CreatedAt,
-		&editor.UpdatedAt,
-		&editor.Tier,
-		&editor.Trial,
-	)
+	err := scanEditor(db.QueryRowContext(context.Background(), "SELECT "+editorColumns+" FROM editors e WHERE e.id = $1", editorID), &editor)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -917,95 +906,120 @@ func (db *DB) GetEditorByID(editorID string) (*models.Editor, error) {
 	return &editor, nil
 }
 
-// GetEditors retrieves all editors
-func (db *DB) GetEditors() ([]models.Editor, error) {
-	var editors []models.Editor
-	rows, err := db.QueryContext(context.Background(), "SELECT * FROM editors")
-	if err != nil {
-		return nil, fmt.Errorf("error fetching editors: %w", err)
+// ListEditors retrieves a page of editors
+func (db *DB) ListEditors(params ListParams) (*models.List, error) {
+	q := &listQuery{}
+	if params.Tier != "" {
+		q.where("e.tier = ?", params.Tier)
+	}
+	if params.VideoID != "" {
+		q.where("EXISTS (SELECT 1 FROM video_editor ve WHERE ve.editor_id = e.id AND ve.video_id = ?)", params.VideoID)
+	}
+	if params.CreatedAfter != nil {
+		q.where("e.created_at > ?", *params.CreatedAfter)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var editor models.Editor
-		if err := rows.Scan(
-			&editor.ID,
-			&editor.Username,
-			&editor.Email,
-			&editor.Password,
-			&editor.CreatedAt,
-			&editor.UpdatedAt,
-			&editor.Tier,
-			&editor.Trial,
-		); err != nil {
-			return nil, fmt.Errorf("error scanning editor: %w", err)
-		}
+	var total int
+	if err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM editors e"+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("error counting editors: %w", err)
+	}
 
-		editors = append(editors, editor)
+	page, err := q.page(params, editorSortFields, "e.id")
+	if err != nil {
+		return nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	editors, err := db.queryEditors("SELECT "+editorColumns+" FROM editors e"+q.whereClause()+page, q.args...)
+	if err != nil {
+		return nil, err
 	}
 
-	return editors, nil
+	list := &models.List{Total: total}
+	if len(editors) > params.Limit {
+		list.NextCursor = nextCursor(params, editorSortFields, len(editors), &editors[params.Limit-1], editors[params.Limit-1].ID)
+		editors = editors[:params.Limit]
+	}
+	list.Data = editors
+
+	return list, nil
 }
 
-// CreateEditor creates a new editor
-func (db *DB) CreateEditor(editor *models.Editor) (*models.Editor, error) {
+// CreateEditor creates a new editor on a trial of trialTier that lasts for
+// trialLength, or on the free tier if trialLength is zero
+func (db *DB) CreateEditor(editor *models.Editor, trialTier models.Tier, trialLength time.Duration) (*models.Editor, error) {
 	ctx := context.Background()
-	result, err := db.ExecContext(ctx, "INSERT INTO editors (username, email, password, tier, trial) VALUES ($1, $2, $3, $4, $5)",
-		editor.Username, editor.Email, editor.Password, editor.Tier, editor.Trial)
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO editors (username, email, password, tier, trial, trial_started_at, trial_ends_at)
+		SELECT $1, $2, $3, CASE WHEN t.trial THEN $4 ELSE 'free' END, t.trial,
+			CASE WHEN t.trial THEN NOW() END, CASE WHEN t.trial THEN NOW() + $5::bigint * INTERVAL '1 second' END
+		FROM (SELECT $5::bigint > 0 AS trial) t
+		RETURNING id`,
+		editor.Username, editor.Email, editor.Password, trialTier, int64(trialLength.Seconds())).Scan(&editor.ID)
 	if err != nil {
 		return nil, fmt.Errorf("error creating editor: %w", err)
 	}
 
-	editorID, err := result.LastInsertId()
+	// Fetch the editor before returning
+	createdEditor, err := db.GetEditorByID(editor.ID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting last insert ID: %w", err)
+		return nil, fmt.Errorf("error fetching editor: %w", err)
 	}
-	editor.ID = fmt.Sprintf("%d", editorID)
-
-	return editor, nil
+	return createdEditor, nil
 }
 
-// UpdateEditor updates an existing editor
-func (db *DB) UpdateEditor(editorID string, editor *models.Editor) (*models.Editor, error) {
-	ctx := context.Background()
-	result, err := db.ExecContext(ctx, "UPDATE editors SET username = $1, email = $2, password = $3, tier = $4, trial = $5, updated_at = NOW() WHERE id = $6",
-		editor.Username, editor.Email, editor.Password, editor.Tier, editor.Trial, editorID)
+// UpdateEditor writes the patchable fields of an editor. The tier is left
+// as is.
+func (db *DB) UpdateEditor(editorID string, fields *models.EditorFields) (*models.Editor, error) {
+	err := db.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec("UPDATE editors SET username = $1, email = $2, password = $3, updated_at = NOW() WHERE id = $4",
+			fields.Username, fields.Email, fields.Password, editorID)
+		if err != nil {
+			return fmt.Errorf("error updating editor: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return bumpEditorVideoVersions(tx, editorID)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error updating editor: %w", err)
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	// Fetch the updated editor before returning
+	updatedEditor, err := db.GetEditorByID(editorID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return nil, ErrNotFound
+		return nil, fmt.Errorf("error fetching editor: %w", err)
 	}
-
-	return editor, nil
+	return updatedEditor, nil
 }
 
 // DeleteEditor deletes an existing editor
 func (db *DB) DeleteEditor(editorID string) error {
-	ctx := context.Background()
-	result, err := db.ExecContext(ctx, "DELETE FROM editors WHERE id = $1", editorID)
-	if err != nil {
-		return fmt.Errorf("error deleting editor: %w", err)
-	}
+	return db.inTx(func(tx *sql.Tx) error {
+		// Bump the videos first, deleting the editor unassigns it
+		if err := bumpEditorVideoVersions(tx, editorID); err != nil {
+			return err
+		}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("error getting rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return ErrNotFound
-	}
+		result, err := tx.Exec("DELETE FROM editors WHERE id = $1", editorID)
+		if err != nil {
+			return fmt.Errorf("error deleting editor: %w", err)
+		}
 
-	return nil
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
 }
 
 // AddEditorToVideo assigns an editor to a video
@@ -1026,8 +1040,13 @@ func (db *DB) RemoveEditorsFromVideo(videoID string) error {
 
 // GetEditorsByVideo retrieves editors assigned to a video
 func (db *DB) GetEditorsByVideo(videoID string) ([]models.Editor, error) {
-	var editors []models.Editor
-	rows, err := db.QueryContext(context.Background(), "SELECT e.* FROM video_editor ve JOIN editors e ON ve.editor_id = e.id WHERE ve.video_id = $1", videoID)
+	return db.queryEditors("SELECT "+editorColumns+" FROM video_editor ve JOIN editors e ON ve.editor_id = e.id WHERE ve.video_id = $1", videoID)
+}
+
+// queryEditors runs a query selecting editorColumns
+func (db *DB) queryEditors(query string, args ...interface{}) ([]models.Editor, error) {
+	editors := []models.Editor{}
+	rows, err := db.QueryContext(context.Background(), query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching editors: %w", err)
 	}
@@ -1035,16 +1054,7 @@ func (db *DB) GetEditorsByVideo(videoID string) ([]models.Editor, error) {
 
 	for rows.Next() {
 		var editor models.Editor
-		if err := rows.Scan(
-			&editor.ID,
-			&editor.Username,
-			&editor.Email,
-			&editor.Password,
-			&editor.CreatedAt,
-			&editor.UpdatedAt,
-			&editor.Tier,
-			&editor.Trial,
-		); err != nil {
+		if err := scanEditor(rows, &editor); err != nil {
 			return nil, fmt.Errorf("error scanning editor: %w", err)
 		}
 
@@ -1058,19 +1068,10 @@ func (db *DB) GetEditorsByVideo(videoID string) ([]models.Editor, error) {
 	return editors, nil
 }
 
-// GetOwner retrieves the owner of a video, channel, or iteration
+// GetOwner retrieves the owner of a video, channel, or iteration, see channelOwner
 func (db *DB) GetOwner(userID string) (*models.User, error) {
 	var user models.User
-	err := db.QueryRowContext(context.Background(), "SELECT * FROM users WHERE id = $1", userID).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.Tier,
-		&user.Trial,
-	)
+	err := scanUser(db.QueryRowContext(context.Background(), "SELECT "+userColumns+" FROM users u WHERE u.id = $1", userID), &user)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
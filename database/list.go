@@ -0,0 +1,125 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort field")
+)
+
+// ListParams holds the pagination, sorting and filtering options of a list query.
+// Filters that don't apply to a resource are ignored.
+type ListParams struct {
+	Limit  int
+	Cursor string
+	// Sort is a whitelisted field name, prefixed with "-" for descending order
+	Sort string
+
+	Status       string
+	ChannelID    string
+	EditorID     string
+	VideoID      string
+	Category     string
+	Tier         string
+	CreatedAfter *time.Time
+}
+
+// sortField maps a public sort name to the SQL expression it orders by and
+// the value of that expression for a returned row
+type sortField struct {
+	column string
+	value  func(item interface{}) string
+}
+
+// cursor is the position after which the next page starts
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// listQuery accumulates the conditions and arguments of a list query
+type listQuery struct {
+	conditions []string
+	args       []interface{}
+}
+
+// where adds a condition, replacing each ? with the next positional parameter
+func (q *listQuery) where(condition string, args ...interface{}) {
+	for _, arg := range args {
+		q.args = append(q.args, arg)
+		condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(q.args)), 1)
+	}
+	q.conditions = append(q.conditions, condition)
+}
+
+// whereClause returns the accumulated conditions as a WHERE clause
+func (q *listQuery) whereClause() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// page restricts the query to the rows after the cursor and returns the
+// ORDER BY and LIMIT clauses. One extra row is fetched to detect a next page.
+func (q *listQuery) page(params ListParams, fields map[string]sortField, idColumn string) (string, error) {
+	name, desc := strings.TrimPrefix(params.Sort, "-"), strings.HasPrefix(params.Sort, "-")
+	field, ok := fields[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrInvalidSort, name)
+	}
+
+	direction, comparison := "ASC", ">"
+	if desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if params.Cursor != "" {
+		c, err := decodeCursor(params.Cursor)
+		if err != nil {
+			return "", err
+		}
+		if c.Sort != params.Sort {
+			return "", fmt.Errorf("%w: cursor was issued for a different sort", ErrInvalidCursor)
+		}
+		q.where(fmt.Sprintf("(%s, %s) %s (?, ?)", field.column, idColumn, comparison), c.Value, c.ID)
+	}
+
+	q.args = append(q.args, params.Limit+1)
+	return fmt.Sprintf(" ORDER BY %s %s, %s %s LIMIT $%d", field.column, direction, idColumn, direction, len(q.args)), nil
+}
+
+// nextCursor returns the cursor following the last item of a page, or an
+// empty string if there are no more rows
+func nextCursor(params ListParams, fields map[string]sortField, fetched int, last interface{}, lastID string) string {
+	if fetched <= params.Limit {
+		return ""
+	}
+	field := fields[strings.TrimPrefix(params.Sort, "-")]
+	return encodeCursor(cursor{Sort: params.Sort, Value: field.value(last), ID: lastID})
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testSortFields sort items that are their own sort value
+var testSortFields = map[string]sortField{
+	"createdAt": {column: "v.created_at", value: func(item interface{}) string { return item.(string) }},
+	"title":     {column: "v.title", value: func(item interface{}) string { return item.(string) }},
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, c := range []cursor{
+		{Sort: "-createdAt", Value: "2024-01-31T23:59:59.999999Z", ID: "0b9f4c0e-7f1e-4c43-9d35-7c1c2a0f6a11"},
+		{Sort: "title", Value: "Ünïcode / & \"quotes\"", ID: "1"},
+		{Sort: "title", Value: "", ID: "2"},
+	} {
+		encoded := encodeCursor(c)
+		decoded, err := decodeCursor(encoded)
+		if err != nil {
+			t.Errorf("decodeCursor(%q): %v", encoded, err)
+			continue
+		}
+		if *decoded != c {
+			t.Errorf("decodeCursor(encodeCursor(%+v)) = %+v", c, *decoded)
+		}
+	}
+}
+
+func TestDecodeInvalidCursor(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"s":"title","v":"a"}`)),
+		base64.RawURLEncoding.EncodeToString([]byte(`["title","a","1"]`)),
+	} {
+		if c, err := decodeCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("decodeCursor(%q) = %+v, %v", s, c, err)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   ListParams
+		want     string
+		wantArgs []interface{}
+		wantErr  error
+	}{
+		{
+			name:     "first page",
+			params:   ListParams{Limit: 20, Sort: "title"},
+			want:     " ORDER BY v.title ASC, v.id ASC LIMIT $1",
+			wantArgs: []interface{}{21},
+		},
+		{
+			name:     "descending after a cursor",
+			params:   ListParams{Limit: 10, Sort: "-createdAt", Cursor: encodeCursor(cursor{Sort: "-createdAt", Value: "2024-02-01", ID: "5"})},
+			want:     " WHERE (v.created_at, v.id) < ($1, $2) ORDER BY v.created_at DESC, v.id DESC LIMIT $3",
+			wantArgs: []interface{}{"2024-02-01", "5", 11},
+		},
+		{
+			name:    "cursor of another sort",
+			params:  ListParams{Limit: 10, Sort: "title", Cursor: encodeCursor(cursor{Sort: "-title", Value: "a", ID: "5"})},
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "invalid cursor",
+			params:  ListParams{Limit: 10, Sort: "title", Cursor: "garbage"},
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "unknown sort",
+			params:  ListParams{Limit: 10, Sort: "-password"},
+			wantErr: ErrInvalidSort,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var q listQuery
+			clauses, err := q.page(tt.params, testSortFields, "v.id")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("page = %q, %v, want %v", clauses, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("page: %v", err)
+			}
+			if got := q.whereClause() + clauses; got != tt.want {
+				t.Errorf("page = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(q.args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", q.args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestNextCursor(t *testing.T) {
+	params := ListParams{Limit: 2, Sort: "-title"}
+	if got := nextCursor(params, testSortFields, 2, "b", "2"); got != "" {
+		t.Errorf("last page has cursor %q", got)
+	}
+
+	next := nextCursor(params, testSortFields, 3, "b", "2")
+	c, err := decodeCursor(next)
+	if err != nil {
+		t.Fatalf("decodeCursor(%q): %v", next, err)
+	}
+	if want := (cursor{Sort: "-title", Value: "b", ID: "2"}); *c != want {
+		t.Errorf("next cursor is %+v, want %+v", *c, want)
+	}
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+const localizationColumns = "l.language, l.title, l.description, l.ai_generated, l.needs_review, l.updated_at"
+
+func scanLocalization(row rowScanner, localization *models.Localization) error {
+	return row.Scan(
+		&localization.Language,
+		&localization.Title,
+		&localization.Description,
+		&localization.AIGenerated,
+		&localization.NeedsReview,
+		&localization.UpdatedAt,
+	)
+}
+
+// GetLocalizationsByVideo retrieves the localizations of a video
+func (db *DB) GetLocalizationsByVideo(videoID string) ([]models.Localization, error) {
+	localizations := []models.Localization{}
+	rows, err := db.QueryContext(context.Background(), "SELECT "+localizationColumns+" FROM video_localizations l WHERE l.video_id = $1 ORDER BY l.language", videoID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching localizations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var localization models.Localization
+		if err := scanLocalization(rows, &localization); err != nil {
+			return nil, fmt.Errorf("error scanning localization: %w", err)
+		}
+		localizations = append(localizations, localization)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return localizations, nil
+}
+
+// PutLocalization creates or replaces the localization of a video in a
+// language and returns the new version of the video. Localizations written
+// this way are considered reviewed.
+func (db *DB) PutLocalization(videoID string, localization *models.Localization, expectedVersion int) (*models.Localization, int, error) {
+	var saved models.Localization
+	var version int
+	err := db.inTx(func(tx *sql.Tx) error {
+		var err error
+		if version, err = bumpVideoVersion(tx, videoID, expectedVersion); err != nil {
+			return err
+		}
+		return scanLocalization(tx.QueryRow(`
+			INSERT INTO video_localizations AS l (video_id, language, title, description)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (video_id, language) DO UPDATE
+			SET title = EXCLUDED.title, description = EXCLUDED.description, ai_generated = FALSE, needs_review = FALSE, updated_at = NOW()
+			RETURNING `+localizationColumns,
+			videoID, localization.Language, localization.Title, localization.Description), &saved)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &saved, version, nil
+}
+
+// AddAILocalizations stores localizations filled in by the AI service,
+// flagged for review, and returns the new version of the video. Languages the
+// video already has a localization for are left alone.
+func (db *DB) AddAILocalizations(videoID string, localizations []models.Localization, expectedVersion int) (int, error) {
+	var version int
+	err := db.inTx(func(tx *sql.Tx) error {
+		var err error
+		if version, err = bumpVideoVersion(tx, videoID, expectedVersion); err != nil {
+			return err
+		}
+		for _, localization := range localizations {
+			_, err := tx.Exec(`
+				INSERT INTO video_localizations (video_id, language, title, description, ai_generated, needs_review)
+				VALUES ($1, $2, $3, $4, TRUE, TRUE)
+				ON CONFLICT (video_id, language) DO NOTHING`,
+				videoID, localization.Language, localization.Title, localization.Description)
+			if err != nil {
+				return fmt.Errorf("error creating localization: %w", err)
+			}
+		}
+		return nil
+	})
+	return version, err
+}
+
+// ApproveLocalization clears the review flag of a localization and returns
+// the new version of the video
+func (db *DB) ApproveLocalization(videoID, language string, expectedVersion int) (*models.Localization, int, error) {
+	var approved models.Localization
+	var version int
+	err := db.inTx(func(tx *sql.Tx) error {
+		var err error
+		if version, err = bumpVideoVersion(tx, videoID, expectedVersion); err != nil {
+			return err
+		}
+		err = scanLocalization(tx.QueryRow("UPDATE video_localizations l SET needs_review = FALSE, updated_at = NOW() WHERE l.video_id = $1 AND l.language = $2 RETURNING "+localizationColumns,
+			videoID, language), &approved)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &approved, version, nil
+}
+
+// DeleteLocalization deletes the localization of a video in a language and
+// returns the new version of the video
+func (db *DB) DeleteLocalization(videoID, language string, expectedVersion int) (int, error) {
+	var version int
+	err := db.inTx(func(tx *sql.Tx) error {
+		var err error
+		if version, err = bumpVideoVersion(tx, videoID, expectedVersion); err != nil {
+			return err
+		}
+		result, err := tx.Exec("DELETE FROM video_localizations WHERE video_id = $1 AND language = $2", videoID, language)
+		if err != nil {
+			return fmt.Errorf("error deleting localization: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return version, err
+}
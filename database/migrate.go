@@ -0,0 +1,355 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the key of the Postgres advisory lock held while
+// migrating, so that concurrently starting instances apply migrations once.
+const migrationLockID int64 = 7301152094
+
+var (
+	ErrDirtyMigration   = errors.New("database is in a dirty migration state")
+	ErrMissingMigration = errors.New("missing migration")
+)
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change with its up and down scripts
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes the schema version of the database
+type MigrationStatus struct {
+	Version    int
+	Dirty      bool
+	Migrations []Migration
+}
+
+// Latest returns the newest version known to the binary
+func (s *MigrationStatus) Latest() int {
+	if len(s.Migrations) == 0 {
+		return 0
+	}
+	return s.Migrations[len(s.Migrations)-1].Version
+}
+
+// LoadMigrations reads the embedded migrations and checks that every version
+// from 1 up to the newest one has both an up and a down script
+func LoadMigrations() ([]Migration, error) {
+	dir, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %w", err)
+	}
+	return loadMigrations(dir)
+}
+
+// loadMigrations reads the migrations of a directory, see LoadMigrations
+func loadMigrations(dir fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(dir, ".")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		matches := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, err := strconv.Atoi(matches[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version: %s", entry.Name())
+		}
+		key := fmt.Sprintf("%d.%s", version, matches[3])
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate %s migration for version %d", matches[3], version)
+		}
+		seen[key] = true
+
+		contents, err := fs.ReadFile(dir, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != matches[2] {
+			return nil, fmt.Errorf("conflicting names for migration %d: %s and %s", version, migration.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("%w: version %d", ErrMissingMigration, i+1)
+		}
+		if !seen[fmt.Sprintf("%d.up", migration.Version)] {
+			return nil, fmt.Errorf("%w: %d_%s.up.sql", ErrMissingMigration, migration.Version, migration.Name)
+		}
+		if !seen[fmt.Sprintf("%d.down", migration.Version)] {
+			return nil, fmt.Errorf("%w: %d_%s.down.sql", ErrMissingMigration, migration.Version, migration.Name)
+		}
+	}
+
+	return migrations, nil
+}
+
+// migrationTarget chooses the version to migrate to from the current one
+type migrationTarget func(current int, migrations []Migration) (int, error)
+
+// latestVersion targets the newest migration
+func latestVersion(current int, migrations []Migration) (int, error) {
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// stepsBack targets the version the given number of migrations below the
+// current one, stopping at 0
+func stepsBack(steps int) migrationTarget {
+	return func(current int, migrations []Migration) (int, error) {
+		if steps > current {
+			return 0, nil
+		}
+		return current - steps, nil
+	}
+}
+
+// exactVersion targets a version, which must be 0 or a known migration
+func exactVersion(version int) migrationTarget {
+	return func(current int, migrations []Migration) (int, error) {
+		if version < 0 || version > len(migrations) {
+			return 0, fmt.Errorf("%w: version %d", ErrMissingMigration, version)
+		}
+		return version, nil
+	}
+}
+
+// MigrateUp applies every pending migration
+func (db *DB) MigrateUp(ctx context.Context) error {
+	return db.migrate(ctx, latestVersion)
+}
+
+// MigrateDown rolls back the given number of applied migrations
+func (db *DB) MigrateDown(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("invalid number of steps: %d", steps)
+	}
+	return db.migrate(ctx, stepsBack(steps))
+}
+
+// MigrateTo migrates up or down until the schema is at the given version
+func (db *DB) MigrateTo(ctx context.Context, version int) error {
+	return db.migrate(ctx, exactVersion(version))
+}
+
+// ForceMigrationVersion records the given version as clean without running
+// any migration. It is the way out of a dirty state after manual repair.
+func (db *DB) ForceMigrationVersion(ctx context.Context, version int) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+	if version < 0 || version > len(migrations) {
+		return fmt.Errorf("%w: version %d", ErrMissingMigration, version)
+	}
+
+	return db.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("error starting transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if err := setMigrationVersion(ctx, tx, version); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// GetMigrationStatus returns the current schema version along with the
+// migrations embedded in the binary
+func (db *DB) GetMigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{Migrations: migrations}
+	err = db.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		status.Version, status.Dirty, err = migrationVersion(ctx, conn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// migrate moves the schema to the version chosen by target, one migration
+// per transaction, while holding the migration lock
+func (db *DB) migrate(ctx context.Context, target migrationTarget) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return db.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		current, dirty, err := migrationVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		steps, err := planMigration(migrations, current, dirty, target)
+		if err != nil {
+			return err
+		}
+
+		for _, step := range steps {
+			script, verb := step.migration.Up, "applying"
+			if !step.up {
+				script, verb = step.migration.Down, "reverting"
+			}
+			if err := applyMigration(ctx, conn, script, step.version); err != nil {
+				return fmt.Errorf("error %s migration %d_%s: %w", verb, step.migration.Version, step.migration.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// migrationStep runs the up or down script of a migration, leaving the
+// schema at version
+type migrationStep struct {
+	migration Migration
+	up        bool
+	version   int
+}
+
+// planMigration returns the scripts that take the schema from the current
+// version to the one chosen by target, in the order they run
+func planMigration(migrations []Migration, current int, dirty bool, target migrationTarget) ([]migrationStep, error) {
+	if dirty {
+		return nil, fmt.Errorf("%w: version %d", ErrDirtyMigration, current)
+	}
+	if current > len(migrations) {
+		return nil, fmt.Errorf("%w: database is at version %d but the newest known migration is %d", ErrMissingMigration, current, len(migrations))
+	}
+
+	version, err := target(current, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []migrationStep
+	for ; current < version; current++ {
+		steps = append(steps, migrationStep{migration: migrations[current], up: true, version: current + 1})
+	}
+	for ; current > version; current-- {
+		steps = append(steps, migrationStep{migration: migrations[current-1], version: current - 1})
+	}
+	return steps, nil
+}
+
+// withMigrationLock runs fn on a dedicated connection holding the migration
+// advisory lock. The schema_migrations table is created if needed.
+func (db *DB) withMigrationLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("error acquiring migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	// Same layout as golang-migrate so databases migrated with the external
+	// tool keep their version
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)"); err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// applyMigration runs a migration script and records the resulting version
+// in the same transaction
+func applyMigration(ctx context.Context, conn *sql.Conn, script string, version int) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if err := setMigrationVersion(ctx, tx, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// migrationVersion reads the recorded schema version, 0 meaning none
+func migrationVersion(ctx context.Context, conn *sql.Conn) (int, bool, error) {
+	var version int
+	var dirty bool
+	err := conn.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("error fetching migration version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// setMigrationVersion replaces the recorded schema version with a clean one
+func setMigrationVersion(ctx context.Context, tx *sql.Tx, version int) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return fmt.Errorf("error clearing migration version: %w", err)
+	}
+	if version == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, FALSE)", version); err != nil {
+		return fmt.Errorf("error recording migration version: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// migrationDir returns a directory with a script for each name, made of a
+// comment with the name
+func migrationDir(names ...string) fstest.MapFS {
+	dir := fstest.MapFS{}
+	for _, name := range names {
+		dir[name] = &fstest.MapFile{Data: []byte("-- " + name)}
+	}
+	return dir
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationDir(
+		"10_ten.down.sql", "10_ten.up.sql",
+		"2_two.up.sql", "2_two.down.sql",
+		"1_one.up.sql", "1_one.down.sql",
+		"3_three.up.sql", "3_three.down.sql",
+		"4_four.up.sql", "4_four.down.sql",
+		"5_five.up.sql", "5_five.down.sql",
+		"6_six.up.sql", "6_six.down.sql",
+		"7_seven.up.sql", "7_seven.down.sql",
+		"8_eight.up.sql", "8_eight.down.sql",
+		"9_nine.up.sql", "9_nine.down.sql",
+	))
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+
+	// Versions are ordered as numbers, not as file names
+	var versions []int
+	for _, migration := range migrations {
+		versions = append(versions, migration.Version)
+	}
+	if want := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("versions = %v, want %v", versions, want)
+	}
+	if ten := migrations[9]; ten.Name != "ten" || ten.Up != "-- 10_ten.up.sql" || ten.Down != "-- 10_ten.down.sql" {
+		t.Errorf("migration 10 = %+v", ten)
+	}
+	if latest := (&MigrationStatus{Migrations: migrations}).Latest(); latest != 10 {
+		t.Errorf("Latest = %d, want 10", latest)
+	}
+}
+
+func TestLoadInvalidMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		wantErr error
+	}{
+		{"gap", []string{"1_a.up.sql", "1_a.down.sql", "3_c.up.sql", "3_c.down.sql"}, ErrMissingMigration},
+		{"no version 1", []string{"2_b.up.sql", "2_b.down.sql"}, ErrMissingMigration},
+		{"no down script", []string{"1_a.up.sql", "1_a.down.sql", "2_b.up.sql"}, ErrMissingMigration},
+		{"no up script", []string{"1_a.down.sql"}, ErrMissingMigration},
+		{"invalid name", []string{"1_a.up.sql", "1_a.down.sql", "README.md"}, nil},
+		{"version 0", []string{"0_init.up.sql", "0_init.down.sql"}, nil},
+		{"duplicate version", []string{"1_a.up.sql", "01_a.up.sql", "1_a.down.sql"}, nil},
+		{"conflicting names", []string{"1_a.up.sql", "1_b.down.sql"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(migrationDir(tt.files...))
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("loadMigrations = %+v, %v", migrations, err)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations are embedded")
+	}
+	for _, migration := range migrations {
+		if migration.Up == "" || migration.Down == "" {
+			t.Errorf("migration %d_%s has an empty script", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestPlanMigration(t *testing.T) {
+	migrations, err := loadMigrations(migrationDir(
+		"1_a.up.sql", "1_a.down.sql",
+		"2_b.up.sql", "2_b.down.sql",
+		"3_c.up.sql", "3_c.down.sql",
+	))
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+
+	// plan describes a step as the script it runs and the version it leaves
+	type plan struct {
+		script  string
+		version int
+	}
+	tests := []struct {
+		name    string
+		current int
+		dirty   bool
+		target  migrationTarget
+		want    []plan
+		wantErr error
+	}{
+		{"up from empty", 0, false, latestVersion, []plan{{"1_a.up", 1}, {"2_b.up", 2}, {"3_c.up", 3}}, nil},
+		{"up from the middle", 2, false, latestVersion, []plan{{"3_c.up", 3}}, nil},
+		{"up to date", 3, false, latestVersion, nil, nil},
+		{"down one", 3, false, stepsBack(1), []plan{{"3_c.down", 2}}, nil},
+		{"down past the start", 2, false, stepsBack(5), []plan{{"2_b.down", 1}, {"1_a.down", 0}}, nil},
+		{"to a lower version", 3, false, exactVersion(1), []plan{{"3_c.down", 2}, {"2_b.down", 1}}, nil},
+		{"to a higher version", 1, false, exactVersion(2), []plan{{"2_b.up", 2}}, nil},
+		{"to the current version", 2, false, exactVersion(2), nil, nil},
+		{"to an unknown version", 0, false, exactVersion(4), nil, ErrMissingMigration},
+		{"to a negative version", 2, false, exactVersion(-1), nil, ErrMissingMigration},
+		{"dirty", 2, true, latestVersion, nil, ErrDirtyMigration},
+		{"newer than the binary", 4, false, stepsBack(1), nil, ErrMissingMigration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := planMigration(migrations, tt.current, tt.dirty, tt.target)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("planMigration error = %v, want %v", err, tt.wantErr)
+			}
+
+			var got []plan
+			for _, step := range steps {
+				script, direction := step.migration.Up, "up"
+				if !step.up {
+					script, direction = step.migration.Down, "down"
+				}
+				name := fmt.Sprintf("%d_%s.%s", step.migration.Version, step.migration.Name, direction)
+				if script != "-- "+name+".sql" {
+					t.Errorf("step %s runs %q", name, script)
+				}
+				got = append(got, plan{name, step.version})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planMigration = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+ALTER TABLE iterations DROP COLUMN version;
+ALTER TABLE videos DROP COLUMN version;
+ALTER TABLE channels DROP COLUMN version;
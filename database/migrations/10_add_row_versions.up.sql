@@ -0,0 +1,3 @@
+ALTER TABLE channels ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE videos ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE iterations ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,11 @@
+ALTER TABLE videos
+  DROP COLUMN made_for_kids,
+  DROP COLUMN default_language,
+  DROP COLUMN license,
+  DROP COLUMN embeddable;
+
+ALTER TABLE videos ALTER COLUMN privacy_status DROP NOT NULL;
+ALTER TABLE videos ALTER COLUMN privacy_status DROP DEFAULT;
+ALTER TABLE videos ALTER COLUMN privacy_status TYPE BOOLEAN
+  USING privacy_status = 'private';
+ALTER TABLE videos ALTER COLUMN privacy_status SET DEFAULT FALSE;
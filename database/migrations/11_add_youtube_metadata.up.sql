@@ -0,0 +1,27 @@
+-- privacy_status used to be a flag where TRUE meant private
+ALTER TABLE videos ALTER COLUMN privacy_status DROP DEFAULT;
+ALTER TABLE videos ALTER COLUMN privacy_status TYPE VARCHAR(16)
+  USING CASE WHEN privacy_status THEN 'private' ELSE 'public' END;
+ALTER TABLE videos ALTER COLUMN privacy_status SET DEFAULT 'private';
+ALTER TABLE videos ALTER COLUMN privacy_status SET NOT NULL;
+
+ALTER TABLE videos
+  ADD COLUMN made_for_kids BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN default_language VARCHAR(35),
+  ADD COLUMN license VARCHAR(32) NOT NULL DEFAULT 'youtube',
+  ADD COLUMN embeddable BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Categories used to be free text. Map the names of YouTube's assignable
+-- categories onto their IDs and drop anything else.
+UPDATE videos v SET category = c.id
+FROM (VALUES
+  ('1', 'film & animation'), ('2', 'autos & vehicles'), ('10', 'music'),
+  ('15', 'pets & animals'), ('17', 'sports'), ('19', 'travel & events'),
+  ('20', 'gaming'), ('22', 'people & blogs'), ('23', 'comedy'),
+  ('24', 'entertainment'), ('25', 'news & politics'), ('26', 'howto & style'),
+  ('27', 'education'), ('28', 'science & technology'), ('29', 'nonprofits & activism')
+) AS c(id, name)
+WHERE lower(trim(v.category)) = c.name;
+
+UPDATE videos SET category = NULL
+WHERE category NOT IN ('1', '2', '10', '15', '17', '19', '20', '22', '23', '24', '25', '26', '27', '28', '29');
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS thumbnails;
+ALTER TABLE videos DROP COLUMN youtube_id;
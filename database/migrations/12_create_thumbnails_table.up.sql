@@ -0,0 +1,17 @@
+ALTER TABLE videos ADD COLUMN youtube_id VARCHAR(32);
+
+CREATE TABLE thumbnails (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+  content_type VARCHAR(32) NOT NULL,
+  width INTEGER NOT NULL,
+  height INTEGER NOT NULL,
+  size INTEGER NOT NULL,
+  data BYTEA NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX thumbnails_video_id_idx ON thumbnails (video_id);
+-- A video has at most one active thumbnail
+CREATE UNIQUE INDEX thumbnails_active_idx ON thumbnails (video_id) WHERE active;
@@ -0,0 +1,7 @@
+CREATE TABLE chapters (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+  start_seconds INTEGER NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  UNIQUE (video_id, start_seconds)
+);
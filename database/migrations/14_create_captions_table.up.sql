@@ -0,0 +1,16 @@
+CREATE TABLE captions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+  -- Tracks without an iteration follow the latest one
+  iteration_id UUID REFERENCES iterations(id) ON DELETE CASCADE,
+  language VARCHAR(35) NOT NULL,
+  name VARCHAR(150) NOT NULL DEFAULT '',
+  format VARCHAR(8) NOT NULL,
+  content TEXT NOT NULL,
+  cue_count INTEGER NOT NULL,
+  youtube_id VARCHAR(64),
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX captions_video_id_idx ON captions (video_id);
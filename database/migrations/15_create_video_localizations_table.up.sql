@@ -0,0 +1,11 @@
+CREATE TABLE video_localizations (
+  video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+  language VARCHAR(35) NOT NULL,
+  title VARCHAR(100) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  ai_generated BOOLEAN NOT NULL DEFAULT FALSE,
+  -- AI translations must be reviewed before the video can be published
+  needs_review BOOLEAN NOT NULL DEFAULT FALSE,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  PRIMARY KEY (video_id, language)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE channels DROP COLUMN ai_provider;
+ALTER TABLE users DROP COLUMN ai_provider;
@@ -0,0 +1,3 @@
+-- NULL falls back to the provider of the user, then to the server default
+ALTER TABLE users ADD COLUMN ai_provider VARCHAR(16);
+ALTER TABLE channels ADD COLUMN ai_provider VARCHAR(16);
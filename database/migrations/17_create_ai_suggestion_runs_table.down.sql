@@ -0,0 +1,2 @@
+ALTER TABLE videos DROP COLUMN ai_fields;
+DROP TABLE IF EXISTS ai_suggestion_runs;
@@ -0,0 +1,17 @@
+CREATE TABLE ai_suggestion_runs (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+  user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  provider VARCHAR(16) NOT NULL,
+  prompt TEXT NOT NULL,
+  seed BIGINT,
+  result JSONB NOT NULL,
+  accepted_fields TEXT[] NOT NULL DEFAULT '{}',
+  rejected_fields TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX ai_suggestion_runs_video_id_idx ON ai_suggestion_runs (video_id, created_at);
+
+-- Fields of the video whose current value was accepted from a suggestion
+ALTER TABLE videos ADD COLUMN ai_fields TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,7 @@
+ALTER TABLE ai_suggestion_runs
+  DROP COLUMN fields,
+  DROP COLUMN length,
+  DROP COLUMN tone,
+  DROP COLUMN parent_id;
+
+ALTER TABLE channels DROP COLUMN prompt_template;
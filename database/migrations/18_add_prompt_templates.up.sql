@@ -0,0 +1,7 @@
+ALTER TABLE channels ADD COLUMN prompt_template TEXT;
+
+ALTER TABLE ai_suggestion_runs
+  ADD COLUMN parent_id UUID REFERENCES ai_suggestion_runs(id) ON DELETE SET NULL,
+  ADD COLUMN tone VARCHAR(16),
+  ADD COLUMN length VARCHAR(16),
+  ADD COLUMN fields TEXT[] NOT NULL DEFAULT '{}';
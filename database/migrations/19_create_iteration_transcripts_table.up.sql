@@ -0,0 +1,9 @@
+CREATE TABLE iteration_transcripts (
+  iteration_id UUID PRIMARY KEY REFERENCES iterations(id) ON DELETE CASCADE,
+  language VARCHAR(35) NOT NULL DEFAULT '',
+  -- upload or the name of the transcription provider
+  source VARCHAR(16) NOT NULL,
+  text TEXT NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,9 @@
+-- Monthly usage of metered features, see models.IsMonthlyMetric
+CREATE TABLE usage_counters (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  period DATE NOT NULL,
+  metric VARCHAR(32) NOT NULL,
+  quantity BIGINT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  PRIMARY KEY (user_id, period, metric)
+);
@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS notifications;
+
+DROP INDEX IF EXISTS editors_trial_ends_at_idx;
+DROP INDEX IF EXISTS users_trial_ends_at_idx;
+
+ALTER TABLE users ALTER COLUMN trial SET DEFAULT TRUE;
+ALTER TABLE users ALTER COLUMN trial DROP NOT NULL;
+
+ALTER TABLE editors
+  DROP COLUMN trial_warned_at,
+  DROP COLUMN trial_ends_at,
+  DROP COLUMN trial_started_at;
+
+ALTER TABLE users
+  DROP COLUMN admin,
+  DROP COLUMN trial_warned_at,
+  DROP COLUMN trial_ends_at,
+  DROP COLUMN trial_started_at;
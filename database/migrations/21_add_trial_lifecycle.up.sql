@@ -0,0 +1,34 @@
+ALTER TABLE users
+  ADD COLUMN trial_started_at TIMESTAMP WITHOUT TIME ZONE,
+  ADD COLUMN trial_ends_at TIMESTAMP WITHOUT TIME ZONE,
+  ADD COLUMN trial_warned_at TIMESTAMP WITHOUT TIME ZONE,
+  ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE editors
+  ADD COLUMN trial_started_at TIMESTAMP WITHOUT TIME ZONE,
+  ADD COLUMN trial_ends_at TIMESTAMP WITHOUT TIME ZONE,
+  ADD COLUMN trial_warned_at TIMESTAMP WITHOUT TIME ZONE;
+
+-- Trials used to never end, give the running ones two weeks from now
+UPDATE users SET trial = FALSE WHERE trial IS NULL;
+ALTER TABLE users ALTER COLUMN trial SET NOT NULL;
+ALTER TABLE users ALTER COLUMN trial SET DEFAULT FALSE;
+UPDATE users SET trial_started_at = created_at, trial_ends_at = NOW() + INTERVAL '14 days' WHERE trial;
+UPDATE editors SET trial_started_at = created_at, trial_ends_at = NOW() + INTERVAL '14 days' WHERE trial;
+
+CREATE INDEX users_trial_ends_at_idx ON users (trial_ends_at) WHERE trial;
+CREATE INDEX editors_trial_ends_at_idx ON editors (trial_ends_at) WHERE trial;
+
+CREATE TABLE notifications (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  editor_id UUID REFERENCES editors(id) ON DELETE CASCADE,
+  kind VARCHAR(32) NOT NULL,
+  message TEXT NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  read_at TIMESTAMP WITHOUT TIME ZONE,
+  CHECK ((user_id IS NULL) <> (editor_id IS NULL))
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, created_at);
+CREATE INDEX notifications_editor_id_idx ON notifications (editor_id, created_at);
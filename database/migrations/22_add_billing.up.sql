@@ -0,0 +1,25 @@
+-- Customer of the user in the billing API, set by the first subscription
+ALTER TABLE users ADD COLUMN billing_customer_id VARCHAR(255) UNIQUE;
+
+CREATE TABLE subscriptions (
+  id VARCHAR(255) PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  customer_id VARCHAR(255) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  price_id VARCHAR(255) NOT NULL,
+  tier VARCHAR(255) NOT NULL,
+  current_period_end TIMESTAMP WITHOUT TIME ZONE,
+  -- Creation time of the latest event applied, so late events don't undo newer ones
+  event_created BIGINT NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX subscriptions_user_id_idx ON subscriptions (user_id);
+
+-- Webhook events already processed, so redeliveries are ignored
+CREATE TABLE billing_events (
+  id VARCHAR(255) PRIMARY KEY,
+  type VARCHAR(64) NOT NULL,
+  received_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,14 @@
+-- Channels go back to the first owner of their organization
+ALTER TABLE channels ADD COLUMN owner_id UUID REFERENCES users(id) ON DELETE CASCADE;
+UPDATE channels c SET owner_id = (
+  SELECT m.user_id FROM organization_members m
+  WHERE m.organization_id = c.organization_id AND m.role = 'owner'
+  ORDER BY m.created_at, m.user_id LIMIT 1
+);
+DELETE FROM channels WHERE owner_id IS NULL;
+ALTER TABLE channels ALTER COLUMN owner_id SET NOT NULL;
+ALTER TABLE channels DROP COLUMN organization_id;
+
+DROP TABLE IF EXISTS organization_invitations;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,43 @@
+CREATE TABLE organizations (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  name VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE organization_members (
+  organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role VARCHAR(32) NOT NULL CHECK (role IN ('owner', 'admin', 'producer', 'viewer')),
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX organization_members_user_id_idx ON organization_members (user_id);
+
+CREATE TABLE organization_invitations (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+  email VARCHAR(255) NOT NULL,
+  role VARCHAR(32) NOT NULL CHECK (role IN ('owner', 'admin', 'producer', 'viewer')),
+  invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  accepted_at TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE INDEX organization_invitations_email_idx ON organization_invitations (LOWER(email)) WHERE accepted_at IS NULL;
+
+-- Every user gets a personal organization, with the same ID, that owns the
+-- channels they used to own
+INSERT INTO organizations (id, name, created_at, updated_at)
+SELECT id, username, created_at, NOW() FROM users;
+INSERT INTO organization_members (organization_id, user_id, role, created_at)
+SELECT id, id, 'owner', created_at FROM users;
+
+ALTER TABLE channels ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE channels SET organization_id = owner_id;
+ALTER TABLE channels ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE channels DROP COLUMN owner_id;
+
+CREATE INDEX channels_organization_id_idx ON channels (organization_id);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS organization_roles;
+
+-- Members with a custom role fall back to viewers
+UPDATE organization_members SET role = 'viewer' WHERE role NOT IN ('owner', 'admin', 'producer', 'viewer');
+DELETE FROM organization_invitations WHERE role NOT IN ('owner', 'admin', 'producer', 'viewer');
+ALTER TABLE organization_members ADD CONSTRAINT organization_members_role_check CHECK (role IN ('owner', 'admin', 'producer', 'viewer'));
+ALTER TABLE organization_invitations ADD CONSTRAINT organization_invitations_role_check CHECK (role IN ('owner', 'admin', 'producer', 'viewer'));
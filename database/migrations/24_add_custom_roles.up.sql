@@ -0,0 +1,13 @@
+-- Members and invitations can also have the custom roles of their organization
+ALTER TABLE organization_members DROP CONSTRAINT organization_members_role_check;
+ALTER TABLE organization_invitations DROP CONSTRAINT organization_invitations_role_check;
+
+CREATE TABLE organization_roles (
+  organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+  name VARCHAR(32) NOT NULL CHECK (name NOT IN ('owner', 'admin', 'producer', 'viewer')),
+  description VARCHAR(255) NOT NULL DEFAULT '',
+  permissions TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  PRIMARY KEY (organization_id, name)
+);
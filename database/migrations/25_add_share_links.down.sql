@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS iteration_comments;
+DROP TABLE IF EXISTS share_link_accesses;
+DROP TABLE IF EXISTS share_links;
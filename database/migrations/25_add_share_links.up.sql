@@ -0,0 +1,41 @@
+CREATE TABLE share_links (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  iteration_id UUID NOT NULL REFERENCES iterations(id) ON DELETE CASCADE,
+  -- Only a hash of the token is stored, the token itself is shown once
+  token_hash VARCHAR(64) NOT NULL UNIQUE,
+  password_hash VARCHAR(255),
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  revoked_at TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE INDEX share_links_iteration_id_idx ON share_links (iteration_id);
+
+CREATE TABLE share_link_accesses (
+  id BIGSERIAL PRIMARY KEY,
+  share_link_id UUID NOT NULL REFERENCES share_links(id) ON DELETE CASCADE,
+  action VARCHAR(16) NOT NULL,
+  display_name VARCHAR(100) NOT NULL DEFAULT '',
+  ip VARCHAR(64) NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  accessed_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX share_link_accesses_share_link_id_idx ON share_link_accesses (share_link_id, accessed_at DESC);
+
+-- Timecoded comments on iterations, left by members or by guests of a share link
+CREATE TABLE iteration_comments (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  iteration_id UUID NOT NULL REFERENCES iterations(id) ON DELETE CASCADE,
+  user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  share_link_id UUID REFERENCES share_links(id) ON DELETE SET NULL,
+  author_name VARCHAR(100) NOT NULL,
+  timecode INTEGER NOT NULL CHECK (timecode >= 0),
+  body TEXT NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  resolved_at TIMESTAMP WITHOUT TIME ZONE,
+  resolved_by UUID REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX iteration_comments_iteration_id_idx ON iteration_comments (iteration_id, timecode);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS sponsor_approvals;
+DROP TABLE IF EXISTS video_sponsorships;
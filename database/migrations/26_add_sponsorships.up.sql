@@ -0,0 +1,24 @@
+CREATE TABLE video_sponsorships (
+  video_id UUID PRIMARY KEY REFERENCES videos(id) ON DELETE CASCADE,
+  sponsor VARCHAR(100) NOT NULL,
+  approver_emails TEXT[] NOT NULL,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+);
+
+-- Requests for an approver to approve an iteration and a snapshot of the
+-- metadata of its video
+CREATE TABLE sponsor_approvals (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+  iteration_id UUID NOT NULL REFERENCES iterations(id) ON DELETE CASCADE,
+  approver_email VARCHAR(255) NOT NULL,
+  metadata JSONB NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+  comment TEXT NOT NULL DEFAULT '',
+  requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  requested_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  decided_at TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE INDEX sponsor_approvals_video_id_idx ON sponsor_approvals (video_id, approver_email);
@@ -0,0 +1,37 @@
+-- The audit log has no foreign keys so entries outlive what they describe
+CREATE TABLE audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  actor_id VARCHAR(255) NOT NULL DEFAULT '',
+  actor_name VARCHAR(255) NOT NULL DEFAULT '',
+  principal_type VARCHAR(16) NOT NULL,
+  action VARCHAR(128) NOT NULL,
+  organization_id UUID,
+  channel_id UUID,
+  video_id UUID,
+  resource_type VARCHAR(32) NOT NULL DEFAULT '',
+  resource_id VARCHAR(255) NOT NULL DEFAULT '',
+  changes JSONB NOT NULL DEFAULT '{}',
+  request_id VARCHAR(255) NOT NULL DEFAULT '',
+  ip VARCHAR(64) NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX audit_log_organization_id_idx ON audit_log (organization_id, id DESC);
+CREATE INDEX audit_log_channel_id_idx ON audit_log (channel_id, id DESC);
+CREATE INDEX audit_log_video_id_idx ON audit_log (video_id, id DESC);
+CREATE INDEX audit_log_actor_id_idx ON audit_log (actor_id, id DESC);
+
+-- Entries can be added but never changed or removed
+CREATE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+  RAISE EXCEPTION 'the audit log is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+  BEFORE UPDATE OR DELETE ON audit_log
+  FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate
+  BEFORE TRUNCATE ON audit_log
+  FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
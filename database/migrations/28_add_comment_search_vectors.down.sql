@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS iteration_comments_search_vector_idx;
+
+DROP TRIGGER IF EXISTS iteration_comments_search_vector_update ON iteration_comments;
+
+DROP FUNCTION IF EXISTS iteration_comments_search_vector_update();
+
+ALTER TABLE iteration_comments DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,17 @@
+ALTER TABLE iteration_comments ADD COLUMN search_vector TSVECTOR;
+
+CREATE FUNCTION iteration_comments_search_vector_update() RETURNS TRIGGER AS $$
+BEGIN
+  NEW.search_vector := to_tsvector('english', COALESCE(NEW.body, ''));
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER iteration_comments_search_vector_update
+  BEFORE INSERT OR UPDATE OF body ON iteration_comments
+  FOR EACH ROW EXECUTE FUNCTION iteration_comments_search_vector_update();
+
+-- Fill in existing rows through the trigger
+UPDATE iteration_comments SET body = body;
+
+CREATE INDEX iteration_comments_search_vector_idx ON iteration_comments USING GIN (search_vector);
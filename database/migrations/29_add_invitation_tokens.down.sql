@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS users_email_lower_idx;
+
+DROP INDEX IF EXISTS organization_invitations_token_hash_idx;
+
+ALTER TABLE organization_invitations DROP COLUMN IF EXISTS token_hash;
@@ -0,0 +1,8 @@
+-- Invitations are accepted with a token sent to the invited address. The
+-- pending invitations from before have none and must be sent again.
+ALTER TABLE organization_invitations ADD COLUMN token_hash VARCHAR(64);
+
+CREATE UNIQUE INDEX organization_invitations_token_hash_idx ON organization_invitations (token_hash);
+
+-- Email addresses are unique whatever their case
+CREATE UNIQUE INDEX users_email_lower_idx ON users (LOWER(email));
@@ -0,0 +1,3 @@
+-- Activity feeds skip events a request recorded more than once, which looks
+-- up earlier entries of the same request and resource
+CREATE INDEX audit_log_request_id_idx ON audit_log (request_id, action, resource_type, resource_id, id) WHERE request_id <> '';
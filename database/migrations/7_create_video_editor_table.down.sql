@@ -0,0 +1 @@
+DROP TABLE IF EXISTS video_editor;
\ No newline at end of file
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS iterations_search_vector_idx;
+DROP INDEX IF EXISTS videos_search_vector_idx;
+
+DROP TRIGGER IF EXISTS iterations_search_vector_update ON iterations;
+DROP TRIGGER IF EXISTS videos_search_vector_update ON videos;
+
+DROP FUNCTION IF EXISTS iterations_search_vector_update();
+DROP FUNCTION IF EXISTS videos_search_vector_update();
+
+ALTER TABLE iterations DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE videos DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,34 @@
+ALTER TABLE videos ADD COLUMN search_vector TSVECTOR;
+ALTER TABLE iterations ADD COLUMN search_vector TSVECTOR;
+
+CREATE FUNCTION videos_search_vector_update() RETURNS TRIGGER AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('english', COALESCE(NEW.title, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(array_to_string(NEW.keywords, ' '), '')), 'B') ||
+    setweight(to_tsvector('english', COALESCE(NEW.description, '')), 'C');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION iterations_search_vector_update() RETURNS TRIGGER AS $$
+BEGIN
+  NEW.search_vector := to_tsvector('english', COALESCE(NEW.notes, ''));
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER videos_search_vector_update
+  BEFORE INSERT OR UPDATE OF title, description, keywords ON videos
+  FOR EACH ROW EXECUTE FUNCTION videos_search_vector_update();
+
+CREATE TRIGGER iterations_search_vector_update
+  BEFORE INSERT OR UPDATE OF notes ON iterations
+  FOR EACH ROW EXECUTE FUNCTION iterations_search_vector_update();
+
+-- Fill in existing rows through the triggers
+UPDATE videos SET title = title;
+UPDATE iterations SET notes = notes;
+
+CREATE INDEX videos_search_vector_idx ON videos USING GIN (search_vector);
+CREATE INDEX iterations_search_vector_idx ON iterations USING GIN (search_vector);
@@ -0,0 +1,27 @@
+-- Rows still in the trash would reappear as live ones
+DELETE FROM channels WHERE deleted_at IS NOT NULL;
+DELETE FROM videos WHERE deleted_at IS NOT NULL;
+DELETE FROM iterations WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE video_editor
+  DROP CONSTRAINT video_editor_editor_id_fkey,
+  ADD CONSTRAINT video_editor_editor_id_fkey FOREIGN KEY (editor_id) REFERENCES editors(id),
+  DROP CONSTRAINT video_editor_video_id_fkey,
+  ADD CONSTRAINT video_editor_video_id_fkey FOREIGN KEY (video_id) REFERENCES videos(id);
+ALTER TABLE iterations
+  DROP CONSTRAINT iterations_video_id_fkey,
+  ADD CONSTRAINT iterations_video_id_fkey FOREIGN KEY (video_id) REFERENCES videos(id);
+ALTER TABLE videos
+  DROP CONSTRAINT videos_channel_id_fkey,
+  ADD CONSTRAINT videos_channel_id_fkey FOREIGN KEY (channel_id) REFERENCES channels(id);
+ALTER TABLE channels
+  DROP CONSTRAINT channels_owner_id_fkey,
+  ADD CONSTRAINT channels_owner_id_fkey FOREIGN KEY (owner_id) REFERENCES users(id);
+
+DROP INDEX IF EXISTS iterations_deleted_at_idx;
+DROP INDEX IF EXISTS videos_deleted_at_idx;
+DROP INDEX IF EXISTS channels_deleted_at_idx;
+
+ALTER TABLE iterations DROP COLUMN deleted_at;
+ALTER TABLE videos DROP COLUMN deleted_at;
+ALTER TABLE channels DROP COLUMN deleted_at;
@@ -0,0 +1,23 @@
+ALTER TABLE channels ADD COLUMN deleted_at TIMESTAMP WITHOUT TIME ZONE;
+ALTER TABLE videos ADD COLUMN deleted_at TIMESTAMP WITHOUT TIME ZONE;
+ALTER TABLE iterations ADD COLUMN deleted_at TIMESTAMP WITHOUT TIME ZONE;
+
+CREATE INDEX channels_deleted_at_idx ON channels (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX videos_deleted_at_idx ON videos (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX iterations_deleted_at_idx ON iterations (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Purging a row removes everything that depends on it
+ALTER TABLE channels
+  DROP CONSTRAINT channels_owner_id_fkey,
+  ADD CONSTRAINT channels_owner_id_fkey FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE videos
+  DROP CONSTRAINT videos_channel_id_fkey,
+  ADD CONSTRAINT videos_channel_id_fkey FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE;
+ALTER TABLE iterations
+  DROP CONSTRAINT iterations_video_id_fkey,
+  ADD CONSTRAINT iterations_video_id_fkey FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE;
+ALTER TABLE video_editor
+  DROP CONSTRAINT video_editor_video_id_fkey,
+  ADD CONSTRAINT video_editor_video_id_fkey FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+  DROP CONSTRAINT video_editor_editor_id_fkey,
+  ADD CONSTRAINT video_editor_editor_id_fkey FOREIGN KEY (editor_id) REFERENCES editors(id) ON DELETE CASCADE;
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+const notificationColumns = "n.id, n.kind, n.message, n.created_at, n.read_at"
+
+func scanNotification(row rowScanner, notification *models.Notification) error {
+	return row.Scan(
+		&notification.ID,
+		&notification.Kind,
+		&notification.Message,
+		&notification.CreatedAt,
+		&notification.ReadAt,
+	)
+}
+
+// GetNotificationsByUser retrieves the latest notifications of a user,
+// newest first
+func (db *DB) GetNotificationsByUser(userID string, unreadOnly bool, limit int) ([]models.Notification, error) {
+	notifications := []models.Notification{}
+	rows, err := db.QueryContext(context.Background(), `
+		SELECT `+notificationColumns+` FROM notifications n
+		WHERE n.user_id = $1 AND (NOT $2 OR n.read_at IS NULL)
+		ORDER BY n.created_at DESC LIMIT $3`, userID, unreadOnly, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching notifications: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var notification models.Notification
+		if err := scanNotification(rows, &notification); err != nil {
+			return nil, fmt.Errorf("error scanning notification: %w", err)
+		}
+		notifications = append(notifications, notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// MarkNotificationRead marks a notification of a user as read
+func (db *DB) MarkNotificationRead(userID, notificationID string) error {
+	result, err := db.ExecContext(context.Background(), "UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND user_id = $2", notificationID, userID)
+	if err != nil {
+		return fmt.Errorf("error updating notification: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,516 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// ErrLastOwner is returned when a change would leave an organization without
+// an owner
+var ErrLastOwner = errors.New("organization needs an owner")
+
+// ErrHasChannels is returned when deleting an organization that still owns
+// channels, including channels in the trash
+var ErrHasChannels = errors.New("organization has channels")
+
+// channelOwner selects the owner of the organization of the channel c: the
+// earliest of its owners. Their tier and quotas apply to the channel.
+const channelOwner = `(SELECT m.user_id FROM organization_members m
+	WHERE m.organization_id = c.organization_id AND m.role = 'owner'
+	ORDER BY m.created_at, m.user_id LIMIT 1)`
+
+// memberCondition is a condition on the channel c that holds when the user
+// in the query parameter param is a member of its organization whose role,
+// built-in or custom, grants permission
+func memberCondition(param string, permission models.Permission) string {
+	var roles []string
+	for _, r := range models.BuiltinRolesWith(permission) {
+		roles = append(roles, "'"+string(r)+"'")
+	}
+	return "EXISTS (SELECT 1 FROM organization_members m WHERE m.organization_id = c.organization_id AND m.user_id = " +
+		param + " AND (m.role IN (" + strings.Join(roles, ", ") + ") OR EXISTS (SELECT 1 FROM organization_roles cr" +
+		" WHERE cr.organization_id = m.organization_id AND cr.name = m.role AND '" + string(permission) + "' = ANY(cr.permissions))))"
+}
+
+// organizationFrom joins the organizations o to the memberships m and the
+// custom roles cr of their members
+const organizationFrom = `organizations o
+	JOIN organization_members m ON m.organization_id = o.id
+	LEFT JOIN organization_roles cr ON cr.organization_id = o.id AND cr.name = m.role`
+
+const (
+	organizationColumns = "o.id, o.name, m.role, COALESCE(cr.permissions, '{}'), o.created_at, o.updated_at"
+	memberColumns       = "m.user_id, u.username, u.email, m.role, m.created_at"
+	invitationColumns   = "inv.id, inv.organization_id, o.name, inv.email, inv.role, COALESCE(inv.invited_by::text, ''), inv.created_at, inv.expires_at, inv.accepted_at"
+)
+
+func scanOrganization(row rowScanner, organization *models.Organization) error {
+	var custom []string
+	err := row.Scan(
+		&organization.ID,
+		&organization.Name,
+		&organization.Role,
+		pq.Array(&custom),
+		&organization.CreatedAt,
+		&organization.UpdatedAt,
+	)
+	organization.Permissions = rolePermissions(organization.Role, custom)
+	return err
+}
+
+func scanMember(row rowScanner, member *models.Member) error {
+	return row.Scan(
+		&member.UserID,
+		&member.Username,
+		&member.Email,
+		&member.Role,
+		&member.CreatedAt,
+	)
+}
+
+func scanInvitation(row rowScanner, invitation *models.Invitation) error {
+	return row.Scan(
+		&invitation.ID,
+		&invitation.OrganizationID,
+		&invitation.OrganizationName,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.InvitedBy,
+		&invitation.CreatedAt,
+		&invitation.ExpiresAt,
+		&invitation.AcceptedAt,
+	)
+}
+
+// CreateOrganization creates an organization owned by a user
+func (db *DB) CreateOrganization(name, ownerID string) (*models.Organization, error) {
+	organizationID := uuid.New().String()
+	err := db.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("INSERT INTO organizations (id, name) VALUES ($1, $2)", organizationID, name); err != nil {
+			return fmt.Errorf("error creating organization: %w", err)
+		}
+		if _, err := tx.Exec("INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)", organizationID, ownerID, models.RoleOwner); err != nil {
+			return fmt.Errorf("error adding owner: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.GetOrganization(organizationID, ownerID)
+}
+
+// GetOrganization retrieves an organization the user is a member of, along
+// with the user's role
+func (db *DB) GetOrganization(organizationID, userID string) (*models.Organization, error) {
+	var organization models.Organization
+	err := scanOrganization(db.QueryRowContext(context.Background(), `
+		SELECT `+organizationColumns+` FROM `+organizationFrom+`
+		WHERE o.id = $1 AND m.user_id = $2`, organizationID, userID), &organization)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error fetching organization: %w", err)
+	}
+	return &organization, nil
+}
+
+// GetOrganizationsByUser retrieves the organizations a user is a member of
+func (db *DB) GetOrganizationsByUser(userID string) ([]models.Organization, error) {
+	organizations := []models.Organization{}
+	rows, err := db.QueryContext(context.Background(), `
+		SELECT `+organizationColumns+` FROM `+organizationFrom+`
+		WHERE m.user_id = $1 ORDER BY o.created_at, o.id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching organizations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var organization models.Organization
+		if err := scanOrganization(rows, &organization); err != nil {
+			return nil, fmt.Errorf("error scanning organization: %w", err)
+		}
+		organizations = append(organizations, organization)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return organizations, nil
+}
+
+// DefaultOrganization returns the first organization a user owns, creating
+// one named after the user if there is none. Channels created without an
+// organization go there.
+func (db *DB) DefaultOrganization(user *models.User) (*models.Organization, error) {
+	var organization models.Organization
+	err := scanOrganization(db.QueryRowContext(context.Background(), `
+		SELECT `+organizationColumns+` FROM `+organizationFrom+`
+		WHERE m.user_id = $1 AND m.role = $2 ORDER BY o.created_at, o.id LIMIT 1`, user.ID, models.RoleOwner), &organization)
+	if err == nil {
+		return &organization, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("error fetching organization: %w", err)
+	}
+	return db.CreateOrganization(user.Username, user.ID)
+}
+
+// GetOrganizationOwner retrieves the owner of an organization whose tier and
+// quotas apply to its channels, see channelOwner
+func (db *DB) GetOrganizationOwner(organizationID string) (*models.User, error) {
+	var user models.User
+	err := scanUser(db.QueryRowContext(context.Background(), `
+		SELECT `+userColumns+` FROM users u
+		JOIN organization_members m ON m.user_id = u.id
+		WHERE m.organization_id = $1 AND m.role = $2
+		ORDER BY m.created_at, m.user_id LIMIT 1`, organizationID, models.RoleOwner), &user)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error fetching organization owner: %w", err)
+	}
+	return &user, nil
+}
+
+// UpdateOrganization renames an organization
+func (db *DB) UpdateOrganization(organizationID, name string) error {
+	result, err := db.ExecContext(context.Background(), "UPDATE organizations SET name = $1, updated_at = NOW() WHERE id = $2", name, organizationID)
+	if err != nil {
+		return fmt.Errorf("error updating organization: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteOrganization deletes an organization without channels along with
+// its members and invitations
+func (db *DB) DeleteOrganization(organizationID string) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		var hasChannels bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM channels WHERE organization_id = $1)", organizationID).Scan(&hasChannels); err != nil {
+			return fmt.Errorf("error counting channels: %w", err)
+		}
+		if hasChannels {
+			return ErrHasChannels
+		}
+
+		result, err := tx.Exec("DELETE FROM organizations WHERE id = $1", organizationID)
+		if err != nil {
+			return fmt.Errorf("error deleting organization: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// GetMemberPermissions returns the role of a user in an organization and the
+// permissions it grants
+func (db *DB) GetMemberPermissions(organizationID, userID string) (models.Role, []models.Permission, error) {
+	var role models.Role
+	var custom []string
+	err := db.QueryRowContext(context.Background(), `
+		SELECT m.role, COALESCE(cr.permissions, '{}') FROM organization_members m
+		LEFT JOIN organization_roles cr ON cr.organization_id = m.organization_id AND cr.name = m.role
+		WHERE m.organization_id = $1 AND m.user_id = $2`, organizationID, userID).Scan(&role, pq.Array(&custom))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil, ErrNotFound
+		}
+		return "", nil, fmt.Errorf("error fetching member: %w", err)
+	}
+	return role, rolePermissions(role, custom), nil
+}
+
+// GetMembers retrieves the members of an organization
+func (db *DB) GetMembers(organizationID string) ([]models.Member, error) {
+	members := []models.Member{}
+	rows, err := db.QueryContext(context.Background(), `
+		SELECT `+memberColumns+` FROM organization_members m JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1 ORDER BY m.created_at, m.user_id`, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching members: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member models.Member
+		if err := scanMember(rows, &member); err != nil {
+			return nil, fmt.Errorf("error scanning member: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return members, nil
+}
+
+// SetMemberRole changes the role of a member. The last owner can't be demoted.
+func (db *DB) SetMemberRole(organizationID, userID string, role models.Role) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		if role != models.RoleOwner {
+			if err := checkOtherOwner(tx, organizationID, userID); err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.Exec("UPDATE organization_members SET role = $1 WHERE organization_id = $2 AND user_id = $3", role, organizationID, userID)
+		if err != nil {
+			return fmt.Errorf("error updating member: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// RemoveMember removes a user from an organization. The last owner can't leave.
+func (db *DB) RemoveMember(organizationID, userID string) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		if err := checkOtherOwner(tx, organizationID, userID); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec("DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2", organizationID, userID)
+		if err != nil {
+			return fmt.Errorf("error removing member: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// checkOtherOwner returns ErrLastOwner if the user is the only owner of the
+// organization. The owners are locked until the end of the transaction so
+// two owners can't step down at the same time.
+func checkOtherOwner(tx *sql.Tx, organizationID, userID string) error {
+	rows, err := tx.Query("SELECT user_id FROM organization_members WHERE organization_id = $1 AND role = $2 FOR UPDATE", organizationID, models.RoleOwner)
+	if err != nil {
+		return fmt.Errorf("error fetching owners: %w", err)
+	}
+	defer rows.Close()
+
+	isOwner, others := false, 0
+	for rows.Next() {
+		var ownerID string
+		if err := rows.Scan(&ownerID); err != nil {
+			return fmt.Errorf("error scanning owner: %w", err)
+		}
+		if ownerID == userID {
+			isOwner = true
+		} else {
+			others++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	if isOwner && others == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// CreateInvitation invites an email address to an organization, replacing
+// any pending invitation of the same address. The invitation is accepted
+// with the token hashed to tokenHash. If a user has that address they are
+// notified.
+func (db *DB) CreateInvitation(invitation *models.Invitation, tokenHash string) (*models.Invitation, error) {
+	invitationID := uuid.New().String()
+	err := db.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM organization_invitations WHERE organization_id = $1 AND LOWER(email) = LOWER($2) AND accepted_at IS NULL",
+			invitation.OrganizationID, invitation.Email)
+		if err != nil {
+			return fmt.Errorf("error replacing invitation: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO organization_invitations (id, organization_id, email, role, invited_by, expires_at, token_hash)
+			VALUES ($1, $2, $3, $4, $5, NOW() + $6::bigint * INTERVAL '1 second', $7)`,
+			invitationID, invitation.OrganizationID, invitation.Email, invitation.Role, invitation.InvitedBy, int64(models.InvitationLifetime/time.Second), tokenHash)
+		if err != nil {
+			return fmt.Errorf("error creating invitation: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO notifications (user_id, kind, message)
+			SELECT u.id, $3, 'You have been invited to join ' || o.name || ' as ' || $4 || '.'
+			FROM users u, organizations o
+			WHERE LOWER(u.email) = LOWER($1) AND o.id = $2`,
+			invitation.Email, invitation.OrganizationID, models.NotificationInvitation, string(invitation.Role))
+		if err != nil {
+			return fmt.Errorf("error creating notification: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.getInvitation("inv.id = $1", invitationID)
+}
+
+func (db *DB) getInvitation(condition string, args ...interface{}) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := scanInvitation(db.QueryRowContext(context.Background(), `
+		SELECT `+invitationColumns+` FROM organization_invitations inv
+		JOIN organizations o ON o.id = inv.organization_id WHERE `+condition, args...), &invitation)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error fetching invitation: %w", err)
+	}
+	return &invitation, nil
+}
+
+func (db *DB) queryInvitations(condition string, args ...interface{}) ([]models.Invitation, error) {
+	invitations := []models.Invitation{}
+	rows, err := db.QueryContext(context.Background(), `
+		SELECT `+invitationColumns+` FROM organization_invitations inv
+		JOIN organizations o ON o.id = inv.organization_id
+		WHERE inv.accepted_at IS NULL AND `+condition+` ORDER BY inv.created_at DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching invitations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var invitation models.Invitation
+		if err := scanInvitation(rows, &invitation); err != nil {
+			return nil, fmt.Errorf("error scanning invitation: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return invitations, nil
+}
+
+// GetInvitationsByOrganization retrieves the pending invitations of an
+// organization, including expired ones
+func (db *DB) GetInvitationsByOrganization(organizationID string) ([]models.Invitation, error) {
+	return db.queryInvitations("inv.organization_id = $1", organizationID)
+}
+
+// GetInvitationsByEmail retrieves the invitations an email address can accept
+func (db *DB) GetInvitationsByEmail(email string) ([]models.Invitation, error) {
+	return db.queryInvitations("LOWER(inv.email) = LOWER($1) AND inv.expires_at > NOW()", email)
+}
+
+// DeleteInvitation revokes a pending invitation of an organization
+func (db *DB) DeleteInvitation(organizationID, invitationID string) error {
+	result, err := db.ExecContext(context.Background(), "DELETE FROM organization_invitations WHERE id = $1 AND organization_id = $2 AND accepted_at IS NULL", invitationID, organizationID)
+	if err != nil {
+		return fmt.Errorf("error deleting invitation: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AcceptInvitation makes a user a member of the organization of the
+// invitation whose token is hashed to tokenHash, and returns the
+// organization with the ID of the invitation. Users who already are members
+// keep their role.
+func (db *DB) AcceptInvitation(tokenHash string, user *models.User) (*models.Organization, string, error) {
+	var invitationID, organizationID string
+	err := db.inTx(func(tx *sql.Tx) error {
+		var role models.Role
+		err := tx.QueryRow(`
+			UPDATE organization_invitations SET accepted_at = NOW()
+			WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > NOW()
+			RETURNING id, organization_id, role`, tokenHash).Scan(&invitationID, &organizationID, &role)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error accepting invitation: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)
+			ON CONFLICT (organization_id, user_id) DO NOTHING`, organizationID, user.ID, role)
+		if err != nil {
+			return fmt.Errorf("error adding member: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	organization, err := db.GetOrganization(organizationID, user.ID)
+	return organization, invitationID, err
+}
+
+// TransferChannel moves a channel, with its videos, to another
+// organization. If expectedVersion is not zero the transfer only applies to
+// that version of the channel.
+func (db *DB) TransferChannel(channelID, organizationID string, expectedVersion int) (*models.Channel, error) {
+	result, err := db.ExecContext(context.Background(), `
+		UPDATE channels SET organization_id = $1, updated_at = NOW(), version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL AND ($3 = 0 OR version = $3)`,
+		organizationID, channelID, expectedVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error transferring channel: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, versionConflict(db, "channels", channelID)
+	}
+
+	return db.GetChannelByID(channelID)
+}
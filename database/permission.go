@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// ErrRoleExists is returned when creating a custom role with the name of a
+// role the organization already has
+var ErrRoleExists = errors.New("role already exists")
+
+// ErrRoleInUse is returned when deleting a custom role that members or
+// pending invitations still have
+var ErrRoleInUse = errors.New("role in use")
+
+const roleColumns = "cr.name, cr.description, cr.permissions, cr.created_at, cr.updated_at"
+
+func scanRole(row rowScanner, role *models.RoleDefinition) error {
+	var permissions []string
+	err := row.Scan(
+		&role.Name,
+		&role.Description,
+		pq.Array(&permissions),
+		&role.CreatedAt,
+		&role.UpdatedAt,
+	)
+	role.Permissions = toPermissions(permissions)
+	return err
+}
+
+// rolePermissions returns the permissions of a role given those stored for
+// it if it is a custom role
+func rolePermissions(role models.Role, custom []string) []models.Permission {
+	if permissions, ok := models.BuiltinRoles[role]; ok {
+		return permissions
+	}
+	return toPermissions(custom)
+}
+
+func toPermissions(values []string) []models.Permission {
+	permissions := make([]models.Permission, 0, len(values))
+	for _, value := range values {
+		permissions = append(permissions, models.Permission(value))
+	}
+	return permissions
+}
+
+func fromPermissions(permissions []models.Permission) []string {
+	values := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		values = append(values, string(permission))
+	}
+	return values
+}
+
+// GetRolePermissions returns the permissions a role of an organization
+// grants. ErrNotFound is returned if the organization has no such role.
+func (db *DB) GetRolePermissions(organizationID string, role models.Role) ([]models.Permission, error) {
+	if role.IsBuiltin() {
+		return models.BuiltinRoles[role], nil
+	}
+
+	var permissions []string
+	err := db.QueryRowContext(context.Background(), "SELECT permissions FROM organization_roles WHERE organization_id = $1 AND name = $2",
+		organizationID, role).Scan(pq.Array(&permissions))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error fetching role: %w", err)
+	}
+	return toPermissions(permissions), nil
+}
+
+// GetRoles retrieves the built-in roles followed by the custom roles of an
+// organization
+func (db *DB) GetRoles(organizationID string) ([]models.RoleDefinition, error) {
+	roles := models.BuiltinRoleDefinitions()
+	rows, err := db.QueryContext(context.Background(), "SELECT "+roleColumns+" FROM organization_roles cr WHERE cr.organization_id = $1 ORDER BY cr.name", organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching roles: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role models.RoleDefinition
+		if err := scanRole(rows, &role); err != nil {
+			return nil, fmt.Errorf("error scanning role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return roles, nil
+}
+
+// CreateRole adds a custom role to an organization
+func (db *DB) CreateRole(organizationID string, role *models.RoleDefinition) (*models.RoleDefinition, error) {
+	var created models.RoleDefinition
+	err := scanRole(db.QueryRowContext(context.Background(), `
+		INSERT INTO organization_roles AS cr (organization_id, name, description, permissions) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (organization_id, name) DO NOTHING
+		RETURNING `+roleColumns, organizationID, role.Name, role.Description, pq.Array(fromPermissions(role.Permissions))), &created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRoleExists
+		}
+		return nil, fmt.Errorf("error creating role: %w", err)
+	}
+	return &created, nil
+}
+
+// UpdateRole changes the description and permissions of a custom role. The
+// members who have it get the new permissions right away.
+func (db *DB) UpdateRole(organizationID string, role *models.RoleDefinition) (*models.RoleDefinition, error) {
+	var updated models.RoleDefinition
+	err := scanRole(db.QueryRowContext(context.Background(), `
+		UPDATE organization_roles cr SET description = $3, permissions = $4, updated_at = NOW()
+		WHERE cr.organization_id = $1 AND cr.name = $2
+		RETURNING `+roleColumns, organizationID, role.Name, role.Description, pq.Array(fromPermissions(role.Permissions))), &updated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error updating role: %w", err)
+	}
+	return &updated, nil
+}
+
+// DeleteRole deletes a custom role that no member or pending invitation has
+func (db *DB) DeleteRole(organizationID string, role models.Role) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			DELETE FROM organization_roles cr WHERE cr.organization_id = $1 AND cr.name = $2
+			AND NOT EXISTS (SELECT 1 FROM organization_members m WHERE m.organization_id = $1 AND m.role = $2)
+			AND NOT EXISTS (SELECT 1 FROM organization_invitations inv WHERE inv.organization_id = $1 AND inv.role = $2 AND inv.accepted_at IS NULL)`,
+			organizationID, role)
+		if err != nil {
+			return fmt.Errorf("error deleting role: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected > 0 {
+			return nil
+		}
+
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM organization_roles WHERE organization_id = $1 AND name = $2)", organizationID, role).Scan(&exists); err != nil {
+			return fmt.Errorf("error fetching role: %w", err)
+		}
+		if exists {
+			return ErrRoleInUse
+		}
+		return ErrNotFound
+	})
+}
+
+// resourceOrganizationQueries select the organization of each kind of
+// resource, including resources in the trash
+var resourceOrganizationQueries = map[string]string{
+	models.ResourceOrganization: "SELECT id FROM organizations WHERE id = $1",
+	models.ResourceChannel:      "SELECT organization_id FROM channels WHERE id = $1",
+	models.ResourceVideo:        "SELECT c.organization_id FROM videos v JOIN channels c ON c.id = v.channel_id WHERE v.id = $1",
+	models.ResourceIteration: `SELECT c.organization_id FROM iterations i
+		JOIN videos v ON v.id = i.video_id JOIN channels c ON c.id = v.channel_id WHERE i.id = $1`,
+}
+
+// GetResourceOrganization returns the ID of the organization a resource
+// belongs to
+func (db *DB) GetResourceOrganization(resourceType, resourceID string) (string, error) {
+	query, ok := resourceOrganizationQueries[resourceType]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	var organizationID string
+	err := db.QueryRowContext(context.Background(), query, resourceID).Scan(&organizationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("error fetching %s: %w", resourceType, err)
+	}
+	return organizationID, nil
+}
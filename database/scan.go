@@ -0,0 +1,128 @@
+package database
+
+import (
+	"github.com/lib/pq"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Column lists are spelled out rather than using SELECT * so that adding a
+// column in a migration doesn't break the scans below. Nullable text columns
+// are coalesced since the models use plain strings.
+const (
+	userColumns      = "u.id, u.username, u.email, u.password, u.created_at, u.updated_at, u.tier, u.trial, u.trial_started_at, u.trial_ends_at, u.admin, COALESCE(u.ai_provider, '')"
+	channelColumns   = "c.id, c.name, c.api_key, COALESCE(" + channelOwner + "::text, ''), c.organization_id, COALESCE(c.ai_provider, ''), COALESCE(c.prompt_template, ''), c.created_at, c.updated_at, c.version"
+	videoColumns     = "v.id, v.status, COALESCE(v.resources, ''), COALESCE(v.title, ''), COALESCE(v.description, ''), v.keywords, COALESCE(v.category, ''), v.privacy_status, v.made_for_kids, COALESCE(v.default_language, ''), v.license, v.embeddable, COALESCE(v.youtube_id, ''), v.ai_fields, v.channel_id, v.created_at, v.updated_at, v.version"
+	iterationColumns = "i.id, i.video_id, i.url, COALESCE(i.length, ''), i.status, COALESCE(i.notes, ''), i.created_at, i.updated_at, i.version"
+	editorColumns    = "e.id, e.username, e.email, e.password, e.created_at, e.updated_at, e.tier, e.trial, e.trial_started_at, e.trial_ends_at"
+)
+
+func scanUser(row rowScanner, user *models.User) error {
+	return row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.Password,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Tier,
+		&user.Trial,
+		&user.TrialStartedAt,
+		&user.TrialEndsAt,
+		&user.Admin,
+		&user.AIProvider,
+	)
+}
+
+func scanChannel(row rowScanner, channel *models.Channel) error {
+	return row.Scan(
+		&channel.ID,
+		&channel.Name,
+		&channel.API_KEY,
+		&channel.Owner.ID,
+		&channel.OrganizationID,
+		&channel.AIProvider,
+		&channel.PromptTemplate,
+		&channel.CreatedAt,
+		&channel.UpdatedAt,
+		&channel.Version,
+	)
+}
+
+func scanVideo(row rowScanner, video *models.Video) error {
+	return row.Scan(
+		&video.ID,
+		&video.Status,
+		&video.Resources,
+		&video.Title,
+		&video.Description,
+		pq.Array(&video.Keywords),
+		&video.Category,
+		&video.PrivacyStatus,
+		&video.MadeForKids,
+		&video.DefaultLanguage,
+		&video.License,
+		&video.Embeddable,
+		&video.YouTubeID,
+		pq.Array(&video.AIFields),
+		&video.Channel.ID,
+		&video.CreatedAt,
+		&video.UpdatedAt,
+		&video.Version,
+	)
+}
+
+func scanIteration(row rowScanner, iteration *models.Iteration) error {
+	return row.Scan(
+		&iteration.ID,
+		&iteration.Video.ID,
+		&iteration.URL,
+		&iteration.Length,
+		&iteration.Status,
+		&iteration.Notes,
+		&iteration.CreatedAt,
+		&iteration.UpdatedAt,
+		&iteration.Version,
+	)
+}
+
+func scanEditor(row rowScanner, editor *models.Editor) error {
+	return row.Scan(
+		&editor.ID,
+		&editor.Username,
+		&editor.Email,
+		&editor.Password,
+		&editor.CreatedAt,
+		&editor.UpdatedAt,
+		&editor.Tier,
+		&editor.Trial,
+		&editor.TrialStartedAt,
+		&editor.TrialEndsAt,
+	)
+}
+
+// Sort fields accepted by the list endpoints of each resource
+var (
+	userSortFields = map[string]sortField{
+		"createdAt": {"u.created_at", func(item interface{}) string { return item.(*models.User).CreatedAt }},
+		"username":  {"u.username", func(item interface{}) string { return item.(*models.User).Username }},
+	}
+	videoSortFields = map[string]sortField{
+		"createdAt": {"v.created_at", func(item interface{}) string { return item.(*models.Video).CreatedAt }},
+		"updatedAt": {"v.updated_at", func(item interface{}) string { return item.(*models.Video).UpdatedAt }},
+		"title":     {"COALESCE(v.title, '')", func(item interface{}) string { return item.(*models.Video).Title }},
+	}
+	iterationSortFields = map[string]sortField{
+		"createdAt": {"i.created_at", func(item interface{}) string { return item.(*models.Iteration).CreatedAt }},
+		"updatedAt": {"i.updated_at", func(item interface{}) string { return item.(*models.Iteration).UpdatedAt }},
+	}
+	editorSortFields = map[string]sortField{
+		"createdAt": {"e.created_at", func(item interface{}) string { return item.(*models.Editor).CreatedAt }},
+		"username":  {"e.username", func(item interface{}) string { return item.(*models.Editor).Username }},
+	}
+)
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// searchHeadlineOptions configures the highlighted fragments of search results
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
+// searchEscapedDocument escapes the HTML in the matched text so that the only
+// markup of headlines is the one highlighting the terms. The parser of
+// ts_headline skips entities, so they don't break matches.
+const searchEscapedDocument = `replace(replace(replace(replace(replace(m.document,
+	'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+
+// searchQuery matches videos, iteration notes and comments in the channels of
+// the organizations of the user. Headlines are only computed for the returned
+// page as they are costly.
+var searchQuery = `
+	WITH q AS (SELECT websearch_to_tsquery('english', $1) AS query),
+	matches AS (
+		SELECT 'video' AS type, v.id, v.id AS video_id, COALESCE(v.title, '') AS title,
+			concat_ws(' ', v.title, v.description, array_to_string(v.keywords, ' ')) AS document,
+			ts_rank(v.search_vector, q.query) AS rank
+		FROM videos v JOIN channels c ON c.id = v.channel_id, q
+		WHERE ` + memberCondition("$2", models.PermissionVideoRead) + ` AND v.deleted_at IS NULL AND v.search_vector @@ q.query
+		UNION ALL
+		SELECT 'iteration', i.id, v.id, COALESCE(v.title, ''), COALESCE(i.notes, ''), ts_rank(i.search_vector, q.query)
+		FROM iterations i JOIN videos v ON v.id = i.video_id JOIN channels c ON c.id = v.channel_id, q
+		WHERE ` + memberCondition("$2", models.PermissionVideoRead) + ` AND i.deleted_at IS NULL AND i.search_vector @@ q.query
+		UNION ALL
+		SELECT 'comment', ic.id, v.id, COALESCE(v.title, ''), ic.body, ts_rank(ic.search_vector, q.query)
+		FROM iteration_comments ic JOIN iterations i ON i.id = ic.iteration_id JOIN videos v ON v.id = i.video_id
+			JOIN channels c ON c.id = v.channel_id, q
+		WHERE ` + memberCondition("$2", models.PermissionVideoRead) + ` AND i.deleted_at IS NULL AND v.deleted_at IS NULL AND ic.search_vector @@ q.query
+	)
+	SELECT m.type, m.id, m.video_id, m.title, ts_headline('english', ` + searchEscapedDocument + `, q.query, $3), m.rank, COUNT(*) OVER ()
+	FROM matches m, q
+	WHERE $4 = '' OR m.type = $4
+	ORDER BY m.rank DESC, m.id
+	LIMIT $5`
+
+// Search runs a ranked full-text search over the videos, iteration notes and
+// comments visible to a user. kind restricts the results to one type when not empty.
+func (db *DB) Search(userID, query, kind string, limit int) (*models.List, error) {
+	rows, err := db.QueryContext(context.Background(), searchQuery, query, userID, searchHeadlineOptions, kind, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching: %w", err)
+	}
+	defer rows.Close()
+
+	list := &models.List{}
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var result models.SearchResult
+		if err := rows.Scan(
+			&result.Type,
+			&result.ID,
+			&result.VideoID,
+			&result.Title,
+			&result.Headline,
+			&result.Rank,
+			&list.Total,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning search result: %w", err)
+		}
+
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	list.Data = results
+
+	return list, nil
+}
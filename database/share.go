@@ -0,0 +1,244 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+const (
+	shareLinkColumns   = "sl.id, sl.iteration_id, sl.password_hash IS NOT NULL, COALESCE(sl.password_hash, ''), COALESCE(sl.created_by::text, ''), sl.created_at, sl.expires_at, sl.revoked_at, sl.revoked_at IS NULL AND sl.expires_at > NOW()"
+	shareAccessColumns = "a.id, a.share_link_id, a.action, a.display_name, a.ip, a.user_agent, a.accessed_at"
+	commentColumns     = "cm.id, cm.iteration_id, COALESCE(cm.user_id::text, ''), COALESCE(cm.share_link_id::text, ''), cm.author_name, cm.timecode, cm.body, cm.created_at, cm.resolved_at, COALESCE(cm.resolved_by::text, '')"
+)
+
+func scanShareLink(row rowScanner, link *models.ShareLink) error {
+	return row.Scan(
+		&link.ID,
+		&link.IterationID,
+		&link.PasswordProtected,
+		&link.PasswordHash,
+		&link.CreatedBy,
+		&link.CreatedAt,
+		&link.ExpiresAt,
+		&link.RevokedAt,
+		&link.Active,
+	)
+}
+
+func scanShareAccess(row rowScanner, access *models.ShareAccess) error {
+	return row.Scan(
+		&access.ID,
+		&access.ShareLinkID,
+		&access.Action,
+		&access.DisplayName,
+		&access.IP,
+		&access.UserAgent,
+		&access.AccessedAt,
+	)
+}
+
+func scanComment(row rowScanner, comment *models.Comment) error {
+	return row.Scan(
+		&comment.ID,
+		&comment.IterationID,
+		&comment.UserID,
+		&comment.ShareLinkID,
+		&comment.AuthorName,
+		&comment.Timecode,
+		&comment.Body,
+		&comment.CreatedAt,
+		&comment.ResolvedAt,
+		&comment.ResolvedBy,
+	)
+}
+
+// CreateShareLink creates a share link for an iteration that lasts lifetime.
+// tokenHash identifies the link, and passwordHash is empty for links without
+// a password.
+func (db *DB) CreateShareLink(iterationID, createdBy, tokenHash, passwordHash string, lifetime time.Duration) (*models.ShareLink, error) {
+	var link models.ShareLink
+	err := scanShareLink(db.QueryRowContext(context.Background(), `
+		INSERT INTO share_links AS sl (iteration_id, token_hash, password_hash, created_by, expires_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, NOW() + $5::bigint * INTERVAL '1 second')
+		RETURNING `+shareLinkColumns, iterationID, tokenHash, passwordHash, createdBy, int64(lifetime/time.Second)), &link)
+	if err != nil {
+		return nil, fmt.Errorf("error creating share link: %w", err)
+	}
+	return &link, nil
+}
+
+// GetShareLinksByIteration retrieves the share links of an iteration, newest
+// first, including revoked and expired ones
+func (db *DB) GetShareLinksByIteration(iterationID string) ([]models.ShareLink, error) {
+	links := []models.ShareLink{}
+	rows, err := db.QueryContext(context.Background(), "SELECT "+shareLinkColumns+" FROM share_links sl WHERE sl.iteration_id = $1 ORDER BY sl.created_at DESC", iterationID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching share links: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var link models.ShareLink
+		if err := scanShareLink(rows, &link); err != nil {
+			return nil, fmt.Errorf("error scanning share link: %w", err)
+		}
+		links = append(links, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return links, nil
+}
+
+// GetShareLinkByToken retrieves the share link with a token hash, whether or
+// not it is still active. Links of iterations in the trash are not found.
+func (db *DB) GetShareLinkByToken(tokenHash string) (*models.ShareLink, error) {
+	var link models.ShareLink
+	err := scanShareLink(db.QueryRowContext(context.Background(), `
+		SELECT `+shareLinkColumns+` FROM share_links sl JOIN iterations i ON i.id = sl.iteration_id
+		WHERE sl.token_hash = $1 AND i.deleted_at IS NULL`, tokenHash), &link)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error fetching share link: %w", err)
+	}
+	return &link, nil
+}
+
+// RevokeShareLink revokes a share link of an iteration right away
+func (db *DB) RevokeShareLink(iterationID, linkID string) (*models.ShareLink, error) {
+	var link models.ShareLink
+	err := scanShareLink(db.QueryRowContext(context.Background(), `
+		UPDATE share_links sl SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE sl.id = $1 AND sl.iteration_id = $2
+		RETURNING `+shareLinkColumns, linkID, iterationID), &link)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error revoking share link: %w", err)
+	}
+	return &link, nil
+}
+
+// RecordShareAccess adds an entry to the access log of a share link
+func (db *DB) RecordShareAccess(access *models.ShareAccess) error {
+	_, err := db.ExecContext(context.Background(), `
+		INSERT INTO share_link_accesses (share_link_id, action, display_name, ip, user_agent) VALUES ($1, $2, $3, $4, $5)`,
+		access.ShareLinkID, access.Action, access.DisplayName, access.IP, access.UserAgent)
+	if err != nil {
+		return fmt.Errorf("error recording share link access: %w", err)
+	}
+	return nil
+}
+
+// CountRecentShareAccesses counts the entries of an action in the access log
+// of a share link from an IP address within window. When there are some it
+// also returns how long until the oldest of them leaves the window.
+func (db *DB) CountRecentShareAccesses(linkID, action, ip string, window time.Duration) (int, time.Duration, error) {
+	var count int
+	var remaining float64
+	err := db.QueryRowContext(context.Background(), `
+		SELECT COUNT(*), COALESCE(EXTRACT(EPOCH FROM MIN(accessed_at) + $4::bigint * INTERVAL '1 second' - NOW()), 0)
+		FROM share_link_accesses
+		WHERE share_link_id = $1 AND action = $2 AND ip = $3 AND accessed_at > NOW() - $4::bigint * INTERVAL '1 second'`,
+		linkID, action, ip, int64(window/time.Second)).Scan(&count, &remaining)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error counting share link accesses: %w", err)
+	}
+	return count, time.Duration(remaining * float64(time.Second)), nil
+}
+
+// GetShareAccesses retrieves the access log of a share link of an iteration,
+// newest first
+func (db *DB) GetShareAccesses(iterationID, linkID string, limit int) ([]models.ShareAccess, error) {
+	accesses := []models.ShareAccess{}
+	rows, err := db.QueryContext(context.Background(), `
+		SELECT `+shareAccessColumns+` FROM share_link_accesses a JOIN share_links sl ON sl.id = a.share_link_id
+		WHERE a.share_link_id = $1 AND sl.iteration_id = $2
+		ORDER BY a.accessed_at DESC, a.id DESC LIMIT $3`, linkID, iterationID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching share link accesses: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var access models.ShareAccess
+		if err := scanShareAccess(rows, &access); err != nil {
+			return nil, fmt.Errorf("error scanning share link access: %w", err)
+		}
+		accesses = append(accesses, access)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return accesses, nil
+}
+
+// CreateComment adds a comment to an iteration
+func (db *DB) CreateComment(comment *models.Comment) (*models.Comment, error) {
+	var created models.Comment
+	err := scanComment(db.QueryRowContext(context.Background(), `
+		INSERT INTO iteration_comments AS cm (iteration_id, user_id, share_link_id, author_name, timecode, body)
+		VALUES ($1, NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, $4, $5, $6)
+		RETURNING `+commentColumns,
+		comment.IterationID, comment.UserID, comment.ShareLinkID, comment.AuthorName, comment.Timecode, comment.Body), &created)
+	if err != nil {
+		return nil, fmt.Errorf("error creating comment: %w", err)
+	}
+	return &created, nil
+}
+
+// GetCommentsByIteration retrieves the comments of an iteration in the order
+// of their timecodes
+func (db *DB) GetCommentsByIteration(iterationID string) ([]models.Comment, error) {
+	comments := []models.Comment{}
+	rows, err := db.QueryContext(context.Background(), "SELECT "+commentColumns+" FROM iteration_comments cm WHERE cm.iteration_id = $1 ORDER BY cm.timecode, cm.created_at", iterationID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching comments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var comment models.Comment
+		if err := scanComment(rows, &comment); err != nil {
+			return nil, fmt.Errorf("error scanning comment: %w", err)
+		}
+		comments = append(comments, comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return comments, nil
+}
+
+// ResolveComment marks a comment of an iteration as resolved by a user, or
+// as open again if resolved is false
+func (db *DB) ResolveComment(iterationID, commentID, userID string, resolved bool) (*models.Comment, error) {
+	var comment models.Comment
+	err := scanComment(db.QueryRowContext(context.Background(), `
+		UPDATE iteration_comments cm
+		SET resolved_at = CASE WHEN $3 THEN COALESCE(resolved_at, NOW()) END,
+			resolved_by = CASE WHEN $3 THEN COALESCE(resolved_by, $4::uuid) END
+		WHERE cm.id = $1 AND cm.iteration_id = $2
+		RETURNING `+commentColumns, commentID, iterationID, resolved, userID), &comment)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error resolving comment: %w", err)
+	}
+	return &comment, nil
+}
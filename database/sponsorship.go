@@ -0,0 +1,230 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// ErrApprovalDecided is returned when an approval that was already approved
+// or rejected is decided again
+var ErrApprovalDecided = errors.New("approval already decided")
+
+const (
+	sponsorshipColumns     = "sp.sponsor, sp.approver_emails, sp.updated_at"
+	sponsorApprovalColumns = "sa.id, sa.video_id, sa.iteration_id, sa.approver_email, sa.metadata, sa.status, sa.comment, COALESCE(sa.requested_by::text, ''), sa.requested_at, sa.expires_at, sa.decided_at"
+)
+
+func scanSponsorship(row rowScanner, sponsorship *models.Sponsorship) error {
+	return row.Scan(
+		&sponsorship.Sponsor,
+		pq.Array(&sponsorship.ApproverEmails),
+		&sponsorship.UpdatedAt,
+	)
+}
+
+func scanSponsorApproval(row rowScanner, approval *models.SponsorApproval) error {
+	var metadata []byte
+	err := row.Scan(
+		&approval.ID,
+		&approval.VideoID,
+		&approval.IterationID,
+		&approval.ApproverEmail,
+		&metadata,
+		&approval.Status,
+		&approval.Comment,
+		&approval.RequestedBy,
+		&approval.RequestedAt,
+		&approval.ExpiresAt,
+		&approval.DecidedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(metadata, &approval.Metadata); err != nil {
+		return fmt.Errorf("error decoding approval metadata: %w", err)
+	}
+	return nil
+}
+
+// GetSponsorship retrieves the sponsorship of a video
+func (db *DB) GetSponsorship(videoID string) (*models.Sponsorship, error) {
+	var sponsorship models.Sponsorship
+	err := scanSponsorship(db.QueryRowContext(context.Background(), "SELECT "+sponsorshipColumns+" FROM video_sponsorships sp WHERE sp.video_id = $1", videoID), &sponsorship)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error fetching sponsorship: %w", err)
+	}
+	return &sponsorship, nil
+}
+
+// PutSponsorship marks a video as sponsored or changes its sponsorship and
+// returns the new version of the video. If expectedVersion is not zero the
+// sponsorship is only changed on that version.
+func (db *DB) PutSponsorship(videoID string, sponsorship *models.Sponsorship, expectedVersion int) (*models.Sponsorship, int, error) {
+	var saved models.Sponsorship
+	var version int
+	err := db.inTx(func(tx *sql.Tx) error {
+		var err error
+		if version, err = bumpVideoVersion(tx, videoID, expectedVersion); err != nil {
+			return err
+		}
+		return scanSponsorship(tx.QueryRow(`
+			INSERT INTO video_sponsorships AS sp (video_id, sponsor, approver_emails)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (video_id) DO UPDATE
+			SET sponsor = EXCLUDED.sponsor, approver_emails = EXCLUDED.approver_emails, updated_at = NOW()
+			RETURNING `+sponsorshipColumns,
+			videoID, sponsorship.Sponsor, pq.Array(sponsorship.ApproverEmails)), &saved)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &saved, version, nil
+}
+
+// DeleteSponsorship marks a video as no longer sponsored and returns its new
+// version. Its approvals are kept in case it is sponsored again.
+func (db *DB) DeleteSponsorship(videoID string, expectedVersion int) (int, error) {
+	var version int
+	err := db.inTx(func(tx *sql.Tx) error {
+		var err error
+		if version, err = bumpVideoVersion(tx, videoID, expectedVersion); err != nil {
+			return err
+		}
+		result, err := tx.Exec("DELETE FROM video_sponsorships WHERE video_id = $1", videoID)
+		if err != nil {
+			return fmt.Errorf("error deleting sponsorship: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return version, err
+}
+
+// CreateSponsorApprovals asks approvers to approve an iteration of a video
+// with its metadata. Pending requests of the same approvers are replaced, so
+// their links stop working.
+func (db *DB) CreateSponsorApprovals(videoID, iterationID, requestedBy string, emails []string, metadata models.SponsorMetadata) ([]models.SponsorApproval, error) {
+	data, err := json.Marshal(&metadata)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding approval metadata: %w", err)
+	}
+
+	approvals := []models.SponsorApproval{}
+	err = db.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM sponsor_approvals WHERE video_id = $1 AND approver_email = ANY($2) AND status = $3",
+			videoID, pq.Array(emails), models.ApprovalPending)
+		if err != nil {
+			return fmt.Errorf("error replacing approvals: %w", err)
+		}
+
+		for _, email := range emails {
+			var approval models.SponsorApproval
+			err := scanSponsorApproval(tx.QueryRow(`
+				INSERT INTO sponsor_approvals AS sa (video_id, iteration_id, approver_email, metadata, requested_by, expires_at)
+				VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, NOW() + $6::bigint * INTERVAL '1 second')
+				RETURNING `+sponsorApprovalColumns,
+				videoID, iterationID, email, data, requestedBy, int64(models.SponsorApprovalLifetime/time.Second)), &approval)
+			if err != nil {
+				return fmt.Errorf("error creating approval: %w", err)
+			}
+			approvals = append(approvals, approval)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return approvals, nil
+}
+
+// GetSponsorApprovals retrieves the approvals of a video, the latest decided
+// first and the pending ones last
+func (db *DB) GetSponsorApprovals(videoID string) ([]models.SponsorApproval, error) {
+	approvals := []models.SponsorApproval{}
+	rows, err := db.QueryContext(context.Background(), `
+		SELECT `+sponsorApprovalColumns+` FROM sponsor_approvals sa WHERE sa.video_id = $1
+		ORDER BY sa.decided_at DESC NULLS LAST, sa.requested_at DESC`, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching approvals: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var approval models.SponsorApproval
+		if err := scanSponsorApproval(rows, &approval); err != nil {
+			return nil, fmt.Errorf("error scanning approval: %w", err)
+		}
+		approvals = append(approvals, approval)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return approvals, nil
+}
+
+// GetSponsorApproval retrieves an approval by ID. Approvals of videos in the
+// trash are not found.
+func (db *DB) GetSponsorApproval(approvalID string) (*models.SponsorApproval, error) {
+	var approval models.SponsorApproval
+	err := scanSponsorApproval(db.QueryRowContext(context.Background(), `
+		SELECT `+sponsorApprovalColumns+` FROM sponsor_approvals sa JOIN videos v ON v.id = sa.video_id
+		WHERE sa.id = $1 AND v.deleted_at IS NULL`, approvalID), &approval)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error fetching approval: %w", err)
+	}
+	return &approval, nil
+}
+
+// DecideSponsorApproval records the decision of an approver on a pending
+// approval and notifies whoever requested it
+func (db *DB) DecideSponsorApproval(approvalID, status, comment string) (*models.SponsorApproval, error) {
+	var approval models.SponsorApproval
+	err := db.inTx(func(tx *sql.Tx) error {
+		err := scanSponsorApproval(tx.QueryRow(`
+			UPDATE sponsor_approvals sa SET status = $2, comment = $3, decided_at = NOW()
+			WHERE sa.id = $1 AND sa.status = $4
+			RETURNING `+sponsorApprovalColumns, approvalID, status, comment, models.ApprovalPending), &approval)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrApprovalDecided
+			}
+			return fmt.Errorf("error deciding approval: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO notifications (user_id, kind, message)
+			SELECT u.id, $2, $3::text || ' ' || $4::text || ' ' || COALESCE(NULLIF(v.title, ''), 'an untitled video') || '.'
+			FROM users u, videos v WHERE u.id = NULLIF($1, '')::uuid AND v.id = $5`,
+			approval.RequestedBy, models.NotificationSponsorApproval, approval.ApproverEmail, status, approval.VideoID)
+		if err != nil {
+			return fmt.Errorf("error creating notification: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
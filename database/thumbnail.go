@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+const thumbnailColumns = "t.id, t.video_id, t.content_type, t.width, t.height, t.size, t.active, t.created_at"
+
+func scanThumbnail(row rowScanner, thumbnail *models.Thumbnail) error {
+	return row.Scan(
+		&thumbnail.ID,
+		&thumbnail.VideoID,
+		&thumbnail.ContentType,
+		&thumbnail.Width,
+		&thumbnail.Height,
+		&thumbnail.Size,
+		&thumbnail.Active,
+		&thumbnail.CreatedAt,
+	)
+}
+
+// CreateThumbnail stores a thumbnail candidate for a video. The first
+// thumbnail of a video becomes its active one.
+func (db *DB) CreateThumbnail(videoID string, thumbnail *models.Thumbnail, data []byte) (*models.Thumbnail, error) {
+	var created models.Thumbnail
+	err := scanThumbnail(db.QueryRowContext(context.Background(), `
+		INSERT INTO thumbnails (video_id, content_type, width, height, size, data, active)
+		VALUES ($1, $2, $3, $4, $5, $6, NOT EXISTS (SELECT 1 FROM thumbnails WHERE video_id = $1 AND active))
+		RETURNING id, video_id, content_type, width, height, size, active, created_at`,
+		videoID, thumbnail.ContentType, thumbnail.Width, thumbnail.Height, thumbnail.Size, data), &created)
+	if err != nil {
+		return nil, fmt.Errorf("error creating thumbnail: %w", err)
+	}
+	return &created, nil
+}
+
+// GetThumbnailsByVideo retrieves the thumbnail candidates of a video
+func (db *DB) GetThumbnailsByVideo(videoID string) ([]models.Thumbnail, error) {
+	thumbnails := []models.Thumbnail{}
+	rows, err := db.QueryContext(context.Background(), "SELECT "+thumbnailColumns+" FROM thumbnails t WHERE t.video_id = $1 ORDER BY t.created_at", videoID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching thumbnails: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var thumbnail models.Thumbnail
+		if err := scanThumbnail(rows, &thumbnail); err != nil {
+			return nil, fmt.Errorf("error scanning thumbnail: %w", err)
+		}
+		thumbnails = append(thumbnails, thumbnail)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return thumbnails, nil
+}
+
+// GetThumbnailImage retrieves the metadata and image data of a thumbnail
+func (db *DB) GetThumbnailImage(videoID, thumbnailID string) (*models.Thumbnail, []byte, error) {
+	return db.thumbnailImage("t.video_id = $1 AND t.id = $2", videoID, thumbnailID)
+}
+
+// GetActiveThumbnailImage retrieves the active thumbnail of a video, or
+// ErrNotFound if it has none
+func (db *DB) GetActiveThumbnailImage(videoID string) (*models.Thumbnail, []byte, error) {
+	return db.thumbnailImage("t.video_id = $1 AND t.active", videoID)
+}
+
+func (db *DB) thumbnailImage(condition string, args ...interface{}) (*models.Thumbnail, []byte, error) {
+	var thumbnail models.Thumbnail
+	var data []byte
+	err := db.QueryRowContext(context.Background(), "SELECT "+thumbnailColumns+", t.data FROM thumbnails t WHERE "+condition, args...).Scan(
+		&thumbnail.ID,
+		&thumbnail.VideoID,
+		&thumbnail.ContentType,
+		&thumbnail.Width,
+		&thumbnail.Height,
+		&thumbnail.Size,
+		&thumbnail.Active,
+		&thumbnail.CreatedAt,
+		&data,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, fmt.Errorf("error fetching thumbnail: %w", err)
+	}
+	return &thumbnail, data, nil
+}
+
+// ActivateThumbnail makes a thumbnail the active one of its video
+func (db *DB) ActivateThumbnail(videoID, thumbnailID string) (*models.Thumbnail, error) {
+	var thumbnail models.Thumbnail
+	err := db.inTx(func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM thumbnails WHERE video_id = $1 AND id = $2)", videoID, thumbnailID).Scan(&exists); err != nil {
+			return fmt.Errorf("error fetching thumbnail: %w", err)
+		}
+		if !exists {
+			return ErrNotFound
+		}
+
+		// Deactivate first so the unique index on the active thumbnail holds
+		if _, err := tx.Exec("UPDATE thumbnails SET active = FALSE WHERE video_id = $1 AND active AND id <> $2", videoID, thumbnailID); err != nil {
+			return fmt.Errorf("error deactivating thumbnail: %w", err)
+		}
+		return scanThumbnail(tx.QueryRow("UPDATE thumbnails t SET active = TRUE WHERE t.id = $1 RETURNING "+thumbnailColumns, thumbnailID), &thumbnail)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &thumbnail, nil
+}
+
+// DeleteThumbnail deletes a thumbnail candidate. Deleting the active
+// thumbnail leaves the video without one.
+func (db *DB) DeleteThumbnail(videoID, thumbnailID string) error {
+	result, err := db.ExecContext(context.Background(), "DELETE FROM thumbnails WHERE video_id = $1 AND id = $2", videoID, thumbnailID)
+	if err != nil {
+		return fmt.Errorf("error deleting thumbnail: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
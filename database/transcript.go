@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+const transcriptColumns = "t.iteration_id, t.language, t.source, t.text, t.created_at, t.updated_at"
+
+func scanTranscript(row rowScanner, transcript *models.Transcript) error {
+	return row.Scan(
+		&transcript.IterationID,
+		&transcript.Language,
+		&transcript.Source,
+		&transcript.Text,
+		&transcript.CreatedAt,
+		&transcript.UpdatedAt,
+	)
+}
+
+// PutTranscript creates or replaces the transcript of an iteration
+func (db *DB) PutTranscript(transcript *models.Transcript) (*models.Transcript, error) {
+	var stored models.Transcript
+	err := scanTranscript(db.QueryRowContext(context.Background(), `
+		INSERT INTO iteration_transcripts AS t (iteration_id, language, source, text)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (iteration_id) DO UPDATE
+		SET language = EXCLUDED.language, source = EXCLUDED.source, text = EXCLUDED.text, updated_at = NOW()
+		RETURNING `+transcriptColumns,
+		transcript.IterationID, transcript.Language, transcript.Source, transcript.Text), &stored)
+	if err != nil {
+		return nil, fmt.Errorf("error saving transcript: %w", err)
+	}
+	return &stored, nil
+}
+
+// GetTranscript retrieves the transcript of an iteration
+func (db *DB) GetTranscript(iterationID string) (*models.Transcript, error) {
+	var transcript models.Transcript
+	err := scanTranscript(db.QueryRowContext(context.Background(), "SELECT "+transcriptColumns+" FROM iteration_transcripts t WHERE t.iteration_id = $1", iterationID), &transcript)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error fetching transcript: %w", err)
+	}
+	return &transcript, nil
+}
+
+// GetLatestTranscriptByVideo retrieves the transcript of the latest
+// iteration of a video that has one
+func (db *DB) GetLatestTranscriptByVideo(videoID string) (*models.Transcript, error) {
+	var transcript models.Transcript
+	err := scanTranscript(db.QueryRowContext(context.Background(), `
+		SELECT `+transcriptColumns+` FROM iteration_transcripts t
+		JOIN iterations i ON i.id = t.iteration_id
+		WHERE i.video_id = $1 AND i.deleted_at IS NULL
+		ORDER BY i.created_at DESC LIMIT 1`, videoID), &transcript)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error fetching transcript: %w", err)
+	}
+	return &transcript, nil
+}
+
+// DeleteTranscript deletes the transcript of an iteration
+func (db *DB) DeleteTranscript(iterationID string) error {
+	result, err := db.ExecContext(context.Background(), "DELETE FROM iteration_transcripts WHERE iteration_id = $1", iterationID)
+	if err != nil {
+		return fmt.Errorf("error deleting transcript: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// ErrParentDeleted is returned when restoring an item whose parent is still in the trash
+var ErrParentDeleted = errors.New("parent resource is deleted")
+
+// Deleting moves a row to the trash by setting deleted_at. Dependent rows are
+// trashed with the same timestamp so that restoring the parent brings back
+// exactly the rows that were deleted along with it.
+
+// DeleteChannel moves a channel, its videos and their iterations to the
+// trash. If expectedVersion is not zero only that version is deleted.
+func (db *DB) DeleteChannel(channelID string, expectedVersion int) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		var deletedAt time.Time
+		err := tx.QueryRow("UPDATE channels SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2) RETURNING deleted_at", channelID, expectedVersion).Scan(&deletedAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return versionConflict(tx, "channels", channelID)
+			}
+			return fmt.Errorf("error deleting channel: %w", err)
+		}
+
+		if _, err := tx.Exec("UPDATE iterations SET deleted_at = $1 WHERE deleted_at IS NULL AND video_id IN (SELECT id FROM videos WHERE channel_id = $2 AND deleted_at IS NULL)", deletedAt, channelID); err != nil {
+			return fmt.Errorf("error deleting iterations: %w", err)
+		}
+		if _, err := tx.Exec("UPDATE videos SET deleted_at = $1 WHERE channel_id = $2 AND deleted_at IS NULL", deletedAt, channelID); err != nil {
+			return fmt.Errorf("error deleting videos: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// DeleteVideo moves a video and its iterations to the trash. If
+// expectedVersion is not zero only that version is deleted.
+func (db *DB) DeleteVideo(videoID string, expectedVersion int) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		var deletedAt time.Time
+		err := tx.QueryRow("UPDATE videos SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2) RETURNING deleted_at", videoID, expectedVersion).Scan(&deletedAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return versionConflict(tx, "videos", videoID)
+			}
+			return fmt.Errorf("error deleting video: %w", err)
+		}
+
+		if _, err := tx.Exec("UPDATE iterations SET deleted_at = $1 WHERE video_id = $2 AND deleted_at IS NULL", deletedAt, videoID); err != nil {
+			return fmt.Errorf("error deleting iterations: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// DeleteIteration moves an iteration to the trash. If expectedVersion is not
+// zero only that version is deleted.
+func (db *DB) DeleteIteration(iterationID string, expectedVersion int) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec("UPDATE iterations SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)", iterationID, expectedVersion)
+		if err != nil {
+			return fmt.Errorf("error deleting iteration: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return versionConflict(tx, "iterations", iterationID)
+		}
+
+		return bumpIterationVideoVersion(tx, iterationID)
+	})
+}
+
+// RestoreChannel takes a channel the user manages out of the trash
+// along with the videos and iterations deleted with it
+func (db *DB) RestoreChannel(userID, channelID string) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		var deletedAt time.Time
+		err := tx.QueryRow("SELECT deleted_at FROM channels c WHERE c.id = $1 AND "+memberCondition("$2", models.PermissionChannelManage)+" AND c.deleted_at IS NOT NULL FOR UPDATE OF c", channelID, userID).Scan(&deletedAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error fetching channel: %w", err)
+		}
+
+		if _, err := tx.Exec("UPDATE channels SET deleted_at = NULL WHERE id = $1", channelID); err != nil {
+			return fmt.Errorf("error restoring channel: %w", err)
+		}
+		if _, err := tx.Exec("UPDATE videos SET deleted_at = NULL WHERE channel_id = $1 AND deleted_at = $2", channelID, deletedAt); err != nil {
+			return fmt.Errorf("error restoring videos: %w", err)
+		}
+		if _, err := tx.Exec("UPDATE iterations SET deleted_at = NULL WHERE deleted_at = $1 AND video_id IN (SELECT id FROM videos WHERE channel_id = $2)", deletedAt, channelID); err != nil {
+			return fmt.Errorf("error restoring iterations: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// RestoreVideo takes a video the user is allowed to delete out of the trash
+// along with the iterations deleted with it
+func (db *DB) RestoreVideo(userID, videoID string) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		var deletedAt time.Time
+		var channelDeleted bool
+		err := tx.QueryRow(`
+			SELECT v.deleted_at, c.deleted_at IS NOT NULL
+			FROM videos v JOIN channels c ON c.id = v.channel_id
+			WHERE v.id = $1 AND `+memberCondition("$2", models.PermissionVideoDelete)+` AND v.deleted_at IS NOT NULL
+			FOR UPDATE OF v`, videoID, userID).Scan(&deletedAt, &channelDeleted)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("error fetching video: %w", err)
+		}
+		if channelDeleted {
+			return ErrParentDeleted
+		}
+
+		if _, err := tx.Exec("UPDATE videos SET deleted_at = NULL WHERE id = $1", videoID); err != nil {
+			return fmt.Errorf("error restoring video: %w", err)
+		}
+		if _, err := tx.Exec("UPDATE iterations SET deleted_at = NULL WHERE video_id = $1 AND deleted_at = $2", videoID, deletedAt); err != nil {
+			return fmt.Errorf("error restoring iterations: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// RestoreIteration takes an iteration the user is allowed to delete out of
+// the trash
+func (db *DB) RestoreIteration(userID, iterationID string) error {
+	var videoDeleted bool
+	err := db.QueryRowContext(context.Background(), `
+		SELECT v.deleted_at IS NOT NULL
+		FROM iterations i JOIN videos v ON v.id = i.video_id JOIN channels c ON c.id = v.channel_id
+		WHERE i.id = $1 AND `+memberCondition("$2", models.PermissionIterationDelete)+` AND i.deleted_at IS NOT NULL`, iterationID, userID).Scan(&videoDeleted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("error fetching iteration: %w", err)
+	}
+	if videoDeleted {
+		return ErrParentDeleted
+	}
+
+	return db.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec("UPDATE iterations SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", iterationID)
+		if err != nil {
+			return fmt.Errorf("error restoring iteration: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		return bumpIterationVideoVersion(tx, iterationID)
+	})
+}
+
+// trashQuery lists the items deleted directly in the channels of a user's
+// organizations that their role lets them restore. Rows trashed along with their parent are left out since they are
+// restored with it.
+var trashQuery = `
+	SELECT 'channel', c.id, c.name, c.id, NULL::uuid, c.deleted_at
+	FROM channels c
+	WHERE ` + memberCondition("$1", models.PermissionChannelManage) + ` AND c.deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'video', v.id, COALESCE(v.title, ''), c.id, v.id, v.deleted_at
+	FROM videos v JOIN channels c ON c.id = v.channel_id
+	WHERE ` + memberCondition("$1", models.PermissionVideoDelete) + ` AND v.deleted_at IS NOT NULL AND c.deleted_at IS DISTINCT FROM v.deleted_at
+	UNION ALL
+	SELECT 'iteration', i.id, i.url, c.id, v.id, i.deleted_at
+	FROM iterations i JOIN videos v ON v.id = i.video_id JOIN channels c ON c.id = v.channel_id
+	WHERE ` + memberCondition("$1", models.PermissionIterationDelete) + ` AND i.deleted_at IS NOT NULL AND v.deleted_at IS DISTINCT FROM i.deleted_at
+	ORDER BY 6 DESC`
+
+// ListTrash retrieves the deleted items of a user, with the time each will be purged at
+func (db *DB) ListTrash(userID string, retention time.Duration) ([]models.TrashItem, error) {
+	items := []models.TrashItem{}
+	rows, err := db.QueryContext(context.Background(), trashQuery, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching trash: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.TrashItem
+		var videoID sql.NullString
+		var deletedAt time.Time
+		if err := rows.Scan(
+			&item.Type,
+			&item.ID,
+			&item.Name,
+			&item.ChannelID,
+			&videoID,
+			&deletedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning trash item: %w", err)
+		}
+
+		item.VideoID = videoID.String
+		item.DeletedAt = deletedAt.Format(time.RFC3339Nano)
+		item.PurgeAt = deletedAt.Add(retention).Format(time.RFC3339Nano)
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return items, nil
+}
+
+// PurgeTrash permanently deletes the channels, videos and iterations that
+// have been in the trash for longer than the retention period. Dependent
+// rows are removed by the cascading foreign keys.
+func (db *DB) PurgeTrash(ctx context.Context, retention time.Duration) (int64, error) {
+	var purged int64
+	for _, table := range []string{"channels", "videos", "iterations"} {
+		result, err := db.ExecContext(ctx, "DELETE FROM "+table+" WHERE deleted_at < NOW() - $1 * INTERVAL '1 second'", retention.Seconds())
+		if err != nil {
+			return purged, fmt.Errorf("error purging %s: %w", table, err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return purged, fmt.Errorf("error getting rows affected: %w", err)
+		}
+		purged += rowsAffected
+	}
+
+	return purged, nil
+}
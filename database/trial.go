@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// ErrNoTrial is returned when extending the trial of an account that isn't
+// on one without giving a tier to start a new one on
+var ErrNoTrial = errors.New("no active trial")
+
+// ErrPayingAccount is returned when starting a trial for a user with a paying
+// subscription, whose tier comes from billing
+var ErrPayingAccount = errors.New("account has a paying subscription")
+
+// trialAccounts are the tables of accounts with trials and the column that
+// links their notifications
+var trialAccounts = []struct{ table, notificationColumn string }{
+	{"users", "user_id"},
+	{"editors", "editor_id"},
+}
+
+// payingSubscription is a condition on the accounts of table that have a
+// paying subscription, given the paying statuses as the parameter param.
+// Only users have subscriptions.
+func payingSubscription(table, param string) string {
+	return "EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = " + table + ".id AND s.status = ANY(" + param + "))"
+}
+
+// WarnEndingTrials notifies the users and editors whose trial ends within
+// the given time, once per trial, and returns how many were warned
+func (db *DB) WarnEndingTrials(ctx context.Context, within time.Duration) (int64, error) {
+	var warned int64
+	for _, account := range trialAccounts {
+		result, err := db.ExecContext(ctx, `
+			WITH warned AS (
+				UPDATE `+account.table+` SET trial_warned_at = NOW()
+				WHERE trial AND trial_warned_at IS NULL AND trial_ends_at > NOW() AND trial_ends_at <= NOW() + $1::bigint * INTERVAL '1 second'
+				RETURNING id, trial_ends_at
+			)
+			INSERT INTO notifications (`+account.notificationColumn+`, kind, message)
+			SELECT id, $2, 'Your trial ends on ' || TO_CHAR(trial_ends_at, 'YYYY-MM-DD') || '. Upgrade to keep your current limits.' FROM warned`,
+			int64(within.Seconds()), models.NotificationTrialEnding)
+		if err != nil {
+			return warned, fmt.Errorf("error warning %s of ending trials: %w", account.table, err)
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return warned, fmt.Errorf("error getting rows affected: %w", err)
+		}
+		warned += count
+	}
+	return warned, nil
+}
+
+// ExpireTrials moves the users and editors whose trial has ended to the
+// free tier, notifies them and returns how many there were
+func (db *DB) ExpireTrials(ctx context.Context) (int64, error) {
+	var expired int64
+	for _, account := range trialAccounts {
+		result, err := db.ExecContext(ctx, `
+			WITH expired AS (
+				UPDATE `+account.table+` SET tier = $1, trial = FALSE, updated_at = NOW()
+				WHERE trial AND trial_ends_at <= NOW()
+				RETURNING id
+			)
+			INSERT INTO notifications (`+account.notificationColumn+`, kind, message)
+			SELECT id, $2, 'Your trial has ended and your account is now on the free tier.' FROM expired`,
+			models.Free, models.NotificationTrialEnded)
+		if err != nil {
+			return expired, fmt.Errorf("error expiring trials of %s: %w", account.table, err)
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return expired, fmt.Errorf("error getting rows affected: %w", err)
+		}
+		expired += count
+	}
+	return expired, nil
+}
+
+// ExtendUserTrial extends the trial of a user, see extendTrial
+func (db *DB) ExtendUserTrial(userID string, by time.Duration, tier models.Tier) error {
+	return db.extendTrial(trialAccounts[0].table, trialAccounts[0].notificationColumn, userID, by, tier)
+}
+
+// ExtendEditorTrial extends the trial of an editor, see extendTrial
+func (db *DB) ExtendEditorTrial(editorID string, by time.Duration, tier models.Tier) error {
+	return db.extendTrial(trialAccounts[1].table, trialAccounts[1].notificationColumn, editorID, by, tier)
+}
+
+// extendTrial pushes back the end of a running trial by the given time,
+// counting from now if it already ended. With a tier it also starts a new
+// trial on that tier for accounts that aren't on one; without it accounts
+// that aren't on a trial get ErrNoTrial. Users with a paying subscription
+// get ErrPayingAccount instead of a new trial, since it would end on the free
+// tier.
+func (db *DB) extendTrial(table, notificationColumn, id string, by time.Duration, tier models.Tier) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		var endsAt string
+		err := tx.QueryRow(`
+			UPDATE `+table+` SET
+				tier = CASE WHEN $3 = '' THEN tier ELSE $3 END,
+				trial_started_at = CASE WHEN trial THEN trial_started_at ELSE NOW() END,
+				trial_ends_at = CASE WHEN trial THEN GREATEST(trial_ends_at, NOW()) ELSE NOW() END + $2::bigint * INTERVAL '1 second',
+				trial = TRUE,
+				trial_warned_at = NULL,
+				updated_at = NOW()
+			WHERE id = $1 AND (trial OR ($3 <> '' AND NOT `+payingSubscription(table, "$4")+`))
+			RETURNING TO_CHAR(trial_ends_at, 'YYYY-MM-DD')`, id, int64(by.Seconds()), tier, pq.Array(models.PayingSubscriptionStatuses)).Scan(&endsAt)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("error extending trial: %w", err)
+			}
+			var exists, paying bool
+			err := tx.QueryRow("SELECT TRUE, "+payingSubscription(table, "$2")+" FROM "+table+" WHERE id = $1", id, pq.Array(models.PayingSubscriptionStatuses)).Scan(&exists, &paying)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("error fetching account: %w", err)
+			}
+			switch {
+			case !exists:
+				return ErrNotFound
+			case paying && tier != "":
+				return ErrPayingAccount
+			}
+			return ErrNoTrial
+		}
+
+		_, err = tx.Exec("INSERT INTO notifications ("+notificationColumn+", kind, message) VALUES ($1, $2, $3)",
+			id, models.NotificationTrialExtended, "Your trial has been extended until "+endsAt+".")
+		if err != nil {
+			return fmt.Errorf("error creating notification: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// ErrQuotaExceeded is returned when using a metered feature would go over
+// the limit of the period
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ConsumeUsage adds amount to the usage of a monthly metric in the period
+// starting at period and returns the new usage. Nothing is added and
+// ErrQuotaExceeded is returned if that would go over limit, along with the
+// current usage. A negative limit means unlimited.
+func (db *DB) ConsumeUsage(userID, metric string, period time.Time, amount, limit int64) (int64, error) {
+	var used int64
+	err := db.QueryRowContext(context.Background(), `
+		INSERT INTO usage_counters AS u (user_id, period, metric, quantity)
+		SELECT $1, $2, $3, $4::bigint WHERE $5::bigint < 0 OR $4::bigint <= $5::bigint
+		ON CONFLICT (user_id, period, metric) DO UPDATE
+		SET quantity = u.quantity + EXCLUDED.quantity, updated_at = NOW()
+		WHERE $5::bigint < 0 OR u.quantity + EXCLUDED.quantity <= $5::bigint
+		RETURNING u.quantity`, userID, period, metric, amount, limit).Scan(&used)
+	if err == nil {
+		return used, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("error updating usage: %w", err)
+	}
+
+	err = db.QueryRowContext(context.Background(), "SELECT COALESCE(SUM(quantity), 0) FROM usage_counters WHERE user_id = $1 AND period = $2 AND metric = $3",
+		userID, period, metric).Scan(&used)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching usage: %w", err)
+	}
+	return used, ErrQuotaExceeded
+}
+
+// RefundUsage takes back usage that was consumed for a call that failed
+func (db *DB) RefundUsage(userID, metric string, period time.Time, amount int64) error {
+	_, err := db.ExecContext(context.Background(), `
+		UPDATE usage_counters SET quantity = GREATEST(quantity - $4::bigint, 0), updated_at = NOW()
+		WHERE user_id = $1 AND period = $2 AND metric = $3`, userID, period, metric, amount)
+	if err != nil {
+		return fmt.Errorf("error updating usage: %w", err)
+	}
+	return nil
+}
+
+// GetMonthlyUsage retrieves the usage of the monthly metrics of a user in the
+// period starting at period
+func (db *DB) GetMonthlyUsage(userID string, period time.Time) (map[string]int64, error) {
+	usage := map[string]int64{models.MetricAICalls: 0, models.MetricUploads: 0}
+	rows, err := db.QueryContext(context.Background(), "SELECT metric, quantity FROM usage_counters WHERE user_id = $1 AND period = $2", userID, period)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching usage: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var metric string
+		var quantity int64
+		if err := rows.Scan(&metric, &quantity); err != nil {
+			return nil, fmt.Errorf("error scanning usage: %w", err)
+		}
+		usage[metric] = quantity
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return usage, nil
+}
+
+// CountChannelsByOwner counts the channels whose tier and quotas are those of
+// a user, see channelOwner. Channels in the trash count until they are
+// purged since they can be restored.
+func (db *DB) CountChannelsByOwner(userID string) (int64, error) {
+	var count int64
+	err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM channels c WHERE "+channelOwner+" = $1", userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting channels: %w", err)
+	}
+	return count, nil
+}
+
+// GetStorageBytesByOwner sums the size of the thumbnails, caption tracks and
+// transcripts stored for the channels owned by a user, including the trash
+func (db *DB) GetStorageBytesByOwner(userID string) (int64, error) {
+	var size int64
+	err := db.QueryRowContext(context.Background(), `
+		SELECT
+			(SELECT COALESCE(SUM(t.size), 0) FROM thumbnails t
+				JOIN videos v ON v.id = t.video_id JOIN channels c ON c.id = v.channel_id WHERE `+channelOwner+` = $1)
+			+ (SELECT COALESCE(SUM(OCTET_LENGTH(cap.content)), 0) FROM captions cap
+				JOIN videos v ON v.id = cap.video_id JOIN channels c ON c.id = v.channel_id WHERE `+channelOwner+` = $1)
+			+ (SELECT COALESCE(SUM(OCTET_LENGTH(tr.text)), 0) FROM iteration_transcripts tr
+				JOIN iterations i ON i.id = tr.iteration_id JOIN videos v ON v.id = i.video_id JOIN channels c ON c.id = v.channel_id WHERE `+channelOwner+` = $1)`,
+		userID).Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("error computing storage: %w", err)
+	}
+	return size, nil
+}
+
+// CountEditorsByOwner counts the distinct editors assigned to the videos of
+// the channels owned by a user if the editors of videoID were replaced by
+// editorIDs. An empty videoID counts the current editors plus editorIDs.
+func (db *DB) CountEditorsByOwner(userID, videoID string, editorIDs []string) (int64, error) {
+	var count int64
+	err := db.QueryRowContext(context.Background(), `
+		SELECT COUNT(*) FROM (
+			SELECT ve.editor_id FROM video_editor ve
+			JOIN videos v ON v.id = ve.video_id JOIN channels c ON c.id = v.channel_id
+			WHERE `+channelOwner+` = $1 AND v.id::text <> $2
+			UNION
+			SELECT unnest($3::uuid[])
+		) e`, userID, videoID, pq.Array(editorIDs)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting editors: %w", err)
+	}
+	return count, nil
+}
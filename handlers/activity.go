@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// GetVideoActivityHandler lists what happened to a video and its iterations,
+// newest first, as sentences like "Alex uploaded v4"
+func GetVideoActivityHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoRead)
+		if !ok {
+			return
+		}
+
+		renderActivity(w, r, db, database.AuditFilter{VideoID: video.ID})
+	}
+}
+
+// GetChannelActivityHandler lists what happened to a channel and its videos,
+// newest first, as sentences like "Alex uploaded v4". Each event names the
+// video it is about.
+func GetChannelActivityHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channel, err := db.GetChannelByID(chi.URLParam(r, "channelID"))
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Channel not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch channel"})
+			return
+		}
+		if !authorize(w, r, db, channel.OrganizationID, models.PermissionChannelRead, "Channel") {
+			return
+		}
+
+		renderActivity(w, r, db, database.AuditFilter{ChannelID: channel.ID})
+	}
+}
+
+// renderActivity responds with a page of the activity feed matching a filter,
+// described for the authenticated user
+func renderActivity(w http.ResponseWriter, r *http.Request, db *database.DB, filter database.AuditFilter) {
+	limit, err := parseListLimit(r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, map[string]string{"error": err.Error()})
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(r)
+	activity, err := db.ListActivity(filter, userID, limit, r.URL.Query().Get("cursor"))
+	if err != nil {
+		renderListError(w, r, err, "Failed to fetch activity")
+		return
+	}
+
+	render.JSON(w, r, activity)
+}
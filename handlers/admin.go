@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// extendTrialRequest is the body of the trial extension endpoints. Tier
+// starts a new trial on that tier for accounts whose trial has ended.
+type extendTrialRequest struct {
+	Days int         `json:"days"`
+	Tier models.Tier `json:"tier"`
+}
+
+// trialFields are the fields of an account's trial recorded in the audit log
+func trialFields(tier models.Tier, trial bool, endsAt *time.Time) map[string]interface{} {
+	return map[string]interface{}{"tier": tier, "trial": trial, "trialEndsAt": endsAt}
+}
+
+// extendTrial decodes an extension request and applies it with extend
+func extendTrial(w http.ResponseWriter, r *http.Request, extend func(by time.Duration, tier models.Tier) error) bool {
+	var request extendTrialRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, map[string]string{"error": "Invalid trial extension"})
+		return false
+	}
+	if request.Days <= 0 || request.Days > 365 {
+		renderValidationError(w, r, &models.ValidationError{Field: "days", Message: "must be between 1 and 365"})
+		return false
+	}
+	switch request.Tier {
+	case "", models.Basic, models.Premium:
+	default:
+		renderValidationError(w, r, &models.ValidationError{Field: "tier", Message: "must be basic or premium"})
+		return false
+	}
+
+	if err := extend(time.Duration(request.Days)*24*time.Hour, request.Tier); err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": "Account not found"})
+		case errors.Is(err, database.ErrNoTrial):
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, map[string]string{"error": "The account is not on a trial, give a tier to start one"})
+		case errors.Is(err, database.ErrPayingAccount):
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, map[string]string{"error": "The account has a paying subscription, its tier comes from billing"})
+		default:
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to extend trial"})
+		}
+		return false
+	}
+	return true
+}
+
+// ExtendUserTrialHandler extends the trial of a user
+func ExtendUserTrialHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := chi.URLParam(r, "userID")
+		var before *models.User
+		ok := extendTrial(w, r, func(by time.Duration, tier models.Tier) error {
+			var err error
+			if before, err = db.GetUserByID(userID); err != nil {
+				return err
+			}
+			return db.ExtendUserTrial(userID, by, tier)
+		})
+		if !ok {
+			return
+		}
+
+		user, err := db.GetUserByID(userID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch user"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditExtend, models.AuditTarget{ResourceType: models.ResourceTrial, ResourceID: userID},
+			trialFields(before.Tier, before.Trial, before.TrialEndsAt), trialFields(user.Tier, user.Trial, user.TrialEndsAt))
+
+		render.JSON(w, r, user)
+	}
+}
+
+// ExtendEditorTrialHandler extends the trial of an editor
+func ExtendEditorTrialHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		editorID := chi.URLParam(r, "editorID")
+		var before *models.Editor
+		ok := extendTrial(w, r, func(by time.Duration, tier models.Tier) error {
+			var err error
+			if before, err = db.GetEditorByID(editorID); err != nil {
+				return err
+			}
+			return db.ExtendEditorTrial(editorID, by, tier)
+		})
+		if !ok {
+			return
+		}
+
+		editor, err := db.GetEditorByID(editorID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch editor"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditExtend, models.AuditTarget{ResourceType: models.ResourceTrial, ResourceID: editorID},
+			trialFields(before.Tier, before.Trial, before.TrialEndsAt), trialFields(editor.Tier, editor.Trial, editor.TrialEndsAt))
+
+		render.JSON(w, r, editor)
+	}
+}
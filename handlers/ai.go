@@ -0,0 +1,628 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/ai"
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// aiProvider resolves the AI provider of a request: the one chosen for the
+// channel, then the one chosen by the authenticated user, then the default
+func aiProvider(r *http.Request, providers *ai.Registry, channel *models.Channel) (ai.Provider, error) {
+	var names []string
+	if channel != nil {
+		names = append(names, channel.AIProvider)
+	}
+	if user, err := middleware.GetUserFromContext(r); err == nil {
+		names = append(names, user.AIProvider)
+	}
+	return providers.Resolve(names...)
+}
+
+// aiErrorStatus maps a failed call to an AI provider to a response status
+// and message
+func aiErrorStatus(err error) (int, string) {
+	var providerErr *ai.Error
+	errors.As(err, &providerErr)
+	switch {
+	case errors.Is(err, ai.ErrNotConfigured):
+		return http.StatusServiceUnavailable, "The selected AI provider is not configured"
+	case errors.Is(err, ai.ErrTimeout):
+		return http.StatusGatewayTimeout, "The AI provider took too long to respond"
+	case errors.Is(err, ai.ErrRateLimited):
+		return http.StatusServiceUnavailable, "The AI provider is rate limiting requests, try again later"
+	case providerErr != nil:
+		return http.StatusBadGateway, "The AI provider failed: " + providerErr.Kind.Error()
+	default:
+		return http.StatusInternalServerError, "Failed to get AI suggestions"
+	}
+}
+
+// renderAIError responds to a failed call to an AI provider
+func renderAIError(w http.ResponseWriter, r *http.Request, err error) {
+	fmt.Println(err)
+	if errors.Is(err, context.Canceled) {
+		// The client is gone
+		return
+	}
+
+	var providerErr *ai.Error
+	if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(providerErr.RetryAfter.Seconds())))
+	}
+	status, message := aiErrorStatus(err)
+	render.Status(r, status)
+	render.JSON(w, r, map[string]string{"error": message})
+}
+
+// draftSuggestionRequest reads the video draft of the /ai/suggestions
+// endpoints and resolves its provider. The provider of the video's channel is
+// used if the draft names one or has the ID of a saved video, in which case
+// its latest transcript is sent along. The call is counted against the AI
+// quota of the channel's account, or of the caller without a channel, and the
+// returned function gives it back if the call fails. It responds with an
+// error and returns false if any of that fails.
+func draftSuggestionRequest(w http.ResponseWriter, r *http.Request, db *database.DB, providers *ai.Registry) (ai.Provider, *ai.SuggestionRequest, func(), bool) {
+	var video models.Video
+	if err := json.NewDecoder(r.Body).Decode(&video); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, map[string]string{"error": "Invalid video data"})
+		return nil, nil, nil, false
+	}
+
+	var channel *models.Channel
+	if video.Channel.ID != "" {
+		var err error
+		channel, err = db.GetChannelByID(video.Channel.ID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Channel not found"})
+				return nil, nil, nil, false
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch channel"})
+			return nil, nil, nil, false
+		}
+		if !authorize(w, r, db, channel.OrganizationID, models.PermissionAIUse, "Channel") {
+			return nil, nil, nil, false
+		}
+	}
+	if video.ID != "" {
+		if !authorizeResource(w, r, db, models.ResourceVideo, video.ID, models.PermissionAIUse) {
+			return nil, nil, nil, false
+		}
+		// A draft of a saved video is paid for by its channel even when the
+		// draft doesn't name it
+		if channel == nil {
+			stored, err := db.GetVideoByID(video.ID)
+			if err != nil {
+				fmt.Println(err)
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, map[string]string{"error": "Failed to fetch video"})
+				return nil, nil, nil, false
+			}
+			channel = &stored.Channel
+		}
+	}
+
+	provider, err := aiProvider(r, providers, channel)
+	if err != nil {
+		renderAIError(w, r, err)
+		return nil, nil, nil, false
+	}
+
+	request := ai.NewSuggestionRequest(&video)
+	if video.ID != "" {
+		if request.Transcript, err = latestTranscript(db, video.ID); err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch transcript"})
+			return nil, nil, nil, false
+		}
+	}
+
+	refund, ok := consumeAIQuota(w, r, db, channel)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	return provider, request, refund, true
+}
+
+// GetAISuggestionsHandler retrieves AI suggestions for video metadata
+func GetAISuggestionsHandler(db *database.DB, providers *ai.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Nothing is stored, the suggestions are only returned
+		middleware.SkipAudit(r)
+
+		provider, request, refund, ok := draftSuggestionRequest(w, r, db, providers)
+		if !ok {
+			return
+		}
+
+		aiSuggestions, err := provider.Suggest(r.Context(), request)
+		if err != nil {
+			refund()
+			renderAIError(w, r, err)
+			return
+		}
+
+		render.JSON(w, r, aiSuggestions)
+	}
+}
+
+// eventStream writes server-sent events. The response only starts with the
+// first event so errors that happen before can still be sent as JSON.
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	started bool
+}
+
+func (s *eventStream) send(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if !s.started {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		// Keep proxies such as nginx from buffering the events
+		s.w.Header().Set("X-Accel-Buffering", "no")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// StreamAISuggestionsHandler streams AI suggestions for video metadata as
+// server-sent events. Providers that stream send "token" events with pieces
+// of their output, the others "field" events with each field. A "done" event
+// carries the complete suggestions and an "error" event reports a failure
+// once the stream has started. The call to the provider is canceled when the
+// client disconnects.
+func StreamAISuggestionsHandler(db *database.DB, providers *ai.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Streaming is not supported"})
+			return
+		}
+		middleware.SkipAudit(r)
+
+		provider, request, refund, ok := draftSuggestionRequest(w, r, db, providers)
+		if !ok {
+			return
+		}
+
+		// The request context ends when the client disconnects, which
+		// cancels the call to the provider
+		stream := &eventStream{w: w, flusher: flusher}
+		suggestions, err := ai.SuggestStream(r.Context(), provider, request, func(event ai.Event) error {
+			return stream.send(event.Type, event)
+		})
+		if err != nil {
+			refund()
+			if !stream.started || errors.Is(err, context.Canceled) {
+				renderAIError(w, r, err)
+				return
+			}
+			fmt.Println(err)
+			status, message := aiErrorStatus(err)
+			stream.send("error", map[string]interface{}{"status": status, "error": message})
+			return
+		}
+
+		stream.send("done", suggestions)
+	}
+}
+
+// routeAISuggestionRun fetches the suggestion run of a nested route,
+// responding with an error if it doesn't exist
+func routeAISuggestionRun(w http.ResponseWriter, r *http.Request, db *database.DB, videoID string) (*models.AISuggestionRun, bool) {
+	run, err := db.GetAISuggestionRun(videoID, chi.URLParam(r, "runID"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": "Suggestion not found"})
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch suggestion"})
+		return nil, false
+	}
+	return run, true
+}
+
+// decodeAISuggestionFields reads the {"fields": [...]} body of the accept
+// and reject endpoints
+func decodeAISuggestionFields(r *http.Request) ([]string, error) {
+	var request struct {
+		Fields []string `json:"fields"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, err
+	}
+	if len(request.Fields) == 0 {
+		return nil, &models.ValidationError{Field: "fields", Message: "must list at least one field"}
+	}
+	for _, field := range request.Fields {
+		if !models.IsAISuggestionField(field) {
+			return nil, &models.ValidationError{Field: "fields", Message: fmt.Sprintf("%q is not one of title, description, keywords or chapters", field)}
+		}
+	}
+	return request.Fields, nil
+}
+
+// promptTopTitles is the number of past titles given to prompt templates
+const promptTopTitles = 5
+
+// decodeSuggestionOptions reads the options of a suggestion run from an
+// optional JSON body into v, which embeds models.SuggestionOptions
+func decodeSuggestionOptions(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// suggest renders the prompt template of the video's channel and asks the
+// AI provider for suggestions. It responds with an error and returns false
+// if that fails. The returned run is not saved.
+func suggest(w http.ResponseWriter, r *http.Request, db *database.DB, providers *ai.Registry, video *models.Video, options models.SuggestionOptions, fields []string) (*models.AISuggestionRun, bool) {
+	provider, err := aiProvider(r, providers, &video.Channel)
+	if err != nil {
+		renderAIError(w, r, err)
+		return nil, false
+	}
+
+	topTitles, err := db.GetPublishedTitlesByChannel(video.Channel.ID, promptTopTitles)
+	if err != nil {
+		fmt.Println(err)
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch channel titles"})
+		return nil, false
+	}
+
+	transcript, err := latestTranscript(db, video.ID)
+	if err != nil {
+		fmt.Println(err)
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch transcript"})
+		return nil, false
+	}
+
+	prompt, err := models.RenderPrompt(video.Channel.PromptTemplate, &models.PromptData{
+		ChannelName: video.Channel.Name,
+		Title:       video.Title,
+		Description: video.Description,
+		Keywords:    video.Keywords,
+		Category:    video.Category,
+		Transcript:  transcript,
+		TopTitles:   topTitles,
+	})
+	if err != nil {
+		fmt.Println(err)
+		render.Status(r, http.StatusUnprocessableEntity)
+		render.JSON(w, r, map[string]string{"error": "The prompt template of the channel failed to render"})
+		return nil, false
+	}
+
+	if options.Seed == nil {
+		seed := int64(rand.Int31())
+		options.Seed = &seed
+	}
+
+	refund, ok := consumeAIQuota(w, r, db, &video.Channel)
+	if !ok {
+		return nil, false
+	}
+
+	request := ai.NewSuggestionRequest(video)
+	request.Transcript = transcript
+	request.Prompt = prompt
+	request.Seed = options.Seed
+	request.Tone = options.Tone
+	request.Length = options.Length
+	request.Fields = fields
+	suggestions, err := provider.Suggest(r.Context(), request)
+	if err != nil {
+		refund()
+		renderAIError(w, r, err)
+		return nil, false
+	}
+
+	run := &models.AISuggestionRun{
+		VideoID:           video.ID,
+		Provider:          provider.Name(),
+		Prompt:            prompt,
+		SuggestionOptions: options,
+		Fields:            fields,
+		Result:            *suggestions,
+	}
+	if user, err := middleware.GetUserFromContext(r); err == nil {
+		run.UserID = user.ID
+	}
+	return run, true
+}
+
+// CreateAISuggestionRunHandler generates AI suggestions from the current
+// draft of a video and stores them with the video. The optional body sets
+// the seed, tone and length of the run.
+func CreateAISuggestionRunHandler(db *database.DB, providers *ai.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionAIUse)
+		if !ok {
+			return
+		}
+
+		var options models.SuggestionOptions
+		if err := decodeSuggestionOptions(r, &options); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid suggestion options"})
+			return
+		}
+		if err := options.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		run, ok := suggest(w, r, db, providers, video, options, nil)
+		if !ok {
+			return
+		}
+
+		createdRun, err := db.CreateAISuggestionRun(run)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to save suggestions"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditGenerate, videoTarget(video, models.ResourceSuggestion, createdRun.ID),
+			nil, map[string]interface{}{"provider": createdRun.Provider, "fields": createdRun.Fields, "parentId": createdRun.ParentID})
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, createdRun)
+	}
+}
+
+// RegenerateAISuggestionHandler generates a single field of a suggestion run
+// again. The new run keeps the other fields of the previous one and records
+// it as its parent. The tone and length of the previous run are reused
+// unless the body sets them; the seed is new unless the body sets it.
+func RegenerateAISuggestionHandler(db *database.DB, providers *ai.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionAIUse)
+		if !ok {
+			return
+		}
+		parent, ok := routeAISuggestionRun(w, r, db, video.ID)
+		if !ok {
+			return
+		}
+
+		var request struct {
+			Field string `json:"field"`
+			models.SuggestionOptions
+		}
+		if err := decodeSuggestionOptions(r, &request); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid suggestion options"})
+			return
+		}
+		if !models.IsAISuggestionField(request.Field) {
+			renderValidationError(w, r, &models.ValidationError{Field: "field", Message: "must be one of title, description, keywords or chapters"})
+			return
+		}
+		options := request.SuggestionOptions
+		if options.Tone == "" {
+			options.Tone = parent.Tone
+		}
+		if options.Length == "" {
+			options.Length = parent.Length
+		}
+		if err := options.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		run, ok := suggest(w, r, db, providers, video, options, []string{request.Field})
+		if !ok {
+			return
+		}
+
+		result := parent.Result
+		switch request.Field {
+		case models.AIFieldTitle:
+			result.Title = run.Result.Title
+		case models.AIFieldDescription:
+			result.Description = run.Result.Description
+		case models.AIFieldKeywords:
+			result.Keywords = run.Result.Keywords
+		case models.AIFieldChapters:
+			result.Chapters = run.Result.Chapters
+		}
+		run.Result = result
+		run.ParentID = parent.ID
+
+		createdRun, err := db.CreateAISuggestionRun(run)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to save suggestions"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditGenerate, videoTarget(video, models.ResourceSuggestion, createdRun.ID),
+			nil, map[string]interface{}{"provider": createdRun.Provider, "fields": createdRun.Fields, "parentId": createdRun.ParentID})
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, createdRun)
+	}
+}
+
+// GetAISuggestionRunsHandler lists the suggestion runs of a video
+func GetAISuggestionRunsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoRead)
+		if !ok {
+			return
+		}
+
+		runs, err := db.GetAISuggestionRunsByVideo(video.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch suggestions"})
+			return
+		}
+
+		render.JSON(w, r, runs)
+	}
+}
+
+// GetAISuggestionRunHandler retrieves a suggestion run of a video
+func GetAISuggestionRunHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		videoID := chi.URLParam(r, "videoID")
+		if !authorizeResource(w, r, db, models.ResourceVideo, videoID, models.PermissionVideoRead) {
+			return
+		}
+		run, ok := routeAISuggestionRun(w, r, db, videoID)
+		if !ok {
+			return
+		}
+
+		render.JSON(w, r, run)
+	}
+}
+
+// AcceptAISuggestionHandler copies the fields listed in the request body
+// from a suggestion run into the video and marks them as AI-sourced
+func AcceptAISuggestionHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ifMatch, ok := routeVideoVersion(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+		run, ok := routeAISuggestionRun(w, r, db, video.ID)
+		if !ok {
+			return
+		}
+
+		accepted, err := decodeAISuggestionFields(r)
+		if err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		fields := video.Fields()
+		var chapters []models.Chapter
+		for _, field := range accepted {
+			switch field {
+			case models.AIFieldTitle:
+				fields.Title = run.Result.Title
+			case models.AIFieldDescription:
+				fields.Description = run.Result.Description
+			case models.AIFieldKeywords:
+				fields.Keywords = run.Result.Keywords
+			case models.AIFieldChapters:
+				if chapters, err = models.ParseChapterLines(run.Result.Chapters); err != nil {
+					renderValidationError(w, r, err)
+					return
+				}
+			}
+		}
+
+		if err := fields.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+		newChapters := video.Chapters
+		if chapters != nil {
+			if err := models.ValidateChapters(chapters, videoDuration(video)); err != nil {
+				renderValidationError(w, r, err)
+				return
+			}
+			newChapters = chapters
+		}
+		if err := models.ValidateDescriptionWithChapters(fields.Description, newChapters); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		updatedVideo, err := db.AcceptAISuggestion(video.ID, run.ID, &fields, chapters, accepted, video.Version)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Video not found"})
+				return
+			}
+			if errors.Is(err, database.ErrVersionMismatch) {
+				renderVersionConflict(w, r, ifMatch)
+				return
+			}
+			var validationErr *models.ValidationError
+			if errors.As(err, &validationErr) {
+				renderValidationError(w, r, err)
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to accept suggestion"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditAccept, videoTarget(video, models.ResourceSuggestion, run.ID), video.Fields(), updatedVideo.Fields())
+
+		setETag(w, updatedVideo.Version)
+		render.JSON(w, r, updatedVideo)
+	}
+}
+
+// RejectAISuggestionHandler records that the fields listed in the request
+// body of a suggestion run were rejected
+func RejectAISuggestionHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+		run, ok := routeAISuggestionRun(w, r, db, video.ID)
+		if !ok {
+			return
+		}
+
+		rejected, err := decodeAISuggestionFields(r)
+		if err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		updatedRun, err := db.RejectAISuggestion(video.ID, run.ID, rejected)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to reject suggestion"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditReject, videoTarget(video, models.ResourceSuggestion, run.ID),
+			map[string][]string{"rejectedFields": run.RejectedFields}, map[string][]string{"rejectedFields": updatedRun.RejectedFields})
+
+		render.JSON(w, r, updatedRun)
+	}
+}
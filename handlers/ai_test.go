@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/ai"
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+func TestDraftSuggestionsOfSavedVideoUseChannelQuota(t *testing.T) {
+	db := testDB(t)
+	owner, video := testVideo(t, db)
+	producer := testUser(t, db)
+	invitation := &models.Invitation{OrganizationID: video.Channel.OrganizationID, Email: producer.Email, Role: models.RoleProducer, InvitedBy: owner.ID}
+	if _, err := db.CreateInvitation(invitation, hashToken("invitation")); err != nil {
+		t.Fatalf("CreateInvitation: %v", err)
+	}
+	if _, _, err := db.AcceptInvitation(hashToken("invitation"), producer); err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+
+	// The draft only has the ID of the video, not its channel
+	suggest := as(producer, GetAISuggestionsHandler(db, ai.NewRegistry(&config.Config{AIProvider: models.AIProviderStub})))
+	if response := request("/ai/suggestions", suggest, http.MethodPost, "/ai/suggestions", `{"id": "`+video.ID+`", "title": "Draft"}`); response.Code != http.StatusOK {
+		t.Fatalf("suggesting got %d: %s", response.Code, response.Body)
+	}
+
+	period, _ := models.UsagePeriod(time.Now())
+	for user, want := range map[*models.User]int64{owner: 1, producer: 0} {
+		usage, err := db.GetMonthlyUsage(user.ID, period)
+		if err != nil {
+			t.Fatalf("GetMonthlyUsage: %v", err)
+		}
+		if usage[models.MetricAICalls] != want {
+			t.Errorf("%s made %d AI calls, want %d", user.Email, usage[models.MetricAICalls], want)
+		}
+	}
+}
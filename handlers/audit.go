@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// organizationTarget is a resource of an organization in the audit log
+func organizationTarget(organizationID, resourceType, resourceID string) models.AuditTarget {
+	return models.AuditTarget{OrganizationID: organizationID, ResourceType: resourceType, ResourceID: resourceID}
+}
+
+// channelTarget is a channel in the audit log
+func channelTarget(channel *models.Channel) models.AuditTarget {
+	return models.AuditTarget{
+		OrganizationID: channel.OrganizationID,
+		ChannelID:      channel.ID,
+		ResourceType:   models.ResourceChannel,
+		ResourceID:     channel.ID,
+	}
+}
+
+// videoTarget is a video, or a resource of a video, in the audit log
+func videoTarget(video *models.Video, resourceType, resourceID string) models.AuditTarget {
+	return models.AuditTarget{
+		OrganizationID: video.Channel.OrganizationID,
+		ChannelID:      video.Channel.ID,
+		VideoID:        video.ID,
+		ResourceType:   resourceType,
+		ResourceID:     resourceID,
+	}
+}
+
+// iterationTarget is an iteration, or a resource of an iteration, in the
+// audit log
+func iterationTarget(iteration *models.Iteration, resourceType, resourceID string) models.AuditTarget {
+	return videoTarget(&iteration.Video, resourceType, resourceID)
+}
+
+// accountFields are the fields of a user recorded in the audit log
+func accountFields(user *models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"username":   user.Username,
+		"email":      user.Email,
+		"tier":       user.Tier,
+		"aiProvider": user.AIProvider,
+	}
+}
+
+// auditCSVHeader lists the columns of the CSV export of the audit log
+var auditCSVHeader = []string{"id", "createdAt", "principalType", "actorId", "actorName", "action", "organizationId", "channelId", "videoId", "resourceType", "resourceId", "changes", "requestId", "ip"}
+
+// parseAuditFilter reads the filters of the audit log from the query string
+func parseAuditFilter(r *http.Request) (database.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := database.AuditFilter{
+		OrganizationID: query.Get("organizationId"),
+		ChannelID:      query.Get("channelId"),
+		VideoID:        query.Get("videoId"),
+		ActorID:        query.Get("actorId"),
+		PrincipalType:  query.Get("principalType"),
+		Action:         query.Get("action"),
+		ResourceType:   query.Get("resourceType"),
+		ResourceID:     query.Get("resourceId"),
+	}
+
+	for name, id := range map[string]string{"organizationId": filter.OrganizationID, "channelId": filter.ChannelID, "videoId": filter.VideoID} {
+		if id == "" {
+			continue
+		}
+		if _, err := uuid.Parse(id); err != nil {
+			return filter, fmt.Errorf("%s must be a valid ID", name)
+		}
+	}
+
+	for name, bound := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+		}
+		t = t.UTC()
+		*bound = &t
+	}
+
+	return filter, nil
+}
+
+// GetAuditLogHandler lists the entries of the audit log of an organization,
+// newest first, for members with audit:read. Admins can leave out the
+// organizationId to see every entry, including the ones of users and
+// editors. Entries are filtered by channelId, videoId, actorId,
+// principalType, action, resourceType, resourceId, since and until, and
+// exported as CSV with ?format=csv.
+func GetAuditLogHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := middleware.GetUserFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		filter, err := parseAuditFilter(r)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+		if filter.OrganizationID == "" {
+			if !user.Admin {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, map[string]string{"error": "organizationId is required"})
+				return
+			}
+		} else if !authorize(w, r, db, filter.OrganizationID, models.PermissionAuditRead, "Organization") {
+			return
+		}
+
+		if r.URL.Query().Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), "text/csv") {
+			exportAuditLog(w, r, db, filter)
+			return
+		}
+
+		limit, err := parseListLimit(r)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+
+		entries, err := db.ListAuditEntries(filter, limit, r.URL.Query().Get("cursor"))
+		if err != nil {
+			renderListError(w, r, err, "Failed to fetch audit log")
+			return
+		}
+
+		render.JSON(w, r, entries)
+	}
+}
+
+// escapeCSVFormulas prefixes the cells that spreadsheets would run as
+// formulas with a quote. Names, emails and changes come from users, who
+// could otherwise plant formulas in the export.
+func escapeCSVFormulas(record []string) []string {
+	for i, cell := range record {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			record[i] = "'" + cell
+		}
+	}
+	return record
+}
+
+// exportAuditLog streams the entries of the audit log matching a filter as
+// CSV
+func exportAuditLog(w http.ResponseWriter, r *http.Request, db *database.DB, filter database.AuditFilter) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.csv"`, time.Now().UTC().Format("20060102-150405")))
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(auditCSVHeader); err != nil {
+		return
+	}
+	err := db.ExportAuditEntries(filter, func(entry *models.AuditEntry) error {
+		changes, err := json.Marshal(entry.Changes)
+		if err != nil {
+			return err
+		}
+		return writer.Write(escapeCSVFormulas([]string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.CreatedAt.Format(time.RFC3339),
+			entry.PrincipalType,
+			entry.AuditActor.ID,
+			entry.Name,
+			entry.Action,
+			entry.OrganizationID,
+			entry.ChannelID,
+			entry.VideoID,
+			entry.ResourceType,
+			entry.ResourceID,
+			string(changes),
+			entry.RequestID,
+			entry.IP,
+		}))
+	})
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	// The status has been sent, so a failure can only cut the export short
+	if err != nil && r.Context().Err() == nil {
+		fmt.Println(err)
+	}
+}
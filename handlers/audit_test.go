@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEscapeCSVFormulas(t *testing.T) {
+	record := []string{"=HYPERLINK(\"http://evil\")", "+1", "-2", "@SUM(A1)", "\tcmd", "\rcmd", "jane@example.com", "", `{"title":"=1"}`, "2024-01-01T00:00:00Z"}
+	want := []string{"'=HYPERLINK(\"http://evil\")", "'+1", "'-2", "'@SUM(A1)", "'\tcmd", "'\rcmd", "jane@example.com", "", `{"title":"=1"}`, "2024-01-01T00:00:00Z"}
+	if got := escapeCSVFormulas(record); !reflect.DeepEqual(got, want) {
+		t.Errorf("escapeCSVFormulas = %q, want %q", got, want)
+	}
+}
@@ -13,11 +13,13 @@ import (
 
 	"github.com/FuseWorkflows/fuse-go-server/config"
 	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
 	"github.com/FuseWorkflows/fuse-go-server/models"
 )
 
-// SignupHandler handles user signup
-func SignupHandler(db *database.DB) http.HandlerFunc {
+// SignupHandler handles user signup. New users start on a trial unless
+// trials are disabled.
+func SignupHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var user models.User
 
@@ -37,12 +39,19 @@ func SignupHandler(db *database.DB) http.HandlerFunc {
 		user.Password = string(hashedPassword)
 
 		// Create the user
-		createdUser, err := db.CreateUser(&user)
+		var createdUser *models.User
+		if cfg.TrialLength > 0 {
+			createdUser, err = db.CreateUserWithTrial(&user, models.Tier(cfg.TrialTier), cfg.TrialLength)
+		} else {
+			createdUser, err = db.CreateUser(&user)
+		}
 		if err != nil {
 			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, map[string]string{"error": "Failed to create user"})
 			return
 		}
+		middleware.SetAuditActor(r, models.AuditActor{ID: createdUser.ID, Name: createdUser.Username, PrincipalType: models.PrincipalUser})
+		middleware.AuditChange(r, models.AuditCreate, models.AuditTarget{ResourceType: models.ResourceUser, ResourceID: createdUser.ID}, nil, accountFields(createdUser))
 
 		render.Status(r, http.StatusCreated)
 		render.JSON(w, r, createdUser)
@@ -64,6 +73,8 @@ func (l *LoginRequest) Bind(r *http.Request) error {
 // LoginHandler handles user login
 func LoginHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		middleware.SkipAudit(r)
+
 		var loginRequest LoginRequest
 
 		if err := render.Bind(r, &loginRequest); err != nil {
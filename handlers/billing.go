@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/billing"
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// maxWebhookBytes bounds the size of billing webhooks
+const maxWebhookBytes = 1 << 20
+
+// CreateCheckoutSessionHandler starts the purchase of a tier by the
+// authenticated user with {"tier": "premium"} and responds with the URL of
+// the checkout page. The tier changes once the billing API reports the
+// subscription through the webhook.
+func CreateCheckoutSessionHandler(db *database.DB, client *billing.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := middleware.GetUserFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		var request struct {
+			Tier models.Tier `json:"tier"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid request body"})
+			return
+		}
+		if !client.Configured() {
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, map[string]string{"error": "Billing is not configured"})
+			return
+		}
+		if _, ok := client.Price(request.Tier); !ok {
+			renderValidationError(w, r, &models.ValidationError{Field: "tier", Message: "is not for sale"})
+			return
+		}
+
+		customerID, err := db.GetBillingCustomerID(user.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch billing customer"})
+			return
+		}
+
+		session, err := client.CreateCheckoutSession(r.Context(), &billing.CheckoutRequest{
+			Tier:       request.Tier,
+			UserID:     user.ID,
+			Email:      user.Email,
+			CustomerID: customerID,
+		})
+		if err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusBadGateway)
+			render.JSON(w, r, map[string]string{"error": "Failed to create checkout session"})
+			return
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, session)
+	}
+}
+
+// GetSubscriptionsHandler lists the subscriptions of the authenticated user
+func GetSubscriptionsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		subscriptions, err := db.GetSubscriptionsByUser(userID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch subscriptions"})
+			return
+		}
+
+		render.JSON(w, r, subscriptions)
+	}
+}
+
+// BillingWebhookHandler processes the signed subscription events of the
+// billing API. It is the only way the tier of a user changes. Events are
+// processed once, and redeliveries and events of other types are
+// acknowledged without doing anything so the API stops sending them.
+func BillingWebhookHandler(db *database.DB, client *billing.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBytes))
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Failed to read webhook"})
+			return
+		}
+
+		event, err := client.ParseWebhook(payload, r.Header.Get(billing.SignatureHeader))
+		if err != nil {
+			if errors.Is(err, billing.ErrNotConfigured) {
+				render.Status(r, http.StatusServiceUnavailable)
+				render.JSON(w, r, map[string]string{"error": "Billing webhooks are not configured"})
+				return
+			}
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+		middleware.SetAuditActor(r, models.AuditActor{ID: event.ID, Name: event.Type, PrincipalType: models.PrincipalWebhook})
+
+		var target models.AuditTarget
+		var after map[string]interface{}
+		switch event.Type {
+		case billing.EventSubscriptionCreated, billing.EventSubscriptionUpdated, billing.EventSubscriptionDeleted:
+			var subscription billing.Subscription
+			if err := json.Unmarshal(event.Data.Object, &subscription); err != nil {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, map[string]string{"error": "Invalid subscription"})
+				return
+			}
+			change := &database.SubscriptionChange{
+				ID:               subscription.ID,
+				UserID:           subscription.Metadata["user_id"],
+				CustomerID:       subscription.Customer,
+				Status:           subscription.Status,
+				PriceID:          subscription.Price(),
+				CurrentPeriodEnd: subscription.CurrentPeriodEnd,
+			}
+			if event.Type == billing.EventSubscriptionDeleted {
+				change.Status = "canceled"
+			}
+			// Subscriptions to prices that don't sell a tier don't pay for one
+			change.Tier = models.Free
+			if tier, ok := client.Tier(change.PriceID); ok {
+				change.Tier = tier
+			} else {
+				fmt.Printf("subscription %s is for unknown price %q\n", subscription.ID, change.PriceID)
+			}
+			err = db.ApplySubscriptionEvent(event.ID, event.Type, event.Created, change)
+			target = models.AuditTarget{ResourceType: models.ResourceSubscription, ResourceID: change.ID}
+			after = map[string]interface{}{"userId": change.UserID, "status": change.Status, "tier": change.Tier, "currentPeriodEnd": change.CurrentPeriodEnd}
+		case billing.EventPaymentFailed:
+			var invoice billing.Invoice
+			if err := json.Unmarshal(event.Data.Object, &invoice); err != nil {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, map[string]string{"error": "Invalid invoice"})
+				return
+			}
+			err = db.RecordPaymentFailure(event.ID, event.Type, event.Created, invoice.Customer, invoice.Subscription)
+			target = models.AuditTarget{ResourceType: models.ResourceSubscription, ResourceID: invoice.Subscription}
+			after = map[string]interface{}{"paymentFailed": true}
+		default:
+			middleware.SkipAudit(r)
+			render.JSON(w, r, map[string]string{"message": "Event ignored"})
+			return
+		}
+
+		if err != nil {
+			if errors.Is(err, database.ErrDuplicateEvent) {
+				middleware.SkipAudit(r)
+				render.JSON(w, r, map[string]string{"message": "Event already processed"})
+				return
+			}
+			if errors.Is(err, database.ErrNotFound) {
+				fmt.Printf("billing event %s is for an unknown user\n", event.ID)
+				middleware.SkipAudit(r)
+				render.JSON(w, r, map[string]string{"message": "Event ignored"})
+				return
+			}
+			fmt.Println(err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to process event"})
+			return
+		}
+
+		middleware.AuditChange(r, models.AuditUpdate, target, nil, after)
+
+		render.JSON(w, r, map[string]string{"message": "Event processed"})
+	}
+}
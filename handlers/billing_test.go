@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/FuseWorkflows/fuse-go-server/billing"
+	"github.com/FuseWorkflows/fuse-go-server/billingtest"
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// testDB connects to the database of TEST_DATABASE_URL and applies the
+// migrations. Tests that need a database are skipped when it isn't set.
+func testDB(t *testing.T) *database.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	conn, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("error connecting to database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	db := &database.DB{DB: conn}
+	if err := db.MigrateUp(context.Background()); err != nil {
+		t.Fatalf("error applying migrations: %v", err)
+	}
+	return db
+}
+
+// testUser creates a user with a unique name and email
+func testUser(t *testing.T, db *database.DB) *models.User {
+	t.Helper()
+	name := "user-" + uuid.NewString()[:8]
+	user, err := db.CreateUser(&models.User{Username: name, Email: name + "@example.com", Password: "password"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+// webhookTest delivers the events of a fake billing API to the webhook
+type webhookTest struct {
+	t       *testing.T
+	db      *database.DB
+	fake    *billingtest.Server
+	client  *billing.Client
+	webhook *httptest.Server
+	user    *models.User
+}
+
+func newWebhookTest(t *testing.T) *webhookTest {
+	db := testDB(t)
+	fake := billingtest.NewServer("whsec_test")
+	t.Cleanup(fake.Close)
+	client := billing.NewClient(&config.Config{
+		BillingAPIURL:        fake.URL,
+		BillingSecretKey:     "sk_test",
+		BillingWebhookSecret: fake.WebhookSecret,
+		BillingPriceBasic:    "price_basic",
+		BillingPricePremium:  "price_premium",
+		BillingSuccessURL:    "https://app.example.com/billing",
+	})
+	webhook := httptest.NewServer(BillingWebhookHandler(db, client))
+	t.Cleanup(webhook.Close)
+	return &webhookTest{t: t, db: db, fake: fake, client: client, webhook: webhook, user: testUser(t, db)}
+}
+
+// subscribe completes a checkout of tier by the user and returns the ID of
+// the subscription along with the event reporting it
+func (wt *webhookTest) subscribe(tier models.Tier) (string, *billing.Event) {
+	wt.t.Helper()
+	session, err := wt.client.CreateCheckoutSession(context.Background(), &billing.CheckoutRequest{Tier: tier, UserID: wt.user.ID, Email: wt.user.Email})
+	if err != nil {
+		wt.t.Fatalf("CreateCheckoutSession: %v", err)
+	}
+	event, err := wt.fake.Complete(session.ID)
+	if err != nil {
+		wt.t.Fatalf("Complete: %v", err)
+	}
+	var subscription billing.Subscription
+	if err := json.Unmarshal(event.Data.Object, &subscription); err != nil {
+		wt.t.Fatalf("error decoding subscription: %v", err)
+	}
+	return subscription.ID, event
+}
+
+// send delivers an event signed at signedAt and returns the status and the
+// message of the response
+func (wt *webhookTest) send(event *billing.Event, signedAt time.Time) (int, string) {
+	wt.t.Helper()
+	response, err := wt.fake.SendAt(wt.webhook.URL, event, signedAt)
+	if err != nil {
+		wt.t.Fatalf("SendAt: %v", err)
+	}
+	defer response.Body.Close()
+	var body map[string]string
+	json.NewDecoder(response.Body).Decode(&body)
+	return response.StatusCode, body["message"]
+}
+
+// expect checks the tier of the user and the status of a subscription
+func (wt *webhookTest) expect(tier models.Tier, subscriptionID, status string) {
+	wt.t.Helper()
+	user, err := wt.db.GetUserByID(wt.user.ID)
+	if err != nil {
+		wt.t.Fatalf("GetUserByID: %v", err)
+	}
+	if user.Tier != tier {
+		wt.t.Errorf("user is on the %q tier, want %q", user.Tier, tier)
+	}
+
+	subscriptions, err := wt.db.GetSubscriptionsByUser(wt.user.ID)
+	if err != nil {
+		wt.t.Fatalf("GetSubscriptionsByUser: %v", err)
+	}
+	for _, subscription := range subscriptions {
+		if subscription.ID == subscriptionID {
+			if subscription.Status != status {
+				wt.t.Errorf("subscription is %q, want %q", subscription.Status, status)
+			}
+			return
+		}
+	}
+	if status != "" {
+		wt.t.Errorf("subscription %s not found", subscriptionID)
+	}
+}
+
+func TestBillingWebhookSignature(t *testing.T) {
+	wt := newWebhookTest(t)
+	subscriptionID, created := wt.subscribe(models.Premium)
+
+	// Replays signed before the tolerance window are rejected
+	if code, _ := wt.send(created, time.Now().Add(-billing.Tolerance-time.Minute)); code != http.StatusBadRequest {
+		t.Errorf("replayed event got status %d, want %d", code, http.StatusBadRequest)
+	}
+	wt.expect(models.Free, subscriptionID, "")
+
+	if code, message := wt.send(created, time.Now().Add(-billing.Tolerance+time.Minute)); code != http.StatusOK || message != "Event processed" {
+		t.Errorf("event signed within the tolerance got %d %q", code, message)
+	}
+	wt.expect(models.Premium, subscriptionID, "active")
+}
+
+func TestBillingWebhookDuplicateEvents(t *testing.T) {
+	wt := newWebhookTest(t)
+	subscriptionID, created := wt.subscribe(models.Premium)
+
+	if code, message := wt.send(created, time.Now()); code != http.StatusOK || message != "Event processed" {
+		t.Fatalf("first delivery got %d %q", code, message)
+	}
+
+	// Cancel, then redeliver the creation: it must not bring the tier back
+	canceled, err := wt.fake.Cancel(subscriptionID)
+	if err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if code, _ := wt.send(canceled, time.Now()); code != http.StatusOK {
+		t.Fatalf("cancellation got %d", code)
+	}
+	if code, message := wt.send(created, time.Now()); code != http.StatusOK || message != "Event already processed" {
+		t.Errorf("redelivery got %d %q", code, message)
+	}
+	wt.expect(models.Free, subscriptionID, "canceled")
+}
+
+func TestBillingWebhookOutOfOrderEvents(t *testing.T) {
+	wt := newWebhookTest(t)
+	subscriptionID, created := wt.subscribe(models.Premium)
+	if code, _ := wt.send(created, time.Now()); code != http.StatusOK {
+		t.Fatalf("creation got %d", code)
+	}
+
+	downgraded, err := wt.fake.Update(subscriptionID, func(subscription *billing.Subscription) {
+		subscription.Items.Data[0].Price.ID = "price_basic"
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	canceled, err := wt.fake.Cancel(subscriptionID)
+	if err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+
+	// The cancellation arrives before the older downgrade, which is
+	// acknowledged but doesn't revive the subscription
+	if code, _ := wt.send(canceled, time.Now()); code != http.StatusOK {
+		t.Fatalf("cancellation got %d", code)
+	}
+	wt.expect(models.Free, subscriptionID, "canceled")
+	if code, message := wt.send(downgraded, time.Now()); code != http.StatusOK || message != "Event processed" {
+		t.Errorf("late update got %d %q", code, message)
+	}
+	wt.expect(models.Free, subscriptionID, "canceled")
+}
+
+func TestBillingWebhookPaymentFailed(t *testing.T) {
+	wt := newWebhookTest(t)
+	subscriptionID, created := wt.subscribe(models.Premium)
+	if code, _ := wt.send(created, time.Now()); code != http.StatusOK {
+		t.Fatalf("creation got %d", code)
+	}
+
+	failed, err := wt.fake.FailPayment(subscriptionID)
+	if err != nil {
+		t.Fatalf("FailPayment: %v", err)
+	}
+	if code, message := wt.send(failed, time.Now()); code != http.StatusOK || message != "Event processed" {
+		t.Fatalf("payment failure got %d %q", code, message)
+	}
+	if code, message := wt.send(failed, time.Now()); code != http.StatusOK || message != "Event already processed" {
+		t.Errorf("redelivered payment failure got %d %q", code, message)
+	}
+
+	// Past due subscriptions keep their tier until they are canceled
+	wt.expect(models.Premium, subscriptionID, "past_due")
+
+	notifications, err := wt.db.GetNotificationsByUser(wt.user.ID, false, 10)
+	if err != nil {
+		t.Fatalf("GetNotificationsByUser: %v", err)
+	}
+	count := 0
+	for _, notification := range notifications {
+		if notification.Kind == models.NotificationPaymentFailed {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("got %d payment failure notifications, want 1", count)
+	}
+}
+
+func TestBillingWebhookMetadataOfAnotherUser(t *testing.T) {
+	wt := newWebhookTest(t)
+	subscriptionID, created := wt.subscribe(models.Premium)
+	if code, _ := wt.send(created, time.Now()); code != http.StatusOK {
+		t.Fatalf("creation got %d", code)
+	}
+
+	// The metadata of the subscription now names someone else, who must not
+	// get the subscription nor its tier
+	other := testUser(t, wt.db)
+	updated, err := wt.fake.Update(subscriptionID, func(subscription *billing.Subscription) {
+		subscription.Metadata = map[string]string{"user_id": other.ID}
+		subscription.Items.Data[0].Price.ID = "price_basic"
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if code, message := wt.send(updated, time.Now()); code != http.StatusOK || message != "Event processed" {
+		t.Fatalf("update got %d %q", code, message)
+	}
+	wt.expect(models.Basic, subscriptionID, "active")
+
+	user, err := wt.db.GetUserByID(other.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	subscriptions, err := wt.db.GetSubscriptionsByUser(other.ID)
+	if err != nil {
+		t.Fatalf("GetSubscriptionsByUser: %v", err)
+	}
+	if user.Tier != other.Tier || len(subscriptions) != 0 {
+		t.Errorf("the other user is on the %q tier with %d subscriptions", user.Tier, len(subscriptions))
+	}
+}
+
+func TestTrialNotStartedForPayingUser(t *testing.T) {
+	wt := newWebhookTest(t)
+	subscriptionID, created := wt.subscribe(models.Premium)
+	if code, _ := wt.send(created, time.Now()); code != http.StatusOK {
+		t.Fatalf("creation got %d", code)
+	}
+
+	// The trial would end on the free tier while the subscription is paid
+	extend := ExtendUserTrialHandler(wt.db)
+	response := request("/admin/users/{userID}/trial/extend", extend, http.MethodPost, "/admin/users/"+wt.user.ID+"/trial/extend", `{"days": 7, "tier": "basic"}`)
+	if response.Code != http.StatusConflict {
+		t.Errorf("starting a trial got %d: %s", response.Code, response.Body)
+	}
+	wt.expect(models.Premium, subscriptionID, "active")
+	if user, err := wt.db.GetUserByID(wt.user.ID); err != nil || user.Trial {
+		t.Errorf("the user is %+v, %v", user, err)
+	}
+}
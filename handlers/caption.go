@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/captions"
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+	"github.com/FuseWorkflows/fuse-go-server/utils"
+)
+
+// latestIterationID returns the ID of the iteration a video is published with
+func latestIterationID(video *models.Video) string {
+	if len(video.Iterations) == 0 {
+		return ""
+	}
+	return video.Iterations[len(video.Iterations)-1].ID
+}
+
+// pushCaption uploads a caption track, or its new content, to YouTube. Tracks
+// of videos that haven't been uploaded yet or that are pinned to another
+// iteration are left alone.
+func pushCaption(db *database.DB, cfg *config.Config, video *models.Video, caption *models.Caption, content []byte) error {
+	if video.YouTubeID == "" || !caption.AppliesTo(latestIterationID(video)) {
+		return nil
+	}
+
+	if caption.YouTubeID != "" {
+		return utils.UpdateYouTubeCaption(cfg, video.Channel.API_KEY, caption, content)
+	}
+
+	youtubeID, err := utils.InsertYouTubeCaption(cfg, video.Channel.API_KEY, video.YouTubeID, caption, content)
+	if err != nil {
+		return err
+	}
+	caption.YouTubeID = youtubeID
+	return db.SetCaptionYouTubeID(caption.ID, youtubeID)
+}
+
+// publishCaptions uploads the caption tracks of a video that was just
+// uploaded to YouTube
+func publishCaptions(db *database.DB, cfg *config.Config, video *models.Video) error {
+	tracks, err := db.GetCaptionsByVideo(video.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, track := range tracks {
+		caption, content, err := db.GetCaptionContent(video.ID, track.ID)
+		if err != nil {
+			return err
+		}
+		// Any earlier upload of the track belongs to a previous YouTube video
+		caption.YouTubeID = ""
+		if err := pushCaption(db, cfg, video, caption, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCaptionsHandler lists the caption tracks of a video
+func GetCaptionsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoRead)
+		if !ok {
+			return
+		}
+
+		tracks, err := db.GetCaptionsByVideo(video.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch captions"})
+			return
+		}
+
+		render.JSON(w, r, tracks)
+	}
+}
+
+// UploadCaptionHandler adds a caption track to a video from the "file" field
+// of a multipart form, along with its "language", "name" and optional
+// "iterationId". The format is taken from the "format" field, the file name
+// or the content, in that order.
+func UploadCaptionHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+
+		// Leave room for the multipart framing and the other fields
+		r.Body = http.MaxBytesReader(w, r.Body, models.MaxCaptionBytes+64<<10)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				render.Status(r, http.StatusRequestEntityTooLarge)
+				render.JSON(w, r, map[string]string{"error": "Caption file must be at most 1 MB"})
+				return
+			}
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "A caption file is required"})
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Failed to read caption file"})
+			return
+		}
+
+		format := captions.DetectFormat(data)
+		if name := r.FormValue("format"); name != "" {
+			if format, err = captions.ParseFormat(name); err != nil {
+				renderValidationError(w, r, &models.ValidationError{Field: "format", Message: "must be srt or vtt"})
+				return
+			}
+		} else if detected, err := captions.ParseFormat(path.Ext(header.Filename)); err == nil {
+			format = detected
+		}
+
+		caption := &models.Caption{
+			VideoID:     video.ID,
+			IterationID: r.FormValue("iterationId"),
+			Language:    r.FormValue("language"),
+			Name:        r.FormValue("name"),
+			Format:      format,
+		}
+		if err := caption.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		cues, err := models.ParseCaptionFile(data, format)
+		if err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+		caption.CueCount = len(cues)
+
+		// Tracks are stored normalized so conversions and offsets start from valid files
+		content, _ := captions.Write(cues, format)
+		if !checkStorageQuota(w, r, db, &video.Channel, int64(len(content))) {
+			return
+		}
+		createdCaption, err := db.CreateCaption(caption, content)
+		if err != nil {
+			var validationErr *models.ValidationError
+			if errors.As(err, &validationErr) {
+				renderValidationError(w, r, err)
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to create caption"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditCreate, videoTarget(video, models.ResourceCaption, createdCaption.ID), nil, createdCaption)
+
+		if err := pushCaption(db, cfg, video, createdCaption, content); err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusBadGateway)
+			render.JSON(w, r, map[string]string{"error": "Caption was created but uploading it to YouTube failed"})
+			return
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, createdCaption)
+	}
+}
+
+// GetCaptionFileHandler serves a caption track, converted to the format in
+// the "format" query parameter if given
+func GetCaptionFileHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeResource(w, r, db, models.ResourceVideo, chi.URLParam(r, "videoID"), models.PermissionVideoRead) {
+			return
+		}
+		caption, content, ok := routeCaption(w, r, db)
+		if !ok {
+			return
+		}
+
+		format := caption.Format
+		if name := r.URL.Query().Get("format"); name != "" {
+			var err error
+			if format, err = captions.ParseFormat(name); err != nil {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, map[string]string{"error": "Format must be srt or vtt"})
+				return
+			}
+		}
+
+		if format != caption.Format {
+			cues, err := captions.Parse(content, caption.Format)
+			if err != nil {
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, map[string]string{"error": "Failed to convert caption"})
+				return
+			}
+			content, _ = captions.Write(cues, format)
+		}
+
+		w.Header().Set("Content-Type", format.ContentType()+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", caption.Language+"."+string(format)))
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write(content)
+	}
+}
+
+// OffsetCaptionHandler shifts the timing of a caption track by the duration
+// in the request body, such as {"offset": "-1.5s"}
+func OffsetCaptionHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Offset string `json:"offset"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid request body"})
+			return
+		}
+		offset, err := time.ParseDuration(request.Offset)
+		if err != nil {
+			renderValidationError(w, r, &models.ValidationError{Field: "offset", Message: "must be a duration such as 1.5s or -200ms"})
+			return
+		}
+
+		video, ok := routeVideo(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+		caption, content, ok := routeCaption(w, r, db)
+		if !ok {
+			return
+		}
+
+		cues, err := captions.Parse(content, caption.Format)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to read caption"})
+			return
+		}
+		cues = captions.Offset(cues, offset)
+		if len(cues) == 0 {
+			renderValidationError(w, r, &models.ValidationError{Field: "offset", Message: "would move every cue before the start of the video"})
+			return
+		}
+
+		content, _ = captions.Write(cues, caption.Format)
+		updatedCaption, err := db.UpdateCaptionContent(video.ID, caption.ID, content, len(cues))
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Caption not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to update caption"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditUpdate, videoTarget(video, models.ResourceCaption, caption.ID), caption, updatedCaption)
+
+		if err := pushCaption(db, cfg, video, updatedCaption, content); err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusBadGateway)
+			render.JSON(w, r, map[string]string{"error": "Caption was updated but uploading it to YouTube failed"})
+			return
+		}
+
+		render.JSON(w, r, updatedCaption)
+	}
+}
+
+// DeleteCaptionHandler deletes a caption track, removing it from YouTube
+// first if it has been uploaded
+func DeleteCaptionHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+		caption, _, ok := routeCaption(w, r, db)
+		if !ok {
+			return
+		}
+
+		if caption.YouTubeID != "" {
+			if err := utils.DeleteYouTubeCaption(cfg, video.Channel.API_KEY, caption.YouTubeID); err != nil {
+				fmt.Println(err)
+				render.Status(r, http.StatusBadGateway)
+				render.JSON(w, r, map[string]string{"error": "Failed to delete caption from YouTube"})
+				return
+			}
+		}
+
+		if err := db.DeleteCaption(video.ID, caption.ID); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Caption not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to delete caption"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditDelete, videoTarget(video, models.ResourceCaption, caption.ID), caption, nil)
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, map[string]string{"message": "Caption deleted successfully"})
+	}
+}
+
+// routeCaption fetches the caption track of a nested caption route,
+// responding with an error if it doesn't exist
+func routeCaption(w http.ResponseWriter, r *http.Request, db *database.DB) (*models.Caption, []byte, bool) {
+	caption, content, err := db.GetCaptionContent(chi.URLParam(r, "videoID"), chi.URLParam(r, "captionID"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": "Caption not found"})
+			return nil, nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch caption"})
+		return nil, nil, false
+	}
+	return caption, content, true
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/FuseWorkflows/fuse-go-server/middleware"
@@ -34,10 +35,12 @@ func GetChannelHandler(db *database.DB) http.HandlerFunc {
 	}
 }
 
-// CreateChannelHandler creates a new channel
+// CreateChannelHandler creates a new channel in the organization of its
+// "organizationId", which takes channel:create in it, or in the first
+// organization the user owns
 func CreateChannelHandler(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, err := middleware.GetUserIDFromContext(r)
+		user, err := middleware.GetUserFromContext(r)
 		if err != nil {
 			render.Status(r, http.StatusUnauthorized)
 			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
@@ -51,7 +54,47 @@ func CreateChannelHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		channel.Owner.ID = userID
+		if !models.IsAIProvider(channel.AIProvider) {
+			renderValidationError(w, r, &models.ValidationError{Field: "aiProvider", Message: "must be http, openai or stub"})
+			return
+		}
+		if channel.PromptTemplate != "" {
+			fields := channel.Fields()
+			if err := fields.Validate(); err != nil {
+				renderValidationError(w, r, err)
+				return
+			}
+		}
+
+		if channel.OrganizationID == "" {
+			organization, err := db.DefaultOrganization(user)
+			if err != nil {
+				fmt.Println(err)
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, map[string]string{"error": "Failed to fetch organization"})
+				return
+			}
+			channel.OrganizationID = organization.ID
+		} else if !authorize(w, r, db, channel.OrganizationID, models.PermissionChannelCreate, "Organization") {
+			return
+		}
+
+		// The channel counts against the quota of the owner of the organization
+		account, err := db.GetOrganizationOwner(channel.OrganizationID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch account"})
+			return
+		}
+		count, err := db.CountChannelsByOwner(account.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to count channels"})
+			return
+		}
+		if !checkQuota(w, r, account, models.MetricChannels, count, 1) {
+			return
+		}
 
 		createdChannel, err := db.CreateChannel(&channel)
 		if err != nil {
@@ -59,6 +102,7 @@ func CreateChannelHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Failed to create channel"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditCreate, channelTarget(createdChannel), nil, createdChannel.Fields())
 
 		render.Status(r, http.StatusCreated)
 		render.JSON(w, r, createdChannel)
@@ -86,12 +130,20 @@ func GetChannelByIDHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Failed to fetch channel"})
 			return
 		}
+		if !authorize(w, r, db, channel.OrganizationID, models.PermissionChannelRead, "Channel") {
+			return
+		}
 
+		if notModified(w, r, channel.Version) {
+			return
+		}
+
+		setETag(w, channel.Version)
 		render.JSON(w, r, channel)
 	}
 }
 
-// UpdateChannelHandler updates a channel by ID
+// UpdateChannelHandler applies a JSON merge patch or JSON patch to a channel by ID
 func UpdateChannelHandler(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		channelID := chi.URLParam(r, "channelID")
@@ -101,25 +153,57 @@ func UpdateChannelHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		var channel models.Channel
-		if err := json.NewDecoder(r.Body).Decode(&channel); err != nil {
+		version, err := ifMatchVersion(r)
+		if err != nil {
 			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, map[string]string{"error": "Invalid channel data"})
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+
+		channel, err := db.GetChannelByID(channelID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Channel not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch channel"})
+			return
+		}
+		if version != 0 && version != channel.Version {
+			renderPreconditionFailed(w, r)
+			return
+		}
+		if !authorize(w, r, db, channel.OrganizationID, models.PermissionChannelManage, "Channel") {
+			return
+		}
+
+		fields, err := decodePatch(r, channel.Fields())
+		if err != nil {
+			renderPatchError(w, r, err)
 			return
 		}
 
-		updatedChannel, err := db.UpdateChannel(channelID, &channel)
+		updatedChannel, err := db.UpdateChannel(channelID, &fields, channel.Version)
 		if err != nil {
 			if errors.Is(err, database.ErrNotFound) {
 				render.Status(r, http.StatusNotFound)
 				render.JSON(w, r, map[string]string{"error": "Channel not found"})
 				return
 			}
+			if errors.Is(err, database.ErrVersionMismatch) {
+				renderVersionConflict(w, r, version)
+				return
+			}
 			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, map[string]string{"error": "Failed to update channel"})
 			return
 		}
 
+		middleware.AuditChange(r, models.AuditUpdate, channelTarget(channel), channel.Fields(), updatedChannel.Fields())
+
+		setETag(w, updatedChannel.Version)
 		render.JSON(w, r, updatedChannel)
 	}
 }
@@ -134,15 +218,7 @@ func DeleteChannelHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		// Get the authenticated user's ID
-		userID, err := middleware.GetUserIDFromContext(r)
-		if err != nil {
-			render.Status(r, http.StatusUnauthorized)
-			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
-			return
-		}
-
-		// Check if the user owns the channel
+		// Check if the user manages the channel
 		channel, err := db.GetChannelByID(channelID)
 		if err != nil {
 			if errors.Is(err, database.ErrNotFound) {
@@ -154,24 +230,34 @@ func DeleteChannelHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Failed to fetch channel"})
 			return
 		}
-		if channel.Owner.ID != userID {
-			render.Status(r, http.StatusForbidden)
-			render.JSON(w, r, map[string]string{"error": "You are not authorized to delete this channel"})
+		if !authorize(w, r, db, channel.OrganizationID, models.PermissionChannelManage, "Channel") {
+			return
+		}
+
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
 			return
 		}
 
 		// Delete the channel
-		err = db.DeleteChannel(channelID)
+		err = db.DeleteChannel(channelID, version)
 		if err != nil {
 			if errors.Is(err, database.ErrNotFound) {
 				render.Status(r, http.StatusNotFound)
 				render.JSON(w, r, map[string]string{"error": "Channel not found"})
 				return
 			}
+			if errors.Is(err, database.ErrVersionMismatch) {
+				renderPreconditionFailed(w, r)
+				return
+			}
 			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, map[string]string{"error": "Failed to delete channel"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditDelete, channelTarget(channel), channel.Fields(), nil)
 
 		render.Status(r, http.StatusOK)
 		render.JSON(w, r, map[string]string{"message": "Channel deleted successfully"})
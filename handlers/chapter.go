@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// videoDuration returns the length in seconds of the latest iteration of a
+// video, or zero if it is unknown
+func videoDuration(video *models.Video) int {
+	if len(video.Iterations) == 0 {
+		return 0
+	}
+	seconds, _ := models.ParseLength(video.Iterations[len(video.Iterations)-1].Length)
+	return seconds
+}
+
+// validateVideoChapters checks the chapters of a video against YouTube's
+// rules, the length of its latest iteration and the description they are
+// appended to
+func validateVideoChapters(video *models.Video, chapters []models.Chapter) error {
+	if err := models.ValidateChapters(chapters, videoDuration(video)); err != nil {
+		return err
+	}
+	return models.ValidateDescriptionWithChapters(video.Description, chapters)
+}
+
+// GetChaptersHandler lists the chapters of a video
+func GetChaptersHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoRead)
+		if !ok {
+			return
+		}
+
+		render.JSON(w, r, video.Chapters)
+	}
+}
+
+// ReplaceChaptersHandler replaces the chapters of a video with the list in
+// the request body
+func ReplaceChaptersHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, version, ok := routeVideoVersion(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+
+		chapters := []models.Chapter{}
+		if err := json.NewDecoder(r.Body).Decode(&chapters); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid chapter data"})
+			return
+		}
+
+		if err := validateVideoChapters(video, chapters); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		replaceChapters(w, r, db, video, chapters, version)
+	}
+}
+
+// DeleteChaptersHandler removes all chapters from a video
+func DeleteChaptersHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, version, ok := routeVideoVersion(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+
+		replaceChapters(w, r, db, video, []models.Chapter{}, version)
+	}
+}
+
+// replaceChapters stores the chapters on the version of the video they were
+// validated against
+func replaceChapters(w http.ResponseWriter, r *http.Request, db *database.DB, video *models.Video, chapters []models.Chapter, ifMatch int) {
+	newVersion, err := db.ReplaceChapters(video.ID, chapters, video.Version)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": "Video not found"})
+			return
+		}
+		if errors.Is(err, database.ErrVersionMismatch) {
+			renderVersionConflict(w, r, ifMatch)
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to update chapters"})
+		return
+	}
+	action := models.AuditUpdate
+	if len(chapters) == 0 {
+		action = models.AuditDelete
+	}
+	middleware.AuditChange(r, action, videoTarget(video, models.ResourceChapters, video.ID),
+		map[string][]models.Chapter{"chapters": video.Chapters}, map[string][]models.Chapter{"chapters": chapters})
+
+	setETag(w, newVersion)
+	render.JSON(w, r, chapters)
+}
@@ -8,17 +8,25 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 
+	"github.com/FuseWorkflows/fuse-go-server/config"
 	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
 	"github.com/FuseWorkflows/fuse-go-server/models"
 )
 
-// GetEditorHandler retrieves a list of editors
+// GetEditorHandler retrieves a page of editors
 func GetEditorHandler(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		editors, err := db.GetEditors()
+		params, err := parseListParams(r)
 		if err != nil {
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, map[string]string{"error": "Failed to fetch editors"})
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+
+		editors, err := db.ListEditors(params)
+		if err != nil {
+			renderListError(w, r, err, "Failed to fetch editors")
 			return
 		}
 
@@ -26,8 +34,9 @@ func GetEditorHandler(db *database.DB) http.HandlerFunc {
 	}
 }
 
-// CreateEditorHandler creates a new editor
-func CreateEditorHandler(db *database.DB) http.HandlerFunc {
+// CreateEditorHandler creates a new editor. Like users, editors start on a
+// trial unless trials are disabled, whatever tier or trial the body sets.
+func CreateEditorHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var editor models.Editor
 		if err := json.NewDecoder(r.Body).Decode(&editor); err != nil {
@@ -36,12 +45,13 @@ func CreateEditorHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		createdEditor, err := db.CreateEditor(&editor)
+		createdEditor, err := db.CreateEditor(&editor, models.Tier(cfg.TrialTier), cfg.TrialLength)
 		if err != nil {
 			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, map[string]string{"error": "Failed to create editor"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditCreate, models.AuditTarget{ResourceType: models.ResourceEditor, ResourceID: createdEditor.ID}, nil, createdEditor.Fields())
 
 		render.Status(r, http.StatusCreated)
 		render.JSON(w, r, createdEditor)
@@ -74,7 +84,7 @@ func GetEditorByIDHandler(db *database.DB) http.HandlerFunc {
 	}
 }
 
-// UpdateEditorHandler updates an editor by ID
+// UpdateEditorHandler applies a JSON merge patch or JSON patch to an editor by ID
 func UpdateEditorHandler(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		editorID := chi.URLParam(r, "editorID")
@@ -84,14 +94,30 @@ func UpdateEditorHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		var editor models.Editor
-		if err := json.NewDecoder(r.Body).Decode(&editor); err != nil {
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, map[string]string{"error": "Invalid editor data"})
+		editor, err := db.GetEditorByID(editorID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Editor not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch editor"})
+			return
+		}
+
+		fields, err := decodePatch(r, editor.Fields())
+		if err != nil {
+			renderPatchError(w, r, err)
+			return
+		}
+		// Tiers only change through trials granted by admins
+		if fields.Tier != editor.Tier {
+			renderValidationError(w, r, &models.ValidationError{Field: "tier", Message: "can only be changed by an admin with POST /admin/editors/{editorID}/trial/extend"})
 			return
 		}
 
-		updatedEditor, err := db.UpdateEditor(editorID, &editor)
+		updatedEditor, err := db.UpdateEditor(editorID, &fields)
 		if err != nil {
 			if errors.Is(err, database.ErrNotFound) {
 				render.Status(r, http.StatusNotFound)
@@ -102,6 +128,7 @@ func UpdateEditorHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Failed to update editor"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditUpdate, models.AuditTarget{ResourceType: models.ResourceEditor, ResourceID: editorID}, editor.Fields(), updatedEditor.Fields())
 
 		render.JSON(w, r, updatedEditor)
 	}
@@ -128,6 +155,7 @@ func DeleteEditorHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Failed to delete editor"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditDelete, models.AuditTarget{ResourceType: models.ResourceEditor, ResourceID: editorID}, nil, nil)
 
 		render.Status(r, http.StatusOK)
 		render.JSON(w, r, map[string]string{"message": "Editor deleted successfully"})
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+// errInvalidIfMatch is returned for an If-Match header that doesn't name a
+// single version of the resource
+var errInvalidIfMatch = errors.New("If-Match must be a single entity tag")
+
+// etag returns the entity tag of a resource version
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// setETag sets the ETag header of a response
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// ifMatchVersion returns the version required by the If-Match header of a
+// request, or zero if the request is unconditional
+func ifMatchVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, errInvalidIfMatch
+	}
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || version < 1 {
+		return 0, errInvalidIfMatch
+	}
+	return version, nil
+}
+
+// notModified responds with 304 Not Modified if the If-None-Match header of
+// a request matches the current version of the resource
+func notModified(w http.ResponseWriter, r *http.Request, version int) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			setETag(w, version)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// renderPreconditionFailed responds to a write whose If-Match header names a
+// version other than the current one
+func renderPreconditionFailed(w http.ResponseWriter, r *http.Request) {
+	render.Status(r, http.StatusPreconditionFailed)
+	render.JSON(w, r, map[string]string{"error": "Resource has been modified"})
+}
+
+// renderVersionConflict responds to a patch whose target changed between
+// being read and written. Unconditional requests get 409 since the client
+// didn't name a version.
+func renderVersionConflict(w http.ResponseWriter, r *http.Request, ifMatch int) {
+	if ifMatch != 0 {
+		renderPreconditionFailed(w, r)
+		return
+	}
+	render.Status(r, http.StatusConflict)
+	render.JSON(w, r, map[string]string{"error": "Resource was modified concurrently, retry the request"})
+}
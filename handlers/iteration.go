@@ -8,16 +8,30 @@ import (
 	"github.com/go-chi/render"
 
 	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
 	"github.com/FuseWorkflows/fuse-go-server/models"
 )
 
-// GetIterationHandler retrieves a list of iterations
+// GetIterationHandler retrieves a page of the iterations the user can see
 func GetIterationHandler(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		iterations, err := db.GetIterations()
+		userID, err := middleware.GetUserIDFromContext(r)
 		if err != nil {
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, map[string]string{"error": "Failed to fetch iterations"})
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		params, err := parseListParams(r)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+
+		iterations, err := db.ListIterations(userID, params)
+		if err != nil {
+			renderListError(w, r, err, "Failed to fetch iterations")
 			return
 		}
 
@@ -34,6 +48,9 @@ func CreateIterationHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Invalid iteration data"})
 			return
 		}
+		if !authorizeResource(w, r, db, models.ResourceVideo, iteration.Video.ID, models.PermissionIterationCreate) {
+			return
+		}
 
 		createdIteration, err := db.CreateIteration(&iteration)
 		if err != nil {
@@ -41,6 +58,7 @@ func CreateIterationHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Failed to create iteration"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditCreate, iterationTarget(createdIteration, models.ResourceIteration, createdIteration.ID), nil, createdIteration.Fields())
 
 		render.Status(r, http.StatusCreated)
 		render.JSON(w, r, createdIteration)
@@ -68,12 +86,20 @@ func GetIterationByIDHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Failed to fetch iteration"})
 			return
 		}
+		if !authorize(w, r, db, iteration.Video.Channel.OrganizationID, models.PermissionVideoRead, "Iteration") {
+			return
+		}
 
+		if notModified(w, r, iteration.Version) {
+			return
+		}
+
+		setETag(w, iteration.Version)
 		render.JSON(w, r, iteration)
 	}
 }
 
-// UpdateIterationHandler updates an iteration by ID
+// UpdateIterationHandler applies a JSON merge patch or JSON patch to an iteration by ID
 func UpdateIterationHandler(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		iterationID := chi.URLParam(r, "iterationID")
@@ -83,14 +109,14 @@ func UpdateIterationHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		var iteration models.Iteration
-		if err := render.Bind(r, &iteration); err != nil {
+		version, err := ifMatchVersion(r)
+		if err != nil {
 			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, map[string]string{"error": "Invalid iteration data"})
+			render.JSON(w, r, map[string]string{"error": err.Error()})
 			return
 		}
 
-		updatedIteration, err := db.UpdateIteration(iterationID, &iteration)
+		iteration, err := db.GetIterationByID(iterationID)
 		if err != nil {
 			if errors.Is(err, database.ErrNotFound) {
 				render.Status(r, http.StatusNotFound)
@@ -98,10 +124,42 @@ func UpdateIterationHandler(db *database.DB) http.HandlerFunc {
 				return
 			}
 			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch iteration"})
+			return
+		}
+		if version != 0 && version != iteration.Version {
+			renderPreconditionFailed(w, r)
+			return
+		}
+		if !authorize(w, r, db, iteration.Video.Channel.OrganizationID, models.PermissionIterationUpdate, "Iteration") {
+			return
+		}
+
+		fields, err := decodePatch(r, iteration.Fields())
+		if err != nil {
+			renderPatchError(w, r, err)
+			return
+		}
+
+		updatedIteration, err := db.UpdateIteration(iterationID, &fields, iteration.Version)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Iteration not found"})
+				return
+			}
+			if errors.Is(err, database.ErrVersionMismatch) {
+				renderVersionConflict(w, r, version)
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, map[string]string{"error": "Failed to update iteration"})
 			return
 		}
 
+		middleware.AuditChange(r, models.AuditUpdate, iterationTarget(iteration, models.ResourceIteration, iteration.ID), iteration.Fields(), updatedIteration.Fields())
+
+		setETag(w, updatedIteration.Version)
 		render.JSON(w, r, updatedIteration)
 	}
 }
@@ -116,17 +174,33 @@ func DeleteIterationHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		err := db.DeleteIteration(iterationID)
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+		iteration, ok := routeIteration(w, r, db, models.PermissionIterationDelete)
+		if !ok {
+			return
+		}
+
+		err = db.DeleteIteration(iterationID, version)
 		if err != nil {
 			if errors.Is(err, database.ErrNotFound) {
 				render.Status(r, http.StatusNotFound)
 				render.JSON(w, r, map[string]string{"error": "Iteration not found"})
 				return
 			}
+			if errors.Is(err, database.ErrVersionMismatch) {
+				renderPreconditionFailed(w, r)
+				return
+			}
 			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, map[string]string{"error": "Failed to delete iteration"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditDelete, iterationTarget(iteration, models.ResourceIteration, iteration.ID), iteration.Fields(), nil)
 
 		render.Status(r, http.StatusOK)
 		render.JSON(w, r, map[string]string{"message": "Iteration deleted successfully"})
@@ -149,6 +223,10 @@ func AddNoteToIterationHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Invalid note data"})
 			return
 		}
+		iteration, ok := routeIteration(w, r, db, models.PermissionCommentCreate)
+		if !ok {
+			return
+		}
 
 		err := db.AddNoteToIteration(iterationID, &note)
 		if err != nil {
@@ -161,6 +239,7 @@ func AddNoteToIterationHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Failed to add note to iteration"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditCreate, iterationTarget(iteration, models.ResourceNote, iteration.ID), nil, note)
 
 		render.Status(r, http.StatusOK)
 		render.JSON(w, r, map[string]string{"message": "Note added successfully"})
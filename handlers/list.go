@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+	defaultListSort  = "-createdAt"
+)
+
+// parseListParams reads the pagination, sorting and filtering query parameters
+func parseListParams(r *http.Request) (database.ListParams, error) {
+	query := r.URL.Query()
+	params := database.ListParams{
+		Limit:     defaultListLimit,
+		Cursor:    query.Get("cursor"),
+		Sort:      query.Get("sort"),
+		Status:    query.Get("status"),
+		ChannelID: query.Get("channelID"),
+		EditorID:  query.Get("editorID"),
+		VideoID:   query.Get("videoID"),
+		Category:  query.Get("category"),
+		Tier:      query.Get("tier"),
+	}
+
+	if params.Sort == "" {
+		params.Sort = defaultListSort
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxListLimit {
+			return params, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		params.Limit = n
+	}
+
+	for name, id := range map[string]string{"channelID": params.ChannelID, "editorID": params.EditorID, "videoID": params.VideoID} {
+		if id == "" {
+			continue
+		}
+		if _, err := uuid.Parse(id); err != nil {
+			return params, fmt.Errorf("%s must be a valid ID", name)
+		}
+	}
+
+	if createdAfter := query.Get("createdAfter"); createdAfter != "" {
+		t, err := time.Parse(time.RFC3339, createdAfter)
+		if err != nil {
+			return params, errors.New("createdAfter must be an RFC 3339 timestamp")
+		}
+		t = t.UTC()
+		params.CreatedAfter = &t
+	}
+
+	return params, nil
+}
+
+// parseListLimit reads the page size of lists that only take a limit and a
+// cursor
+func parseListLimit(r *http.Request) (int, error) {
+	limit := r.URL.Query().Get("limit")
+	if limit == "" {
+		return defaultListLimit, nil
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 || n > maxListLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+	}
+	return n, nil
+}
+
+// renderListError responds to an error returned by a list query
+func renderListError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if errors.Is(err, database.ErrInvalidCursor) || errors.Is(err, database.ErrInvalidSort) {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, map[string]string{"error": err.Error()})
+		return
+	}
+	render.Status(r, http.StatusInternalServerError)
+	render.JSON(w, r, map[string]string{"error": message})
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"golang.org/x/text/language"
+
+	"github.com/FuseWorkflows/fuse-go-server/ai"
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// validateVideoLocalizations checks that the localizations of a video can be
+// published: YouTube needs to know the default language, and AI translations
+// must have been reviewed
+func validateVideoLocalizations(video *models.Video) error {
+	if len(video.Localizations) == 0 {
+		return nil
+	}
+	if video.DefaultLanguage == "" {
+		return &models.ValidationError{Field: "defaultLanguage", Message: "is required on videos with localizations"}
+	}
+	for _, localization := range video.Localizations {
+		if localization.Language == video.DefaultLanguage {
+			return &models.ValidationError{Field: "localizations", Message: fmt.Sprintf("%s is the default language of the video", localization.Language)}
+		}
+		if localization.NeedsReview {
+			return &models.ValidationError{Field: "localizations", Message: fmt.Sprintf("%s must be reviewed before publishing", localization.Language)}
+		}
+	}
+	return nil
+}
+
+// routeLanguage canonicalizes the language of a nested localization route
+func routeLanguage(w http.ResponseWriter, r *http.Request) (string, bool) {
+	tag, err := language.Parse(chi.URLParam(r, "language"))
+	if err != nil {
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, map[string]string{"error": "Localization not found"})
+		return "", false
+	}
+	return tag.String(), true
+}
+
+// renderLocalizationError responds to a failed localization write
+func renderLocalizationError(w http.ResponseWriter, r *http.Request, err error, ifMatch int) {
+	if errors.Is(err, database.ErrNotFound) {
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, map[string]string{"error": "Localization not found"})
+		return
+	}
+	if errors.Is(err, database.ErrVersionMismatch) {
+		renderVersionConflict(w, r, ifMatch)
+		return
+	}
+	render.Status(r, http.StatusInternalServerError)
+	render.JSON(w, r, map[string]string{"error": "Failed to update localizations"})
+}
+
+// videoLocalization returns the localization of a video in a language, or nil
+// if it has none
+func videoLocalization(video *models.Video, lang string) *models.Localization {
+	for i := range video.Localizations {
+		if video.Localizations[i].Language == lang {
+			return &video.Localizations[i]
+		}
+	}
+	return nil
+}
+
+// GetLocalizationsHandler lists the localizations of a video
+func GetLocalizationsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoRead)
+		if !ok {
+			return
+		}
+
+		setETag(w, video.Version)
+		render.JSON(w, r, video.Localizations)
+	}
+}
+
+// PutLocalizationHandler creates or replaces the localization of a video in
+// the language of the route
+func PutLocalizationHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ifMatch, ok := routeVideoVersion(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+
+		var localization models.Localization
+		if err := json.NewDecoder(r.Body).Decode(&localization); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid localization data"})
+			return
+		}
+		localization.Language = chi.URLParam(r, "language")
+		if err := localization.Validate(video.DefaultLanguage); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		saved, version, err := db.PutLocalization(video.ID, &localization, video.Version)
+		if err != nil {
+			renderLocalizationError(w, r, err, ifMatch)
+			return
+		}
+		previous := videoLocalization(video, localization.Language)
+		action := models.AuditUpdate
+		if previous == nil {
+			action = models.AuditCreate
+		}
+		middleware.AuditChange(r, action, videoTarget(video, models.ResourceLocalization, localization.Language), previous, saved)
+
+		setETag(w, version)
+		render.JSON(w, r, saved)
+	}
+}
+
+// ApproveLocalizationHandler marks an AI translation as reviewed
+func ApproveLocalizationHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ifMatch, ok := routeVideoVersion(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+		lang, ok := routeLanguage(w, r)
+		if !ok {
+			return
+		}
+
+		approved, version, err := db.ApproveLocalization(video.ID, lang, video.Version)
+		if err != nil {
+			renderLocalizationError(w, r, err, ifMatch)
+			return
+		}
+		middleware.AuditChange(r, models.AuditApprove, videoTarget(video, models.ResourceLocalization, lang), videoLocalization(video, lang), approved)
+
+		setETag(w, version)
+		render.JSON(w, r, approved)
+	}
+}
+
+// DeleteLocalizationHandler deletes the localization of a video in the
+// language of the route
+func DeleteLocalizationHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ifMatch, ok := routeVideoVersion(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+		lang, ok := routeLanguage(w, r)
+		if !ok {
+			return
+		}
+
+		version, err := db.DeleteLocalization(video.ID, lang, video.Version)
+		if err != nil {
+			renderLocalizationError(w, r, err, ifMatch)
+			return
+		}
+		middleware.AuditChange(r, models.AuditDelete, videoTarget(video, models.ResourceLocalization, lang), videoLocalization(video, lang), nil)
+
+		setETag(w, version)
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, map[string]string{"message": "Localization deleted successfully"})
+	}
+}
+
+// TranslateLocalizationsHandler fills the languages in the request body that
+// the video has no localization for yet through the AI service. The
+// translations are flagged for review and block publishing until approved.
+func TranslateLocalizationsHandler(db *database.DB, providers *ai.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ifMatch, ok := routeVideoVersion(w, r, db, models.PermissionVideoUpdate)
+		if !ok || !authorize(w, r, db, video.Channel.OrganizationID, models.PermissionAIUse, "Video") {
+			return
+		}
+
+		var request struct {
+			Languages []string `json:"languages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid request body"})
+			return
+		}
+		if video.DefaultLanguage == "" {
+			renderValidationError(w, r, &models.ValidationError{Field: "defaultLanguage", Message: "is required to translate a video"})
+			return
+		}
+
+		existing := map[string]bool{video.DefaultLanguage: true}
+		for _, localization := range video.Localizations {
+			existing[localization.Language] = true
+		}
+		var missing []string
+		for _, lang := range request.Languages {
+			tag, err := language.Parse(lang)
+			if err != nil {
+				renderValidationError(w, r, &models.ValidationError{Field: "languages", Message: fmt.Sprintf("%q is not a BCP-47 language tag", lang)})
+				return
+			}
+			if !existing[tag.String()] {
+				existing[tag.String()] = true
+				missing = append(missing, tag.String())
+			}
+		}
+		if len(missing) == 0 {
+			render.JSON(w, r, video.Localizations)
+			return
+		}
+
+		provider, err := aiProvider(r, providers, &video.Channel)
+		if err != nil {
+			renderAIError(w, r, err)
+			return
+		}
+		refund, ok := consumeAIQuota(w, r, db, &video.Channel)
+		if !ok {
+			return
+		}
+		translations, err := provider.Localize(r.Context(), &ai.LocalizationRequest{
+			Title:           video.Title,
+			Description:     video.Description,
+			SourceLanguage:  video.DefaultLanguage,
+			TargetLanguages: missing,
+		})
+		if err != nil {
+			refund()
+			renderAIError(w, r, err)
+			return
+		}
+
+		// Only keep valid translations into the requested languages
+		requested := map[string]bool{}
+		for _, lang := range missing {
+			requested[lang] = true
+		}
+		var localizations []models.Localization
+		for _, localization := range translations {
+			if localization.Validate(video.DefaultLanguage) != nil || !requested[localization.Language] {
+				continue
+			}
+			localizations = append(localizations, localization)
+		}
+
+		version, err := db.AddAILocalizations(video.ID, localizations, video.Version)
+		if err != nil {
+			renderLocalizationError(w, r, err, ifMatch)
+			return
+		}
+		for _, localization := range localizations {
+			middleware.AuditChange(r, models.AuditGenerate, videoTarget(video, models.ResourceLocalization, localization.Language), nil, localization)
+		}
+
+		saved, err := db.GetLocalizationsByVideo(video.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch localizations"})
+			return
+		}
+
+		setETag(w, version)
+		render.JSON(w, r, saved)
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+)
+
+// maxNotifications is how many notifications are listed at most
+const maxNotifications = 100
+
+// GetNotificationsHandler lists the latest notifications of the
+// authenticated user. ?unread=true only lists the unread ones.
+func GetNotificationsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		notifications, err := db.GetNotificationsByUser(userID, r.URL.Query().Get("unread") == "true", maxNotifications)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch notifications"})
+			return
+		}
+
+		render.JSON(w, r, notifications)
+	}
+}
+
+// MarkNotificationReadHandler marks a notification of the authenticated user
+// as read
+func MarkNotificationReadHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		// Reading notifications changes nothing worth auditing
+		middleware.SkipAudit(r)
+
+		if err := db.MarkNotificationRead(userID, chi.URLParam(r, "notificationID")); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Notification not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to update notification"})
+			return
+		}
+
+		render.JSON(w, r, map[string]string{"message": "Notification marked as read"})
+	}
+}
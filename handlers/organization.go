@@ -0,0 +1,616 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/mail"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// routeOrganization fetches the organization of a nested organization route
+// for the authenticated user. It responds with 404 if the user isn't a member
+// and with 403 if their role doesn't grant permission. An empty permission
+// lets any member through.
+func routeOrganization(w http.ResponseWriter, r *http.Request, db *database.DB, permission models.Permission) (*models.Organization, bool) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		render.Status(r, http.StatusUnauthorized)
+		render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	organization, err := db.GetOrganization(chi.URLParam(r, "organizationID"), userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": "Organization not found"})
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch organization"})
+		return nil, false
+	}
+	if permission != "" && !models.HasPermission(organization.Permissions, permission) {
+		renderForbidden(w, r, permission)
+		return nil, false
+	}
+	return organization, true
+}
+
+// checkGrantable checks that a role exists in an organization and doesn't
+// grant permissions the authenticated user lacks, so members can't hand out
+// more than they have. It responds with an error otherwise.
+func checkGrantable(w http.ResponseWriter, r *http.Request, db *database.DB, organization *models.Organization, role models.Role) bool {
+	permissions, err := db.GetRolePermissions(organization.ID, role)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			renderValidationError(w, r, &models.ValidationError{Field: "role", Message: "is not a role of the organization"})
+			return false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch role"})
+		return false
+	}
+	if !models.HasPermissions(organization.Permissions, permissions) {
+		render.Status(r, http.StatusForbidden)
+		render.JSON(w, r, map[string]string{"error": fmt.Sprintf("The %s role grants permissions you don't have", role)})
+		return false
+	}
+	return true
+}
+
+// checkManageable checks that the authenticated user can change or remove
+// a member, which takes member:manage and every permission the member has
+func checkManageable(w http.ResponseWriter, r *http.Request, db *database.DB, organization *models.Organization, memberID string) bool {
+	if !models.HasPermission(organization.Permissions, models.PermissionMemberManage) {
+		renderForbidden(w, r, models.PermissionMemberManage)
+		return false
+	}
+
+	_, permissions, err := db.GetMemberPermissions(organization.ID, memberID)
+	if err != nil {
+		renderMembershipError(w, r, err, "Member not found")
+		return false
+	}
+	if !models.HasPermissions(organization.Permissions, permissions) {
+		render.Status(r, http.StatusForbidden)
+		render.JSON(w, r, map[string]string{"error": "This member has permissions you don't have"})
+		return false
+	}
+	return true
+}
+
+// renderMembershipError responds to a failed change of the members of an
+// organization
+func renderMembershipError(w http.ResponseWriter, r *http.Request, err error, notFound string) {
+	if errors.Is(err, database.ErrNotFound) {
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, map[string]string{"error": notFound})
+		return
+	}
+	if errors.Is(err, database.ErrLastOwner) {
+		render.Status(r, http.StatusConflict)
+		render.JSON(w, r, map[string]string{"error": "The organization needs another owner first"})
+		return
+	}
+	fmt.Println(err)
+	render.Status(r, http.StatusInternalServerError)
+	render.JSON(w, r, map[string]string{"error": "Failed to update members"})
+}
+
+// GetOrganizationsHandler lists the organizations of the authenticated user
+// along with their role in each
+func GetOrganizationsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		organizations, err := db.GetOrganizationsByUser(userID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch organizations"})
+			return
+		}
+
+		render.JSON(w, r, organizations)
+	}
+}
+
+// CreateOrganizationHandler creates an organization owned by the
+// authenticated user
+func CreateOrganizationHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		var organization models.Organization
+		if err := json.NewDecoder(r.Body).Decode(&organization); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid organization data"})
+			return
+		}
+		if err := organization.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		createdOrganization, err := db.CreateOrganization(organization.Name, userID)
+		if err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to create organization"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditCreate, organizationTarget(createdOrganization.ID, models.ResourceOrganization, createdOrganization.ID),
+			nil, map[string]string{"name": createdOrganization.Name})
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, createdOrganization)
+	}
+}
+
+// GetOrganizationHandler retrieves an organization of the authenticated user
+func GetOrganizationHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, "")
+		if !ok {
+			return
+		}
+
+		render.JSON(w, r, organization)
+	}
+}
+
+// UpdateOrganizationHandler renames an organization
+func UpdateOrganizationHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, models.PermissionOrganizationManage)
+		if !ok {
+			return
+		}
+
+		previousName := organization.Name
+		if err := json.NewDecoder(r.Body).Decode(organization); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid organization data"})
+			return
+		}
+		if err := organization.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		userID, _ := middleware.GetUserIDFromContext(r)
+		organizationID := chi.URLParam(r, "organizationID")
+		if err := db.UpdateOrganization(organizationID, organization.Name); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Organization not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to update organization"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditUpdate, organizationTarget(organizationID, models.ResourceOrganization, organizationID),
+			map[string]string{"name": previousName}, map[string]string{"name": organization.Name})
+
+		updatedOrganization, err := db.GetOrganization(organizationID, userID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch organization"})
+			return
+		}
+
+		render.JSON(w, r, updatedOrganization)
+	}
+}
+
+// DeleteOrganizationHandler deletes an organization that has no channels
+// left
+func DeleteOrganizationHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, models.PermissionOrganizationManage)
+		if !ok {
+			return
+		}
+
+		if err := db.DeleteOrganization(organization.ID); err != nil {
+			if errors.Is(err, database.ErrHasChannels) {
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, map[string]string{"error": "Transfer or delete the channels of the organization first, including the ones in the trash"})
+				return
+			}
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Organization not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to delete organization"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditDelete, organizationTarget(organization.ID, models.ResourceOrganization, organization.ID),
+			map[string]string{"name": organization.Name}, nil)
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, map[string]string{"message": "Organization deleted successfully"})
+	}
+}
+
+// GetMembersHandler lists the members of an organization
+func GetMembersHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, "")
+		if !ok {
+			return
+		}
+
+		members, err := db.GetMembers(organization.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch members"})
+			return
+		}
+
+		render.JSON(w, r, members)
+	}
+}
+
+// UpdateMemberHandler changes the role of a member with {"role": "producer"}.
+// Neither the member's current role nor the new one can grant permissions
+// the authenticated user lacks, so only owners can make or demote owners.
+func UpdateMemberHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, models.PermissionMemberManage)
+		if !ok {
+			return
+		}
+
+		var request struct {
+			Role models.Role `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid request body"})
+			return
+		}
+		if request.Role == "" {
+			renderValidationError(w, r, &models.ValidationError{Field: "role", Message: "is required"})
+			return
+		}
+
+		memberID := chi.URLParam(r, "userID")
+		if !checkManageable(w, r, db, organization, memberID) || !checkGrantable(w, r, db, organization, request.Role) {
+			return
+		}
+
+		previousRole, _, err := db.GetMemberPermissions(organization.ID, memberID)
+		if err != nil {
+			renderMembershipError(w, r, err, "Member not found")
+			return
+		}
+		if err := db.SetMemberRole(organization.ID, memberID, request.Role); err != nil {
+			renderMembershipError(w, r, err, "Member not found")
+			return
+		}
+		middleware.AuditChange(r, models.AuditUpdate, organizationTarget(organization.ID, models.ResourceMember, memberID),
+			map[string]models.Role{"role": previousRole}, map[string]models.Role{"role": request.Role})
+
+		members, err := db.GetMembers(organization.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch members"})
+			return
+		}
+
+		render.JSON(w, r, members)
+	}
+}
+
+// RemoveMemberHandler removes a member from an organization. Members can
+// leave on their own, otherwise the same rules as UpdateMemberHandler apply.
+func RemoveMemberHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, "")
+		if !ok {
+			return
+		}
+
+		userID, _ := middleware.GetUserIDFromContext(r)
+		memberID := chi.URLParam(r, "userID")
+		if memberID != userID && !checkManageable(w, r, db, organization, memberID) {
+			return
+		}
+
+		if err := db.RemoveMember(organization.ID, memberID); err != nil {
+			renderMembershipError(w, r, err, "Member not found")
+			return
+		}
+		middleware.AuditChange(r, models.AuditDelete, organizationTarget(organization.ID, models.ResourceMember, memberID), nil, nil)
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, map[string]string{"message": "Member removed successfully"})
+	}
+}
+
+// GetInvitationsHandler lists the pending invitations of an organization
+func GetInvitationsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, models.PermissionMemberManage)
+		if !ok {
+			return
+		}
+
+		invitations, err := db.GetInvitationsByOrganization(organization.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch invitations"})
+			return
+		}
+
+		render.JSON(w, r, invitations)
+	}
+}
+
+// CreateInvitationHandler invites someone to an organization with
+// {"email": "...", "role": "producer"}. The invitation is accepted with the
+// token emailed to that address, and users who already have it are
+// notified. The role can't grant permissions the inviter lacks.
+func CreateInvitationHandler(db *database.DB, mailer mail.Sender, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, models.PermissionMemberManage)
+		if !ok {
+			return
+		}
+
+		var invitation models.Invitation
+		if err := json.NewDecoder(r.Body).Decode(&invitation); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid invitation data"})
+			return
+		}
+		if err := invitation.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+		if !checkGrantable(w, r, db, organization, invitation.Role) {
+			return
+		}
+
+		token, err := newToken()
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to generate token"})
+			return
+		}
+
+		inviter, _ := middleware.GetUserFromContext(r)
+		invitation.OrganizationID = organization.ID
+		invitation.InvitedBy = inviter.ID
+		createdInvitation, err := db.CreateInvitation(&invitation, hashToken(token))
+		if err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to create invitation"})
+			return
+		}
+
+		// The token is only ever sent to the invited address, so that
+		// accepting proves access to it
+		err = mailer.Send(r.Context(), &mail.Message{
+			To:      createdInvitation.Email,
+			Subject: "You have been invited to join " + organization.Name + " on Fuse",
+			Body: fmt.Sprintf("%s invited you to join %s as %s.\n\nAccept the invitation at %s/invitations/%s before %s.\n",
+				inviter.Username, organization.Name, createdInvitation.Role, cfg.AppURL, token, createdInvitation.ExpiresAt.UTC().Format(time.RFC1123)),
+		})
+		if err != nil {
+			db.DeleteInvitation(organization.ID, createdInvitation.ID)
+			render.Status(r, http.StatusBadGateway)
+			render.JSON(w, r, map[string]string{"error": "Failed to send the invitation email"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditCreate, organizationTarget(organization.ID, models.ResourceInvitation, createdInvitation.ID),
+			nil, map[string]interface{}{"email": createdInvitation.Email, "role": createdInvitation.Role})
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, createdInvitation)
+	}
+}
+
+// DeleteInvitationHandler revokes a pending invitation
+func DeleteInvitationHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, models.PermissionMemberManage)
+		if !ok {
+			return
+		}
+
+		invitationID := chi.URLParam(r, "invitationID")
+		if err := db.DeleteInvitation(organization.ID, invitationID); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Invitation not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to delete invitation"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditRevoke, organizationTarget(organization.ID, models.ResourceInvitation, invitationID), nil, nil)
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, map[string]string{"message": "Invitation deleted successfully"})
+	}
+}
+
+// GetMyInvitationsHandler lists the invitations the authenticated user can accept
+func GetMyInvitationsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := middleware.GetUserFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		invitations, err := db.GetInvitationsByEmail(user.Email)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch invitations"})
+			return
+		}
+
+		render.JSON(w, r, invitations)
+	}
+}
+
+// AcceptInvitationHandler makes the authenticated user a member of the
+// organization they were invited to with {"token": "..."}, the token of the
+// invitation email
+func AcceptInvitationHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := middleware.GetUserFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		var request struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+			renderValidationError(w, r, &models.ValidationError{Field: "token", Message: "is required"})
+			return
+		}
+
+		organization, invitationID, err := db.AcceptInvitation(hashToken(request.Token), user)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Invitation not found or expired"})
+				return
+			}
+			fmt.Println(err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to accept invitation"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditAccept, organizationTarget(organization.ID, models.ResourceInvitation, invitationID),
+			nil, map[string]models.Role{"role": organization.Role})
+
+		render.JSON(w, r, organization)
+	}
+}
+
+// TransferChannelHandler moves a channel to another organization with
+// {"organizationId": "..."}. It takes channel:transfer in the current
+// organization and channel:create in the new one, and the channel then
+// counts against the quotas of the owner of the new organization.
+func TransferChannelHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			OrganizationID string `json:"organizationId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid request body"})
+			return
+		}
+
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+
+		channel, err := db.GetChannelByID(chi.URLParam(r, "channelID"))
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Channel not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch channel"})
+			return
+		}
+		if version != 0 && version != channel.Version {
+			renderPreconditionFailed(w, r)
+			return
+		}
+		if request.OrganizationID == channel.OrganizationID {
+			renderValidationError(w, r, &models.ValidationError{Field: "organizationId", Message: "is the current organization of the channel"})
+			return
+		}
+
+		if !authorize(w, r, db, channel.OrganizationID, models.PermissionChannelTransfer, "Channel") {
+			return
+		}
+		if !authorize(w, r, db, request.OrganizationID, models.PermissionChannelCreate, "Organization") {
+			return
+		}
+
+		owner, err := db.GetOrganizationOwner(request.OrganizationID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch account"})
+			return
+		}
+		if owner.ID != channel.Owner.ID {
+			count, err := db.CountChannelsByOwner(owner.ID)
+			if err != nil {
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, map[string]string{"error": "Failed to count channels"})
+				return
+			}
+			if !checkQuota(w, r, owner, models.MetricChannels, count, 1) {
+				return
+			}
+		}
+
+		transferredChannel, err := db.TransferChannel(channel.ID, request.OrganizationID, channel.Version)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Channel not found"})
+				return
+			}
+			if errors.Is(err, database.ErrVersionMismatch) {
+				renderVersionConflict(w, r, version)
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to transfer channel"})
+			return
+		}
+		// Both organizations keep the transfer in their audit log
+		for _, target := range []models.AuditTarget{channelTarget(channel), channelTarget(transferredChannel)} {
+			middleware.AuditChange(r, models.AuditTransfer, target,
+				map[string]string{"organizationId": channel.OrganizationID}, map[string]string{"organizationId": transferredChannel.OrganizationID})
+		}
+
+		setETag(w, transferredChannel.Version)
+		render.JSON(w, r, transferredChannel)
+	}
+}
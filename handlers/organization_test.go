@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/mail"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// as authenticates the requests of a handler as user, like the Auth
+// middleware does
+func as(user *models.User, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "user", user)))
+	})
+}
+
+// request serves a JSON request with the handler of a route and returns the
+// response
+func request(pattern string, handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	router := chi.NewRouter()
+	router.Method(method, pattern, handler)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return recorder
+}
+
+func TestAcceptInvitationWithEmailedToken(t *testing.T) {
+	db := testDB(t)
+	owner := testUser(t, db)
+	invitee := testUser(t, db)
+	organization, err := db.CreateOrganization("Studio", owner.ID)
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+
+	outbox := mail.NewOutbox(false)
+	create := as(owner, CreateInvitationHandler(db, outbox, &config.Config{AppURL: "https://app.example.com"}))
+	response := request("/organizations/{organizationID}/invitations", create, http.MethodPost,
+		"/organizations/"+organization.ID+"/invitations", `{"email": "`+strings.ToUpper(invitee.Email)+`", "role": "producer"}`)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("creating the invitation got %d: %s", response.Code, response.Body)
+	}
+	if strings.Contains(response.Body.String(), "token") {
+		t.Errorf("the invitation was returned with its token: %s", response.Body)
+	}
+
+	messages := outbox.Messages()
+	if len(messages) != 1 || !strings.EqualFold(messages[0].To, invitee.Email) {
+		t.Fatalf("sent %+v", messages)
+	}
+	token := regexp.MustCompile(`/invitations/([A-Za-z0-9_-]+)`).FindStringSubmatch(messages[0].Body)
+	if token == nil {
+		t.Fatalf("no token in %q", messages[0].Body)
+	}
+
+	// Having the invited address isn't enough, the token is
+	accept := as(invitee, AcceptInvitationHandler(db))
+	if response := request("/me/invitations/accept", accept, http.MethodPost, "/me/invitations/accept", `{"token": "wrong"}`); response.Code != http.StatusNotFound {
+		t.Errorf("accepting with a wrong token got %d", response.Code)
+	}
+	if response := request("/me/invitations/accept", accept, http.MethodPost, "/me/invitations/accept", `{"token": "`+token[1]+`"}`); response.Code != http.StatusOK {
+		t.Fatalf("accepting with the token got %d: %s", response.Code, response.Body)
+	}
+	if _, err := db.GetOrganization(organization.ID, invitee.ID); err != nil {
+		t.Errorf("the invitee isn't a member: %v", err)
+	}
+
+	// Tokens can only be used once
+	other := as(testUser(t, db), AcceptInvitationHandler(db))
+	if response := request("/me/invitations/accept", other, http.MethodPost, "/me/invitations/accept", `{"token": "`+token[1]+`"}`); response.Code != http.StatusNotFound {
+		t.Errorf("accepting an accepted invitation got %d", response.Code)
+	}
+}
+
+func TestUpdateUserOnlyOwnAccount(t *testing.T) {
+	db := testDB(t)
+	user := testUser(t, db)
+	victim := testUser(t, db)
+
+	update := as(user, UpdateUserHandler(db))
+	body := `{"username": "taken", "email": "` + user.Email + `", "password": "password"}`
+	if response := request("/users/{userID}", update, http.MethodPatch, "/users/"+victim.ID, body); response.Code != http.StatusForbidden {
+		t.Errorf("updating another user got %d", response.Code)
+	}
+	if got, err := db.GetUserByID(victim.ID); err != nil || got.Email != victim.Email {
+		t.Errorf("the other user is %+v, %v", got, err)
+	}
+}
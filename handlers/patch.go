@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+	"github.com/FuseWorkflows/fuse-go-server/patch"
+)
+
+// errInvalidPatchResult is returned when a patch applies cleanly but doesn't
+// produce a valid resource
+var errInvalidPatchResult = errors.New("patched resource is invalid")
+
+// decodePatch applies the merge patch or JSON patch in the request body to
+// the current fields of a resource and returns the validated result. Fields
+// absent from the patch keep their current value while null clears them.
+func decodePatch[T any](r *http.Request, current T) (T, error) {
+	var next T
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return next, err
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return next, err
+	}
+
+	patched, err := patch.Apply(r.Header.Get("Content-Type"), doc, body)
+	if err != nil {
+		return next, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&next); err != nil {
+		return next, fmt.Errorf("%w: %v", errInvalidPatchResult, err)
+	}
+
+	if v, ok := any(&next).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return next, err
+		}
+	}
+	return next, nil
+}
+
+// renderValidationError responds to invalid input, with 422 for values that
+// break a rule of the resource
+func renderValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		render.Status(r, http.StatusUnprocessableEntity)
+		render.JSON(w, r, map[string]string{"error": validationErr.Error()})
+		return
+	}
+	render.Status(r, http.StatusBadRequest)
+	render.JSON(w, r, map[string]string{"error": err.Error()})
+}
+
+// renderPatchError responds to an error returned by decodePatch or by the
+// write of the patched fields
+func renderPatchError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		renderValidationError(w, r, err)
+	case errors.Is(err, errInvalidPatchResult):
+		render.Status(r, http.StatusUnprocessableEntity)
+		render.JSON(w, r, map[string]string{"error": err.Error()})
+	case errors.Is(err, patch.ErrUnsupportedMediaType):
+		w.Header().Set("Accept-Patch", patch.MergePatchType+", "+patch.JSONPatchType)
+		render.Status(r, http.StatusUnsupportedMediaType)
+		render.JSON(w, r, map[string]string{"error": err.Error()})
+	case errors.Is(err, patch.ErrTestFailed):
+		render.Status(r, http.StatusConflict)
+		render.JSON(w, r, map[string]string{"error": err.Error()})
+	case errors.Is(err, patch.ErrInvalidPatch):
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, map[string]string{"error": err.Error()})
+	default:
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, map[string]string{"error": "Invalid patch"})
+	}
+}
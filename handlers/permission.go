@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// authorize checks that the role of the authenticated user in an
+// organization grants permission, responding with 403 otherwise. Non-members
+// get 404 as if the resource, named name in the message, didn't exist.
+func authorize(w http.ResponseWriter, r *http.Request, db *database.DB, organizationID string, permission models.Permission, name string) bool {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		render.Status(r, http.StatusUnauthorized)
+		render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+		return false
+	}
+
+	_, permissions, err := db.GetMemberPermissions(organizationID, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": name + " not found"})
+			return false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch membership"})
+		return false
+	}
+	if !models.HasPermission(permissions, permission) {
+		renderForbidden(w, r, permission)
+		return false
+	}
+	return true
+}
+
+// resourceNames are the kinds of resources as they appear in error messages
+var resourceNames = map[string]string{
+	models.ResourceOrganization: "Organization",
+	models.ResourceChannel:      "Channel",
+	models.ResourceVideo:        "Video",
+	models.ResourceIteration:    "Iteration",
+}
+
+// authorizeResource is authorize for the organization a resource belongs
+// to, responding with 404 if the resource doesn't exist
+func authorizeResource(w http.ResponseWriter, r *http.Request, db *database.DB, resourceType, resourceID string, permission models.Permission) bool {
+	organizationID, err := db.GetResourceOrganization(resourceType, resourceID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": resourceNames[resourceType] + " not found"})
+			return false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch " + resourceType})
+		return false
+	}
+	return authorize(w, r, db, organizationID, permission, resourceNames[resourceType])
+}
+
+// renderForbidden responds to a request the role of the user doesn't allow
+func renderForbidden(w http.ResponseWriter, r *http.Request, permission models.Permission) {
+	render.Status(r, http.StatusForbidden)
+	render.JSON(w, r, map[string]string{"error": fmt.Sprintf("This requires the %s permission", permission)})
+}
+
+// GetRolesHandler lists the built-in and custom roles of an organization
+// with the permissions they grant
+func GetRolesHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, "")
+		if !ok {
+			return
+		}
+
+		roles, err := db.GetRoles(organization.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch roles"})
+			return
+		}
+
+		render.JSON(w, r, map[string]interface{}{"roles": roles, "permissions": models.Permissions})
+	}
+}
+
+// decodeRole reads and validates a custom role, which can't grant
+// permissions the authenticated user doesn't have
+func decodeRole(w http.ResponseWriter, r *http.Request, organization *models.Organization, role *models.RoleDefinition) bool {
+	if err := json.NewDecoder(r.Body).Decode(role); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, map[string]string{"error": "Invalid role data"})
+		return false
+	}
+	if err := role.Validate(); err != nil {
+		renderValidationError(w, r, err)
+		return false
+	}
+	if !models.HasPermissions(organization.Permissions, role.Permissions) {
+		render.Status(r, http.StatusForbidden)
+		render.JSON(w, r, map[string]string{"error": "Roles can't grant permissions you don't have"})
+		return false
+	}
+	return true
+}
+
+// CreateRoleHandler adds a custom role to an organization with
+// {"name": "reviewer", "description": "...", "permissions": ["video:read", "comment:resolve"]}
+func CreateRoleHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, models.PermissionRoleManage)
+		if !ok {
+			return
+		}
+
+		var role models.RoleDefinition
+		if !decodeRole(w, r, organization, &role) {
+			return
+		}
+
+		createdRole, err := db.CreateRole(organization.ID, &role)
+		if err != nil {
+			if errors.Is(err, database.ErrRoleExists) {
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, map[string]string{"error": "The organization already has a role with this name"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to create role"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditCreate, organizationTarget(organization.ID, models.ResourceRole, string(createdRole.Name)),
+			nil, map[string]interface{}{"description": createdRole.Description, "permissions": createdRole.Permissions})
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, createdRole)
+	}
+}
+
+// UpdateRoleHandler replaces the description and permissions of a custom
+// role. Its members get the new permissions right away.
+func UpdateRoleHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, models.PermissionRoleManage)
+		if !ok {
+			return
+		}
+
+		name := models.Role(chi.URLParam(r, "role"))
+		if name.IsBuiltin() {
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, map[string]string{"error": "Built-in roles can't be changed"})
+			return
+		}
+
+		current, err := db.GetRolePermissions(organization.ID, name)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Role not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch role"})
+			return
+		}
+		if !models.HasPermissions(organization.Permissions, current) {
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, map[string]string{"error": "This role grants permissions you don't have"})
+			return
+		}
+
+		role := models.RoleDefinition{Name: name}
+		if !decodeRole(w, r, organization, &role) {
+			return
+		}
+		role.Name = name
+
+		updatedRole, err := db.UpdateRole(organization.ID, &role)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Role not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to update role"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditUpdate, organizationTarget(organization.ID, models.ResourceRole, string(name)),
+			map[string][]models.Permission{"permissions": current}, map[string][]models.Permission{"permissions": updatedRole.Permissions})
+
+		render.JSON(w, r, updatedRole)
+	}
+}
+
+// DeleteRoleHandler deletes a custom role that no member or pending
+// invitation has
+func DeleteRoleHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organization, ok := routeOrganization(w, r, db, models.PermissionRoleManage)
+		if !ok {
+			return
+		}
+
+		name := models.Role(chi.URLParam(r, "role"))
+		if name.IsBuiltin() {
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, map[string]string{"error": "Built-in roles can't be deleted"})
+			return
+		}
+
+		if err := db.DeleteRole(organization.ID, name); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Role not found"})
+				return
+			}
+			if errors.Is(err, database.ErrRoleInUse) {
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, map[string]string{"error": "Change the role of its members and revoke its invitations first"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to delete role"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditDelete, organizationTarget(organization.ID, models.ResourceRole, string(name)), nil, nil)
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, map[string]string{"message": "Role deleted successfully"})
+	}
+}
+
+// GetEffectivePermissionsHandler lists the permissions the authenticated
+// user, or the member in the userId query parameter, has on an organization,
+// channel, video or iteration. Looking up other members takes member:manage.
+func GetEffectivePermissionsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		resourceType, resourceID := chi.URLParam(r, "resourceType"), chi.URLParam(r, "resourceID")
+		if !models.IsResourceType(resourceType) {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Resource type must be organization, channel, video or iteration"})
+			return
+		}
+
+		organizationID, err := db.GetResourceOrganization(resourceType, resourceID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch " + resourceType})
+			return
+		}
+		var permissions []models.Permission
+		if err == nil {
+			_, permissions, err = db.GetMemberPermissions(organizationID, userID)
+		}
+		if err != nil {
+			// Resources of other organizations are reported as missing
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Resource not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch membership"})
+			return
+		}
+
+		principalID := r.URL.Query().Get("userId")
+		if principalID == "" {
+			principalID = userID
+		}
+		if principalID != userID && !models.HasPermission(permissions, models.PermissionMemberManage) {
+			renderForbidden(w, r, models.PermissionMemberManage)
+			return
+		}
+
+		effective := models.EffectivePermissions{
+			UserID:         principalID,
+			ResourceType:   resourceType,
+			ResourceID:     resourceID,
+			OrganizationID: organizationID,
+			Permissions:    []models.Permission{},
+		}
+		role, permissions, err := db.GetMemberPermissions(organizationID, principalID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch membership"})
+			return
+		}
+		if err == nil {
+			effective.Role, effective.Permissions = role, permissions
+		}
+
+		render.JSON(w, r, effective)
+	}
+}
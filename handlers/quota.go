@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// quotaAccount returns the user whose quota pays for a request: the owner of
+// the channel involved, or the authenticated user if there is no channel.
+// It responds with an error and returns false if that fails.
+func quotaAccount(w http.ResponseWriter, r *http.Request, db *database.DB, channel *models.Channel) (*models.User, bool) {
+	user, err := middleware.GetUserFromContext(r)
+	if channel != nil && channel.Owner.ID != "" && (err != nil || channel.Owner.ID != user.ID) {
+		user, err = db.GetUserByID(channel.Owner.ID)
+	}
+	if err != nil {
+		fmt.Println(err)
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch account"})
+		return nil, false
+	}
+	return user, true
+}
+
+// setQuotaHeaders describes the quota of a metric. The reset time is only
+// set for monthly metrics.
+func setQuotaHeaders(w http.ResponseWriter, metric string, limit, used int64, reset time.Time) {
+	if limit == models.Unlimited {
+		return
+	}
+	w.Header().Set("X-Quota-Metric", metric)
+	w.Header().Set("X-Quota-Limit", strconv.FormatInt(limit, 10))
+	w.Header().Set("X-Quota-Remaining", strconv.FormatInt(max(limit-used, 0), 10))
+	if !reset.IsZero() {
+		w.Header().Set("X-Quota-Reset", strconv.FormatInt(reset.Unix(), 10))
+	}
+}
+
+// consumeQuota counts one use of a monthly metric against the quota of an
+// account. Once the month's quota is used up it responds with 429 and
+// returns false. The returned function gives the use back when the call it
+// was for fails.
+func consumeQuota(w http.ResponseWriter, r *http.Request, db *database.DB, account *models.User, metric string) (func(), bool) {
+	limit := models.QuotaFor(account.Tier)[metric]
+	start, end := models.UsagePeriod(time.Now())
+
+	used, err := db.ConsumeUsage(account.ID, metric, start, 1, limit)
+	if err != nil && !errors.Is(err, database.ErrQuotaExceeded) {
+		fmt.Println(err)
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to record usage"})
+		return nil, false
+	}
+	setQuotaHeaders(w, metric, limit, used, end)
+	if err != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(end).Seconds())+1))
+		render.Status(r, http.StatusTooManyRequests)
+		render.JSON(w, r, map[string]string{"error": fmt.Sprintf("The monthly %s quota of the %s tier is used up", metric, account.Tier)})
+		return nil, false
+	}
+
+	refund := func() {
+		if err := db.RefundUsage(account.ID, metric, start, 1); err != nil {
+			fmt.Println(err)
+		}
+	}
+	return refund, true
+}
+
+// consumeAIQuota counts an AI call against the quota of the account paying
+// for channel, see quotaAccount and consumeQuota
+func consumeAIQuota(w http.ResponseWriter, r *http.Request, db *database.DB, channel *models.Channel) (func(), bool) {
+	account, ok := quotaAccount(w, r, db, channel)
+	if !ok {
+		return nil, false
+	}
+	return consumeQuota(w, r, db, account, models.MetricAICalls)
+}
+
+// checkQuota checks that an account can go from current to current+adding of
+// a metric that isn't monthly. It responds with 402 and returns false if
+// that goes over the limit of its tier.
+func checkQuota(w http.ResponseWriter, r *http.Request, account *models.User, metric string, current, adding int64) bool {
+	limit := models.QuotaFor(account.Tier)[metric]
+	if limit == models.Unlimited || current+adding <= limit {
+		return true
+	}
+
+	setQuotaHeaders(w, metric, limit, current, time.Time{})
+	render.Status(r, http.StatusPaymentRequired)
+	render.JSON(w, r, map[string]string{"error": fmt.Sprintf("The %s limit of the %s tier is reached, upgrade to get more", metric, account.Tier)})
+	return false
+}
+
+// checkStorageQuota checks that an account has room to store size more
+// bytes
+func checkStorageQuota(w http.ResponseWriter, r *http.Request, db *database.DB, channel *models.Channel, size int64) bool {
+	account, ok := quotaAccount(w, r, db, channel)
+	if !ok {
+		return false
+	}
+
+	used, err := db.GetStorageBytesByOwner(account.ID)
+	if err != nil {
+		fmt.Println(err)
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to compute storage"})
+		return false
+	}
+	return checkQuota(w, r, account, models.MetricStorageBytes, used, size)
+}
+
+// checkEditorQuota checks that the editors of a video's channel owner stay
+// within their limit if the editors of videoID become editorIDs
+func checkEditorQuota(w http.ResponseWriter, r *http.Request, db *database.DB, channel *models.Channel, videoID string, editorIDs []string) bool {
+	account, ok := quotaAccount(w, r, db, channel)
+	if !ok {
+		return false
+	}
+
+	limit := models.QuotaFor(account.Tier)[models.MetricEditors]
+	if limit == models.Unlimited {
+		return true
+	}
+	count, err := db.CountEditorsByOwner(account.ID, videoID, editorIDs)
+	if err != nil {
+		fmt.Println(err)
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to count editors"})
+		return false
+	}
+	return checkQuota(w, r, account, models.MetricEditors, count, 0)
+}
+
+// addsEditors reports whether next assigns an editor that current doesn't
+func addsEditors(current, next []string) bool {
+	assigned := map[string]bool{}
+	for _, id := range current {
+		assigned[id] = true
+	}
+	for _, id := range next {
+		if !assigned[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUsageHandler reports the usage of the authenticated user against the
+// quotas of their tier
+func GetUsageHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := middleware.GetUserFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		start, end := models.UsagePeriod(time.Now())
+		used, err := db.GetMonthlyUsage(user.ID, start)
+		if err == nil {
+			used[models.MetricStorageBytes], err = db.GetStorageBytesByOwner(user.ID)
+		}
+		if err == nil {
+			used[models.MetricChannels], err = db.CountChannelsByOwner(user.ID)
+		}
+		if err == nil {
+			used[models.MetricEditors], err = db.CountEditorsByOwner(user.ID, "", nil)
+		}
+		if err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch usage"})
+			return
+		}
+
+		usage := models.Usage{Tier: user.Tier, PeriodStart: start, PeriodEnd: end, Metrics: map[string]models.MetricUsage{}}
+		for metric, limit := range models.QuotaFor(user.Tier) {
+			metricUsage := models.MetricUsage{Used: used[metric]}
+			if limit != models.Unlimited {
+				limit := limit
+				metricUsage.Limit = &limit
+			}
+			usage.Metrics[metric] = metricUsage
+		}
+
+		render.JSON(w, r, usage)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+)
+
+// SearchHandler searches the videos, iteration notes and comments visible to the user
+func SearchHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		if query == "" {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Search query is required"})
+			return
+		}
+
+		kind := r.URL.Query().Get("type")
+		if kind != "" && kind != "video" && kind != "iteration" && kind != "comment" {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "type must be video, iteration or comment"})
+			return
+		}
+
+		limit := defaultListLimit
+		if l := r.URL.Query().Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n < 1 || n > maxListLimit {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d", maxListLimit)})
+				return
+			}
+			limit = n
+		}
+
+		results, err := db.Search(userID, query, kind, limit)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to search"})
+			return
+		}
+
+		render.JSON(w, r, results)
+	}
+}
@@ -0,0 +1,680 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// maxShareAccesses is how many entries of an access log are listed at most
+const maxShareAccesses = 500
+
+// GuestSessionHeader carries the session of a guest of a share link. Media
+// players that can't set headers send the GuestSessionCookie set along with
+// the session instead. Sessions are never taken from the URL, which ends up
+// in logs and Referer headers.
+const (
+	GuestSessionHeader = "X-Share-Session"
+	GuestSessionCookie = "share_session"
+)
+
+// guestSessionAudience is the audience of guest sessions
+const guestSessionAudience = "share-guest-session"
+
+// Streams of media are cut after mediaStreamTimeout. Players fetch long
+// media in several range requests, so this only bounds a single one.
+const (
+	mediaDialTimeout     = 10 * time.Second
+	mediaResponseTimeout = 30 * time.Second
+	mediaStreamTimeout   = time.Hour
+)
+
+// errPrivateMediaAddress is returned when media is hosted on an address that
+// isn't public
+var errPrivateMediaAddress = errors.New("media address is not public")
+
+// mediaClient fetches the media of shared iterations for people outside the
+// organization. It only connects to public addresses, so that an iteration
+// URL can't make the server fetch internal services for them, and doesn't
+// go through proxies, which would connect on its behalf.
+var mediaClient = &http.Client{
+	Timeout: mediaStreamTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: mediaDialTimeout,
+			// The address is checked once resolved so that DNS can't point
+			// an allowed name at a private address
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+					return errPrivateMediaAddress
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   mediaDialTimeout,
+		ResponseHeaderTimeout: mediaResponseTimeout,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+	},
+}
+
+// sharedAddressSpace is the range of carrier-grade NAT, which isn't public
+// either
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP reports whether ip can be reached on the internet, as opposed to
+// loopback, private, link-local (like cloud metadata services) and other
+// special addresses
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
+
+// newToken returns a random token for a link, like the ones of share links
+// and invitations. Only its hash is stored.
+func newToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// deriveKey derives the key of a kind of token, named by its audience, from
+// secret, so that tokens of different kinds can't stand in for each other
+func deriveKey(secret, audience string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(audience))
+	return mac.Sum(nil)
+}
+
+// guestSessionKey is the key guest sessions are signed with
+func guestSessionKey(cfg *config.Config) []byte {
+	return deriveKey(cfg.JWTKey, guestSessionAudience)
+}
+
+// hashToken returns the hash a token is stored as
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// routeShareLink fetches the share link of a guest route, responding with 404
+// if it doesn't exist and with 410 if it expired or was revoked
+func routeShareLink(w http.ResponseWriter, r *http.Request, db *database.DB) (*models.ShareLink, bool) {
+	link, err := db.GetShareLinkByToken(hashToken(chi.URLParam(r, "token")))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": "Share link not found"})
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch share link"})
+		return nil, false
+	}
+	if !link.Active {
+		render.Status(r, http.StatusGone)
+		render.JSON(w, r, map[string]string{"error": "This share link has expired or was revoked"})
+		return nil, false
+	}
+	return link, true
+}
+
+// guestSession checks the session of a guest of a share link and returns
+// their display name, responding with 401 if there is no valid session
+func guestSession(w http.ResponseWriter, r *http.Request, cfg *config.Config, link *models.ShareLink) (string, bool) {
+	if displayName, ok := parseGuestSession(r, cfg, link); ok {
+		return displayName, true
+	}
+
+	render.Status(r, http.StatusUnauthorized)
+	render.JSON(w, r, map[string]string{"error": "A guest session is required, start one with POST /share/{token}/session"})
+	return "", false
+}
+
+// parseGuestSession returns the display name of the guest of a request if
+// it has a valid session on a share link
+func parseGuestSession(r *http.Request, cfg *config.Config, link *models.ShareLink) (string, bool) {
+	session := r.Header.Get(GuestSessionHeader)
+	if cookie, err := r.Cookie(GuestSessionCookie); session == "" && err == nil {
+		session = cookie.Value
+	}
+
+	token, err := jwt.Parse(session, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return guestSessionKey(cfg), nil
+	})
+	if err == nil && token.Valid {
+		claims, _ := token.Claims.(jwt.MapClaims)
+		displayName, _ := claims["displayName"].(string)
+		if claims.VerifyAudience(guestSessionAudience, true) && claims["shareLinkID"] == link.ID && displayName != "" {
+			return displayName, true
+		}
+	}
+	return "", false
+}
+
+// logShareAccess adds a request of a guest to the access log of a share link
+func logShareAccess(db *database.DB, r *http.Request, link *models.ShareLink, action, displayName string) {
+	err := db.RecordShareAccess(&models.ShareAccess{
+		ShareLinkID: link.ID,
+		Action:      action,
+		DisplayName: displayName,
+		IP:          middleware.ClientIP(r),
+		UserAgent:   r.UserAgent(),
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// GetSharedIterationHandler describes the iteration of a share link to its
+// guests. Until they start a session it only tells whether the link needs a
+// password and when it expires.
+func GetSharedIterationHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		link, ok := routeShareLink(w, r, db)
+		if !ok {
+			return
+		}
+
+		displayName, ok := parseGuestSession(r, cfg, link)
+		if !ok {
+			render.JSON(w, r, models.SharedIteration{
+				ExpiresAt:        link.ExpiresAt,
+				PasswordRequired: link.PasswordProtected,
+			})
+			return
+		}
+
+		iteration, err := db.GetIterationByID(link.IterationID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch iteration"})
+			return
+		}
+		logShareAccess(db, r, link, models.ShareAccessView, displayName)
+
+		render.JSON(w, r, models.SharedIteration{
+			IterationID:      iteration.ID,
+			VideoTitle:       iteration.Video.Title,
+			Length:           iteration.Length,
+			ExpiresAt:        link.ExpiresAt,
+			PasswordRequired: link.PasswordProtected,
+		})
+	}
+}
+
+// CreateGuestSessionHandler starts a guest session on a share link with
+// {"displayName": "...", "password": "..."}, the password being only needed
+// for protected links. The returned session is sent back in the
+// X-Share-Session header or the session query parameter.
+func CreateGuestSessionHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Sessions are recorded in the access log of the link instead
+		middleware.SkipAudit(r)
+
+		link, ok := routeShareLink(w, r, db)
+		if !ok {
+			return
+		}
+
+		var request models.GuestSessionRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid session data"})
+			return
+		}
+		if err := request.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		if link.PasswordProtected {
+			// Wrong passwords are counted from the access log so that
+			// guessing is slowed down per link and IP address
+			attempts, retryAfter, err := db.CountRecentShareAccesses(link.ID, models.ShareAccessDenied, middleware.ClientIP(r), models.PasswordAttemptWindow)
+			if err != nil {
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, map[string]string{"error": "Failed to check password attempts"})
+				return
+			}
+			if attempts >= models.MaxPasswordAttempts {
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+				render.Status(r, http.StatusTooManyRequests)
+				render.JSON(w, r, map[string]string{"error": "Too many incorrect passwords, try again later"})
+				return
+			}
+
+			if err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(request.Password)); err != nil {
+				logShareAccess(db, r, link, models.ShareAccessDenied, request.DisplayName)
+				render.Status(r, http.StatusUnauthorized)
+				render.JSON(w, r, map[string]string{"error": "Incorrect password"})
+				return
+			}
+		}
+
+		expiresAt := time.Now().Add(models.GuestSessionLifetime)
+		if link.ExpiresAt.Before(expiresAt) {
+			expiresAt = link.ExpiresAt
+		}
+		session, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"shareLinkID": link.ID,
+			"displayName": request.DisplayName,
+			"aud":         guestSessionAudience,
+			"exp":         expiresAt.Unix(),
+		}).SignedString(guestSessionKey(cfg))
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to sign session"})
+			return
+		}
+		logShareAccess(db, r, link, models.ShareAccessSession, request.DisplayName)
+
+		// The cookie is only sent back to the routes of this link. Players
+		// embedded in the web app load the media from another site, which
+		// only gets secure SameSite=None cookies.
+		http.SetCookie(w, &http.Cookie{
+			Name:     GuestSessionCookie,
+			Value:    session,
+			Path:     "/share/" + chi.URLParam(r, "token"),
+			Expires:  expiresAt,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteNoneMode,
+		})
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, map[string]interface{}{"session": session, "expiresAt": expiresAt})
+	}
+}
+
+// StreamSharedMediaHandler streams the media of the iteration of a share link
+func StreamSharedMediaHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		link, ok := routeShareLink(w, r, db)
+		if !ok {
+			return
+		}
+		displayName, ok := guestSession(w, r, cfg, link)
+		if !ok {
+			return
+		}
+
+		iteration, err := db.GetIterationByID(link.IterationID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch iteration"})
+			return
+		}
+
+		streamMedia(w, r, iteration.URL, func() {
+			// Players request many ranges while seeking, only the start is logged
+			if rng := r.Header.Get("Range"); rng == "" || strings.HasPrefix(rng, "bytes=0-") {
+				logShareAccess(db, r, link, models.ShareAccessStream, displayName)
+			}
+		})
+	}
+}
+
+// streamMedia proxies the media at mediaURL, passing Range requests through so
+// players can seek. Only http and https URLs on public addresses are fetched.
+// started is called once the media server has accepted the request.
+func streamMedia(w http.ResponseWriter, r *http.Request, mediaURL string, started func()) {
+	request, err := http.NewRequestWithContext(r.Context(), http.MethodGet, mediaURL, nil)
+	if err != nil || (request.URL.Scheme != "http" && request.URL.Scheme != "https") {
+		render.Status(r, http.StatusBadGateway)
+		render.JSON(w, r, map[string]string{"error": "The iteration has no media to stream"})
+		return
+	}
+	for _, header := range []string{"Range", "If-Range"} {
+		if value := r.Header.Get(header); value != "" {
+			request.Header.Set(header, value)
+		}
+	}
+
+	response, err := mediaClient.Do(request)
+	if err != nil {
+		fmt.Println(err)
+		render.Status(r, http.StatusBadGateway)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch media"})
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusPartialContent && response.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		render.Status(r, http.StatusBadGateway)
+		render.JSON(w, r, map[string]string{"error": fmt.Sprintf("Media server responded with %d", response.StatusCode)})
+		return
+	}
+	started()
+
+	for _, header := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"} {
+		if value := response.Header.Get(header); value != "" {
+			w.Header().Set(header, value)
+		}
+	}
+	w.WriteHeader(response.StatusCode)
+	if _, err := io.Copy(w, response.Body); err != nil && r.Context().Err() == nil {
+		fmt.Println(err)
+	}
+}
+
+// GetSharedCommentsHandler lists the comments guests left through a share
+// link. Comments of members and of other links aren't shown.
+func GetSharedCommentsHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		link, ok := routeShareLink(w, r, db)
+		if !ok {
+			return
+		}
+		if _, ok := guestSession(w, r, cfg, link); !ok {
+			return
+		}
+
+		comments, err := db.GetCommentsByIteration(link.IterationID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch comments"})
+			return
+		}
+
+		shared := []models.Comment{}
+		for _, comment := range comments {
+			if comment.ShareLinkID == link.ID {
+				shared = append(shared, comment)
+			}
+		}
+
+		render.JSON(w, r, shared)
+	}
+}
+
+// CreateSharedCommentHandler lets a guest comment on the iteration of a share
+// link with {"timecode": "1:23", "body": "..."} under their display name
+func CreateSharedCommentHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		link, ok := routeShareLink(w, r, db)
+		if !ok {
+			return
+		}
+		displayName, ok := guestSession(w, r, cfg, link)
+		if !ok {
+			return
+		}
+
+		var comment models.Comment
+		if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid comment data"})
+			return
+		}
+		if err := comment.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+		comment.IterationID = link.IterationID
+		comment.ShareLinkID = link.ID
+		comment.UserID = ""
+		comment.AuthorName = displayName
+
+		createdComment, err := db.CreateComment(&comment)
+		if err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to create comment"})
+			return
+		}
+		logShareAccess(db, r, link, models.ShareAccessComment, displayName)
+
+		middleware.SetAuditActor(r, models.AuditActor{ID: link.ID, Name: displayName, PrincipalType: models.PrincipalGuest})
+		if iteration, err := db.GetIterationByID(link.IterationID); err == nil {
+			middleware.AuditChange(r, models.AuditCreate, iterationTarget(iteration, models.ResourceComment, createdComment.ID), nil, createdComment)
+		} else {
+			fmt.Println(err)
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, createdComment)
+	}
+}
+
+// CreateShareLinkHandler creates a share link for an iteration with an
+// optional {"expiresAt": "...", "password": "..."}. The response has the
+// token of the link, which can't be retrieved later.
+func CreateShareLinkHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iteration, ok := routeIteration(w, r, db, models.PermissionIterationShare)
+		if !ok {
+			return
+		}
+
+		var request models.ShareLinkRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid share link data"})
+			return
+		}
+		lifetime, err := request.Lifetime(time.Now())
+		if err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		var passwordHash string
+		if request.Password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+			if err != nil {
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, map[string]string{"error": "Failed to hash password"})
+				return
+			}
+			passwordHash = string(hash)
+		}
+
+		token, err := newToken()
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to generate token"})
+			return
+		}
+
+		userID, _ := middleware.GetUserIDFromContext(r)
+		link, err := db.CreateShareLink(iteration.ID, userID, hashToken(token), passwordHash, lifetime)
+		if err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to create share link"})
+			return
+		}
+		link.Token = token
+		middleware.AuditChange(r, models.AuditCreate, iterationTarget(iteration, models.ResourceShareLink, link.ID), nil, link)
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, link)
+	}
+}
+
+// GetShareLinksHandler lists the share links of an iteration
+func GetShareLinksHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iteration, ok := routeIteration(w, r, db, models.PermissionIterationShare)
+		if !ok {
+			return
+		}
+
+		links, err := db.GetShareLinksByIteration(iteration.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch share links"})
+			return
+		}
+
+		render.JSON(w, r, links)
+	}
+}
+
+// RevokeShareLinkHandler revokes a share link. Guests lose access right away,
+// and the link stays listed with its access log.
+func RevokeShareLinkHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iteration, ok := routeIteration(w, r, db, models.PermissionIterationShare)
+		if !ok {
+			return
+		}
+
+		linkID := chi.URLParam(r, "linkID")
+		if _, err := db.RevokeShareLink(iteration.ID, linkID); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Share link not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to revoke share link"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditRevoke, iterationTarget(iteration, models.ResourceShareLink, linkID), nil, nil)
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, map[string]string{"message": "Share link revoked successfully"})
+	}
+}
+
+// GetShareAccessesHandler lists the latest entries of the access log of a
+// share link
+func GetShareAccessesHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iteration, ok := routeIteration(w, r, db, models.PermissionIterationShare)
+		if !ok {
+			return
+		}
+
+		accesses, err := db.GetShareAccesses(iteration.ID, chi.URLParam(r, "linkID"), maxShareAccesses)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch share link accesses"})
+			return
+		}
+
+		render.JSON(w, r, accesses)
+	}
+}
+
+// GetCommentsHandler lists the comments of an iteration, from members and
+// guests alike
+func GetCommentsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iteration, ok := routeIteration(w, r, db, models.PermissionVideoRead)
+		if !ok {
+			return
+		}
+
+		comments, err := db.GetCommentsByIteration(iteration.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch comments"})
+			return
+		}
+
+		render.JSON(w, r, comments)
+	}
+}
+
+// CreateCommentHandler comments on an iteration with
+// {"timecode": "1:23", "body": "..."} under the username of the member
+func CreateCommentHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iteration, ok := routeIteration(w, r, db, models.PermissionCommentCreate)
+		if !ok {
+			return
+		}
+
+		var comment models.Comment
+		if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid comment data"})
+			return
+		}
+		if err := comment.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		user, _ := middleware.GetUserFromContext(r)
+		comment.IterationID = iteration.ID
+		comment.ShareLinkID = ""
+		comment.UserID = user.ID
+		comment.AuthorName = user.Username
+
+		createdComment, err := db.CreateComment(&comment)
+		if err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to create comment"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditCreate, iterationTarget(iteration, models.ResourceComment, createdComment.ID), nil, createdComment)
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, createdComment)
+	}
+}
+
+// ResolveCommentHandler marks a comment of an iteration as resolved, or as
+// open again when resolved is false
+func ResolveCommentHandler(db *database.DB, resolved bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iteration, ok := routeIteration(w, r, db, models.PermissionCommentResolve)
+		if !ok {
+			return
+		}
+
+		userID, _ := middleware.GetUserIDFromContext(r)
+		comment, err := db.ResolveComment(iteration.ID, chi.URLParam(r, "commentID"), userID, resolved)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Comment not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to update comment"})
+			return
+		}
+		action := models.AuditResolve
+		if !resolved {
+			action = models.AuditReopen
+		}
+		middleware.AuditChange(r, action, iterationTarget(iteration, models.ResourceComment, comment.ID), nil, nil)
+
+		render.JSON(w, r, comment)
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// testVideo creates a draft video in a channel of a new organization and
+// returns it with the owner of the organization
+func testVideo(t *testing.T, db *database.DB) (*models.User, *models.Video) {
+	t.Helper()
+	owner := testUser(t, db)
+	organization, err := db.CreateOrganization("Studio", owner.ID)
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+	channel, err := db.CreateChannel(&models.Channel{Name: "Channel", OrganizationID: organization.ID})
+	if err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+	video, err := db.CreateVideo(&models.Video{Status: models.Draft, Title: "Secret launch", PrivacyStatus: models.Private, License: models.YouTubeLicense, Channel: *channel})
+	if err != nil {
+		t.Fatalf("CreateVideo: %v", err)
+	}
+	return owner, video
+}
+
+// testShareLink creates an iteration and a share link to it protected by
+// password, and returns the token of the link
+func testShareLink(t *testing.T, db *database.DB, password string) string {
+	t.Helper()
+	owner, video := testVideo(t, db)
+	iteration, err := db.CreateIteration(&models.Iteration{Video: *video, URL: "https://media.example.com/cut.mp4", Length: "1:23", Status: models.Completed})
+	if err != nil {
+		t.Fatalf("CreateIteration: %v", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+	token, err := newToken()
+	if err != nil {
+		t.Fatalf("newToken: %v", err)
+	}
+	if _, err := db.CreateShareLink(iteration.ID, owner.ID, hashToken(token), string(hash), models.DefaultShareLinkLifetime); err != nil {
+		t.Fatalf("CreateShareLink: %v", err)
+	}
+	return token
+}
+
+func TestSharedIterationNeedsSession(t *testing.T) {
+	db := testDB(t)
+	cfg := &config.Config{JWTKey: "secret"}
+	token := testShareLink(t, db, "hunter2")
+
+	describe := GetSharedIterationHandler(db, cfg)
+	response := request("/share/{token}", describe, http.MethodGet, "/share/"+token, "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("describing the link got %d", response.Code)
+	}
+	if body := response.Body.String(); strings.Contains(body, "Secret launch") || strings.Contains(body, "1:23") || !strings.Contains(body, `"passwordRequired":true`) {
+		t.Errorf("described the link without a session as %s", body)
+	}
+
+	response = request("/share/{token}/session", CreateGuestSessionHandler(db, cfg), http.MethodPost, "/share/"+token+"/session", `{"displayName": "Guest", "password": "hunter2"}`)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("starting a session got %d: %s", response.Code, response.Body)
+	}
+	var session struct {
+		Session string `json:"session"`
+	}
+	json.NewDecoder(response.Body).Decode(&session)
+
+	router := chi.NewRouter()
+	router.Get("/share/{token}", describe)
+	withSession := httptest.NewRequest(http.MethodGet, "/share/"+token, nil)
+	withSession.Header.Set(GuestSessionHeader, session.Session)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, withSession)
+	if !strings.Contains(response.Body.String(), "Secret launch") {
+		t.Errorf("described the link with a session as %s", response.Body)
+	}
+}
+
+func TestGuestSessionThrottlesWrongPasswords(t *testing.T) {
+	db := testDB(t)
+	cfg := &config.Config{JWTKey: "secret"}
+	token := testShareLink(t, db, "hunter2")
+	start := CreateGuestSessionHandler(db, cfg)
+
+	for i := 0; i < models.MaxPasswordAttempts; i++ {
+		response := request("/share/{token}/session", start, http.MethodPost, "/share/"+token+"/session", `{"displayName": "Guest", "password": "wrong"}`)
+		if response.Code != http.StatusUnauthorized {
+			t.Fatalf("wrong password %d got %d", i+1, response.Code)
+		}
+	}
+
+	// Even the right password is refused until the attempts age out
+	response := request("/share/{token}/session", start, http.MethodPost, "/share/"+token+"/session", `{"displayName": "Guest", "password": "hunter2"}`)
+	if response.Code != http.StatusTooManyRequests || response.Header().Get("Retry-After") == "" {
+		t.Errorf("got %d with Retry-After %q after too many wrong passwords", response.Code, response.Header().Get("Retry-After"))
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::ffff:10.0.0.1": false,
+		"224.0.0.1":       false,
+	}
+	for address, want := range tests {
+		if got := isPublicIP(net.ParseIP(address)); got != want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
+
+func TestStreamMediaOnlyFetchesPublicURLs(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the internal server was fetched")
+		w.Write([]byte("secret"))
+	}))
+	defer internal.Close()
+
+	for _, mediaURL := range []string{internal.URL + "/latest/meta-data", "file:///etc/passwd", "gopher://localhost:70/"} {
+		recorder := httptest.NewRecorder()
+		streamMedia(recorder, httptest.NewRequest(http.MethodGet, "/share/token/media", nil), mediaURL, func() {
+			t.Errorf("started streaming %s", mediaURL)
+		})
+		if recorder.Code != http.StatusBadGateway || strings.Contains(recorder.Body.String(), "secret") {
+			t.Errorf("streaming %s got %d: %s", mediaURL, recorder.Code, recorder.Body)
+		}
+	}
+}
+
+func TestParseGuestSession(t *testing.T) {
+	cfg := &config.Config{JWTKey: "login-key"}
+	link := &models.ShareLink{ID: "link-1"}
+	claims := jwt.MapClaims{"shareLinkID": link.ID, "displayName": "Guest", "aud": guestSessionAudience, "exp": time.Now().Add(time.Hour).Unix()}
+	sign := func(claims jwt.MapClaims, key []byte) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+		if err != nil {
+			t.Fatalf("SignedString: %v", err)
+		}
+		return token
+	}
+	session := sign(claims, guestSessionKey(cfg))
+	withoutAudience := jwt.MapClaims{"shareLinkID": link.ID, "displayName": "Guest", "exp": claims["exp"]}
+
+	tests := []struct {
+		name    string
+		prepare func(r *http.Request)
+		want    bool
+	}{
+		{"header", func(r *http.Request) { r.Header.Set(GuestSessionHeader, session) }, true},
+		{"cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: GuestSessionCookie, Value: session}) }, true},
+		{"query parameter", func(r *http.Request) { r.URL.RawQuery = "session=" + session }, false},
+		{"signed with the login key", func(r *http.Request) { r.Header.Set(GuestSessionHeader, sign(claims, []byte(cfg.JWTKey))) }, false},
+		{"without audience", func(r *http.Request) { r.Header.Set(GuestSessionHeader, sign(withoutAudience, guestSessionKey(cfg))) }, false},
+		{"approval link", func(r *http.Request) { r.Header.Set(GuestSessionHeader, sign(claims, approvalKey(cfg))) }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/share/token", nil)
+			tt.prepare(r)
+			if displayName, ok := parseGuestSession(r, cfg, link); ok != tt.want || (ok && displayName != "Guest") {
+				t.Errorf("parseGuestSession = %q, %v, want %v", displayName, ok, tt.want)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/share/other", nil)
+	r.Header.Set(GuestSessionHeader, session)
+	if _, ok := parseGuestSession(r, cfg, &models.ShareLink{ID: "link-2"}); ok {
+		t.Error("a session of another link was accepted")
+	}
+}
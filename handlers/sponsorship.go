@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/mail"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// checkSponsorApprovals responds with 409 if a video is sponsored and some
+// approvers haven't approved its latest iteration with its current metadata.
+// Everything that publishes videos must check it first. Today that is only
+// the upload, since videos can't be created or patched as published and
+// there is no scheduled publishing.
+func checkSponsorApprovals(w http.ResponseWriter, r *http.Request, db *database.DB, video *models.Video) bool {
+	if video.Sponsorship == nil {
+		return true
+	}
+
+	approvals, err := db.GetSponsorApprovals(video.ID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch approvals"})
+		return false
+	}
+	if missing := video.MissingApprovals(approvals); len(missing) > 0 {
+		render.Status(r, http.StatusConflict)
+		render.JSON(w, r, map[string]interface{}{
+			"error":            fmt.Sprintf("Sponsored videos need the approval of %s before publishing", strings.Join(missing, ", ")),
+			"missingApprovals": missing,
+		})
+		return false
+	}
+	return true
+}
+
+// approvalAudience is the audience of the tokens of approval links
+const approvalAudience = "sponsor-approval"
+
+// approvalKey is the key approval links are signed with. Without
+// APPROVAL_SIGNING_KEY it is derived from the login key, so that login
+// tokens and approval links can't stand in for each other.
+func approvalKey(cfg *config.Config) []byte {
+	if cfg.ApprovalKey != "" {
+		return []byte(cfg.ApprovalKey)
+	}
+	return deriveKey(cfg.JWTKey, approvalAudience)
+}
+
+// signApproval returns the token of the link that lets an approver decide on
+// an approval. It expires with the approval.
+func signApproval(cfg *config.Config, approval *models.SponsorApproval) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"approvalID": approval.ID,
+		"aud":        approvalAudience,
+		"exp":        approval.ExpiresAt.Unix(),
+	}).SignedString(approvalKey(cfg))
+}
+
+// routeApproval fetches the approval of an approver route from its signed
+// token, responding with 404 if the token is invalid or was replaced and with
+// 410 if it expired
+func routeApproval(w http.ResponseWriter, r *http.Request, db *database.DB, cfg *config.Config) (*models.SponsorApproval, bool) {
+	token, err := jwt.Parse(chi.URLParam(r, "token"), func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return approvalKey(cfg), nil
+	})
+	var validationErr *jwt.ValidationError
+	if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+		render.Status(r, http.StatusGone)
+		render.JSON(w, r, map[string]string{"error": "This approval link has expired"})
+		return nil, false
+	}
+
+	var approvalID string
+	if err == nil && token.Valid {
+		claims, _ := token.Claims.(jwt.MapClaims)
+		if claims.VerifyAudience(approvalAudience, true) {
+			approvalID, _ = claims["approvalID"].(string)
+		}
+	}
+	if approvalID == "" {
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, map[string]string{"error": "Approval not found"})
+		return nil, false
+	}
+
+	approval, err := db.GetSponsorApproval(approvalID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": "Approval not found"})
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch approval"})
+		return nil, false
+	}
+	return approval, true
+}
+
+// GetSponsorshipHandler retrieves the sponsorship of a video
+func GetSponsorshipHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoRead)
+		if !ok {
+			return
+		}
+		if video.Sponsorship == nil {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": "Video is not sponsored"})
+			return
+		}
+
+		setETag(w, video.Version)
+		render.JSON(w, r, video.Sponsorship)
+	}
+}
+
+// PutSponsorshipHandler marks a video as sponsored with
+// {"sponsor": "...", "approverEmails": ["..."]}, or changes who must approve
+// it. Removing approvers takes channel:manage.
+func PutSponsorshipHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ifMatch, ok := routeVideoVersion(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+
+		var sponsorship models.Sponsorship
+		if err := json.NewDecoder(r.Body).Decode(&sponsorship); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid sponsorship data"})
+			return
+		}
+		if err := sponsorship.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		// Removing approvers of a sponsored video would let whoever edits it
+		// replace them with themselves, so it takes the same permission as
+		// removing the sponsorship
+		if video.Sponsorship != nil && slices.ContainsFunc(video.Sponsorship.ApproverEmails, func(email string) bool {
+			return !slices.Contains(sponsorship.ApproverEmails, email)
+		}) && !authorize(w, r, db, video.Channel.OrganizationID, models.PermissionChannelManage, "Video") {
+			return
+		}
+
+		saved, version, err := db.PutSponsorship(video.ID, &sponsorship, video.Version)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Video not found"})
+				return
+			}
+			if errors.Is(err, database.ErrVersionMismatch) {
+				renderVersionConflict(w, r, ifMatch)
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to update sponsorship"})
+			return
+		}
+		action := models.AuditUpdate
+		if video.Sponsorship == nil {
+			action = models.AuditCreate
+		}
+		middleware.AuditChange(r, action, videoTarget(video, models.ResourceSponsorship, video.ID), video.Sponsorship, saved)
+
+		setETag(w, version)
+		render.JSON(w, r, saved)
+	}
+}
+
+// DeleteSponsorshipHandler marks a video as no longer sponsored. Since that
+// lifts the approvals it needs, it takes channel:manage.
+func DeleteSponsorshipHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ifMatch, ok := routeVideoVersion(w, r, db, models.PermissionChannelManage)
+		if !ok {
+			return
+		}
+		if video.Sponsorship == nil {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": "Video is not sponsored"})
+			return
+		}
+
+		version, err := db.DeleteSponsorship(video.ID, video.Version)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Video is not sponsored"})
+				return
+			}
+			if errors.Is(err, database.ErrVersionMismatch) {
+				renderVersionConflict(w, r, ifMatch)
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to delete sponsorship"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditDelete, videoTarget(video, models.ResourceSponsorship, video.ID), video.Sponsorship, nil)
+
+		setETag(w, version)
+		render.JSON(w, r, map[string]string{"message": "Sponsorship deleted successfully"})
+	}
+}
+
+// GetSponsorApprovalsHandler lists the approvals of a video along with the
+// approvers whose approval is still needed to publish it
+func GetSponsorApprovalsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoRead)
+		if !ok {
+			return
+		}
+
+		approvals, err := db.GetSponsorApprovals(video.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch approvals"})
+			return
+		}
+
+		render.JSON(w, r, map[string]interface{}{
+			"approvals":        approvals,
+			"missingApprovals": video.MissingApprovals(approvals),
+		})
+	}
+}
+
+// RequestSponsorApprovalsHandler asks approvers to approve the latest
+// iteration of a sponsored video with its current metadata. The optional
+// body {"approverEmails": ["..."]} picks approvers, the ones whose approval
+// is missing are asked otherwise. Each approver is emailed the link to their
+// approval, which only they get.
+func RequestSponsorApprovalsHandler(db *database.DB, mailer mail.Sender, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+		if video.Sponsorship == nil {
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, map[string]string{"error": "Video is not sponsored"})
+			return
+		}
+		if len(video.Iterations) == 0 {
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, map[string]string{"error": "Video has no iteration to approve"})
+			return
+		}
+
+		var request struct {
+			ApproverEmails []string `json:"approverEmails"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid approval request"})
+			return
+		}
+
+		emails := []string{}
+		if len(request.ApproverEmails) == 0 {
+			approvals, err := db.GetSponsorApprovals(video.ID)
+			if err != nil {
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, map[string]string{"error": "Failed to fetch approvals"})
+				return
+			}
+			emails = video.MissingApprovals(approvals)
+			if len(emails) == 0 {
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, map[string]string{"error": "Every approver has already approved the latest iteration"})
+				return
+			}
+		}
+		for _, email := range request.ApproverEmails {
+			email = strings.ToLower(strings.TrimSpace(email))
+			if !slices.Contains(video.Sponsorship.ApproverEmails, email) {
+				renderValidationError(w, r, &models.ValidationError{Field: "approverEmails", Message: fmt.Sprintf("%s is not an approver of the video", email)})
+				return
+			}
+			if !slices.Contains(emails, email) {
+				emails = append(emails, email)
+			}
+		}
+
+		requester, _ := middleware.GetUserFromContext(r)
+		lastIteration := video.Iterations[len(video.Iterations)-1]
+		approvals, err := db.CreateSponsorApprovals(video.ID, lastIteration.ID, requester.ID, emails, models.NewSponsorMetadata(video))
+		if err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to request approvals"})
+			return
+		}
+		for i := range approvals {
+			middleware.AuditChange(r, models.AuditRequest, videoTarget(video, models.ResourceApproval, approvals[i].ID),
+				nil, map[string]string{"approverEmail": approvals[i].ApproverEmail, "iterationId": approvals[i].IterationID})
+		}
+		for i := range approvals {
+			token, err := signApproval(cfg, &approvals[i])
+			if err != nil {
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, map[string]string{"error": "Failed to sign approval link"})
+				return
+			}
+			err = mailer.Send(r.Context(), &mail.Message{
+				To:      approvals[i].ApproverEmail,
+				Subject: "Approval requested for " + video.Title,
+				Body: fmt.Sprintf("%s asks you to approve %s, sponsored by %s.\n\nReview it at %s/approvals/%s before %s.\n",
+					requester.Username, video.Title, video.Sponsorship.Sponsor, cfg.AppURL, token, approvals[i].ExpiresAt.UTC().Format(time.RFC1123)),
+			})
+			if err != nil {
+				render.Status(r, http.StatusBadGateway)
+				render.JSON(w, r, map[string]string{"error": "Failed to send the approval email to " + approvals[i].ApproverEmail})
+				return
+			}
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, approvals)
+	}
+}
+
+// GetSponsorReviewHandler shows an approver the iteration and metadata they
+// are asked to approve
+func GetSponsorReviewHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		approval, ok := routeApproval(w, r, db, cfg)
+		if !ok {
+			return
+		}
+
+		iteration, err := db.GetIterationByID(approval.IterationID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Iteration not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch iteration"})
+			return
+		}
+		sponsorship, err := db.GetSponsorship(approval.VideoID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch sponsorship"})
+			return
+		}
+
+		review := models.SponsorReview{SponsorApproval: *approval, Length: iteration.Length}
+		if sponsorship != nil {
+			review.Sponsor = sponsorship.Sponsor
+		}
+		render.JSON(w, r, review)
+	}
+}
+
+// StreamSponsorReviewHandler streams the iteration an approver is asked to
+// approve
+func StreamSponsorReviewHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		approval, ok := routeApproval(w, r, db, cfg)
+		if !ok {
+			return
+		}
+
+		iteration, err := db.GetIterationByID(approval.IterationID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Iteration not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch iteration"})
+			return
+		}
+
+		streamMedia(w, r, iteration.URL, func() {})
+	}
+}
+
+// DecideSponsorApprovalHandler records the decision of an approver with an
+// optional {"comment": "..."}. Each approval is decided once, and changes to
+// the video after it need a new one.
+func DecideSponsorApprovalHandler(db *database.DB, cfg *config.Config, status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		approval, ok := routeApproval(w, r, db, cfg)
+		if !ok {
+			return
+		}
+
+		var decision models.ApprovalDecision
+		if err := json.NewDecoder(r.Body).Decode(&decision); err != nil && !errors.Is(err, io.EOF) {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid decision data"})
+			return
+		}
+		if err := decision.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		decided, err := db.DecideSponsorApproval(approval.ID, status, decision.Comment)
+		if err != nil {
+			if errors.Is(err, database.ErrApprovalDecided) {
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, map[string]string{"error": "This approval was already decided"})
+				return
+			}
+			fmt.Println(err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to record decision"})
+			return
+		}
+
+		middleware.SetAuditActor(r, models.AuditActor{ID: approval.ApproverEmail, Name: approval.ApproverEmail, PrincipalType: models.PrincipalApprover})
+		action := models.AuditApprove
+		if status == models.ApprovalRejected {
+			action = models.AuditReject
+		}
+		if video, err := db.GetVideoByID(approval.VideoID); err == nil {
+			middleware.AuditChange(r, action, videoTarget(video, models.ResourceApproval, approval.ID),
+				map[string]string{"status": approval.Status}, map[string]string{"status": decided.Status, "comment": decided.Comment})
+		} else {
+			fmt.Println(err)
+		}
+
+		render.JSON(w, r, decided)
+	}
+}
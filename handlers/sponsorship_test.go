@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// approvalStatus returns the status routeApproval responds with to token
+// when it is rejected before the approval is fetched
+func approvalStatus(t *testing.T, cfg *config.Config, token string) int {
+	t.Helper()
+	router := chi.NewRouter()
+	router.Get("/approvals/{token}", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := routeApproval(w, r, nil, cfg); ok {
+			t.Fatal("routeApproval accepted the token")
+		}
+	})
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/approvals/"+token, nil))
+	return recorder.Code
+}
+
+func TestApprovalLinksAreNotLoginTokens(t *testing.T) {
+	cfg := &config.Config{JWTKey: "login-key"}
+	approval := &models.SponsorApproval{ID: "approval-1", ExpiresAt: time.Now().Add(time.Hour)}
+
+	// Tokens signed with the login key aren't approval links, even with the
+	// claims of one
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"approvalID": approval.ID,
+		"aud":        approvalAudience,
+		"exp":        approval.ExpiresAt.Unix(),
+	}).SignedString([]byte(cfg.JWTKey))
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	if code := approvalStatus(t, cfg, forged); code != http.StatusNotFound {
+		t.Errorf("token signed with the login key got %d", code)
+	}
+
+	// Nor are tokens of the approval key meant for another audience
+	other, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"approvalID": approval.ID,
+		"exp":        approval.ExpiresAt.Unix(),
+	}).SignedString(approvalKey(cfg))
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	if code := approvalStatus(t, cfg, other); code != http.StatusNotFound {
+		t.Errorf("token without the audience got %d", code)
+	}
+
+	// An explicit key replaces the derived one
+	token, err := signApproval(cfg, approval)
+	if err != nil {
+		t.Fatalf("signApproval: %v", err)
+	}
+	if code := approvalStatus(t, &config.Config{JWTKey: cfg.JWTKey, ApprovalKey: "approval-key"}, token); code != http.StatusNotFound {
+		t.Errorf("token of the derived key got %d with an approval key", code)
+	}
+
+	expired, err := signApproval(cfg, &models.SponsorApproval{ID: approval.ID, ExpiresAt: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("signApproval: %v", err)
+	}
+	if code := approvalStatus(t, cfg, expired); code != http.StatusGone {
+		t.Errorf("expired token got %d", code)
+	}
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+	"github.com/FuseWorkflows/fuse-go-server/utils"
+)
+
+// pushActiveThumbnail sets the active thumbnail of a video on YouTube. Videos
+// that haven't been uploaded yet or have no thumbnail are left alone.
+func pushActiveThumbnail(db *database.DB, cfg *config.Config, video *models.Video) error {
+	if video.YouTubeID == "" {
+		return nil
+	}
+
+	thumbnail, data, err := db.GetActiveThumbnailImage(video.ID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return utils.SetYouTubeThumbnail(cfg, video.Channel.API_KEY, video.YouTubeID, data, thumbnail.ContentType)
+}
+
+// GetThumbnailsHandler lists the thumbnail candidates of a video
+func GetThumbnailsHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoRead)
+		if !ok {
+			return
+		}
+
+		thumbnails, err := db.GetThumbnailsByVideo(video.ID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch thumbnails"})
+			return
+		}
+
+		render.JSON(w, r, thumbnails)
+	}
+}
+
+// UploadThumbnailHandler adds a thumbnail candidate to a video from the
+// "file" field of a multipart form
+func UploadThumbnailHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+
+		// Leave room for the multipart framing around the image
+		r.Body = http.MaxBytesReader(w, r.Body, models.MaxThumbnailBytes+64<<10)
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				render.Status(r, http.StatusRequestEntityTooLarge)
+				render.JSON(w, r, map[string]string{"error": "Thumbnail must be at most 2 MB"})
+				return
+			}
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "A thumbnail file is required"})
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Failed to read thumbnail"})
+			return
+		}
+
+		thumbnail, err := models.InspectThumbnail(data)
+		if err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+		if !checkStorageQuota(w, r, db, &video.Channel, int64(len(data))) {
+			return
+		}
+
+		createdThumbnail, err := db.CreateThumbnail(video.ID, thumbnail, data)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to create thumbnail"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditCreate, videoTarget(video, models.ResourceThumbnail, createdThumbnail.ID), nil, createdThumbnail)
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, createdThumbnail)
+	}
+}
+
+// GetThumbnailImageHandler serves the image of a thumbnail candidate
+func GetThumbnailImageHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		videoID := chi.URLParam(r, "videoID")
+		if !authorizeResource(w, r, db, models.ResourceVideo, videoID, models.PermissionVideoRead) {
+			return
+		}
+
+		thumbnail, data, err := db.GetThumbnailImage(videoID, chi.URLParam(r, "thumbnailID"))
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Thumbnail not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch thumbnail"})
+			return
+		}
+
+		w.Header().Set("Content-Type", thumbnail.ContentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	}
+}
+
+// ActivateThumbnailHandler selects the thumbnail used for a video. The change
+// is pushed to YouTube right away if the video has already been uploaded.
+func ActivateThumbnailHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+
+		thumbnail, err := db.ActivateThumbnail(video.ID, chi.URLParam(r, "thumbnailID"))
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Thumbnail not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to activate thumbnail"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditActivate, videoTarget(video, models.ResourceThumbnail, thumbnail.ID), nil, nil)
+
+		if err := pushActiveThumbnail(db, cfg, video); err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusBadGateway)
+			render.JSON(w, r, map[string]string{"error": "Thumbnail was activated but setting it on YouTube failed"})
+			return
+		}
+
+		render.JSON(w, r, thumbnail)
+	}
+}
+
+// DeleteThumbnailHandler deletes a thumbnail candidate
+func DeleteThumbnailHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		video, ok := routeVideo(w, r, db, models.PermissionVideoUpdate)
+		if !ok {
+			return
+		}
+
+		thumbnailID := chi.URLParam(r, "thumbnailID")
+		err := db.DeleteThumbnail(video.ID, thumbnailID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Thumbnail not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to delete thumbnail"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditDelete, videoTarget(video, models.ResourceThumbnail, thumbnailID), nil, nil)
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, map[string]string{"message": "Thumbnail deleted successfully"})
+	}
+}
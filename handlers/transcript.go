@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/ai"
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// routeIteration fetches the iteration of a nested route, responding with an
+// error if it doesn't exist or the user's role doesn't grant permission on it
+func routeIteration(w http.ResponseWriter, r *http.Request, db *database.DB, permission models.Permission) (*models.Iteration, bool) {
+	iteration, err := db.GetIterationByID(chi.URLParam(r, "iterationID"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": "Iteration not found"})
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch iteration"})
+		return nil, false
+	}
+	if !authorize(w, r, db, iteration.Video.Channel.OrganizationID, permission, "Iteration") {
+		return nil, false
+	}
+	return iteration, true
+}
+
+// latestTranscript returns the text of the latest transcript of a video to
+// send to AI providers, or an empty string if there is none
+func latestTranscript(db *database.DB, videoID string) (string, error) {
+	transcript, err := db.GetLatestTranscriptByVideo(videoID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	return transcript.PromptText(), nil
+}
+
+// saveTranscript validates and stores a transcript of an iteration
+func saveTranscript(w http.ResponseWriter, r *http.Request, db *database.DB, iteration *models.Iteration, transcript *models.Transcript) {
+	if err := transcript.Validate(); err != nil {
+		renderValidationError(w, r, err)
+		return
+	}
+	if !checkStorageQuota(w, r, db, &iteration.Video.Channel, int64(len(transcript.Text))) {
+		return
+	}
+
+	storedTranscript, err := db.PutTranscript(transcript)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to save transcript"})
+		return
+	}
+	middleware.AuditChange(r, models.AuditUpdate, iterationTarget(iteration, models.ResourceTranscript, iteration.ID),
+		nil, map[string]string{"language": storedTranscript.Language, "source": storedTranscript.Source})
+
+	render.JSON(w, r, storedTranscript)
+}
+
+// GetTranscriptHandler retrieves the transcript of an iteration
+func GetTranscriptHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iteration, ok := routeIteration(w, r, db, models.PermissionVideoRead)
+		if !ok {
+			return
+		}
+
+		transcript, err := db.GetTranscript(iteration.ID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Transcript not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch transcript"})
+			return
+		}
+
+		render.JSON(w, r, transcript)
+	}
+}
+
+// UploadTranscriptHandler sets the transcript of an iteration from a
+// multipart "file" in SRT, WebVTT or plain text, with optional "format" and
+// "language" fields. The format is guessed from the file when not given.
+func UploadTranscriptHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iteration, ok := routeIteration(w, r, db, models.PermissionIterationUpdate)
+		if !ok {
+			return
+		}
+
+		// Leave room for the multipart framing and the other fields
+		r.Body = http.MaxBytesReader(w, r.Body, models.MaxTranscriptBytes+64<<10)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				render.Status(r, http.StatusRequestEntityTooLarge)
+				render.JSON(w, r, map[string]string{"error": "Transcript file must be at most 1 MB"})
+				return
+			}
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "A transcript file is required"})
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Failed to read transcript file"})
+			return
+		}
+
+		format := strings.ToLower(r.FormValue("format"))
+		if format == "" {
+			switch ext := strings.ToLower(path.Ext(header.Filename)); ext {
+			case ".srt", ".vtt":
+				format = ext[1:]
+			case ".txt":
+				format = "txt"
+			default:
+				format = detectTranscriptFormat(data)
+			}
+		}
+
+		text, err := models.ParseTranscriptFile(data, format)
+		if err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+
+		saveTranscript(w, r, db, iteration, &models.Transcript{
+			IterationID: iteration.ID,
+			Language:    r.FormValue("language"),
+			Source:      models.TranscriptSourceUpload,
+			Text:        text,
+		})
+	}
+}
+
+// detectTranscriptFormat tells caption files from plain text: WebVTT files
+// start with a header and SRT files with a cue number and a timing line
+func detectTranscriptFormat(data []byte) string {
+	lines := strings.SplitN(strings.TrimPrefix(string(data), "\uFEFF"), "\n", 3)
+	switch {
+	case strings.HasPrefix(lines[0], "WEBVTT"):
+		return "vtt"
+	case len(lines) > 1 && strings.Contains(lines[1], "-->"):
+		return "srt"
+	}
+	return "txt"
+}
+
+// TranscribeIterationHandler sets the transcript of an iteration from the
+// transcription provider. The optional body {"language": "en"} hints the
+// spoken language.
+func TranscribeIterationHandler(db *database.DB, providers *ai.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iteration, ok := routeIteration(w, r, db, models.PermissionIterationUpdate)
+		if !ok || !authorize(w, r, db, iteration.Video.Channel.OrganizationID, models.PermissionAIUse, "Iteration") {
+			return
+		}
+
+		var request ai.TranscriptionRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid transcription request"})
+			return
+		}
+		request.MediaURL = iteration.URL
+
+		transcriber, err := providers.Transcriber()
+		if err != nil {
+			renderAIError(w, r, err)
+			return
+		}
+
+		refund, ok := consumeAIQuota(w, r, db, &iteration.Video.Channel)
+		if !ok {
+			return
+		}
+		transcript, err := transcriber.Transcribe(r.Context(), &request)
+		if err != nil {
+			refund()
+			renderAIError(w, r, err)
+			return
+		}
+		if strings.TrimSpace(transcript.Text) == "" {
+			refund()
+			render.Status(r, http.StatusBadGateway)
+			render.JSON(w, r, map[string]string{"error": "The transcription provider returned an empty transcript"})
+			return
+		}
+
+		transcript.IterationID = iteration.ID
+		transcript.Source = transcriber.Name()
+		saveTranscript(w, r, db, iteration, transcript)
+	}
+}
+
+// DeleteTranscriptHandler deletes the transcript of an iteration
+func DeleteTranscriptHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iteration, ok := routeIteration(w, r, db, models.PermissionIterationUpdate)
+		if !ok {
+			return
+		}
+
+		if err := db.DeleteTranscript(iteration.ID); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Transcript not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to delete transcript"})
+			return
+		}
+		middleware.AuditChange(r, models.AuditDelete, iterationTarget(iteration, models.ResourceTranscript, iteration.ID), nil, nil)
+
+		render.JSON(w, r, map[string]string{"message": "Transcript deleted successfully"})
+	}
+}
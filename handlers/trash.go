@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// GetTrashHandler retrieves the channels, videos and iterations deleted by the user
+func GetTrashHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middleware.GetUserIDFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		items, err := db.ListTrash(userID, cfg.TrashRetention)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch trash"})
+			return
+		}
+
+		render.JSON(w, r, items)
+	}
+}
+
+// RestoreChannelHandler restores a deleted channel with its videos and iterations
+func RestoreChannelHandler(db *database.DB) http.HandlerFunc {
+	return restoreHandler("channelID", "Channel", db.RestoreChannel, func(id string) (models.AuditTarget, error) {
+		channel, err := db.GetChannelByID(id)
+		if err != nil {
+			return models.AuditTarget{}, err
+		}
+		return channelTarget(channel), nil
+	})
+}
+
+// RestoreVideoHandler restores a deleted video with its iterations
+func RestoreVideoHandler(db *database.DB) http.HandlerFunc {
+	return restoreHandler("videoID", "Video", db.RestoreVideo, func(id string) (models.AuditTarget, error) {
+		video, err := db.GetVideoByID(id)
+		if err != nil {
+			return models.AuditTarget{}, err
+		}
+		return videoTarget(video, models.ResourceVideo, video.ID), nil
+	})
+}
+
+// RestoreIterationHandler restores a deleted iteration
+func RestoreIterationHandler(db *database.DB) http.HandlerFunc {
+	return restoreHandler("iterationID", "Iteration", db.RestoreIteration, func(id string) (models.AuditTarget, error) {
+		iteration, err := db.GetIterationByID(id)
+		if err != nil {
+			return models.AuditTarget{}, err
+		}
+		return iterationTarget(iteration, models.ResourceIteration, iteration.ID), nil
+	})
+}
+
+// restoreHandler takes the resource identified by the URL parameter out of
+// the user's trash and records it in the audit log with the target of the
+// restored resource
+func restoreHandler(param, resource string, restore func(userID, id string) error, target func(id string) (models.AuditTarget, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, param)
+		if id == "" {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": resource + " ID is required"})
+			return
+		}
+
+		userID, err := middleware.GetUserIDFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+
+		err = restore(userID, id)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": resource + " not found in trash"})
+				return
+			}
+			if errors.Is(err, database.ErrParentDeleted) {
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, map[string]string{"error": "Restore the parent of this " + strings.ToLower(resource) + " first"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to restore " + strings.ToLower(resource)})
+			return
+		}
+
+		if auditTarget, err := target(id); err == nil {
+			middleware.AuditChange(r, models.AuditRestore, auditTarget, nil, nil)
+		} else {
+			fmt.Println(err)
+		}
+
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, map[string]string{"message": resource + " restored successfully"})
+	}
+}
@@ -13,13 +13,19 @@ import (
 	"github.com/FuseWorkflows/fuse-go-server/models"
 )
 
-// GetUserHandler retrieves a list of users
+// GetUserHandler retrieves a page of users
 func GetUserHandler(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		users, err := db.GetUsers()
+		params, err := parseListParams(r)
 		if err != nil {
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, map[string]string{"error": "Failed to fetch users"})
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+
+		users, err := db.ListUsers(params)
+		if err != nil {
+			renderListError(w, r, err, "Failed to fetch users")
 			return
 		}
 
@@ -43,6 +49,7 @@ func CreateUserHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Failed to create user"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditCreate, models.AuditTarget{ResourceType: models.ResourceUser, ResourceID: createdUser.ID}, nil, accountFields(createdUser))
 
 		render.Status(r, http.StatusCreated)
 		render.JSON(w, r, createdUser)
@@ -59,12 +66,48 @@ func UpdateUserHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
+		// Users can only update their own account
+		currentUserID, err := middleware.GetUserIDFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+		if currentUserID != userID {
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, map[string]string{"error": "You are not authorized to update this user"})
+			return
+		}
+
 		var user models.User
 		if err := render.Bind(r, &user); err != nil {
 			render.Status(r, http.StatusBadRequest)
 			render.JSON(w, r, map[string]string{"error": "Invalid user data"})
 			return
 		}
+		if !models.IsAIProvider(user.AIProvider) {
+			renderValidationError(w, r, &models.ValidationError{Field: "aiProvider", Message: "must be http, openai or stub"})
+			return
+		}
+
+		currentUser, err := db.GetUserByID(userID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "User not found"})
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch user"})
+			return
+		}
+
+		// Tiers only change through billing. Sending the current tier back is
+		// accepted so clients can update the user they fetched.
+		if user.Tier != "" && user.Tier != currentUser.Tier {
+			renderValidationError(w, r, &models.ValidationError{Field: "tier", Message: "can only be changed by subscribing through POST /billing/checkout"})
+			return
+		}
 
 		updatedUser, err := db.UpdateUser(userID, &user)
 		if err != nil {
@@ -77,6 +120,8 @@ func UpdateUserHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Failed to update user"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditUpdate, models.AuditTarget{ResourceType: models.ResourceUser, ResourceID: userID},
+			accountFields(currentUser), accountFields(updatedUser))
 
 		render.JSON(w, r, updatedUser)
 	}
@@ -107,23 +152,8 @@ func DeleteUserHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		// Delete all channels owned by the user
-		channels, err := db.GetChannelsByUser(userID)
-		if err != nil {
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, map[string]string{"error": "Failed to fetch channels"})
-			return
-		}
-		for _, channel := range channels {
-			err = db.DeleteChannel(channel.ID)
-			if err != nil {
-				render.Status(r, http.StatusInternalServerError)
-				render.JSON(w, r, map[string]string{"error": "Failed to delete channels"})
-				return
-			}
-		}
-
-		// Delete the user
+		// Delete the user. The channels of their personal organizations,
+		// including the ones in the trash, are removed with their videos.
 		err = db.DeleteUser(userID)
 		if err != nil {
 			fmt.Println("Error deleting user", err)
@@ -132,10 +162,16 @@ func DeleteUserHandler(db *database.DB) http.HandlerFunc {
 				render.JSON(w, r, map[string]string{"error": "User not found"})
 				return
 			}
+			if errors.Is(err, database.ErrLastOwner) {
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, map[string]string{"error": "Transfer the ownership of your organizations before deleting your account"})
+				return
+			}
 			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, map[string]string{"error": "Failed to delete user"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditDelete, models.AuditTarget{ResourceType: models.ResourceUser, ResourceID: userID}, nil, nil)
 
 		render.Status(r, http.StatusOK)
 		render.JSON(w, r, map[string]string{"message": "User deleted successfully"})
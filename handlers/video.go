@@ -16,7 +16,7 @@ import (
 	"github.com/FuseWorkflows/fuse-go-server/utils"
 )
 
-// GetVideoHandler retrieves a list of videos
+// GetVideoHandler retrieves a page of videos
 func GetVideoHandler(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := middleware.GetUserIDFromContext(r)
@@ -26,11 +26,17 @@ func GetVideoHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		videos, err := db.GetVideosByUser(userID)
+		params, err := parseListParams(r)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+
+		videos, err := db.ListVideos(userID, params)
 		if err != nil {
 			fmt.Println(err)
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, map[string]string{"error": "Failed to fetch videos"})
+			renderListError(w, r, err, "Failed to fetch videos")
 			return
 		}
 
@@ -38,24 +44,32 @@ func GetVideoHandler(db *database.DB) http.HandlerFunc {
 	}
 }
 
-// CreateVideoHandler creates a new video
+// errPublishedStatus rejects videos that would become published without
+// being uploaded, which checks video:publish and the sponsor approvals
+var errPublishedStatus = &models.ValidationError{Field: "status", Message: "can only become published by uploading through POST /videos/{videoID}/upload"}
+
+// CreateVideoHandler creates a new video. It can't be created as published.
 func CreateVideoHandler(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, err := middleware.GetUserIDFromContext(r)
-		if err != nil {
-			render.Status(r, http.StatusUnauthorized)
-			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+		video := models.NewVideo()
+		if err := json.NewDecoder(r.Body).Decode(video); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": "Invalid video data"})
 			return
 		}
 
-		var video models.Video
-		if err := json.NewDecoder(r.Body).Decode(&video); err != nil {
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, map[string]string{"error": "Invalid video data"})
+		fields := video.Fields()
+		if err := fields.Validate(); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+		if fields.Status == models.Published {
+			renderValidationError(w, r, errPublishedStatus)
 			return
 		}
+		video.DefaultLanguage = fields.DefaultLanguage
 
-		// Ensure the user owns the channel
+		// Ensure the user can work on the channel
 		channel, err := db.GetChannelByID(video.Channel.ID)
 		fmt.Println("channel id" + video.Channel.ID)
 		if err != nil {
@@ -64,19 +78,21 @@ func CreateVideoHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Channel not found"})
 			return
 		}
-		if channel.Owner.ID != userID {
-			render.Status(r, http.StatusForbidden)
-			render.JSON(w, r, map[string]string{"error": "You are not authorized to create videos in this channel"})
+		if !authorize(w, r, db, channel.OrganizationID, models.PermissionVideoCreate, "Channel") {
+			return
+		}
+		if len(fields.EditorIDs) > 0 && !checkEditorQuota(w, r, db, channel, "", fields.EditorIDs) {
 			return
 		}
 
-		createdVideo, err := db.CreateVideo(&video)
+		createdVideo, err := db.CreateVideo(video)
 		if err != nil {
 			fmt.Println(err)
 			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, map[string]string{"error": "Failed to create video"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditCreate, videoTarget(createdVideo, models.ResourceVideo, createdVideo.ID), nil, createdVideo.Fields())
 
 		render.Status(r, http.StatusCreated)
 		render.JSON(w, r, createdVideo)
@@ -104,12 +120,20 @@ func GetVideoByIDHandler(db *database.DB) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Failed to fetch video"})
 			return
 		}
+		if !authorize(w, r, db, video.Channel.OrganizationID, models.PermissionVideoRead, "Video") {
+			return
+		}
 
+		if notModified(w, r, video.Version) {
+			return
+		}
+
+		setETag(w, video.Version)
 		render.JSON(w, r, video)
 	}
 }
 
-// UpdateVideoHandler updates a video by ID
+// UpdateVideoHandler applies a JSON merge patch or JSON patch to a video by ID
 func UpdateVideoHandler(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		videoID := chi.URLParam(r, "videoID")
@@ -119,14 +143,14 @@ func UpdateVideoHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		var video models.Video
-		if err := json.NewDecoder(r.Body).Decode(&video); err != nil {
+		version, err := ifMatchVersion(r)
+		if err != nil {
 			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, map[string]string{"error": "Invalid video data"})
+			render.JSON(w, r, map[string]string{"error": err.Error()})
 			return
 		}
 
-		updatedVideo, err := db.UpdateVideo(videoID, &video)
+		video, err := db.GetVideoByID(videoID)
 		if err != nil {
 			if errors.Is(err, database.ErrNotFound) {
 				render.Status(r, http.StatusNotFound)
@@ -134,10 +158,60 @@ func UpdateVideoHandler(db *database.DB) http.HandlerFunc {
 				return
 			}
 			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"error": "Failed to fetch video"})
+			return
+		}
+		if version != 0 && version != video.Version {
+			renderPreconditionFailed(w, r)
+			return
+		}
+		if !authorize(w, r, db, video.Channel.OrganizationID, models.PermissionVideoUpdate, "Video") {
+			return
+		}
+
+		fields, err := decodePatch(r, video.Fields())
+		if err != nil {
+			renderPatchError(w, r, err)
+			return
+		}
+		// Sending the current status back is accepted so clients can patch
+		// the video they fetched
+		if fields.Status != video.Status && (fields.Status == models.Published || video.Status == models.Published) {
+			renderValidationError(w, r, errPublishedStatus)
+			return
+		}
+		if err := models.ValidateDescriptionWithChapters(fields.Description, video.Chapters); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+		if addsEditors(video.Fields().EditorIDs, fields.EditorIDs) && !checkEditorQuota(w, r, db, &video.Channel, video.ID, fields.EditorIDs) {
+			return
+		}
+
+		updatedVideo, err := db.UpdateVideo(videoID, &fields, video.Version)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, map[string]string{"error": "Video not found"})
+				return
+			}
+			if errors.Is(err, database.ErrVersionMismatch) {
+				renderVersionConflict(w, r, version)
+				return
+			}
+			var validationErr *models.ValidationError
+			if errors.As(err, &validationErr) {
+				renderPatchError(w, r, err)
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, map[string]string{"error": "Failed to update video"})
 			return
 		}
 
+		middleware.AuditChange(r, models.AuditUpdate, videoTarget(video, models.ResourceVideo, video.ID), video.Fields(), updatedVideo.Fields())
+
+		setETag(w, updatedVideo.Version)
 		render.JSON(w, r, updatedVideo)
 	}
 }
@@ -152,23 +226,87 @@ func DeleteVideoHandler(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		err := db.DeleteVideo(videoID)
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+		video, ok := routeVideo(w, r, db, models.PermissionVideoDelete)
+		if !ok {
+			return
+		}
+
+		err = db.DeleteVideo(videoID, version)
 		if err != nil {
 			if errors.Is(err, database.ErrNotFound) {
 				render.Status(r, http.StatusNotFound)
 				render.JSON(w, r, map[string]string{"error": "Video not found"})
 				return
 			}
+			if errors.Is(err, database.ErrVersionMismatch) {
+				renderPreconditionFailed(w, r)
+				return
+			}
 			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, map[string]string{"error": "Failed to delete video"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditDelete, videoTarget(video, models.ResourceVideo, video.ID), video.Fields(), nil)
 
 		render.Status(r, http.StatusOK)
 		render.JSON(w, r, map[string]string{"message": "Video deleted successfully"})
 	}
 }
 
+// routeVideo fetches the video of a nested video route, responding with an
+// error if it doesn't exist or the user's role doesn't grant permission on it
+func routeVideo(w http.ResponseWriter, r *http.Request, db *database.DB, permission models.Permission) (*models.Video, bool) {
+	video, err := db.GetVideoByID(chi.URLParam(r, "videoID"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, map[string]string{"error": "Video not found"})
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]string{"error": "Failed to fetch video"})
+		return nil, false
+	}
+	if !authorize(w, r, db, video.Channel.OrganizationID, permission, "Video") {
+		return nil, false
+	}
+	return video, true
+}
+
+// routeVideoVersion fetches the video of a nested video route and checks it
+// against the If-Match header of the request
+func routeVideoVersion(w http.ResponseWriter, r *http.Request, db *database.DB, permission models.Permission) (*models.Video, int, bool) {
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, map[string]string{"error": err.Error()})
+		return nil, 0, false
+	}
+
+	video, ok := routeVideo(w, r, db, permission)
+	if !ok {
+		return nil, 0, false
+	}
+	if version != 0 && version != video.Version {
+		renderPreconditionFailed(w, r)
+		return nil, 0, false
+	}
+	return video, version, true
+}
+
+// GetVideoCategoriesHandler lists the YouTube categories videos can be assigned to
+func GetVideoCategoriesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, models.VideoCategories)
+	}
+}
+
 // UploadVideoHandler uploads a video to YouTube
 func UploadVideoHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -190,50 +328,80 @@ func UploadVideoHandler(db *database.DB, cfg *config.Config) http.HandlerFunc {
 			render.JSON(w, r, map[string]string{"error": "Failed to fetch video"})
 			return
 		}
+		if !authorize(w, r, db, video.Channel.OrganizationID, models.PermissionVideoPublish, "Video") {
+			return
+		}
+
+		if len(video.Iterations) == 0 {
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, map[string]string{"error": "Video has no iteration to upload"})
+			return
+		}
+
+		// The chapters may no longer fit the latest iteration or the description
+		if err := validateVideoChapters(video, video.Chapters); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+		if err := validateVideoLocalizations(video); err != nil {
+			renderValidationError(w, r, err)
+			return
+		}
+		if !checkSponsorApprovals(w, r, db, video) {
+			return
+		}
 
 		// Get the last iteration
 		lastIteration := video.Iterations[len(video.Iterations)-1]
 
+		account, ok := quotaAccount(w, r, db, &video.Channel)
+		if !ok {
+			return
+		}
+		refund, ok := consumeQuota(w, r, db, account, models.MetricUploads)
+		if !ok {
+			return
+		}
+
 		// Upload the video to YouTube
-		err = utils.UploadVideoToYouTube(lastIteration.URL, video.Channel.API_KEY, video)
+		youtubeID, err := utils.UploadVideoToYouTube(cfg, lastIteration.URL, video.Channel.API_KEY, video)
 		if err != nil {
-			render.Status(r, http.StatusInternalServerError)
+			refund()
+			fmt.Println(err)
+			render.Status(r, http.StatusBadGateway)
 			render.JSON(w, r, map[string]string{"error": "Failed to upload video to YouTube"})
 			return
 		}
 
 		// Update video status to "published"
-		video.Status = models.Published
-		updatedVideo, err := db.UpdateVideo(videoID, video)
+		updatedVideo, err := db.MarkVideoPublished(videoID, youtubeID)
 		if err != nil {
 			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, map[string]string{"error": "Failed to update video status"})
 			return
 		}
+		middleware.AuditChange(r, models.AuditPublish, videoTarget(video, models.ResourceVideo, video.ID),
+			map[string]interface{}{"status": video.Status, "youtubeId": video.YouTubeID, "iterationId": ""},
+			map[string]interface{}{"status": updatedVideo.Status, "youtubeId": updatedVideo.YouTubeID, "iterationId": lastIteration.ID})
 
-		render.Status(r, http.StatusOK)
-		render.JSON(w, r, updatedVideo)
-	}
-}
-
-// GetAISuggestionsHandler retrieves AI suggestions for video metadata
-func GetAISuggestionsHandler(cfg *config.Config) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var video models.Video
-		if err := json.NewDecoder(r.Body).Decode(&video); err != nil {
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, map[string]string{"error": "Invalid video data"})
+		// Push the active thumbnail now that YouTube knows the video
+		if err := pushActiveThumbnail(db, cfg, updatedVideo); err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusBadGateway)
+			render.JSON(w, r, map[string]string{"error": "Video was uploaded but setting its thumbnail failed"})
 			return
 		}
 
-		aiSuggestions, err := utils.GetAISuggestions(&video, cfg)
-		if err != nil {
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, map[string]string{"error": "Failed to get AI suggestions"})
+		if err := publishCaptions(db, cfg, updatedVideo); err != nil {
+			fmt.Println(err)
+			render.Status(r, http.StatusBadGateway)
+			render.JSON(w, r, map[string]string{"error": "Video was uploaded but uploading its captions failed"})
 			return
 		}
 
-		render.JSON(w, r, aiSuggestions)
+		setETag(w, updatedVideo.Version)
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, updatedVideo)
 	}
 }
 
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+func TestUpdateVideoCantPublish(t *testing.T) {
+	db := testDB(t)
+	owner, video := testVideo(t, db)
+	update := as(owner, UpdateVideoHandler(db))
+
+	response := request("/videos/{videoID}", update, http.MethodPatch, "/videos/"+video.ID, `{"status": "published"}`)
+	if response.Code != http.StatusUnprocessableEntity {
+		t.Errorf("publishing through a patch got %d", response.Code)
+	}
+	if got, err := db.GetVideoByID(video.ID); err != nil || got.Status != models.Draft {
+		t.Errorf("the video is %+v, %v", got, err)
+	}
+
+	// Other statuses and the current one can still be sent
+	response = request("/videos/{videoID}", update, http.MethodPatch, "/videos/"+video.ID, `{"status": "pending", "title": "Launch"}`)
+	if response.Code != http.StatusOK {
+		t.Errorf("patching the status to pending got %d: %s", response.Code, response.Body)
+	}
+}
+
+func TestCreateVideoCantPublish(t *testing.T) {
+	db := testDB(t)
+	owner, video := testVideo(t, db)
+	create := as(owner, CreateVideoHandler(db))
+
+	body := `{"title": "Launch", "status": "published", "channel": {"id": "` + video.Channel.ID + `"}}`
+	response := request("/videos", create, http.MethodPost, "/videos", body)
+	if response.Code != http.StatusUnprocessableEntity {
+		t.Errorf("creating a published video got %d: %s", response.Code, response.Body)
+	}
+	videos, err := db.GetVideosByChannel(video.Channel.ID)
+	if err != nil {
+		t.Fatalf("GetVideosByChannel: %v", err)
+	}
+	for _, created := range videos {
+		if created.Status == models.Published {
+			t.Errorf("video %s was created as published", created.ID)
+		}
+	}
+
+	body = `{"title": "Launch", "status": "pending", "channel": {"id": "` + video.Channel.ID + `"}}`
+	if response := request("/videos", create, http.MethodPost, "/videos", body); response.Code != http.StatusCreated {
+		t.Errorf("creating a pending video got %d: %s", response.Code, response.Body)
+	}
+}
@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// Every runs fn once right away and then at every interval until ctx is
+// canceled. Errors are logged and don't stop the job.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil {
+			log.Printf("Job %s failed: %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// audit records in the audit log that a job did action on count resources
+// of a type
+func audit(ctx context.Context, db *database.DB, job, action, resourceType string, count int64) error {
+	return db.RecordAuditEntries(ctx, []models.AuditEntry{{
+		AuditActor:  models.AuditActor{Name: job, PrincipalType: models.PrincipalSystem},
+		Action:      action,
+		AuditTarget: models.AuditTarget{ResourceType: resourceType},
+		Changes:     map[string]models.AuditChange{"count": {After: count}},
+	}})
+}
@@ -0,0 +1,26 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// PurgeTrash returns a job that permanently deletes items that have been in
+// the trash for longer than the retention period
+func PurgeTrash(db *database.DB, retention time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := db.PurgeTrash(ctx, retention)
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("Purged %d items from the trash", purged)
+			return audit(ctx, db, "purge-trash", models.AuditPurge, models.ResourceTrash, purged)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+// ExpireTrials returns a job that warns users and editors whose trial ends
+// within the warning period and moves those whose trial has ended to the
+// free tier
+func ExpireTrials(db *database.DB, warning time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		warned, err := db.WarnEndingTrials(ctx, warning)
+		if err != nil {
+			return err
+		}
+		if warned > 0 {
+			log.Printf("Warned %d accounts of the end of their trial", warned)
+		}
+
+		expired, err := db.ExpireTrials(ctx)
+		if err != nil {
+			return err
+		}
+		if expired > 0 {
+			log.Printf("Moved %d accounts with an ended trial to the free tier", expired)
+			return audit(ctx, db, "expire-trials", models.AuditExpire, models.ResourceTrial, expired)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,102 @@
+// Package mail sends the emails of the server, like invitations. Without an
+// SMTP server they are kept in an outbox that logs them, which is meant for
+// development.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+
+	"github.com/FuseWorkflows/fuse-go-server/config"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers emails
+type Sender interface {
+	Send(ctx context.Context, message *Message) error
+}
+
+// NewSender returns the SMTP sender of the configuration, or an outbox if
+// no SMTP server is configured
+func NewSender(cfg *config.Config) Sender {
+	if cfg.SMTPAddr == "" {
+		return NewOutbox(true)
+	}
+	return &SMTPSender{addr: cfg.SMTPAddr, username: cfg.SMTPUsername, password: cfg.SMTPPassword, from: cfg.MailFrom}
+}
+
+// SMTPSender sends emails through an SMTP server
+type SMTPSender struct {
+	addr     string
+	username string
+	password string
+	from     string
+}
+
+// Send sends a message, authenticating when the sender has credentials
+func (s *SMTPSender) Send(ctx context.Context, message *Message) error {
+	if strings.ContainsAny(message.To+message.Subject, "\r\n") {
+		return fmt.Errorf("invalid email header")
+	}
+
+	var auth smtp.Auth
+	if s.username != "" {
+		host, _, err := net.SplitHostPort(s.addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address: %w", err)
+		}
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+
+	data := "From: " + s.from + "\r\n" +
+		"To: " + message.To + "\r\n" +
+		"Subject: " + message.Subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
+		strings.ReplaceAll(message.Body, "\n", "\r\n")
+	if err := smtp.SendMail(s.addr, auth, s.from, []string{message.To}, []byte(data)); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+	return nil
+}
+
+// Outbox keeps the messages it is given instead of sending them
+type Outbox struct {
+	mu       sync.Mutex
+	messages []Message
+	logged   bool
+}
+
+// NewOutbox returns an empty outbox that logs the messages it gets if
+// logged is set
+func NewOutbox(logged bool) *Outbox {
+	return &Outbox{logged: logged}
+}
+
+// Send adds a message to the outbox
+func (o *Outbox) Send(ctx context.Context, message *Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, *message)
+	if o.logged {
+		log.Printf("mail: no SMTP server configured, email to %s: %s\n%s", message.To, message.Subject, message.Body)
+	}
+	return nil
+}
+
+// Messages returns the messages of the outbox
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Message(nil), o.messages...)
+}
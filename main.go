@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -12,6 +15,7 @@ import (
 
 	"github.com/FuseWorkflows/fuse-go-server/config"
 	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/jobs"
 	customMiddleware "github.com/FuseWorkflows/fuse-go-server/middleware"
 	"github.com/FuseWorkflows/fuse-go-server/routes"
 )
@@ -30,12 +34,33 @@ func main() {
 	}
 	defer db.Close()
 
+	// Run the migrate subcommand instead of the server if requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatal("Error running migrations: ", err)
+		}
+		return
+	}
+
 	// Initialize config
 	cfg, err := config.NewConfig()
 	if err != nil {
 		log.Fatal("Error initializing config:", err)
 	}
 
+	// Apply pending migrations
+	if cfg.AutoMigrate {
+		if err := db.MigrateUp(context.Background()); err != nil {
+			log.Fatal("Error applying migrations: ", err)
+		}
+	}
+
+	// Start background jobs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go jobs.Every(ctx, "purge-trash", time.Hour, jobs.PurgeTrash(db, cfg.TrashRetention))
+	go jobs.Every(ctx, "expire-trials", 24*time.Hour, jobs.ExpireTrials(db, cfg.TrialWarning))
+
 	// Initialize router
 	r := chi.NewRouter()
 
@@ -48,16 +73,19 @@ func main() {
 	// CORS middleware
 	corsCfg := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"}, // Replace with allowed origins
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"*"}, // Replace with allowed headers
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "ETag", "Retry-After", "X-Quota-Metric", "X-Quota-Limit", "X-Quota-Remaining", "X-Quota-Reset"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	})
 	r.Use(corsCfg.Handler)
 
 	// Authentication middleware
-	r.Use(customMiddleware.Auth(db, cfg.JWTKey, []string{"/auth/signup", "/auth/login"}))
+	r.Use(customMiddleware.Auth(db, cfg.JWTKey, []string{"/auth/signup", "/auth/login", "/billing/webhook", "/share/", "/approvals/"}))
+
+	// Audit log of mutating requests
+	r.Use(customMiddleware.Audit(db))
 
 	// Routes
 	routes.InitRoutes(r, db, cfg)
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+)
+
+type auditContextKey struct{}
+
+// auditRecord collects what a request did for the audit log
+type auditRecord struct {
+	actor   *models.AuditActor
+	entries []models.AuditEntry
+	skip    bool
+}
+
+// Audit records the changes the handlers of mutating requests report through
+// AuditChange in the audit log, even if the request fails afterwards.
+// Successful requests whose handler reported nothing are logged with their
+// method and route. It must run after Auth, RequestID and RealIP.
+func Audit(db *database.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			record := &auditRecord{}
+			r = r.WithContext(context.WithValue(r.Context(), auditContextKey{}, record))
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			if record.skip || len(record.entries) == 0 && ww.Status() >= http.StatusBadRequest {
+				return
+			}
+
+			actor := record.actor
+			if user, err := GetUserFromContext(r); err == nil {
+				actor = &models.AuditActor{ID: user.ID, Name: user.Username, PrincipalType: models.PrincipalUser}
+			}
+			if actor == nil {
+				actor = &models.AuditActor{PrincipalType: models.PrincipalSystem}
+			}
+
+			entries := record.entries
+			if len(entries) == 0 {
+				entries = []models.AuditEntry{{Action: r.Method + " " + chi.RouteContext(r.Context()).RoutePattern(), Changes: map[string]models.AuditChange{}}}
+			}
+			for i := range entries {
+				entries[i].AuditActor = *actor
+				entries[i].RequestID = chimiddleware.GetReqID(r.Context())
+				entries[i].IP = ClientIP(r)
+			}
+
+			if err := db.RecordAuditEntries(context.Background(), entries); err != nil {
+				fmt.Println(err)
+			}
+		})
+	}
+}
+
+// AuditChange reports that a request did action on a resource. before and
+// after are the resource before and after the action, nil when it didn't
+// exist, and only the fields that differ are recorded.
+func AuditChange(r *http.Request, action string, target models.AuditTarget, before, after interface{}) {
+	record, ok := r.Context().Value(auditContextKey{}).(*auditRecord)
+	if !ok {
+		return
+	}
+	record.entries = append(record.entries, models.AuditEntry{
+		Action:      action,
+		AuditTarget: target,
+		Changes:     models.AuditDiff(before, after),
+	})
+}
+
+// SetAuditActor sets who made a request that isn't made by a user
+func SetAuditActor(r *http.Request, actor models.AuditActor) {
+	if record, ok := r.Context().Value(auditContextKey{}).(*auditRecord); ok {
+		record.actor = &actor
+	}
+}
+
+// SkipAudit keeps a request that changes nothing worth auditing out of the
+// audit log
+func SkipAudit(r *http.Request) {
+	if record, ok := r.Context().Value(auditContextKey{}).(*auditRecord); ok {
+		record.skip = true
+	}
+}
+
+// ClientIP returns the IP address of the client of a request. RealIP has
+// replaced the address with the client's when the request was proxied.
+func ClientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
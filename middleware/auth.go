@@ -90,10 +90,11 @@ func Auth(db *database.DB, jwtKey string, excludedRoutes []string) func(http.Han
 	}
 }
 
-// Helper function to check if a route is excluded
+// Helper function to check if a route is excluded. Routes ending with a slash
+// exclude every path under them.
 func isExcludedRoute(path string, excludedRoutes []string) bool {
 	for _, route := range excludedRoutes {
-		if path == route {
+		if path == route || strings.HasSuffix(route, "/") && strings.HasPrefix(path, route) {
 			return true
 		}
 	}
@@ -108,3 +109,31 @@ func GetUserIDFromContext(r *http.Request) (string, error) {
 	}
 	return user.ID, nil
 }
+
+// GetUserFromContext retrieves the authenticated user from the request context
+func GetUserFromContext(r *http.Request) (*models.User, error) {
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok {
+		return nil, errors.New("user not found in context")
+	}
+	return user, nil
+}
+
+// RequireAdmin only lets admin users through. It must run after Auth.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := GetUserFromContext(r)
+		if err != nil {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]string{"error": "User not authenticated"})
+			return
+		}
+		if !user.Admin {
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, map[string]string{"error": "Only admins can do this"})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
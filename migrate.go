@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/FuseWorkflows/fuse-go-server/database"
+)
+
+const migrateUsage = "usage: fuse migrate up|down [N]|status|to N|force N"
+
+// runMigrate implements the migrate subcommand
+func runMigrate(db *database.DB, args []string) error {
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		if err := db.MigrateUp(ctx); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+			steps = n
+		}
+		if err := db.MigrateDown(ctx, steps); err != nil {
+			return err
+		}
+	case "to", "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+		if args[0] == "to" {
+			err = db.MigrateTo(ctx, version)
+		} else {
+			err = db.ForceMigrationVersion(ctx, version)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return printMigrationStatus(ctx, db)
+}
+
+// printMigrationStatus prints the schema version and every known migration
+func printMigrationStatus(ctx context.Context, db *database.DB) error {
+	status, err := db.GetMigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	state := "clean"
+	if status.Dirty {
+		state = "dirty"
+	}
+	fmt.Printf("Schema version %d of %d (%s)\n", status.Version, status.Latest(), state)
+
+	for _, migration := range status.Migrations {
+		mark := "pending"
+		if migration.Version <= status.Version {
+			mark = "applied"
+		}
+		fmt.Printf("  %-8s %d_%s\n", mark, migration.Version, migration.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ActivityEvent is an entry of the audit log with what is needed to describe
+// it in an activity feed
+type ActivityEvent struct {
+	AuditEntry
+	// VideoTitle is the current title of the video of the entry
+	VideoTitle string
+	// IterationNumber is the position of the iteration of the entry among
+	// the iterations of its video, starting at 1, or 0 if it has none
+	IterationNumber int
+}
+
+// Activity is an event of an activity feed described in a sentence
+type Activity struct {
+	ID int64 `json:"id"`
+	AuditActor
+	Action       string    `json:"action"`
+	ResourceType string    `json:"resourceType"`
+	ResourceID   string    `json:"resourceId"`
+	VideoID      string    `json:"videoId,omitempty"`
+	VideoTitle   string    `json:"videoTitle,omitempty"`
+	Text         string    `json:"text"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// activityFieldNames are the names of the fields of videos in activity feeds
+// that aren't their words spelled out
+var activityFieldNames = map[string]string{
+	"privacyStatus": "visibility",
+	"editorIds":     "editors",
+	"madeForKids":   "made for kids setting",
+}
+
+// NewActivity describes an event for the user with viewerID, who is "You"
+// when they performed it
+func NewActivity(event *ActivityEvent, viewerID string) Activity {
+	return Activity{
+		ID:           event.ID,
+		AuditActor:   event.AuditActor,
+		Action:       event.Action,
+		ResourceType: event.ResourceType,
+		ResourceID:   event.ResourceID,
+		VideoID:      event.VideoID,
+		VideoTitle:   event.VideoTitle,
+		Text:         event.actor(viewerID) + " " + event.predicate(),
+		CreatedAt:    event.CreatedAt,
+	}
+}
+
+// actor is the subject of the sentence describing the event
+func (e *ActivityEvent) actor(viewerID string) string {
+	switch {
+	case e.PrincipalType == PrincipalUser && e.AuditActor.ID != "" && e.AuditActor.ID == viewerID:
+		return "You"
+	case e.Name != "" && e.PrincipalType != PrincipalWebhook && e.PrincipalType != PrincipalSystem:
+		return e.Name
+	case e.PrincipalType == PrincipalGuest:
+		return "A guest"
+	case e.PrincipalType == PrincipalApprover:
+		return "An approver"
+	case e.PrincipalType == PrincipalUser:
+		return "A deleted user"
+	default:
+		return "Fuse"
+	}
+}
+
+// iteration names the iteration of the event like "v4"
+func (e *ActivityEvent) iteration() string {
+	if e.IterationNumber == 0 {
+		return "an iteration"
+	}
+	return fmt.Sprintf("v%d", e.IterationNumber)
+}
+
+// after returns the value of a field after the event as a string
+func (e *ActivityEvent) after(field string) string {
+	if value, ok := e.Changes[field].After.(string); ok {
+		return value
+	}
+	return ""
+}
+
+// fields lists the changed fields of the event in words
+func (e *ActivityEvent) fields() string {
+	var names []string
+	for field := range e.Changes {
+		name, ok := activityFieldNames[field]
+		if !ok {
+			name = spellField(field)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	switch len(names) {
+	case 0:
+		return "the video"
+	case 1:
+		return "the " + names[0]
+	default:
+		return "the " + strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+	}
+}
+
+// predicate is the rest of the sentence describing the event
+func (e *ActivityEvent) predicate() string {
+	switch e.ResourceType + " " + e.Action {
+	case ResourceChannel + " " + AuditCreate:
+		return "created the channel"
+	case ResourceChannel + " " + AuditUpdate:
+		return "updated the channel settings"
+	case ResourceChannel + " " + AuditDelete:
+		return "moved the channel to the trash"
+	case ResourceChannel + " " + AuditRestore:
+		return "restored the channel"
+	case ResourceChannel + " " + AuditTransfer:
+		return "moved the channel to another organization"
+
+	case ResourceVideo + " " + AuditCreate:
+		return "created the video"
+	case ResourceVideo + " " + AuditUpdate:
+		return "edited " + e.fields()
+	case ResourceVideo + " " + AuditDelete:
+		return "moved the video to the trash"
+	case ResourceVideo + " " + AuditRestore:
+		return "restored the video"
+	case ResourceVideo + " " + AuditPublish:
+		if e.IterationNumber == 0 {
+			return "uploaded the video to YouTube"
+		}
+		return "uploaded " + e.iteration()
+
+	case ResourceIteration + " " + AuditCreate:
+		return "added " + e.iteration()
+	case ResourceIteration + " " + AuditUpdate:
+		if status := e.after("status"); status != "" {
+			return fmt.Sprintf("marked %s as %s", e.iteration(), status)
+		}
+		return "updated " + e.iteration()
+	case ResourceIteration + " " + AuditDelete:
+		return "moved " + e.iteration() + " to the trash"
+	case ResourceIteration + " " + AuditRestore:
+		return "restored " + e.iteration()
+	case ResourceNote + " " + AuditCreate:
+		return "added a note to " + e.iteration()
+	case ResourceTranscript + " " + AuditUpdate:
+		return "added a transcript to " + e.iteration()
+	case ResourceTranscript + " " + AuditDelete:
+		return "removed the transcript of " + e.iteration()
+
+	case ResourceComment + " " + AuditCreate:
+		if timecode, ok := e.Changes["timecode"].After.(float64); ok {
+			return fmt.Sprintf("commented on %s at %s", e.iteration(), formatTimecode(int(timecode)))
+		}
+		return "commented on " + e.iteration()
+	case ResourceComment + " " + AuditResolve:
+		return "resolved a comment"
+	case ResourceComment + " " + AuditReopen:
+		return "reopened a comment"
+	case ResourceShareLink + " " + AuditCreate:
+		return "shared " + e.iteration() + " with a link"
+	case ResourceShareLink + " " + AuditRevoke:
+		return "revoked a share link"
+
+	case ResourceThumbnail + " " + AuditCreate:
+		return "uploaded a thumbnail"
+	case ResourceThumbnail + " " + AuditActivate:
+		return "picked a new thumbnail"
+	case ResourceThumbnail + " " + AuditDelete:
+		return "deleted a thumbnail"
+	case ResourceCaption + " " + AuditCreate:
+		if language := e.after("language"); language != "" {
+			return "added " + language + " captions"
+		}
+		return "added captions"
+	case ResourceCaption + " " + AuditUpdate:
+		return "shifted the timing of captions"
+	case ResourceCaption + " " + AuditDelete:
+		return "deleted captions"
+	case ResourceChapters + " " + AuditUpdate:
+		return "updated the chapters"
+	case ResourceChapters + " " + AuditDelete:
+		return "removed the chapters"
+
+	case ResourceLocalization + " " + AuditCreate:
+		return "added the " + e.ResourceID + " localization"
+	case ResourceLocalization + " " + AuditUpdate:
+		return "edited the " + e.ResourceID + " localization"
+	case ResourceLocalization + " " + AuditDelete:
+		return "deleted the " + e.ResourceID + " localization"
+	case ResourceLocalization + " " + AuditApprove:
+		return "approved the " + e.ResourceID + " translation"
+	case ResourceLocalization + " " + AuditGenerate:
+		return "translated the video into " + e.ResourceID + " with AI"
+
+	case ResourceSuggestion + " " + AuditGenerate:
+		return "generated AI suggestions"
+	case ResourceSuggestion + " " + AuditAccept:
+		return "accepted AI suggestions for " + e.fields()
+	case ResourceSuggestion + " " + AuditReject:
+		return "rejected AI suggestions"
+
+	case ResourceSponsorship + " " + AuditCreate:
+		if sponsor := e.after("sponsor"); sponsor != "" {
+			return "marked the video as sponsored by " + sponsor
+		}
+		return "marked the video as sponsored"
+	case ResourceSponsorship + " " + AuditUpdate:
+		return "updated the sponsorship"
+	case ResourceSponsorship + " " + AuditDelete:
+		return "removed the sponsorship"
+	case ResourceApproval + " " + AuditRequest:
+		return fmt.Sprintf("asked %s to approve %s", e.after("approverEmail"), e.iteration())
+	case ResourceApproval + " " + AuditApprove:
+		return "approved the sponsored video"
+	case ResourceApproval + " " + AuditReject:
+		return "requested changes to the sponsored video"
+	}
+
+	return fmt.Sprintf("made a change (%s %s)", e.Action, spellField(e.ResourceType))
+}
+
+// spellField spells out a field name like "defaultLanguage" as
+// "default language"
+func spellField(field string) string {
+	var b strings.Builder
+	for i, r := range field {
+		switch {
+		case r == '_':
+			b.WriteRune(' ')
+		case unicode.IsUpper(r) && i > 0:
+			b.WriteRune(' ')
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// formatTimecode formats a number of seconds like "1:23" or "1:02:03"
+func formatTimecode(seconds int) string {
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
@@ -5,14 +5,29 @@ import (
 	"net/http"
 )
 
+// AI providers a user or channel can choose. An empty provider falls back to
+// the user's choice, then to the server default.
+const (
+	AIProviderHTTP   = "http"
+	AIProviderOpenAI = "openai"
+	AIProviderStub   = "stub"
+)
+
+// IsAIProvider reports whether name is a known AI provider or empty
+func IsAIProvider(name string) bool {
+	switch name {
+	case "", AIProviderHTTP, AIProviderOpenAI, AIProviderStub:
+		return true
+	}
+	return false
+}
+
 type AISuggestions struct {
 	Title       string   `json:"title"`
 	Description string   `json:"description"`
 	Keywords    []string `json:"keywords"`
 	Chapters    []string `json:"chapters"`
 	Thumbnail   string   `json:"thumbnail"`
-	// prompt
-	// seed
 }
 
 // Implement render.Binder for AISuggestions
@@ -40,3 +55,41 @@ func (a *AISuggestions) Bind(r *http.Request) error {
 
 	return nil
 }
+
+// Fields of a video that can be accepted from an AI suggestion
+const (
+	AIFieldTitle       = "title"
+	AIFieldDescription = "description"
+	AIFieldKeywords    = "keywords"
+	AIFieldChapters    = "chapters"
+)
+
+// AISuggestionFields lists the fields that can be accepted from a suggestion
+var AISuggestionFields = []string{AIFieldTitle, AIFieldDescription, AIFieldKeywords, AIFieldChapters}
+
+// IsAISuggestionField reports whether name is a field that can be accepted
+// from a suggestion
+func IsAISuggestionField(name string) bool {
+	return contains(AISuggestionFields, name)
+}
+
+// AISuggestionRun is a stored AI suggestion for a video, along with what it
+// was generated from and which of its fields were accepted or rejected
+type AISuggestionRun struct {
+	ID      string `json:"id"`
+	VideoID string `json:"videoId"`
+	UserID  string `json:"userId,omitempty"`
+	// Provider is the name of the AI provider that generated the suggestion
+	Provider string `json:"provider"`
+	// Prompt is the input sent to the provider
+	Prompt string `json:"prompt"`
+	SuggestionOptions
+	// Fields lists the fields that were generated, or is empty if all were
+	Fields []string `json:"fields"`
+	// ParentID is the run a regenerated run was derived from
+	ParentID       string        `json:"parentId,omitempty"`
+	Result         AISuggestions `json:"result"`
+	AcceptedFields []string      `json:"acceptedFields"`
+	RejectedFields []string      `json:"rejectedFields"`
+	CreatedAt      string        `json:"createdAt"`
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// Types of principals that act on resources
+const (
+	PrincipalUser = "user"
+	// PrincipalGuest is the guest of a share link
+	PrincipalGuest = "guest"
+	// PrincipalApprover is the approver of a sponsored video
+	PrincipalApprover = "approver"
+	// PrincipalWebhook is an external service calling a webhook
+	PrincipalWebhook = "webhook"
+	// PrincipalSystem is the server itself, e.g. a background job
+	PrincipalSystem = "system"
+)
+
+// Actions recorded in the audit log. Requests that don't record an action
+// are logged with their method and route instead.
+const (
+	AuditCreate   = "create"
+	AuditUpdate   = "update"
+	AuditDelete   = "delete"
+	AuditRestore  = "restore"
+	AuditPublish  = "publish"
+	AuditTransfer = "transfer"
+	AuditAccept   = "accept"
+	AuditReject   = "reject"
+	AuditApprove  = "approve"
+	AuditActivate = "activate"
+	AuditGenerate = "generate"
+	AuditRequest  = "request"
+	AuditRevoke   = "revoke"
+	AuditResolve  = "resolve"
+	AuditReopen   = "reopen"
+	AuditExtend   = "extend"
+	AuditExpire   = "expire"
+	AuditPurge    = "purge"
+)
+
+// Types of resources recorded in the audit log besides the ones permissions
+// are checked on
+const (
+	ResourceUser         = "user"
+	ResourceEditor       = "editor"
+	ResourceMember       = "member"
+	ResourceInvitation   = "invitation"
+	ResourceRole         = "role"
+	ResourceThumbnail    = "thumbnail"
+	ResourceCaption      = "caption"
+	ResourceChapters     = "chapters"
+	ResourceLocalization = "localization"
+	ResourceTranscript   = "transcript"
+	ResourceSuggestion   = "suggestion"
+	ResourceNote         = "note"
+	ResourceShareLink    = "share_link"
+	ResourceComment      = "comment"
+	ResourceSponsorship  = "sponsorship"
+	ResourceApproval     = "approval"
+	ResourceSubscription = "subscription"
+	ResourceTrial        = "trial"
+	ResourceTrash        = "trash"
+)
+
+// AuditActor is who performed an audited action. ID is a user ID for users,
+// a share link ID for guests and an email address for approvers.
+type AuditActor struct {
+	ID            string `json:"actorId"`
+	Name          string `json:"actorName"`
+	PrincipalType string `json:"principalType"`
+}
+
+// AuditTarget is the resource an audited action touched and where it
+// belongs. The organization, channel and video are empty when they don't
+// apply.
+type AuditTarget struct {
+	OrganizationID string `json:"organizationId"`
+	ChannelID      string `json:"channelId"`
+	VideoID        string `json:"videoId"`
+	ResourceType   string `json:"resourceType"`
+	ResourceID     string `json:"resourceId"`
+}
+
+// AuditChange is the value of a field before and after an action
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEntry is an entry of the audit log
+type AuditEntry struct {
+	ID int64 `json:"id"`
+	AuditActor
+	Action string `json:"action"`
+	AuditTarget
+	Changes   map[string]AuditChange `json:"changes"`
+	RequestID string                 `json:"requestId"`
+	IP        string                 `json:"ip"`
+	CreatedAt time.Time              `json:"createdAt"`
+}
+
+// auditIgnoredFields change on every write or duplicate the time of the
+// entry and would only add noise
+var auditIgnoredFields = map[string]bool{"createdAt": true, "updatedAt": true, "version": true}
+
+// auditRedactedFields are secrets whose values never go in the audit log
+var auditRedactedFields = map[string]bool{"password": true, "api_key": true, "token": true, "session": true}
+
+// auditRedacted replaces the values of secrets in the audit log
+const auditRedacted = "[redacted]"
+
+// AuditDiff returns the fields that differ between the JSON representations
+// of a resource before and after an action. Either can be nil, in which case
+// every field of the other is listed.
+func AuditDiff(before, after interface{}) map[string]AuditChange {
+	beforeFields := auditFields(before)
+	afterFields := auditFields(after)
+
+	changes := map[string]AuditChange{}
+	for field, value := range beforeFields {
+		if other, ok := afterFields[field]; !ok || !reflect.DeepEqual(value, other) {
+			changes[field] = AuditChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			changes[field] = AuditChange{After: value}
+		}
+	}
+
+	for field, change := range changes {
+		if auditRedactedFields[field] {
+			if change.Before != nil {
+				change.Before = auditRedacted
+			}
+			if change.After != nil {
+				change.After = auditRedacted
+			}
+			changes[field] = change
+		}
+	}
+	return changes
+}
+
+// auditFields decodes the JSON representation of a resource into its fields.
+// Resources that aren't objects are kept under "value", and empty fields are
+// left out.
+func auditFields(resource interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if resource == nil || reflect.ValueOf(resource).Kind() == reflect.Ptr && reflect.ValueOf(resource).IsNil() {
+		return fields
+	}
+
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return fields
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fields
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		fields["value"] = value
+		return fields
+	}
+
+	for field, value := range object {
+		if value == nil || value == "" || auditIgnoredFields[field] {
+			continue
+		}
+		fields[field] = value
+	}
+	return fields
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Statuses of a subscription that keep its tier. Past due subscriptions keep
+// it while the payment is retried; any other status falls back to free.
+var PayingSubscriptionStatuses = []string{"active", "trialing", "past_due"}
+
+// Subscription is a paid subscription of a user to a tier
+type Subscription struct {
+	ID               string     `json:"id"`
+	CustomerID       string     `json:"customerId"`
+	Status           string     `json:"status"`
+	Tier             Tier       `json:"tier"`
+	CurrentPeriodEnd *time.Time `json:"currentPeriodEnd"`
+	CreatedAt        string     `json:"createdAt"`
+	UpdatedAt        string     `json:"updatedAt"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+
+	"github.com/FuseWorkflows/fuse-go-server/captions"
+)
+
+// YouTube caption track limits
+const (
+	MaxCaptionBytes     = 1 << 20
+	maxCaptionNameRunes = 150
+)
+
+// Caption is a caption track of a video. Tracks pinned to an iteration are
+// only published with that iteration, the others with whichever is latest.
+type Caption struct {
+	ID          string          `json:"id"`
+	VideoID     string          `json:"videoId"`
+	IterationID string          `json:"iterationId,omitempty"`
+	Language    string          `json:"language"`
+	Name        string          `json:"name"`
+	Format      captions.Format `json:"format"`
+	CueCount    int             `json:"cueCount"`
+	// YouTubeID is set once the track has been uploaded
+	YouTubeID string `json:"youtubeId"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// Validate checks the metadata of a caption track and canonicalizes its
+// language tag
+func (c *Caption) Validate() error {
+	tag, err := language.Parse(c.Language)
+	if err != nil {
+		return &ValidationError{Field: "language", Message: "must be a BCP-47 language tag"}
+	}
+	c.Language = tag.String()
+
+	if utf8.RuneCountInString(c.Name) > maxCaptionNameRunes {
+		return &ValidationError{Field: "name", Message: "must be at most 150 characters"}
+	}
+	if strings.ContainsAny(c.Name, forbiddenMetadataSet) {
+		return &ValidationError{Field: "name", Message: "must not contain < or >"}
+	}
+	return nil
+}
+
+// AppliesTo reports whether the track is published with an iteration
+func (c *Caption) AppliesTo(iterationID string) bool {
+	return c.IterationID == "" || c.IterationID == iterationID
+}
+
+// ParseCaptionFile parses and validates the content of a caption track
+func ParseCaptionFile(data []byte, format captions.Format) ([]captions.Cue, error) {
+	if len(data) > MaxCaptionBytes {
+		return nil, &ValidationError{Field: "file", Message: "must be at most 1 MB"}
+	}
+	if !utf8.Valid(data) {
+		return nil, &ValidationError{Field: "file", Message: "must be UTF-8 text"}
+	}
+	cues, err := captions.Parse(data, format)
+	if err != nil {
+		return nil, &ValidationError{Field: "file", Message: err.Error()}
+	}
+	if len(cues) == 0 {
+		return nil, &ValidationError{Field: "file", Message: "must contain at least one cue"}
+	}
+	return cues, nil
+}
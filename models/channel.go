@@ -6,13 +6,20 @@ import (
 )
 
 type Channel struct {
-	ID        string  `json:"id"`
-	Name      string  `json:"name"`
-	API_KEY   string  `json:"api_key"`
-	Owner     User    `json:"owner"`
-	Videos    []Video `json:"videos"`
-	CreatedAt string  `json:"createdAt"`
-	UpdatedAt string  `json:"updatedAt"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	API_KEY string `json:"api_key"`
+	// Owner is the owner of the organization, whose tier and quotas apply to the channel
+	Owner          User   `json:"owner"`
+	OrganizationID string `json:"organizationId"`
+	// AIProvider overrides the AI provider of the owner for this channel
+	AIProvider string `json:"aiProvider"`
+	// PromptTemplate is the template of AI suggestion prompts, see PromptData
+	PromptTemplate string  `json:"promptTemplate"`
+	Videos         []Video `json:"videos"`
+	CreatedAt      string  `json:"createdAt"`
+	UpdatedAt      string  `json:"updatedAt"`
+	Version        int     `json:"version"`
 }
 
 func (c *Channel) MarshalJSON() ([]byte, error) {
@@ -43,13 +50,16 @@ func (c *Channel) UnmarshalJSON(data []byte) error {
 func (c *Channel) Bind(r *http.Request) error {
 	// Decode the JSON body into a temporary struct that includes Owner
 	type ChannelWithOwner struct {
-		ID        string  `json:"id"`
-		Name      string  `json:"name"`
-		API_KEY   string  `json:"api_key"`
-		Owner     User    `json:"owner"`
-		Videos    []Video `json:"videos"`
-		CreatedAt string  `json:"createdAt"`
-		UpdatedAt string  `json:"updatedAt"`
+		ID             string  `json:"id"`
+		Name           string  `json:"name"`
+		API_KEY        string  `json:"api_key"`
+		Owner          User    `json:"owner"`
+		OrganizationID string  `json:"organizationId"`
+		AIProvider     string  `json:"aiProvider"`
+		PromptTemplate string  `json:"promptTemplate"`
+		Videos         []Video `json:"videos"`
+		CreatedAt      string  `json:"createdAt"`
+		UpdatedAt      string  `json:"updatedAt"`
 	}
 
 	var temp ChannelWithOwner
@@ -62,9 +72,39 @@ func (c *Channel) Bind(r *http.Request) error {
 	c.Name = temp.Name
 	c.API_KEY = temp.API_KEY
 	c.Owner = temp.Owner
+	c.OrganizationID = temp.OrganizationID
+	c.AIProvider = temp.AIProvider
+	c.PromptTemplate = temp.PromptTemplate
 	c.Videos = temp.Videos
 	c.CreatedAt = temp.CreatedAt
 	c.UpdatedAt = temp.UpdatedAt
 
 	return nil
 }
+
+// ChannelFields are the fields of a channel that can be changed through a patch
+type ChannelFields struct {
+	Name           string `json:"name"`
+	API_KEY        string `json:"api_key"`
+	AIProvider     string `json:"aiProvider"`
+	PromptTemplate string `json:"promptTemplate"`
+}
+
+// Fields returns the current values of the patchable fields of the channel
+func (c *Channel) Fields() ChannelFields {
+	return ChannelFields{Name: c.Name, API_KEY: c.API_KEY, AIProvider: c.AIProvider, PromptTemplate: c.PromptTemplate}
+}
+
+// Validate checks the fields before they are written
+func (f *ChannelFields) Validate() error {
+	if f.Name == "" {
+		return &ValidationError{Field: "name", Message: "is required"}
+	}
+	if !IsAIProvider(f.AIProvider) {
+		return &ValidationError{Field: "aiProvider", Message: "must be http, openai or stub"}
+	}
+	if f.PromptTemplate != "" {
+		return validatePromptTemplate(f.PromptTemplate)
+	}
+	return nil
+}
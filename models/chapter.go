@@ -0,0 +1,174 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// YouTube chapter rules
+const (
+	MinChapters          = 3
+	MinChapterSeconds    = 10
+	maxChapterTitleRunes = 100
+)
+
+// Chapter is a section of a video starting at Start seconds
+type Chapter struct {
+	Start int    `json:"start"`
+	Title string `json:"title"`
+}
+
+// MarshalJSON adds the timestamp the chapter is rendered with
+func (c Chapter) MarshalJSON() ([]byte, error) {
+	type Alias Chapter
+	return json.Marshal(&struct {
+		Alias
+		Timestamp string `json:"timestamp"`
+	}{
+		Alias:     Alias(c),
+		Timestamp: FormatTimestamp(c.Start, c.Start >= 3600),
+	})
+}
+
+// UnmarshalJSON accepts the start either in seconds or as a timestamp such as "1:23"
+func (c *Chapter) UnmarshalJSON(data []byte) error {
+	var temp struct {
+		Start json.RawMessage `json:"start"`
+		Title string          `json:"title"`
+	}
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return err
+	}
+
+	c.Title = temp.Title
+	var timestamp string
+	if err := json.Unmarshal(temp.Start, &timestamp); err == nil {
+		seconds, ok := ParseTimestamp(timestamp)
+		if !ok {
+			return fmt.Errorf("invalid chapter start %q", timestamp)
+		}
+		c.Start = seconds
+		return nil
+	}
+	return json.Unmarshal(temp.Start, &c.Start)
+}
+
+// ParseTimestamp parses a timestamp of the form [[H:]M]:SS, or a number of
+// seconds, into seconds
+func ParseTimestamp(s string) (int, bool) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, false
+	}
+	seconds := 0
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (i > 0 && (len(part) != 2 || n > 59)) {
+			return 0, false
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds, true
+}
+
+// ParseLength parses the length of an iteration, given as a timestamp or a
+// Go duration such as "12m30s", into seconds
+func ParseLength(length string) (int, bool) {
+	if seconds, ok := ParseTimestamp(length); ok {
+		return seconds, true
+	}
+	if d, err := time.ParseDuration(length); err == nil && d >= 0 {
+		return int(d.Seconds()), true
+	}
+	return 0, false
+}
+
+// FormatTimestamp formats seconds the way YouTube expects chapter timestamps
+func FormatTimestamp(seconds int, hours bool) string {
+	if hours {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+// ValidateChapters checks chapters against YouTube's rules: at least three,
+// in ascending order, the first starting at zero and each lasting at least ten
+// seconds. duration is the length of the video in seconds, or zero if unknown.
+func ValidateChapters(chapters []Chapter, duration int) error {
+	if len(chapters) == 0 {
+		return nil
+	}
+	if len(chapters) < MinChapters {
+		return &ValidationError{Field: "chapters", Message: "must contain at least 3 chapters"}
+	}
+	if chapters[0].Start != 0 {
+		return &ValidationError{Field: "chapters", Message: "must start at 00:00"}
+	}
+
+	for i, chapter := range chapters {
+		if strings.TrimSpace(chapter.Title) == "" {
+			return &ValidationError{Field: "chapters", Message: "must all have a title"}
+		}
+		if utf8.RuneCountInString(chapter.Title) > maxChapterTitleRunes {
+			return &ValidationError{Field: "chapters", Message: "must have titles of at most 100 characters"}
+		}
+		if strings.ContainsAny(chapter.Title, forbiddenMetadataSet+"\n") {
+			return &ValidationError{Field: "chapters", Message: "must not have titles containing <, > or line breaks"}
+		}
+		if i > 0 && chapter.Start-chapters[i-1].Start < MinChapterSeconds {
+			return &ValidationError{Field: "chapters", Message: "must be in ascending order and at least 10 seconds long"}
+		}
+	}
+
+	if duration > 0 && duration-chapters[len(chapters)-1].Start < MinChapterSeconds {
+		return &ValidationError{Field: "chapters", Message: "must end at least 10 seconds before the end of the video"}
+	}
+	return nil
+}
+
+// ParseChapterLines parses chapters written as "MM:SS Title" lines, the way
+// they appear in descriptions and AI suggestions
+func ParseChapterLines(lines []string) ([]Chapter, error) {
+	chapters := make([]Chapter, 0, len(lines))
+	for _, line := range lines {
+		timestamp, title, _ := strings.Cut(strings.TrimSpace(line), " ")
+		start, ok := ParseTimestamp(timestamp)
+		if !ok {
+			return nil, &ValidationError{Field: "chapters", Message: fmt.Sprintf("%q doesn't start with a timestamp", line)}
+		}
+		title = strings.TrimSpace(strings.TrimLeft(title, " -–—:"))
+		chapters = append(chapters, Chapter{Start: start, Title: title})
+	}
+	return chapters, nil
+}
+
+// DescriptionWithChapters appends the chapter list to a description
+func DescriptionWithChapters(description string, chapters []Chapter) string {
+	if len(chapters) == 0 {
+		return description
+	}
+
+	hours := chapters[len(chapters)-1].Start >= 3600
+	lines := make([]string, len(chapters))
+	for i, chapter := range chapters {
+		lines[i] = FormatTimestamp(chapter.Start, hours) + " " + chapter.Title
+	}
+
+	if description == "" {
+		return strings.Join(lines, "\n")
+	}
+	return strings.TrimRight(description, "\n") + "\n\n" + strings.Join(lines, "\n")
+}
+
+// ValidateDescriptionWithChapters checks that a description still fits
+// YouTube's limit once the chapters are appended to it
+func ValidateDescriptionWithChapters(description string, chapters []Chapter) error {
+	if len(DescriptionWithChapters(description, chapters)) > MaxDescriptionBytes {
+		return &ValidationError{Field: "chapters", Message: "make the description longer than 5000 bytes"}
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type Editor struct {
 	ID        string `json:"id"`
 	Username  string `json:"username"`
@@ -9,6 +11,9 @@ type Editor struct {
 	UpdatedAt string `json:"updatedAt"`
 	Tier      Tier   `json:"tier"`
 	Trial     bool   `json:"trial"`
+	// TrialStartedAt and TrialEndsAt are set once the editor started a trial
+	TrialStartedAt *time.Time `json:"trialStartedAt,omitempty"`
+	TrialEndsAt    *time.Time `json:"trialEndsAt,omitempty"`
 }
 
 type Tier string
@@ -18,3 +23,32 @@ const (
 	Basic   Tier = "basic"
 	Free    Tier = "free"
 )
+
+// EditorFields are the fields of an editor that can be changed through a
+// patch. Trials are only started by the server and extended by admins, and
+// Tier is only there so that patches can send it back unchanged.
+type EditorFields struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Tier     Tier   `json:"tier"`
+}
+
+// Fields returns the current values of the patchable fields of the editor
+func (e *Editor) Fields() EditorFields {
+	return EditorFields{Username: e.Username, Email: e.Email, Password: e.Password, Tier: e.Tier}
+}
+
+// Validate checks the fields before they are written
+func (f *EditorFields) Validate() error {
+	if f.Username == "" {
+		return &ValidationError{Field: "username", Message: "is required"}
+	}
+	if f.Email == "" {
+		return &ValidationError{Field: "email", Message: "is required"}
+	}
+	if f.Password == "" {
+		return &ValidationError{Field: "password", Message: "is required"}
+	}
+	return nil
+}
@@ -14,6 +14,7 @@ type Iteration struct {
 	Notes     string          `json:"notes"`
 	CreatedAt string          `json:"createdAt"`
 	UpdatedAt string          `json:"updatedAt"`
+	Version   int             `json:"version"`
 	//createdby  Editor
 }
 
@@ -56,3 +57,29 @@ const (
 	Completed  IterationStatus = "completed"
 	Failed     IterationStatus = "failed"
 )
+
+// IterationFields are the fields of an iteration that can be changed through a patch
+type IterationFields struct {
+	URL    string          `json:"url"`
+	Length string          `json:"length"`
+	Status IterationStatus `json:"status"`
+	Notes  string          `json:"notes"`
+}
+
+// Fields returns the current values of the patchable fields of the iteration
+func (i *Iteration) Fields() IterationFields {
+	return IterationFields{URL: i.URL, Length: i.Length, Status: i.Status, Notes: i.Notes}
+}
+
+// Validate checks the fields before they are written
+func (f *IterationFields) Validate() error {
+	if f.URL == "" {
+		return &ValidationError{Field: "url", Message: "is required"}
+	}
+	switch f.Status {
+	case Processing, Completed, Failed:
+	default:
+		return &ValidationError{Field: "status", Message: "must be one of processing, completed or failed"}
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+package models
+
+// List is the envelope returned by list endpoints
+type List struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+	Total      int         `json:"total"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Localization is the title and description of a video in another language
+type Localization struct {
+	// Language is the BCP-47 tag of the localization
+	Language    string `json:"language"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// AIGenerated is set on localizations filled in by the AI service, which
+	// need a review before the video can be published
+	AIGenerated bool   `json:"aiGenerated"`
+	NeedsReview bool   `json:"needsReview"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+// Validate checks a localization against YouTube's metadata rules and
+// canonicalizes its language tag. A video can't be localized into its own
+// default language.
+func (l *Localization) Validate(defaultLanguage string) error {
+	tag, err := language.Parse(l.Language)
+	if err != nil {
+		return &ValidationError{Field: "language", Message: "must be a BCP-47 language tag"}
+	}
+	l.Language = tag.String()
+	if l.Language == defaultLanguage {
+		return &ValidationError{Field: "language", Message: "must differ from the default language of the video"}
+	}
+	if strings.TrimSpace(l.Title) == "" {
+		return &ValidationError{Field: "title", Message: "is required"}
+	}
+	if err := validateTitle(l.Title); err != nil {
+		return err
+	}
+	return validateDescription(l.Description)
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Kinds of notifications
+const (
+	NotificationTrialEnding     = "trial_ending"
+	NotificationTrialEnded      = "trial_ended"
+	NotificationTrialExtended   = "trial_extended"
+	NotificationPaymentFailed   = "payment_failed"
+	NotificationSubscription    = "subscription"
+	NotificationInvitation      = "invitation"
+	NotificationSponsorApproval = "sponsor_approval"
+)
+
+// Notification is a message to a user or an editor
+type Notification struct {
+	ID        string     `json:"id"`
+	Kind      string     `json:"kind"`
+	Message   string     `json:"message"`
+	CreatedAt string     `json:"createdAt"`
+	ReadAt    *time.Time `json:"readAt"`
+}
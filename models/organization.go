@@ -0,0 +1,82 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Role is the role of a member of an organization: one of BuiltinRoles or a
+// custom role of the organization
+type Role string
+
+// Built-in roles, see BuiltinRoles for what they grant
+const (
+	RoleViewer   Role = "viewer"
+	RoleProducer Role = "producer"
+	RoleAdmin    Role = "admin"
+	RoleOwner    Role = "owner"
+)
+
+// InvitationLifetime is how long an invitation can be accepted for
+const InvitationLifetime = 7 * 24 * time.Hour
+
+// MaxOrganizationNameLength limits the names of organizations
+const MaxOrganizationNameLength = 255
+
+// Organization owns channels and gives its members access to them
+type Organization struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Role is the role of the authenticated user in the organization
+	Role Role `json:"role,omitempty"`
+	// Permissions are the permissions the role grants
+	Permissions []Permission `json:"permissions"`
+	CreatedAt   string       `json:"createdAt"`
+	UpdatedAt   string       `json:"updatedAt"`
+}
+
+// Validate checks the fields of an organization
+func (o *Organization) Validate() error {
+	o.Name = strings.TrimSpace(o.Name)
+	if o.Name == "" {
+		return &ValidationError{Field: "name", Message: "is required"}
+	}
+	if len(o.Name) > MaxOrganizationNameLength {
+		return &ValidationError{Field: "name", Message: "must be at most 255 characters"}
+	}
+	return nil
+}
+
+// Member is a user with a role in an organization
+type Member struct {
+	UserID    string `json:"userId"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Role      Role   `json:"role"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// Invitation invites the user with an email address to join an organization
+type Invitation struct {
+	ID               string     `json:"id"`
+	OrganizationID   string     `json:"organizationId"`
+	OrganizationName string     `json:"organizationName"`
+	Email            string     `json:"email"`
+	Role             Role       `json:"role"`
+	InvitedBy        string     `json:"invitedBy"`
+	CreatedAt        string     `json:"createdAt"`
+	ExpiresAt        time.Time  `json:"expiresAt"`
+	AcceptedAt       *time.Time `json:"acceptedAt"`
+}
+
+// Validate checks the fields of an invitation
+func (i *Invitation) Validate() error {
+	i.Email = strings.TrimSpace(i.Email)
+	if !strings.Contains(i.Email, "@") {
+		return &ValidationError{Field: "email", Message: "must be an email address"}
+	}
+	if i.Role == "" {
+		return &ValidationError{Field: "role", Message: "is required"}
+	}
+	return nil
+}
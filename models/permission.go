@@ -0,0 +1,236 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Permission allows an action on the resources of an organization
+type Permission string
+
+// Permissions that roles grant on the resources of their organization
+const (
+	PermissionOrganizationManage Permission = "organization:manage"
+	PermissionMemberManage       Permission = "member:manage"
+	PermissionRoleManage         Permission = "role:manage"
+	PermissionAuditRead          Permission = "audit:read"
+	PermissionChannelRead        Permission = "channel:read"
+	PermissionChannelCreate      Permission = "channel:create"
+	PermissionChannelManage      Permission = "channel:manage"
+	PermissionChannelTransfer    Permission = "channel:transfer"
+	PermissionVideoRead          Permission = "video:read"
+	PermissionVideoCreate        Permission = "video:create"
+	PermissionVideoUpdate        Permission = "video:update"
+	PermissionVideoDelete        Permission = "video:delete"
+	PermissionVideoPublish       Permission = "video:publish"
+	PermissionIterationCreate    Permission = "iteration:create"
+	PermissionIterationUpdate    Permission = "iteration:update"
+	PermissionIterationDelete    Permission = "iteration:delete"
+	PermissionIterationShare     Permission = "iteration:share"
+	PermissionCommentCreate      Permission = "comment:create"
+	PermissionCommentResolve     Permission = "comment:resolve"
+	PermissionAIUse              Permission = "ai:use"
+)
+
+// Permissions lists every permission
+var Permissions = []Permission{
+	PermissionOrganizationManage,
+	PermissionMemberManage,
+	PermissionRoleManage,
+	PermissionAuditRead,
+	PermissionChannelRead,
+	PermissionChannelCreate,
+	PermissionChannelManage,
+	PermissionChannelTransfer,
+	PermissionVideoRead,
+	PermissionVideoCreate,
+	PermissionVideoUpdate,
+	PermissionVideoDelete,
+	PermissionVideoPublish,
+	PermissionIterationCreate,
+	PermissionIterationUpdate,
+	PermissionIterationDelete,
+	PermissionIterationShare,
+	PermissionCommentCreate,
+	PermissionCommentResolve,
+	PermissionAIUse,
+}
+
+var (
+	viewerPermissions = []Permission{
+		PermissionChannelRead,
+		PermissionVideoRead,
+		PermissionCommentCreate,
+	}
+	producerPermissions = append(viewerPermissions[:len(viewerPermissions):len(viewerPermissions)],
+		PermissionVideoCreate,
+		PermissionVideoUpdate,
+		PermissionVideoDelete,
+		PermissionVideoPublish,
+		PermissionIterationCreate,
+		PermissionIterationUpdate,
+		PermissionIterationDelete,
+		PermissionIterationShare,
+		PermissionCommentResolve,
+		PermissionAIUse,
+	)
+	adminPermissions = append(producerPermissions[:len(producerPermissions):len(producerPermissions)],
+		PermissionChannelCreate,
+		PermissionChannelManage,
+		PermissionMemberManage,
+		PermissionRoleManage,
+		PermissionAuditRead,
+	)
+)
+
+// BuiltinRoles are the roles every organization has. Viewers can see the
+// channels of the organization and comment, producers can also work on their
+// videos, admins also manage channels, members and custom roles and read the
+// audit log, and owners can do anything.
+var BuiltinRoles = map[Role][]Permission{
+	RoleViewer:   viewerPermissions,
+	RoleProducer: producerPermissions,
+	RoleAdmin:    adminPermissions,
+	RoleOwner:    Permissions,
+}
+
+// builtinRoleOrder lists the built-in roles from the least to the most
+// privileged
+var builtinRoleOrder = []Role{RoleViewer, RoleProducer, RoleAdmin, RoleOwner}
+
+var builtinRoleDescriptions = map[Role]string{
+	RoleViewer:   "Sees the channels and videos of the organization and comments on them",
+	RoleProducer: "Works on videos and iterations and publishes them",
+	RoleAdmin:    "Manages channels, members and custom roles",
+	RoleOwner:    "Manages the organization itself and transfers its channels",
+}
+
+// BuiltinRoleDefinitions describes the built-in roles from the least to the
+// most privileged
+func BuiltinRoleDefinitions() []RoleDefinition {
+	definitions := make([]RoleDefinition, 0, len(builtinRoleOrder))
+	for _, role := range builtinRoleOrder {
+		definitions = append(definitions, RoleDefinition{
+			Name:        role,
+			Description: builtinRoleDescriptions[role],
+			Permissions: BuiltinRoles[role],
+			Builtin:     true,
+		})
+	}
+	return definitions
+}
+
+// IsBuiltin reports whether r is one of BuiltinRoles
+func (r Role) IsBuiltin() bool {
+	_, ok := BuiltinRoles[r]
+	return ok
+}
+
+// BuiltinRolesWith returns the built-in roles that grant a permission
+func BuiltinRolesWith(permission Permission) []Role {
+	var roles []Role
+	for _, role := range builtinRoleOrder {
+		if HasPermission(BuiltinRoles[role], permission) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Valid reports whether p is one of Permissions
+func (p Permission) Valid() bool {
+	return HasPermission(Permissions, p)
+}
+
+// HasPermission reports whether permissions include permission
+func HasPermission(permissions []Permission, permission Permission) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermissions reports whether permissions include every one of required
+func HasPermissions(permissions, required []Permission) bool {
+	for _, p := range required {
+		if !HasPermission(permissions, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// roleNamePattern restricts the names of custom roles
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// RoleDefinition is a role and the permissions it grants
+type RoleDefinition struct {
+	Name        Role         `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+	Builtin     bool         `json:"builtin"`
+	CreatedAt   string       `json:"createdAt,omitempty"`
+	UpdatedAt   string       `json:"updatedAt,omitempty"`
+}
+
+// Validate checks a custom role and sorts its permissions
+func (d *RoleDefinition) Validate() error {
+	if !roleNamePattern.MatchString(string(d.Name)) {
+		return &ValidationError{Field: "name", Message: "must be up to 32 lowercase letters, digits, dashes or underscores, starting with a letter"}
+	}
+	if d.Name.IsBuiltin() {
+		return &ValidationError{Field: "name", Message: fmt.Sprintf("%s is a built-in role", d.Name)}
+	}
+	if len(d.Description) > 255 {
+		return &ValidationError{Field: "description", Message: "must be at most 255 characters"}
+	}
+	if len(d.Permissions) == 0 {
+		return &ValidationError{Field: "permissions", Message: "must include at least one permission"}
+	}
+
+	seen := map[Permission]bool{}
+	permissions := d.Permissions[:0]
+	for _, permission := range d.Permissions {
+		if !permission.Valid() {
+			return &ValidationError{Field: "permissions", Message: fmt.Sprintf("%q is not a permission", permission)}
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			permissions = append(permissions, permission)
+		}
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i] < permissions[j] })
+	d.Permissions = permissions
+	return nil
+}
+
+// Kinds of resources permissions are checked on
+const (
+	ResourceOrganization = "organization"
+	ResourceChannel      = "channel"
+	ResourceVideo        = "video"
+	ResourceIteration    = "iteration"
+)
+
+// IsResourceType reports whether kind is one of the kinds of resources
+func IsResourceType(kind string) bool {
+	switch kind {
+	case ResourceOrganization, ResourceChannel, ResourceVideo, ResourceIteration:
+		return true
+	}
+	return false
+}
+
+// EffectivePermissions are the permissions a user has on a resource through
+// their role in the organization it belongs to
+type EffectivePermissions struct {
+	UserID         string       `json:"userId"`
+	ResourceType   string       `json:"resourceType"`
+	ResourceID     string       `json:"resourceId"`
+	OrganizationID string       `json:"organizationId"`
+	Role           Role         `json:"role,omitempty"`
+	Permissions    []Permission `json:"permissions"`
+}
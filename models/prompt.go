@@ -0,0 +1,116 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultPromptTemplate is used for channels without a prompt template
+const DefaultPromptTemplate = `Suggest YouTube metadata for a video on the channel "{{.ChannelName}}".
+Draft title: {{.Title}}
+Draft description: {{.Description}}
+Keywords: {{join .Keywords ", "}}
+Category: {{.Category}}
+{{- if .Transcript}}
+
+Transcript:
+{{.Transcript}}
+{{- end}}
+{{- if .TopTitles}}
+
+Titles of the latest videos of the channel, to match their style:
+{{- range .TopTitles}}
+- {{.}}
+{{- end}}
+{{- end}}`
+
+// MaxPromptTemplateLength bounds the size of channel prompt templates
+const MaxPromptTemplateLength = 10000
+
+// PromptData holds the variables available to prompt templates
+type PromptData struct {
+	ChannelName string
+	Title       string
+	Description string
+	Keywords    []string
+	Category    string
+	// Transcript is the transcript of the latest iteration, if any
+	Transcript string
+	// TopTitles are the titles of the latest published videos of the channel
+	TopTitles []string
+}
+
+var promptFuncs = template.FuncMap{"join": strings.Join}
+
+// RenderPrompt renders a prompt template, or the default one if it is empty
+func RenderPrompt(text string, data *PromptData) (string, error) {
+	if text == "" {
+		text = DefaultPromptTemplate
+	}
+	tmpl, err := template.New("prompt").Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// validatePromptTemplate checks that a template renders with every variable set
+func validatePromptTemplate(text string) error {
+	if len(text) > MaxPromptTemplateLength {
+		return &ValidationError{Field: "promptTemplate", Message: "must be at most 10000 bytes"}
+	}
+	sample := &PromptData{
+		ChannelName: "channel",
+		Title:       "title",
+		Description: "description",
+		Keywords:    []string{"keyword"},
+		Category:    "22",
+		Transcript:  "transcript",
+		TopTitles:   []string{"title"},
+	}
+	if _, err := RenderPrompt(text, sample); err != nil {
+		return &ValidationError{Field: "promptTemplate", Message: fmt.Sprintf("is not a valid template: %v", err)}
+	}
+	return nil
+}
+
+// Tones and lengths that can be asked of AI suggestions
+var (
+	AITones   = []string{"neutral", "casual", "professional", "enthusiastic", "humorous"}
+	AILengths = []string{"short", "medium", "long"}
+)
+
+// SuggestionOptions control how AI suggestions are generated
+type SuggestionOptions struct {
+	// Seed makes runs reproducible with providers that support it. A random
+	// seed is picked when it is missing.
+	Seed   *int64 `json:"seed"`
+	Tone   string `json:"tone"`
+	Length string `json:"length"`
+}
+
+// Validate checks the tone and length
+func (o *SuggestionOptions) Validate() error {
+	if o.Tone != "" && !contains(AITones, o.Tone) {
+		return &ValidationError{Field: "tone", Message: "must be one of " + strings.Join(AITones, ", ")}
+	}
+	if o.Length != "" && !contains(AILengths, o.Length) {
+		return &ValidationError{Field: "length", Message: "must be one of " + strings.Join(AILengths, ", ")}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// Metrics that are limited by tier. AI calls and uploads are counted per
+// calendar month, the others are the current totals of the account.
+const (
+	MetricAICalls      = "aiCalls"
+	MetricUploads      = "uploads"
+	MetricStorageBytes = "storageBytes"
+	MetricChannels     = "channels"
+	MetricEditors      = "editors"
+)
+
+// Unlimited marks a metric without a limit
+const Unlimited int64 = -1
+
+// Quota holds the limits of a tier
+type Quota map[string]int64
+
+// TierQuotas are the limits of each tier
+var TierQuotas = map[Tier]Quota{
+	Free: {
+		MetricAICalls:      20,
+		MetricUploads:      5,
+		MetricStorageBytes: 100 << 20,
+		MetricChannels:     1,
+		MetricEditors:      1,
+	},
+	Basic: {
+		MetricAICalls:      200,
+		MetricUploads:      50,
+		MetricStorageBytes: 1 << 30,
+		MetricChannels:     3,
+		MetricEditors:      5,
+	},
+	Premium: {
+		MetricAICalls:      2000,
+		MetricUploads:      Unlimited,
+		MetricStorageBytes: 10 << 30,
+		MetricChannels:     10,
+		MetricEditors:      25,
+	},
+}
+
+// QuotaFor returns the limits of a tier. Unknown tiers get the free limits.
+func QuotaFor(tier Tier) Quota {
+	if quota, ok := TierQuotas[tier]; ok {
+		return quota
+	}
+	return TierQuotas[Free]
+}
+
+// IsMonthlyMetric reports whether a metric is counted per calendar month
+func IsMonthlyMetric(metric string) bool {
+	return metric == MetricAICalls || metric == MetricUploads
+}
+
+// UsagePeriod returns the calendar month, in UTC, that t falls in
+func UsagePeriod(t time.Time) (start, end time.Time) {
+	t = t.UTC()
+	start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// MetricUsage is the use of a metric against its limit. Limit is nil for
+// unlimited metrics.
+type MetricUsage struct {
+	Used  int64  `json:"used"`
+	Limit *int64 `json:"limit"`
+}
+
+// Usage is the use of an account in the current period
+type Usage struct {
+	Tier        Tier                   `json:"tier"`
+	PeriodStart time.Time              `json:"periodStart"`
+	PeriodEnd   time.Time              `json:"periodEnd"`
+	Metrics     map[string]MetricUsage `json:"metrics"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsagePeriod(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+	}
+	tokyo := time.FixedZone("JST", 9*60*60)
+	losAngeles := time.FixedZone("PST", -8*60*60)
+
+	tests := []struct {
+		name      string
+		t         time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{"middle of a month", utc(2024, time.March, 15, 12), utc(2024, time.March, 1, 0), utc(2024, time.April, 1, 0)},
+		{"first instant", utc(2024, time.March, 1, 0), utc(2024, time.March, 1, 0), utc(2024, time.April, 1, 0)},
+		{"last instant", utc(2024, time.April, 1, 0).Add(-time.Nanosecond), utc(2024, time.March, 1, 0), utc(2024, time.April, 1, 0)},
+		{"leap february", utc(2024, time.February, 29, 23), utc(2024, time.February, 1, 0), utc(2024, time.March, 1, 0)},
+		{"december", utc(2023, time.December, 31, 23), utc(2023, time.December, 1, 0), utc(2024, time.January, 1, 0)},
+		{"ahead of UTC in the next month", time.Date(2024, time.April, 1, 8, 0, 0, 0, tokyo), utc(2024, time.March, 1, 0), utc(2024, time.April, 1, 0)},
+		{"behind UTC in the previous month", time.Date(2024, time.March, 31, 17, 0, 0, 0, losAngeles), utc(2024, time.April, 1, 0), utc(2024, time.May, 1, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := UsagePeriod(tt.t)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) || start.Location() != time.UTC {
+				t.Errorf("UsagePeriod(%s) = %s, %s, want %s, %s", tt.t, start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestQuotaFor(t *testing.T) {
+	if got := QuotaFor(Premium)[MetricUploads]; got != Unlimited {
+		t.Errorf("premium uploads = %d, want unlimited", got)
+	}
+	if got, want := QuotaFor("enterprise")[MetricAICalls], TierQuotas[Free][MetricAICalls]; got != want {
+		t.Errorf("unknown tier AI calls = %d, want the free %d", got, want)
+	}
+	for _, metric := range []string{MetricAICalls, MetricUploads} {
+		if !IsMonthlyMetric(metric) {
+			t.Errorf("%s isn't monthly", metric)
+		}
+	}
+	if IsMonthlyMetric(MetricStorageBytes) {
+		t.Errorf("%s is monthly", MetricStorageBytes)
+	}
+}
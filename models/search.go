@@ -0,0 +1,15 @@
+package models
+
+// SearchResult is a single match of a full-text search
+type SearchResult struct {
+	// Type is the kind of resource that matched, "video", "iteration" or
+	// "comment"
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	VideoID string `json:"videoId"`
+	Title   string `json:"title"`
+	// Headline is an excerpt of the matched text, HTML-escaped, with terms
+	// wrapped in <mark>
+	Headline string  `json:"headline"`
+	Rank     float64 `json:"rank"`
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Share links last a week unless they say otherwise, and at most 90 days
+const (
+	DefaultShareLinkLifetime = 7 * 24 * time.Hour
+	MaxShareLinkLifetime     = 90 * 24 * time.Hour
+)
+
+// GuestSessionLifetime is how long a guest can use a share link before
+// starting a new session, if the link doesn't expire sooner
+const GuestSessionLifetime = 12 * time.Hour
+
+// Guests get MaxPasswordAttempts wrong passwords per link and IP address
+// within PasswordAttemptWindow before they have to wait
+const (
+	MaxPasswordAttempts   = 5
+	PasswordAttemptWindow = 15 * time.Minute
+)
+
+// Limits of what guests and members write
+const (
+	MaxDisplayNameLength = 100
+	MaxCommentLength     = 5000
+)
+
+// ShareLink lets people without an account review an iteration
+type ShareLink struct {
+	ID          string `json:"id"`
+	IterationID string `json:"iterationId"`
+	// Token is the secret part of the link. It is only returned when the
+	// link is created since only a hash of it is stored.
+	Token             string     `json:"token,omitempty"`
+	PasswordProtected bool       `json:"passwordProtected"`
+	CreatedBy         string     `json:"createdBy"`
+	CreatedAt         string     `json:"createdAt"`
+	ExpiresAt         time.Time  `json:"expiresAt"`
+	RevokedAt         *time.Time `json:"revokedAt"`
+	// Active is false once the link has expired or was revoked
+	Active bool `json:"active"`
+
+	PasswordHash string `json:"-"`
+}
+
+// ShareLinkRequest creates a share link. Without expiresAt the link lasts
+// DefaultShareLinkLifetime, and guests must give the password if there is one.
+type ShareLinkRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt"`
+	Password  string     `json:"password"`
+}
+
+// Lifetime validates the request and returns how long the link lasts from now
+func (r *ShareLinkRequest) Lifetime(now time.Time) (time.Duration, error) {
+	if r.Password != "" && len(r.Password) < 6 {
+		return 0, &ValidationError{Field: "password", Message: "must be at least 6 characters"}
+	}
+	if r.ExpiresAt == nil {
+		return DefaultShareLinkLifetime, nil
+	}
+	lifetime := r.ExpiresAt.Sub(now)
+	if lifetime <= 0 {
+		return 0, &ValidationError{Field: "expiresAt", Message: "must be in the future"}
+	}
+	if lifetime > MaxShareLinkLifetime {
+		return 0, &ValidationError{Field: "expiresAt", Message: "must be at most 90 days from now"}
+	}
+	return lifetime, nil
+}
+
+// Actions recorded in the access log of share links
+const (
+	ShareAccessView    = "view"
+	ShareAccessSession = "session"
+	ShareAccessDenied  = "denied"
+	ShareAccessStream  = "stream"
+	ShareAccessComment = "comment"
+)
+
+// ShareAccess is an entry of the access log of a share link
+type ShareAccess struct {
+	ID          int64     `json:"id"`
+	ShareLinkID string    `json:"shareLinkId"`
+	Action      string    `json:"action"`
+	DisplayName string    `json:"displayName"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"userAgent"`
+	AccessedAt  time.Time `json:"accessedAt"`
+}
+
+// SharedIteration is what guests of a share link see of its iteration.
+// Guests without a session only see whether they need a password and when
+// the link expires.
+type SharedIteration struct {
+	IterationID      string    `json:"iterationId,omitempty"`
+	VideoTitle       string    `json:"videoTitle,omitempty"`
+	Length           string    `json:"length,omitempty"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	PasswordRequired bool      `json:"passwordRequired"`
+}
+
+// GuestSessionRequest starts a guest session on a share link
+type GuestSessionRequest struct {
+	DisplayName string `json:"displayName"`
+	Password    string `json:"password"`
+}
+
+// Validate checks the display name of a guest
+func (r *GuestSessionRequest) Validate() error {
+	r.DisplayName = strings.TrimSpace(r.DisplayName)
+	if r.DisplayName == "" {
+		return &ValidationError{Field: "displayName", Message: "is required"}
+	}
+	if len(r.DisplayName) > MaxDisplayNameLength {
+		return &ValidationError{Field: "displayName", Message: "must be at most 100 characters"}
+	}
+	return nil
+}
+
+// Comment is a note on an iteration at Timecode seconds, left by a member or
+// by the guest of a share link
+type Comment struct {
+	ID          string     `json:"id"`
+	IterationID string     `json:"iterationId"`
+	UserID      string     `json:"userId,omitempty"`
+	ShareLinkID string     `json:"shareLinkId,omitempty"`
+	AuthorName  string     `json:"authorName"`
+	Timecode    int        `json:"timecode"`
+	Body        string     `json:"body"`
+	CreatedAt   string     `json:"createdAt"`
+	ResolvedAt  *time.Time `json:"resolvedAt"`
+	ResolvedBy  string     `json:"resolvedBy,omitempty"`
+}
+
+// UnmarshalJSON accepts the timecode as a number of seconds or as a
+// timestamp like "1:23"
+func (c *Comment) UnmarshalJSON(data []byte) error {
+	type comment Comment
+	var temp struct {
+		comment
+		Timecode json.RawMessage `json:"timecode"`
+	}
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return err
+	}
+	*c = Comment(temp.comment)
+	if len(temp.Timecode) == 0 {
+		return nil
+	}
+
+	var timestamp string
+	if err := json.Unmarshal(temp.Timecode, &timestamp); err == nil {
+		seconds, ok := ParseTimestamp(timestamp)
+		if !ok {
+			return fmt.Errorf("invalid timecode %q", timestamp)
+		}
+		c.Timecode = seconds
+		return nil
+	}
+	return json.Unmarshal(temp.Timecode, &c.Timecode)
+}
+
+// Validate checks the body and timecode of a comment
+func (c *Comment) Validate() error {
+	c.Body = strings.TrimSpace(c.Body)
+	if c.Body == "" {
+		return &ValidationError{Field: "body", Message: "is required"}
+	}
+	if len(c.Body) > MaxCommentLength {
+		return &ValidationError{Field: "body", Message: "must be at most 5000 characters"}
+	}
+	if c.Timecode < 0 {
+		return &ValidationError{Field: "timecode", Message: "must not be negative"}
+	}
+	return nil
+}
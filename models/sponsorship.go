@@ -0,0 +1,162 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SponsorApprovalLifetime is how long approvers can use the link of an
+// approval request
+const SponsorApprovalLifetime = 14 * 24 * time.Hour
+
+// MaxSponsorApprovers is how many approvers a sponsorship can require
+const MaxSponsorApprovers = 10
+
+// Sponsorship marks a video as sponsored. Every approver must approve the
+// iteration and metadata that are published.
+type Sponsorship struct {
+	Sponsor        string   `json:"sponsor"`
+	ApproverEmails []string `json:"approverEmails"`
+	UpdatedAt      string   `json:"updatedAt"`
+}
+
+// Validate checks the sponsor and canonicalizes the approvers' email
+// addresses
+func (s *Sponsorship) Validate() error {
+	s.Sponsor = strings.TrimSpace(s.Sponsor)
+	if s.Sponsor == "" {
+		return &ValidationError{Field: "sponsor", Message: "is required"}
+	}
+	if len(s.Sponsor) > 100 {
+		return &ValidationError{Field: "sponsor", Message: "must be at most 100 characters"}
+	}
+
+	emails := []string{}
+	seen := map[string]bool{}
+	for _, email := range s.ApproverEmails {
+		email = strings.ToLower(strings.TrimSpace(email))
+		if !strings.Contains(email, "@") {
+			return &ValidationError{Field: "approverEmails", Message: "must be email addresses"}
+		}
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+	if len(emails) == 0 {
+		return &ValidationError{Field: "approverEmails", Message: "must have at least one approver"}
+	}
+	if len(emails) > MaxSponsorApprovers {
+		return &ValidationError{Field: "approverEmails", Message: "must have at most 10 approvers"}
+	}
+	sort.Strings(emails)
+	s.ApproverEmails = emails
+	return nil
+}
+
+// SponsorMetadata is the metadata of a video that approvers approve along
+// with an iteration
+type SponsorMetadata struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Keywords    []string  `json:"keywords"`
+	Chapters    []Chapter `json:"chapters"`
+}
+
+// NewSponsorMetadata returns the current metadata of a video
+func NewSponsorMetadata(video *Video) SponsorMetadata {
+	return SponsorMetadata{
+		Title:       video.Title,
+		Description: video.Description,
+		Keywords:    append([]string{}, video.Keywords...),
+		Chapters:    append([]Chapter{}, video.Chapters...),
+	}
+}
+
+// Equal tells whether two versions of the metadata are the same
+func (m SponsorMetadata) Equal(other SponsorMetadata) bool {
+	a, errA := json.Marshal(m)
+	b, errB := json.Marshal(other)
+	return errA == nil && errB == nil && string(a) == string(b)
+}
+
+// Statuses of sponsor approvals
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
+// SponsorApproval asks an approver to approve an iteration of a sponsored
+// video and the metadata it had when it was requested
+type SponsorApproval struct {
+	ID            string          `json:"id"`
+	VideoID       string          `json:"videoId"`
+	IterationID   string          `json:"iterationId"`
+	ApproverEmail string          `json:"approverEmail"`
+	Metadata      SponsorMetadata `json:"metadata"`
+	Status        string          `json:"status"`
+	Comment       string          `json:"comment"`
+	RequestedBy   string          `json:"requestedBy"`
+	RequestedAt   string          `json:"requestedAt"`
+	ExpiresAt     time.Time       `json:"expiresAt"`
+	DecidedAt     *time.Time      `json:"decidedAt"`
+}
+
+// ApprovalDecision is the answer of an approver
+type ApprovalDecision struct {
+	Comment string `json:"comment"`
+}
+
+// Validate checks the comment of a decision
+func (d *ApprovalDecision) Validate() error {
+	d.Comment = strings.TrimSpace(d.Comment)
+	if len(d.Comment) > MaxCommentLength {
+		return &ValidationError{Field: "comment", Message: "must be at most 5000 characters"}
+	}
+	return nil
+}
+
+// MissingApprovals returns the approvers of the sponsorship of a video that
+// haven't approved its latest iteration with its current metadata. approvals
+// are the approvals of the video, newest first, and only the latest decision
+// of each approver counts.
+func (v *Video) MissingApprovals(approvals []SponsorApproval) []string {
+	missing := []string{}
+	if v.Sponsorship == nil {
+		return missing
+	}
+
+	var iterationID string
+	if len(v.Iterations) > 0 {
+		iterationID = v.Iterations[len(v.Iterations)-1].ID
+	}
+	metadata := NewSponsorMetadata(v)
+
+	latest := map[string]SponsorApproval{}
+	for _, approval := range approvals {
+		if approval.Status == ApprovalPending {
+			continue
+		}
+		if _, ok := latest[approval.ApproverEmail]; !ok {
+			latest[approval.ApproverEmail] = approval
+		}
+	}
+	for _, email := range v.Sponsorship.ApproverEmails {
+		approval, ok := latest[email]
+		if !ok || approval.Status != ApprovalApproved || approval.IterationID != iterationID || !approval.Metadata.Equal(metadata) {
+			missing = append(missing, email)
+		}
+	}
+	return missing
+}
+
+// SponsorReview is what approvers see of the approval they are asked for
+type SponsorReview struct {
+	SponsorApproval
+	Sponsor string `json:"sponsor"`
+	// Length is the length of the iteration to approve
+	Length string `json:"length"`
+}
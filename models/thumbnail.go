@@ -0,0 +1,54 @@
+package models
+
+import (
+	"bytes"
+	"image"
+	_ "image/jpeg" // register the formats accepted by YouTube
+	_ "image/png"
+)
+
+// Thumbnail is a candidate thumbnail image of a video
+type Thumbnail struct {
+	ID          string `json:"id"`
+	VideoID     string `json:"videoId"`
+	ContentType string `json:"contentType"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int    `json:"size"`
+	Active      bool   `json:"active"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// YouTube custom thumbnail limits
+const (
+	MaxThumbnailBytes = 2 << 20
+	MinThumbnailWidth = 640
+)
+
+// InspectThumbnail checks that an image can be used as a YouTube thumbnail
+// and returns its metadata. YouTube accepts JPEG and PNG images of up to 2 MB
+// with a 16:9 aspect ratio and a width of at least 640 pixels.
+func InspectThumbnail(data []byte) (*Thumbnail, error) {
+	if len(data) > MaxThumbnailBytes {
+		return nil, &ValidationError{Field: "file", Message: "must be at most 2 MB"}
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, &ValidationError{Field: "file", Message: "must be a JPEG or PNG image"}
+	}
+	if config.Width < MinThumbnailWidth {
+		return nil, &ValidationError{Field: "file", Message: "must be at least 640 pixels wide"}
+	}
+	// Allow for rounding in the height, e.g. 1366x768
+	if diff := config.Width*9 - config.Height*16; diff < -16 || diff > 16 {
+		return nil, &ValidationError{Field: "file", Message: "must have a 16:9 aspect ratio"}
+	}
+
+	return &Thumbnail{
+		ContentType: "image/" + format,
+		Width:       config.Width,
+		Height:      config.Height,
+		Size:        len(data),
+	}, nil
+}
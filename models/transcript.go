@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+
+	"github.com/FuseWorkflows/fuse-go-server/captions"
+)
+
+// TranscriptSourceUpload marks transcripts uploaded as files. Transcripts
+// produced by a transcription provider carry the provider's name instead.
+const TranscriptSourceUpload = "upload"
+
+// MaxTranscriptBytes bounds the size of uploaded transcript files
+const MaxTranscriptBytes = 1 << 20
+
+// MaxPromptTranscriptLength is how much of a transcript is sent to AI
+// providers, in characters
+const MaxPromptTranscriptLength = 50000
+
+// Transcript is the text spoken in an iteration. Transcripts made from timed
+// cues keep a [HH:MM:SS] timecode at the start of every line.
+type Transcript struct {
+	IterationID string `json:"iterationId"`
+	Language    string `json:"language"`
+	Source      string `json:"source"`
+	Text        string `json:"text"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+// Validate checks the transcript and canonicalizes its language tag, which
+// is optional
+func (t *Transcript) Validate() error {
+	if t.Language != "" {
+		tag, err := language.Parse(t.Language)
+		if err != nil {
+			return &ValidationError{Field: "language", Message: "must be a BCP-47 language tag"}
+		}
+		t.Language = tag.String()
+	}
+	if strings.TrimSpace(t.Text) == "" {
+		return &ValidationError{Field: "text", Message: "must not be empty"}
+	}
+	return nil
+}
+
+// PromptText returns the transcript as given to AI providers, cut to
+// MaxPromptTranscriptLength characters
+func (t *Transcript) PromptText() string {
+	if utf8.RuneCountInString(t.Text) <= MaxPromptTranscriptLength {
+		return t.Text
+	}
+	return string([]rune(t.Text)[:MaxPromptTranscriptLength])
+}
+
+// TranscriptText renders cues as timecoded lines of text
+func TranscriptText(cues []captions.Cue) string {
+	var b strings.Builder
+	for _, cue := range cues {
+		seconds := int(cue.Start / time.Second)
+		text := strings.Join(strings.Fields(cue.Text), " ")
+		fmt.Fprintf(&b, "[%02d:%02d:%02d] %s\n", seconds/3600, seconds/60%60, seconds%60, text)
+	}
+	return b.String()
+}
+
+// ParseTranscriptFile reads an uploaded transcript. SRT and WebVTT files are
+// turned into timecoded text and plain text is kept as it is. format is
+// "srt", "vtt" or "txt".
+func ParseTranscriptFile(data []byte, format string) (string, error) {
+	if len(data) > MaxTranscriptBytes {
+		return "", &ValidationError{Field: "file", Message: "must be at most 1 MB"}
+	}
+	if !utf8.Valid(data) {
+		return "", &ValidationError{Field: "file", Message: "must be UTF-8 text"}
+	}
+	if format == "txt" {
+		return strings.TrimPrefix(string(data), "\uFEFF"), nil
+	}
+
+	captionFormat, err := captions.ParseFormat(format)
+	if err != nil {
+		return "", &ValidationError{Field: "format", Message: "must be srt, vtt or txt"}
+	}
+	cues, err := captions.Parse(data, captionFormat)
+	if err != nil {
+		return "", &ValidationError{Field: "file", Message: err.Error()}
+	}
+	return TranscriptText(cues), nil
+}
@@ -0,0 +1,12 @@
+package models
+
+// TrashItem is a deleted channel, video or iteration that can still be restored
+type TrashItem struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	ChannelID string `json:"channelId"`
+	VideoID   string `json:"videoId,omitempty"`
+	DeletedAt string `json:"deletedAt"`
+	PurgeAt   string `json:"purgeAt"`
+}
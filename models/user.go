@@ -3,18 +3,27 @@ package models
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 )
 
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	CreatedAt string    `json:"createdAt"`
-	UpdatedAt string    `json:"updatedAt"`
-	Tier      Tier      `json:"tier"`
-	Trial     bool      `json:"trial"`
-	Channels  []Channel `json:"channels"`
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+	Tier      Tier   `json:"tier"`
+	Trial     bool   `json:"trial"`
+	// TrialStartedAt and TrialEndsAt are set once the user started a trial.
+	// Trials are managed by the server and can't be changed through updates.
+	TrialStartedAt *time.Time `json:"trialStartedAt,omitempty"`
+	TrialEndsAt    *time.Time `json:"trialEndsAt,omitempty"`
+	// Admin users can manage other accounts. It can only be set in the database.
+	Admin bool `json:"admin"`
+	// AIProvider is the AI provider used for the user's channels that don't choose one
+	AIProvider string    `json:"aiProvider"`
+	Channels   []Channel `json:"channels"`
 	// Editors []
 }
 
@@ -46,15 +55,16 @@ func (u *User) UnmarshalJSON(data []byte) error {
 func (u *User) Bind(r *http.Request) error {
 	// Decode the JSON body into a temporary struct that includes Channels
 	type UserWithChannels struct {
-		ID        string    `json:"id"`
-		Username  string    `json:"username"`
-		Email     string    `json:"email"`
-		Password  string    `json:"password"`
-		CreatedAt string    `json:"createdAt"`
-		UpdatedAt string    `json:"updatedAt"`
-		Tier      Tier      `json:"tier"`
-		Trial     bool      `json:"trial"`
-		Channels  []Channel `json:"channels"`
+		ID         string    `json:"id"`
+		Username   string    `json:"username"`
+		Email      string    `json:"email"`
+		Password   string    `json:"password"`
+		CreatedAt  string    `json:"createdAt"`
+		UpdatedAt  string    `json:"updatedAt"`
+		Tier       Tier      `json:"tier"`
+		Trial      bool      `json:"trial"`
+		AIProvider string    `json:"aiProvider"`
+		Channels   []Channel `json:"channels"`
 	}
 
 	var temp UserWithChannels
@@ -71,6 +81,7 @@ func (u *User) Bind(r *http.Request) error {
 	u.UpdatedAt = temp.UpdatedAt
 	u.Tier = temp.Tier
 	u.Trial = temp.Trial
+	u.AIProvider = temp.AIProvider
 	u.Channels = temp.Channels
 
 	return nil
@@ -0,0 +1,11 @@
+package models
+
+// ValidationError reports a field value that is not acceptable for a resource
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
@@ -2,6 +2,9 @@ package models
 
 import (
 	"encoding/json"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
 )
 
 type Video struct {
@@ -13,11 +16,38 @@ type Video struct {
 	Description   string      `json:"description"`
 	Keywords      []string    `json:"keywords"`
 	Category      string      `json:"category"`
-	PrivacyStatus bool        `json:"privacyStatus"`
-	Channel       Channel     `json:"channel"`
-	Editors       []Editor    `json:"editors"`
-	CreatedAt     string      `json:"createdAt"`
-	UpdatedAt     string      `json:"updatedAt"`
+	PrivacyStatus Privacy     `json:"privacyStatus"`
+	MadeForKids   bool        `json:"madeForKids"`
+	// DefaultLanguage is the BCP-47 tag of the language of the title and description
+	DefaultLanguage string  `json:"defaultLanguage"`
+	License         License `json:"license"`
+	Embeddable      bool    `json:"embeddable"`
+	// YouTubeID is set once the video has been uploaded
+	YouTubeID string    `json:"youtubeId"`
+	Channel   Channel   `json:"channel"`
+	Editors   []Editor  `json:"editors"`
+	Chapters  []Chapter `json:"chapters"`
+	// Localizations hold the title and description in other languages
+	Localizations []Localization `json:"localizations"`
+	// Sponsorship is set on sponsored videos, which need the approval of the
+	// sponsor before they are published
+	Sponsorship *Sponsorship `json:"sponsorship"`
+	// AIFields lists the fields whose current value was accepted from an AI suggestion
+	AIFields  []string `json:"aiFields"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+	Version   int      `json:"version"`
+}
+
+// NewVideo returns a video with the defaults applied to fields missing from a
+// create request
+func NewVideo() *Video {
+	return &Video{
+		Status:        Draft,
+		PrivacyStatus: Private,
+		License:       YouTubeLicense,
+		Embeddable:    true,
+	}
 }
 
 func (v *Video) MarshalJSON() ([]byte, error) {
@@ -55,3 +85,86 @@ const (
 	Published Status = "published"
 	Draft     Status = "draft"
 )
+
+// VideoFields are the fields of a video that can be changed through a patch
+type VideoFields struct {
+	Status          Status   `json:"status"`
+	Resources       string   `json:"resources"`
+	Title           string   `json:"title"`
+	Description     string   `json:"description"`
+	Keywords        []string `json:"keywords"`
+	Category        string   `json:"category"`
+	PrivacyStatus   Privacy  `json:"privacyStatus"`
+	MadeForKids     bool     `json:"madeForKids"`
+	DefaultLanguage string   `json:"defaultLanguage"`
+	License         License  `json:"license"`
+	Embeddable      bool     `json:"embeddable"`
+	EditorIDs       []string `json:"editorIds"`
+}
+
+// Fields returns the current values of the patchable fields of the video
+func (v *Video) Fields() VideoFields {
+	fields := VideoFields{
+		Status:          v.Status,
+		Resources:       v.Resources,
+		Title:           v.Title,
+		Description:     v.Description,
+		Keywords:        append([]string{}, v.Keywords...),
+		Category:        v.Category,
+		PrivacyStatus:   v.PrivacyStatus,
+		MadeForKids:     v.MadeForKids,
+		DefaultLanguage: v.DefaultLanguage,
+		License:         v.License,
+		Embeddable:      v.Embeddable,
+		EditorIDs:       []string{},
+	}
+	for _, editor := range v.Editors {
+		fields.EditorIDs = append(fields.EditorIDs, editor.ID)
+	}
+	return fields
+}
+
+// Validate checks the fields before they are written. The metadata rules are
+// the ones YouTube enforces on upload.
+func (f *VideoFields) Validate() error {
+	switch f.Status {
+	case Pending, Published, Draft:
+	default:
+		return &ValidationError{Field: "status", Message: "must be one of pending, published or draft"}
+	}
+	if err := validateTitle(f.Title); err != nil {
+		return err
+	}
+	if err := validateDescription(f.Description); err != nil {
+		return err
+	}
+	if err := validateTags(f.Keywords); err != nil {
+		return err
+	}
+	if f.Category != "" && !IsVideoCategory(f.Category) {
+		return &ValidationError{Field: "category", Message: "must be an assignable YouTube category ID"}
+	}
+	switch f.PrivacyStatus {
+	case Private, Unlisted, Public:
+	default:
+		return &ValidationError{Field: "privacyStatus", Message: "must be one of private, unlisted or public"}
+	}
+	if f.DefaultLanguage != "" {
+		tag, err := language.Parse(f.DefaultLanguage)
+		if err != nil {
+			return &ValidationError{Field: "defaultLanguage", Message: "must be a BCP-47 language tag"}
+		}
+		f.DefaultLanguage = tag.String()
+	}
+	switch f.License {
+	case YouTubeLicense, CreativeCommonLicense:
+	default:
+		return &ValidationError{Field: "license", Message: "must be one of youtube or creativeCommon"}
+	}
+	for _, id := range f.EditorIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return &ValidationError{Field: "editorIds", Message: "must contain valid editor IDs"}
+		}
+	}
+	return nil
+}
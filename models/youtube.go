@@ -0,0 +1,117 @@
+package models
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Privacy is the YouTube privacy status of a video
+type Privacy string
+
+const (
+	Private  Privacy = "private"
+	Unlisted Privacy = "unlisted"
+	Public   Privacy = "public"
+)
+
+// License is the YouTube license of a video
+type License string
+
+const (
+	YouTubeLicense        License = "youtube"
+	CreativeCommonLicense License = "creativeCommon"
+)
+
+// VideoCategory is a YouTube video category
+type VideoCategory struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// VideoCategories lists the categories YouTube lets uploads be assigned to
+var VideoCategories = []VideoCategory{
+	{"1", "Film & Animation"},
+	{"2", "Autos & Vehicles"},
+	{"10", "Music"},
+	{"15", "Pets & Animals"},
+	{"17", "Sports"},
+	{"19", "Travel & Events"},
+	{"20", "Gaming"},
+	{"22", "People & Blogs"},
+	{"23", "Comedy"},
+	{"24", "Entertainment"},
+	{"25", "News & Politics"},
+	{"26", "Howto & Style"},
+	{"27", "Education"},
+	{"28", "Science & Technology"},
+	{"29", "Nonprofits & Activism"},
+}
+
+// IsVideoCategory reports whether id is an assignable category ID
+func IsVideoCategory(id string) bool {
+	for _, category := range VideoCategories {
+		if category.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// YouTube metadata limits
+const (
+	MaxTitleLength       = 100
+	MaxDescriptionBytes  = 5000
+	MaxTagsLength        = 500
+	forbiddenMetadataSet = "<>"
+)
+
+// TagsLength returns the length YouTube counts against the limit on tags.
+// Tags are joined with commas and those containing spaces are quoted.
+func TagsLength(tags []string) int {
+	length := 0
+	for i, tag := range tags {
+		if i > 0 {
+			length++
+		}
+		length += utf8.RuneCountInString(tag)
+		if strings.Contains(tag, " ") {
+			length += 2
+		}
+	}
+	return length
+}
+
+func validateTitle(title string) error {
+	if utf8.RuneCountInString(title) > MaxTitleLength {
+		return &ValidationError{Field: "title", Message: "must be at most 100 characters"}
+	}
+	if strings.ContainsAny(title, forbiddenMetadataSet) {
+		return &ValidationError{Field: "title", Message: "must not contain < or >"}
+	}
+	return nil
+}
+
+func validateDescription(description string) error {
+	if len(description) > MaxDescriptionBytes {
+		return &ValidationError{Field: "description", Message: "must be at most 5000 bytes"}
+	}
+	if strings.ContainsAny(description, forbiddenMetadataSet) {
+		return &ValidationError{Field: "description", Message: "must not contain < or >"}
+	}
+	return nil
+}
+
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			return &ValidationError{Field: "keywords", Message: "must not contain empty tags"}
+		}
+		if strings.ContainsAny(tag, forbiddenMetadataSet+",") {
+			return &ValidationError{Field: "keywords", Message: "must not contain <, > or commas"}
+		}
+	}
+	if TagsLength(tags) > MaxTagsLength {
+		return &ValidationError{Field: "keywords", Message: "must be at most 500 characters in total"}
+	}
+	return nil
+}
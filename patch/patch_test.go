@@ -0,0 +1,221 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// equalJSON reports whether two JSON documents have the same value
+func equalJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("error decoding %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("error decoding %s: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"replaces members", `{"title": "Old", "status": "draft"}`, `{"title": "New"}`, `{"title": "New", "status": "draft"}`},
+		{"null removes members", `{"title": "Old", "description": "Text"}`, `{"description": null}`, `{"title": "Old"}`},
+		{"merges objects", `{"a": {"b": 1, "c": 2}}`, `{"a": {"c": 3, "d": 4}}`, `{"a": {"b": 1, "c": 3, "d": 4}}`},
+		{"replaces arrays", `{"keywords": ["a", "b"]}`, `{"keywords": ["c"]}`, `{"keywords": ["c"]}`},
+		{"adds objects", `{}`, `{"a": {"b": null, "c": 1}}`, `{"a": {"c": 1}}`},
+		{"replaces non objects", `{"a": 1}`, `["a"]`, `["a"]`},
+		{"ignores missing members", `{"a": 1}`, `{"b": null}`, `{"a": 1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("MergePatch: %v", err)
+			}
+			if !equalJSON(t, got, []byte(tt.want)) {
+				t.Errorf("MergePatch = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := MergePatch([]byte(`{}`), []byte(`{`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("invalid patch got %v", err)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "unescapes pointers",
+			doc:   `{"a/b": 1, "m~n": 2}`,
+			patch: `[{"op": "replace", "path": "/a~1b", "value": 3}, {"op": "replace", "path": "/m~0n", "value": 4}]`,
+			want:  `{"a/b": 3, "m~n": 4}`,
+		},
+		{
+			name:  "unescapes ~01 as ~1",
+			doc:   `{"~1": 1}`,
+			patch: `[{"op": "remove", "path": "/~01"}]`,
+			want:  `{}`,
+		},
+		{
+			name:  "appends with -",
+			doc:   `{"keywords": ["a"]}`,
+			patch: `[{"op": "add", "path": "/keywords/-", "value": "b"}]`,
+			want:  `{"keywords": ["a", "b"]}`,
+		},
+		{
+			name:  "inserts at an index",
+			doc:   `{"keywords": ["a", "c"]}`,
+			patch: `[{"op": "add", "path": "/keywords/1", "value": "b"}]`,
+			want:  `{"keywords": ["a", "b", "c"]}`,
+		},
+		{
+			name:    "- is only for adding",
+			doc:     `{"keywords": ["a"]}`,
+			patch:   `[{"op": "replace", "path": "/keywords/-", "value": "b"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "rejects leading zeros",
+			doc:     `{"keywords": ["a", "b"]}`,
+			patch:   `[{"op": "remove", "path": "/keywords/01"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "rejects indexes past the end",
+			doc:     `{"keywords": ["a"]}`,
+			patch:   `[{"op": "add", "path": "/keywords/2", "value": "b"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:  "moves values",
+			doc:   `{"a": {"b": 1}, "c": {}}`,
+			patch: `[{"op": "move", "from": "/a/b", "path": "/c/b"}]`,
+			want:  `{"a": {}, "c": {"b": 1}}`,
+		},
+		{
+			name:    "can't move a value into itself",
+			doc:     `{"a": {"b": {}}}`,
+			patch:   `[{"op": "move", "from": "/a", "path": "/a/b/c"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:  "moves a value next to itself",
+			doc:   `{"a": 1}`,
+			patch: `[{"op": "move", "from": "/a", "path": "/ab"}]`,
+			want:  `{"ab": 1}`,
+		},
+		{
+			name:  "copies are independent",
+			doc:   `{"a": {"b": 1}}`,
+			patch: `[{"op": "copy", "from": "/a", "path": "/c"}, {"op": "replace", "path": "/c/b", "value": 2}]`,
+			want:  `{"a": {"b": 1}, "c": {"b": 2}}`,
+		},
+		{
+			name:  "tests null",
+			doc:   `{"a": null}`,
+			patch: `[{"op": "test", "path": "/a", "value": null}, {"op": "replace", "path": "/a", "value": 1}]`,
+			want:  `{"a": 1}`,
+		},
+		{
+			name:    "null doesn't match a value",
+			doc:     `{"a": 0}`,
+			patch:   `[{"op": "test", "path": "/a", "value": null}]`,
+			wantErr: ErrTestFailed,
+		},
+		{
+			name:    "failed tests undo the patch",
+			doc:     `{"a": 1}`,
+			patch:   `[{"op": "replace", "path": "/a", "value": 2}, {"op": "test", "path": "/a", "value": 1}]`,
+			wantErr: ErrTestFailed,
+		},
+		{
+			name:    "replace needs an existing value",
+			doc:     `{}`,
+			patch:   `[{"op": "replace", "path": "/a", "value": 1}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "add needs a value",
+			doc:     `{}`,
+			patch:   `[{"op": "add", "path": "/a"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "pointers start with a slash",
+			doc:     `{"a": 1}`,
+			patch:   `[{"op": "remove", "path": "a"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "unknown operations",
+			doc:     `{}`,
+			patch:   `[{"op": "merge", "path": "/a", "value": 1}]`,
+			wantErr: ErrInvalidPatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("JSONPatch = %s, %v, want %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("JSONPatch: %v", err)
+			}
+			if !equalJSON(t, got, []byte(tt.want)) {
+				t.Errorf("JSONPatch = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		contentType string
+		patch       string
+		want        string
+		wantErr     error
+	}{
+		{"", `{"a": 2}`, `{"a": 2}`, nil},
+		{"application/json; charset=utf-8", `{"a": 2}`, `{"a": 2}`, nil},
+		{MergePatchType, `{"a": null}`, `{}`, nil},
+		{JSONPatchType, `[{"op": "remove", "path": "/a"}]`, `{}`, nil},
+		{"text/plain", `{"a": 2}`, "", ErrUnsupportedMediaType},
+		{"not a media type;", `{"a": 2}`, "", ErrUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		got, err := Apply(tt.contentType, []byte(`{"a": 1}`), []byte(tt.patch))
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Apply(%q) = %s, %v, want %v", tt.contentType, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Apply(%q): %v", tt.contentType, err)
+			continue
+		}
+		if !equalJSON(t, got, []byte(tt.want)) {
+			t.Errorf("Apply(%q) = %s, want %s", tt.contentType, got, tt.want)
+		}
+	}
+}
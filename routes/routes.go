@@ -3,16 +3,24 @@ package routes
 import (
 	"github.com/go-chi/chi/v5"
 
+	"github.com/FuseWorkflows/fuse-go-server/ai"
+	"github.com/FuseWorkflows/fuse-go-server/billing"
 	"github.com/FuseWorkflows/fuse-go-server/config"
 	"github.com/FuseWorkflows/fuse-go-server/database"
 	"github.com/FuseWorkflows/fuse-go-server/handlers"
+	"github.com/FuseWorkflows/fuse-go-server/mail"
+	"github.com/FuseWorkflows/fuse-go-server/middleware"
+	"github.com/FuseWorkflows/fuse-go-server/models"
 )
 
 func InitRoutes(r *chi.Mux, db *database.DB, cfg *config.Config) {
+	providers := ai.NewRegistry(cfg)
+	billingClient := billing.NewClient(cfg)
+	mailer := mail.NewSender(cfg)
 
 	// Authentication routes
 	r.Route("/auth", func(r chi.Router) {
-		r.Post("/signup", handlers.SignupHandler(db))
+		r.Post("/signup", handlers.SignupHandler(db, cfg))
 		r.Post("/login", handlers.LoginHandler(db, cfg))
 	})
 
@@ -24,6 +32,28 @@ func InitRoutes(r *chi.Mux, db *database.DB, cfg *config.Config) {
 		r.Delete("/{userID}", handlers.DeleteUserHandler(db))
 	})
 
+	// Organization routes
+	r.Route("/organizations", func(r chi.Router) {
+		r.Get("/", handlers.GetOrganizationsHandler(db))
+		r.Post("/", handlers.CreateOrganizationHandler(db))
+		r.Get("/{organizationID}", handlers.GetOrganizationHandler(db))
+		r.Patch("/{organizationID}", handlers.UpdateOrganizationHandler(db))
+		r.Delete("/{organizationID}", handlers.DeleteOrganizationHandler(db))
+		r.Get("/{organizationID}/members", handlers.GetMembersHandler(db))
+		r.Patch("/{organizationID}/members/{userID}", handlers.UpdateMemberHandler(db))
+		r.Delete("/{organizationID}/members/{userID}", handlers.RemoveMemberHandler(db))
+		r.Get("/{organizationID}/invitations", handlers.GetInvitationsHandler(db))
+		r.Post("/{organizationID}/invitations", handlers.CreateInvitationHandler(db, mailer, cfg))
+		r.Delete("/{organizationID}/invitations/{invitationID}", handlers.DeleteInvitationHandler(db))
+		r.Get("/{organizationID}/roles", handlers.GetRolesHandler(db))
+		r.Post("/{organizationID}/roles", handlers.CreateRoleHandler(db))
+		r.Patch("/{organizationID}/roles/{role}", handlers.UpdateRoleHandler(db))
+		r.Delete("/{organizationID}/roles/{role}", handlers.DeleteRoleHandler(db))
+	})
+
+	// Effective permissions of a user on a resource
+	r.Get("/permissions/{resourceType}/{resourceID}", handlers.GetEffectivePermissionsHandler(db))
+
 	// Channel routes
 	r.Route("/channels", func(r chi.Router) {
 		r.Get("/", handlers.GetChannelHandler(db))
@@ -31,16 +61,51 @@ func InitRoutes(r *chi.Mux, db *database.DB, cfg *config.Config) {
 		r.Get("/{channelID}", handlers.GetChannelByIDHandler(db))
 		r.Patch("/{channelID}", handlers.UpdateChannelHandler(db))
 		r.Delete("/{channelID}", handlers.DeleteChannelHandler(db))
+		r.Post("/{channelID}/restore", handlers.RestoreChannelHandler(db))
+		r.Post("/{channelID}/transfer", handlers.TransferChannelHandler(db))
+		r.Get("/{channelID}/activity", handlers.GetChannelActivityHandler(db))
 	})
 
 	// Video routes
 	r.Route("/videos", func(r chi.Router) {
 		r.Get("/", handlers.GetVideoHandler(db))
 		r.Post("/", handlers.CreateVideoHandler(db))
+		r.Get("/categories", handlers.GetVideoCategoriesHandler())
 		r.Get("/{videoID}", handlers.GetVideoByIDHandler(db))
 		r.Patch("/{videoID}", handlers.UpdateVideoHandler(db))
 		r.Delete("/{videoID}", handlers.DeleteVideoHandler(db))
+		r.Post("/{videoID}/restore", handlers.RestoreVideoHandler(db))
 		r.Post("/{videoID}/upload", handlers.UploadVideoHandler(db, cfg))
+		r.Get("/{videoID}/activity", handlers.GetVideoActivityHandler(db))
+		r.Get("/{videoID}/chapters", handlers.GetChaptersHandler(db))
+		r.Put("/{videoID}/chapters", handlers.ReplaceChaptersHandler(db))
+		r.Delete("/{videoID}/chapters", handlers.DeleteChaptersHandler(db))
+		r.Get("/{videoID}/localizations", handlers.GetLocalizationsHandler(db))
+		r.Post("/{videoID}/localizations/translate", handlers.TranslateLocalizationsHandler(db, providers))
+		r.Put("/{videoID}/localizations/{language}", handlers.PutLocalizationHandler(db))
+		r.Post("/{videoID}/localizations/{language}/approve", handlers.ApproveLocalizationHandler(db))
+		r.Delete("/{videoID}/localizations/{language}", handlers.DeleteLocalizationHandler(db))
+		r.Get("/{videoID}/sponsorship", handlers.GetSponsorshipHandler(db))
+		r.Put("/{videoID}/sponsorship", handlers.PutSponsorshipHandler(db))
+		r.Delete("/{videoID}/sponsorship", handlers.DeleteSponsorshipHandler(db))
+		r.Get("/{videoID}/approvals", handlers.GetSponsorApprovalsHandler(db))
+		r.Post("/{videoID}/approvals", handlers.RequestSponsorApprovalsHandler(db, mailer, cfg))
+		r.Get("/{videoID}/suggestions", handlers.GetAISuggestionRunsHandler(db))
+		r.Post("/{videoID}/suggestions", handlers.CreateAISuggestionRunHandler(db, providers))
+		r.Get("/{videoID}/suggestions/{runID}", handlers.GetAISuggestionRunHandler(db))
+		r.Post("/{videoID}/suggestions/{runID}/accept", handlers.AcceptAISuggestionHandler(db))
+		r.Post("/{videoID}/suggestions/{runID}/reject", handlers.RejectAISuggestionHandler(db))
+		r.Post("/{videoID}/suggestions/{runID}/regenerate", handlers.RegenerateAISuggestionHandler(db, providers))
+		r.Get("/{videoID}/thumbnails", handlers.GetThumbnailsHandler(db))
+		r.Post("/{videoID}/thumbnails", handlers.UploadThumbnailHandler(db))
+		r.Get("/{videoID}/thumbnails/{thumbnailID}", handlers.GetThumbnailImageHandler(db))
+		r.Post("/{videoID}/thumbnails/{thumbnailID}/activate", handlers.ActivateThumbnailHandler(db, cfg))
+		r.Delete("/{videoID}/thumbnails/{thumbnailID}", handlers.DeleteThumbnailHandler(db))
+		r.Get("/{videoID}/captions", handlers.GetCaptionsHandler(db))
+		r.Post("/{videoID}/captions", handlers.UploadCaptionHandler(db, cfg))
+		r.Get("/{videoID}/captions/{captionID}", handlers.GetCaptionFileHandler(db))
+		r.Post("/{videoID}/captions/{captionID}/offset", handlers.OffsetCaptionHandler(db, cfg))
+		r.Delete("/{videoID}/captions/{captionID}", handlers.DeleteCaptionHandler(db, cfg))
 	})
 
 	// Iteration routes
@@ -50,20 +115,83 @@ func InitRoutes(r *chi.Mux, db *database.DB, cfg *config.Config) {
 		r.Get("/{iterationID}", handlers.GetIterationByIDHandler(db))
 		r.Patch("/{iterationID}", handlers.UpdateIterationHandler(db))
 		r.Delete("/{iterationID}", handlers.DeleteIterationHandler(db))
+		r.Post("/{iterationID}/restore", handlers.RestoreIterationHandler(db))
 		r.Post("/{iterationID}/notes", handlers.AddNoteToIterationHandler(db))
+		r.Get("/{iterationID}/transcript", handlers.GetTranscriptHandler(db))
+		r.Put("/{iterationID}/transcript", handlers.UploadTranscriptHandler(db))
+		r.Post("/{iterationID}/transcript/transcribe", handlers.TranscribeIterationHandler(db, providers))
+		r.Delete("/{iterationID}/transcript", handlers.DeleteTranscriptHandler(db))
+		r.Get("/{iterationID}/share-links", handlers.GetShareLinksHandler(db))
+		r.Post("/{iterationID}/share-links", handlers.CreateShareLinkHandler(db))
+		r.Delete("/{iterationID}/share-links/{linkID}", handlers.RevokeShareLinkHandler(db))
+		r.Get("/{iterationID}/share-links/{linkID}/accesses", handlers.GetShareAccessesHandler(db))
+		r.Get("/{iterationID}/comments", handlers.GetCommentsHandler(db))
+		r.Post("/{iterationID}/comments", handlers.CreateCommentHandler(db))
+		r.Post("/{iterationID}/comments/{commentID}/resolve", handlers.ResolveCommentHandler(db, true))
+		r.Post("/{iterationID}/comments/{commentID}/reopen", handlers.ResolveCommentHandler(db, false))
+	})
+
+	// Share link routes for guests, who use a guest session instead of a login
+	r.Route("/share/{token}", func(r chi.Router) {
+		r.Get("/", handlers.GetSharedIterationHandler(db, cfg))
+		r.Post("/session", handlers.CreateGuestSessionHandler(db, cfg))
+		r.Get("/media", handlers.StreamSharedMediaHandler(db, cfg))
+		r.Get("/comments", handlers.GetSharedCommentsHandler(db, cfg))
+		r.Post("/comments", handlers.CreateSharedCommentHandler(db, cfg))
+	})
+
+	// Sponsor approval routes, authenticated by the signed token of the link
+	r.Route("/approvals/{token}", func(r chi.Router) {
+		r.Get("/", handlers.GetSponsorReviewHandler(db, cfg))
+		r.Get("/media", handlers.StreamSponsorReviewHandler(db, cfg))
+		r.Post("/approve", handlers.DecideSponsorApprovalHandler(db, cfg, models.ApprovalApproved))
+		r.Post("/reject", handlers.DecideSponsorApprovalHandler(db, cfg, models.ApprovalRejected))
 	})
 
 	// Editor routes
 	r.Route("/editors", func(r chi.Router) {
 		r.Get("/", handlers.GetEditorHandler(db))
-		r.Post("/", handlers.CreateEditorHandler(db))
+		r.Post("/", handlers.CreateEditorHandler(db, cfg))
 		r.Get("/{editorID}", handlers.GetEditorByIDHandler(db))
 		r.Patch("/{editorID}", handlers.UpdateEditorHandler(db))
 		r.Delete("/{editorID}", handlers.DeleteEditorHandler(db))
 	})
 
+	// Trash routes
+	r.Get("/trash", handlers.GetTrashHandler(db, cfg))
+
+	// Routes of the authenticated user
+	r.Route("/me", func(r chi.Router) {
+		r.Get("/usage", handlers.GetUsageHandler(db))
+		r.Get("/notifications", handlers.GetNotificationsHandler(db))
+		r.Post("/notifications/{notificationID}/read", handlers.MarkNotificationReadHandler(db))
+		r.Get("/invitations", handlers.GetMyInvitationsHandler(db))
+		r.Post("/invitations/accept", handlers.AcceptInvitationHandler(db))
+	})
+
+	// Billing routes. The webhook is authenticated by its signature.
+	r.Route("/billing", func(r chi.Router) {
+		r.Post("/checkout", handlers.CreateCheckoutSessionHandler(db, billingClient))
+		r.Get("/subscriptions", handlers.GetSubscriptionsHandler(db))
+		r.Post("/webhook", handlers.BillingWebhookHandler(db, billingClient))
+	})
+
+	// Admin routes
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(middleware.RequireAdmin)
+		r.Post("/users/{userID}/trial/extend", handlers.ExtendUserTrialHandler(db))
+		r.Post("/editors/{editorID}/trial/extend", handlers.ExtendEditorTrialHandler(db))
+	})
+
+	// Search routes
+	r.Get("/search", handlers.SearchHandler(db))
+
+	// Audit log routes
+	r.Get("/audit", handlers.GetAuditLogHandler(db))
+
 	// AI routes
 	r.Route("/ai", func(r chi.Router) {
-		r.Post("/suggestions", handlers.GetAISuggestionsHandler(cfg))
+		r.Post("/suggestions", handlers.GetAISuggestionsHandler(db, providers))
+		r.Post("/suggestions/stream", handlers.StreamAISuggestionsHandler(db, providers))
 	})
 }
@@ -1,93 +1,160 @@
 package utils
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/FuseWorkflows/fuse-go-server/config"
 	"github.com/FuseWorkflows/fuse-go-server/models"
-	// Import the option package
 )
 
-// UploadVideoToYouTube uploads a video to YouTube
-func UploadVideoToYouTube(videoURL, apiKey string, video *models.Video) error {
-	// // Create a new YouTube service
-	// ctx := context.Background()
-	// service, err := youtube.NewService(ctx, option.WithAPIKey(apiKey)) // Use WithAPIKey
-	// if err != nil {
-	// 	return fmt.Errorf("error creating YouTube service: %w", err)
-	// }
-
-	// // Create a new video upload request
-	// upload := &youtube.Video{
-	// 	Snippet: &youtube.VideoSnippet{
-	// 		Title:       video.Title,
-	// 		Description: video.Description,
-	// 		Tags:        video.Keywords,
-	// 		CategoryId:  video.Category,
-	// 	},
-	// 	Status: &youtube.VideoStatus{
-	// 		PrivacyStatus: "public", // Use video.PrivacyStatus to set correct value
-	// 	},
-	// }
-
-	// // Get the last iteration
-	// lastIteration := video.Iterations[len(video.Iterations)-1]
-
-	// // Upload the video to YouTube
-	// // This code assumes you are uploading the video from a URL
-	// // If you are uploading the video from a local file, you'll need to adjust this code accordingly
-	// call := service.Videos.Insert("snippet,status", upload)
-	// call.Media(videoURL, "video/mp4") // Assumes video is in MP4 format
-
-	// response, err := call.Do()
-	// if err != nil {
-	// 	return fmt.Errorf("error uploading video to YouTube: %w", err)
-	// }
-	// defer response.Body.Close()
-
-	// // Handle potential errors
-	// if response.StatusCode != http.StatusOK {
-	// 	// Handle error codes from YouTube API
-	// 	var apiError googleapi.Error
-	// 	if err := json.NewDecoder(response.Body).Decode(&apiError); err != nil {
-	// 		return fmt.Errorf("error decoding YouTube API error: %w", err)
-	// 	}
-	// 	return fmt.Errorf("YouTube API error: %s", apiError.Message)
-	// }
-
-	// // Extract the video ID from the response
-	// videoID := response.Data["id"].(string)
-
-	// // Update the video in the database with the YouTube video ID
-	// video.ID = videoID                    // Replace with your own logic to update video ID
-	// _, err = updateVideo(video.ID, video) // Replace with your own updateVideo function
-	// if err != nil {
-	// 	return fmt.Errorf("error updating video in database: %w", err)
-	// }
-
-	// // Optionally: fetch the video from YouTube to update metadata from the API
-	// // This is optional but can be useful for updating the video metadata in your database
-	// // You can fetch the video using the video ID you just got:
-	// // fetchedVideo, err := service.Videos.List("snippet,statistics,contentDetails").Id(videoID).Do()
-	// // Handle errors and use the fetched data from fetchedVideo
-
-	// return nil
+// youtubeService creates a YouTube Data API client for a channel
+func youtubeService(ctx context.Context, cfg *config.Config, apiKey string) (*youtube.Service, error) {
+	opts := []option.ClientOption{option.WithAPIKey(apiKey)}
+	if cfg.YouTubeEndpoint != "" {
+		opts = append(opts, option.WithEndpoint(cfg.YouTubeEndpoint))
+	}
+	service, err := youtube.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating YouTube service: %w", err)
+	}
+	return service, nil
+}
+
+// UploadVideoToYouTube uploads a video to YouTube, streaming the media from
+// videoURL, and returns the ID YouTube assigned to it
+func UploadVideoToYouTube(cfg *config.Config, videoURL, apiKey string, video *models.Video) (string, error) {
+	ctx := context.Background()
+	service, err := youtubeService(ctx, cfg, apiKey)
+	if err != nil {
+		return "", err
+	}
+
+	media, err := http.Get(videoURL)
+	if err != nil {
+		return "", fmt.Errorf("error fetching video media: %w", err)
+	}
+	defer media.Body.Close()
+	if media.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching video media: %s", media.Status)
+	}
+
+	parts := []string{"snippet", "status"}
+	if len(video.Localizations) > 0 {
+		parts = append(parts, "localizations")
+	}
+	call := service.Videos.Insert(parts, youtubeVideo(video)).Media(media.Body)
+	uploaded, err := call.Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("error uploading video to YouTube: %w", err)
+	}
+
+	return uploaded.Id, nil
+}
+
+// SetYouTubeThumbnail sets the custom thumbnail of an uploaded video
+func SetYouTubeThumbnail(cfg *config.Config, apiKey, youtubeID string, image []byte, contentType string) error {
+	ctx := context.Background()
+	service, err := youtubeService(ctx, cfg, apiKey)
+	if err != nil {
+		return err
+	}
+
+	call := service.Thumbnails.Set(youtubeID).Media(bytes.NewReader(image), googleapi.ContentType(contentType))
+	if _, err := call.Context(ctx).Do(); err != nil {
+		return fmt.Errorf("error setting YouTube thumbnail: %w", err)
+	}
 
 	return nil
 }
 
-// Helper function for updating video in the database (replace with your own logic)
-func updateVideo(videoID string, video *models.Video) (int64, error) {
-	// // This is a placeholder, replace with your own database interaction
-	// // You can use your existing database library
-	// // For example, using a database/sql driver:
-	// ctx := context.Background()
-	// result, err := db.ExecContext(ctx, "UPDATE videos SET id = $1 WHERE id = $2", video.ID, videoID)
-	// if err != nil {
-	// 	return 0, fmt.Errorf("error updating video: %w", err)
-	// }
-	// rowsAffected, err := result.RowsAffected()
-	// if err != nil {
-	// 	return 0, fmt.Errorf("error getting rows affected: %w", err)
-	// }
-	// return rowsAffected, nil
-
-	return 0, nil
+// InsertYouTubeCaption uploads a caption track to an uploaded video and
+// returns the ID YouTube assigned to the track
+func InsertYouTubeCaption(cfg *config.Config, apiKey, youtubeID string, caption *models.Caption, content []byte) (string, error) {
+	ctx := context.Background()
+	service, err := youtubeService(ctx, cfg, apiKey)
+	if err != nil {
+		return "", err
+	}
+
+	track := &youtube.Caption{Snippet: &youtube.CaptionSnippet{VideoId: youtubeID, Language: caption.Language, Name: caption.Name}}
+	call := service.Captions.Insert([]string{"snippet"}, track).Media(bytes.NewReader(content), googleapi.ContentType(caption.Format.ContentType()))
+	inserted, err := call.Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("error uploading caption to YouTube: %w", err)
+	}
+
+	return inserted.Id, nil
+}
+
+// UpdateYouTubeCaption replaces the content of a caption track on YouTube
+func UpdateYouTubeCaption(cfg *config.Config, apiKey string, caption *models.Caption, content []byte) error {
+	ctx := context.Background()
+	service, err := youtubeService(ctx, cfg, apiKey)
+	if err != nil {
+		return err
+	}
+
+	call := service.Captions.Update([]string{"id"}, &youtube.Caption{Id: caption.YouTubeID}).Media(bytes.NewReader(content), googleapi.ContentType(caption.Format.ContentType()))
+	if _, err := call.Context(ctx).Do(); err != nil {
+		return fmt.Errorf("error updating YouTube caption: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteYouTubeCaption deletes a caption track from YouTube
+func DeleteYouTubeCaption(cfg *config.Config, apiKey, captionID string) error {
+	ctx := context.Background()
+	service, err := youtubeService(ctx, cfg, apiKey)
+	if err != nil {
+		return err
+	}
+
+	if err := service.Captions.Delete(captionID).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("error deleting YouTube caption: %w", err)
+	}
+
+	return nil
+}
+
+// youtubeVideo maps the metadata of a video onto a YouTube insert request.
+// Chapters are listed at the end of the description and localizations are
+// keyed by language. Boolean fields are always sent since false differs from
+// YouTube's defaults.
+func youtubeVideo(video *models.Video) *youtube.Video {
+	var localizations map[string]youtube.VideoLocalization
+	if len(video.Localizations) > 0 {
+		localizations = make(map[string]youtube.VideoLocalization, len(video.Localizations))
+		for _, localization := range video.Localizations {
+			localizations[localization.Language] = youtube.VideoLocalization{
+				Title:       localization.Title,
+				Description: localization.Description,
+			}
+		}
+	}
+
+	return &youtube.Video{
+		Snippet: &youtube.VideoSnippet{
+			Title:           video.Title,
+			Description:     models.DescriptionWithChapters(video.Description, video.Chapters),
+			Tags:            video.Keywords,
+			CategoryId:      video.Category,
+			DefaultLanguage: video.DefaultLanguage,
+		},
+		Status: &youtube.VideoStatus{
+			PrivacyStatus:           string(video.PrivacyStatus),
+			SelfDeclaredMadeForKids: video.MadeForKids,
+			License:                 string(video.License),
+			Embeddable:              video.Embeddable,
+			ForceSendFields:         []string{"SelfDeclaredMadeForKids", "Embeddable"},
+		},
+		Localizations: localizations,
+	}
 }
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FuseWorkflows/fuse-go-server/captions"
+	"github.com/FuseWorkflows/fuse-go-server/config"
+	"github.com/FuseWorkflows/fuse-go-server/models"
+	"github.com/FuseWorkflows/fuse-go-server/youtubetest"
+)
+
+func TestUploadVideoToYouTube(t *testing.T) {
+	youtube := youtubetest.NewServer()
+	defer youtube.Close()
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video bytes"))
+	}))
+	defer media.Close()
+
+	cfg := &config.Config{YouTubeEndpoint: youtube.Endpoint()}
+	video := &models.Video{
+		Title:         "Launch",
+		Description:   "Our launch video",
+		Keywords:      []string{"launch"},
+		PrivacyStatus: "unlisted",
+		Chapters:      []models.Chapter{{Start: 0, Title: "Intro"}, {Start: 75, Title: "Demo"}},
+		Localizations: []models.Localization{{Language: "fr", Title: "Lancement"}},
+	}
+
+	id, err := UploadVideoToYouTube(cfg, media.URL, "key", video)
+	if err != nil {
+		t.Fatalf("UploadVideoToYouTube: %v", err)
+	}
+
+	uploads := youtube.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("got %d uploads, want 1", len(uploads))
+	}
+	upload := uploads[0]
+	if upload.ID != id {
+		t.Errorf("returned ID %q, uploaded %q", id, upload.ID)
+	}
+	if string(upload.Media) != "video bytes" {
+		t.Errorf("uploaded media %q", upload.Media)
+	}
+	if upload.APIKey != "key" {
+		t.Errorf("uploaded with key %q", upload.APIKey)
+	}
+	if upload.Parts != "snippet,status,localizations" {
+		t.Errorf("uploaded parts %q", upload.Parts)
+	}
+	if want := "Our launch video\n\n00:00 Intro\n01:15 Demo"; upload.Video.Snippet.Description != want {
+		t.Errorf("uploaded description %q, want %q", upload.Video.Snippet.Description, want)
+	}
+	if upload.Video.Status.PrivacyStatus != "unlisted" {
+		t.Errorf("uploaded privacy status %q", upload.Video.Status.PrivacyStatus)
+	}
+	if upload.Video.Localizations["fr"].Title != "Lancement" {
+		t.Errorf("uploaded localizations %v", upload.Video.Localizations)
+	}
+
+	if err := SetYouTubeThumbnail(cfg, "key", id, []byte("png"), "image/png"); err != nil {
+		t.Fatalf("SetYouTubeThumbnail: %v", err)
+	}
+	if thumbnails := youtube.Thumbnails(); len(thumbnails) != 1 || thumbnails[0].VideoID != id || string(thumbnails[0].Media) != "png" {
+		t.Errorf("thumbnails %+v", thumbnails)
+	}
+}
+
+func TestUploadVideoToYouTubeMediaError(t *testing.T) {
+	youtube := youtubetest.NewServer()
+	defer youtube.Close()
+	media := httptest.NewServer(http.NotFoundHandler())
+	defer media.Close()
+
+	cfg := &config.Config{YouTubeEndpoint: youtube.Endpoint()}
+	if _, err := UploadVideoToYouTube(cfg, media.URL, "key", &models.Video{Title: "Launch"}); err == nil {
+		t.Fatal("UploadVideoToYouTube succeeded without media")
+	}
+	if uploads := youtube.Uploads(); len(uploads) != 0 {
+		t.Errorf("got %d uploads, want 0", len(uploads))
+	}
+}
+
+func TestYouTubeCaptions(t *testing.T) {
+	youtube := youtubetest.NewServer()
+	defer youtube.Close()
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video bytes"))
+	}))
+	defer media.Close()
+
+	cfg := &config.Config{YouTubeEndpoint: youtube.Endpoint()}
+	videoID, err := UploadVideoToYouTube(cfg, media.URL, "key", &models.Video{Title: "Launch"})
+	if err != nil {
+		t.Fatalf("UploadVideoToYouTube: %v", err)
+	}
+
+	caption := &models.Caption{Language: "en", Name: "English", Format: captions.VTT}
+	vtt := "WEBVTT\n\n00:00.000 --> 00:01.000\nHello\n"
+	caption.YouTubeID, err = InsertYouTubeCaption(cfg, "key", videoID, caption, []byte(vtt))
+	if err != nil {
+		t.Fatalf("InsertYouTubeCaption: %v", err)
+	}
+
+	tracks := youtube.Captions()
+	if len(tracks) != 1 {
+		t.Fatalf("got %d caption tracks, want 1", len(tracks))
+	}
+	track := tracks[0]
+	if track.ID != caption.YouTubeID || track.VideoID != videoID || track.Language != "en" || track.Name != "English" {
+		t.Errorf("caption track %+v", track)
+	}
+	if track.ContentType != "text/vtt" || string(track.Media) != vtt {
+		t.Errorf("caption track content %q of type %q", track.Media, track.ContentType)
+	}
+
+	shifted := strings.Replace(vtt, "00:00.000 --> 00:01.000", "00:02.000 --> 00:03.000", 1)
+	if err := UpdateYouTubeCaption(cfg, "key", caption, []byte(shifted)); err != nil {
+		t.Fatalf("UpdateYouTubeCaption: %v", err)
+	}
+	if tracks := youtube.Captions(); len(tracks) != 1 || string(tracks[0].Media) != shifted {
+		t.Errorf("caption tracks after update %+v", tracks)
+	}
+
+	if err := DeleteYouTubeCaption(cfg, "key", caption.YouTubeID); err != nil {
+		t.Fatalf("DeleteYouTubeCaption: %v", err)
+	}
+	if tracks := youtube.Captions(); len(tracks) != 0 {
+		t.Errorf("got %d caption tracks after delete, want 0", len(tracks))
+	}
+	if err := DeleteYouTubeCaption(cfg, "key", caption.YouTubeID); err == nil {
+		t.Error("deleting a deleted caption track succeeded")
+	}
+}
+
+func TestInsertYouTubeCaptionUnknownVideo(t *testing.T) {
+	youtube := youtubetest.NewServer()
+	defer youtube.Close()
+
+	cfg := &config.Config{YouTubeEndpoint: youtube.Endpoint()}
+	caption := &models.Caption{Language: "en", Name: "English", Format: captions.SRT}
+	if _, err := InsertYouTubeCaption(cfg, "key", "missing", caption, []byte("1\n00:00:00,000 --> 00:00:01,000\nHello\n")); err == nil {
+		t.Fatal("InsertYouTubeCaption succeeded for an unknown video")
+	}
+}
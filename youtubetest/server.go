@@ -0,0 +1,363 @@
+// Package youtubetest provides a fake of the parts of the YouTube Data API
+// used by the server, for tests and local development. Point the server at
+// it with the YOUTUBE_ENDPOINT setting.
+package youtubetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// Upload is a video received by the fake
+type Upload struct {
+	ID     string
+	APIKey string
+	Parts  string
+	Video  youtube.Video
+	Media  []byte
+}
+
+// ThumbnailSet is a thumbnail received by the fake
+type ThumbnailSet struct {
+	VideoID     string
+	ContentType string
+	Media       []byte
+}
+
+// CaptionTrack is a caption track received by the fake
+type CaptionTrack struct {
+	ID          string
+	VideoID     string
+	Language    string
+	Name        string
+	ContentType string
+	Media       []byte
+}
+
+// Server is a fake YouTube Data API server
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	uploads    []Upload
+	thumbnails []ThumbnailSet
+	captions   []CaptionTrack
+	sessions   map[string]*Upload
+	nextID     int
+}
+
+// NewServer starts a fake YouTube Data API server. Close it when done.
+func NewServer() *Server {
+	s := &Server{sessions: map[string]*Upload{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/upload/youtube/v3/videos", s.handleVideosInsert)
+	mux.HandleFunc("/upload/youtube/v3/thumbnails/set", s.handleThumbnailsSet)
+	mux.HandleFunc("/upload/youtube/v3/captions", s.handleCaptionsUpload)
+	mux.HandleFunc("/youtube/v3/captions", s.handleCaptionsDelete)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Endpoint returns the value to pass to option.WithEndpoint
+func (s *Server) Endpoint() string {
+	return s.URL + "/"
+}
+
+// Uploads returns the videos uploaded so far
+func (s *Server) Uploads() []Upload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Upload(nil), s.uploads...)
+}
+
+// Thumbnails returns the thumbnails set so far
+func (s *Server) Thumbnails() []ThumbnailSet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ThumbnailSet(nil), s.thumbnails...)
+}
+
+// Captions returns the caption tracks currently on the fake
+func (s *Server) Captions() []CaptionTrack {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CaptionTrack(nil), s.captions...)
+}
+
+// handleVideosInsert implements videos.insert for both multipart uploads and
+// resumable upload sessions
+func (s *Server) handleVideosInsert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	query := r.URL.Query()
+
+	switch query.Get("uploadType") {
+	case "multipart":
+		metadata, media, _, err := readMultipart(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		upload := Upload{APIKey: query.Get("key"), Parts: strings.Join(query["part"], ","), Media: media}
+		if err := json.Unmarshal(metadata, &upload.Video); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid video metadata")
+			return
+		}
+		s.finishUpload(w, &upload)
+
+	case "resumable":
+		if uploadID := query.Get("upload_id"); uploadID != "" {
+			s.handleChunk(w, r, uploadID)
+			return
+		}
+		upload := &Upload{APIKey: query.Get("key"), Parts: strings.Join(query["part"], ",")}
+		if err := json.NewDecoder(r.Body).Decode(&upload.Video); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid video metadata")
+			return
+		}
+		s.mu.Lock()
+		s.nextID++
+		uploadID := fmt.Sprintf("session%d", s.nextID)
+		s.sessions[uploadID] = upload
+		s.mu.Unlock()
+		w.Header().Set("Location", s.URL+"/upload/youtube/v3/videos?uploadType=resumable&upload_id="+uploadID)
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		writeError(w, http.StatusBadRequest, "unsupported uploadType")
+	}
+}
+
+// handleChunk receives a chunk of a resumable upload. Incomplete uploads are
+// acknowledged the way Google does for clients that send X-GUploader-No-308.
+func (s *Server) handleChunk(w http.ResponseWriter, r *http.Request, uploadID string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	upload, ok := s.sessions[uploadID]
+	if ok {
+		upload.Media = append(upload.Media, data...)
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "upload session not found")
+		return
+	}
+
+	if strings.HasSuffix(r.Header.Get("Content-Range"), "/*") {
+		w.Header().Set("X-Http-Status-Code-Override", "308")
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(upload.Media)-1))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.sessions, uploadID)
+	s.mu.Unlock()
+	s.finishUpload(w, upload)
+}
+
+func (s *Server) finishUpload(w http.ResponseWriter, upload *Upload) {
+	s.mu.Lock()
+	s.nextID++
+	upload.ID = fmt.Sprintf("fake%d", s.nextID)
+	s.uploads = append(s.uploads, *upload)
+	s.mu.Unlock()
+
+	video := upload.Video
+	video.Id = upload.ID
+	video.Kind = "youtube#video"
+	writeJSON(w, &video)
+}
+
+// handleThumbnailsSet implements thumbnails.set for videos uploaded to the fake
+func (s *Server) handleThumbnailsSet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	videoID := r.URL.Query().Get("videoId")
+
+	s.mu.Lock()
+	found := s.hasUpload(videoID)
+	s.mu.Unlock()
+	if !found {
+		writeError(w, http.StatusNotFound, "video not found")
+		return
+	}
+
+	thumbnail := ThumbnailSet{VideoID: videoID, ContentType: r.Header.Get("Content-Type")}
+	var err error
+	if r.URL.Query().Get("uploadType") == "multipart" {
+		_, thumbnail.Media, thumbnail.ContentType, err = readMultipart(r)
+	} else {
+		thumbnail.Media, err = io.ReadAll(r.Body)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.thumbnails = append(s.thumbnails, thumbnail)
+	s.mu.Unlock()
+
+	writeJSON(w, &youtube.ThumbnailSetResponse{
+		Kind:  "youtube#thumbnailSetResponse",
+		Items: []*youtube.ThumbnailDetails{{Default: &youtube.Thumbnail{Url: s.URL + "/vi/" + videoID + "/default.jpg"}}},
+	})
+}
+
+// handleCaptionsUpload implements captions.insert and captions.update for
+// multipart uploads
+func (s *Server) handleCaptionsUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if r.URL.Query().Get("uploadType") != "multipart" {
+		writeError(w, http.StatusBadRequest, "unsupported uploadType")
+		return
+	}
+	metadata, media, contentType, err := readMultipart(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var caption youtube.Caption
+	if err := json.Unmarshal(metadata, &caption); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid caption metadata")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method == http.MethodPut {
+		for i := range s.captions {
+			if s.captions[i].ID == caption.Id {
+				s.captions[i].ContentType = contentType
+				s.captions[i].Media = media
+				writeJSON(w, s.captions[i].resource())
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "caption not found")
+		return
+	}
+
+	if caption.Snippet == nil || !s.hasUpload(caption.Snippet.VideoId) {
+		writeError(w, http.StatusNotFound, "video not found")
+		return
+	}
+	s.nextID++
+	track := CaptionTrack{
+		ID:          fmt.Sprintf("caption%d", s.nextID),
+		VideoID:     caption.Snippet.VideoId,
+		Language:    caption.Snippet.Language,
+		Name:        caption.Snippet.Name,
+		ContentType: contentType,
+		Media:       media,
+	}
+	s.captions = append(s.captions, track)
+	writeJSON(w, track.resource())
+}
+
+// handleCaptionsDelete implements captions.delete
+func (s *Server) handleCaptionsDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id := r.URL.Query().Get("id")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.captions {
+		if s.captions[i].ID == id {
+			s.captions = append(s.captions[:i], s.captions[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "caption not found")
+}
+
+func (t CaptionTrack) resource() *youtube.Caption {
+	return &youtube.Caption{
+		Id:      t.ID,
+		Kind:    "youtube#caption",
+		Snippet: &youtube.CaptionSnippet{VideoId: t.VideoID, Language: t.Language, Name: t.Name},
+	}
+}
+
+// hasUpload reports whether a video was uploaded to the fake. s.mu must be held.
+func (s *Server) hasUpload(videoID string) bool {
+	for _, upload := range s.uploads {
+		if upload.ID == videoID {
+			return true
+		}
+	}
+	return false
+}
+
+// readMultipart reads the metadata and media parts of a multipart/related
+// upload, along with the content type of the media
+func readMultipart(r *http.Request) ([]byte, []byte, string, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, nil, "", fmt.Errorf("expected a multipart body")
+	}
+
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	var parts [][]byte
+	var contentType string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, "", err
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		parts = append(parts, data)
+		contentType = part.Header.Get("Content-Type")
+	}
+	if len(parts) != 2 {
+		return nil, nil, "", fmt.Errorf("expected metadata and media parts")
+	}
+	return parts[0], parts[1], contentType, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError responds with an error in the format of the Google APIs
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": message},
+	})
+}